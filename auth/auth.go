@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"bookshop/datastore"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type SessionStore struct {
+	db *sqlx.DB
+}
+
+func NewSessionStore(db *sqlx.DB) SessionStore {
+	return SessionStore{db: db}
+}
+
+// CreateSession will add the given session, returning
+// datastore.ErrMissingReference if its user does not exist.
+func (s *SessionStore) CreateSession(ctx context.Context, sess Session) error {
+	const sqlSt = `INSERT INTO sessions (token_hash, user_id, created_at, expires_at) VALUES (?,?,?,?)`
+	if _, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind(sqlSt), sess.TokenHash, sess.UserID, time.Now(), sess.ExpiresAt); err != nil {
+		if datastore.IsForeignKeyViolation(err) {
+			return datastore.ErrMissingReference
+		}
+		return errors.Wrap(err, "failed creating session")
+	}
+	return nil
+}
+
+// ReadSession will return the session with the given token hash, returning
+// datastore.ErrNotFound if it does not exist or has expired.
+func (s *SessionStore) ReadSession(ctx context.Context, tokenHash string) (Session, error) {
+	sqlSt := `SELECT * FROM sessions WHERE token_hash = ? AND ` +
+		datastore.Timestamp(s.db, "expires_at") + ` > ` + datastore.Timestamp(s.db, "?")
+	sess := Session{}
+	if err := datastore.Conn(ctx, s.db).GetContext(ctx, &sess, s.db.Rebind(sqlSt), tokenHash, time.Now()); err != nil {
+		if err == sql.ErrNoRows {
+			return Session{}, datastore.ErrNotFound
+		}
+		return Session{}, errors.Wrap(err, "failed to read session")
+	}
+	return sess, nil
+}
+
+// DeleteSession will end the session with the given token hash, returning
+// datastore.ErrNotFound if it does not exist.
+func (s *SessionStore) DeleteSession(ctx context.Context, tokenHash string) error {
+	res, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind("DELETE FROM sessions WHERE token_hash=?"), tokenHash)
+	if err != nil {
+		return errors.Wrap(err, "failed deleting session")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return datastore.ErrNotFound
+	}
+	return nil
+}
+
+// DeleteUserSessions will end every session of the given user, returning how
+// many were ended.
+func (s *SessionStore) DeleteUserSessions(ctx context.Context, userID string) (int64, error) {
+	return s.delete(ctx, "DELETE FROM sessions WHERE user_id=?", userID)
+}
+
+// DeleteExpiredSessions will delete the sessions that expired before the given
+// time, returning how many were deleted.
+func (s *SessionStore) DeleteExpiredSessions(ctx context.Context, before time.Time) (int64, error) {
+	sqlSt := `DELETE FROM sessions WHERE ` + datastore.Timestamp(s.db, "expires_at") + ` <= ` + datastore.Timestamp(s.db, "?")
+	return s.delete(ctx, sqlSt, before)
+}
+
+func (s *SessionStore) delete(ctx context.Context, qry string, arg interface{}) (int64, error) {
+	res, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind(qry), arg)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed deleting sessions")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed deleting sessions")
+	}
+	return n, nil
+}
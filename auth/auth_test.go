@@ -0,0 +1,162 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"bookshop/auth"
+	"bookshop/datastore"
+	"bookshop/datastore/dbtest"
+	"bookshop/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessions(t *testing.T) {
+	ctx := context.Background()
+	later, earlier := time.Now().Add(time.Hour), time.Now().Add(-time.Hour)
+	newStores := func(t *testing.T) (auth.SessionStore, users.UserStore) {
+		dbh := dbtest.Open(t)
+
+		us := users.NewUserStore(dbh)
+		err := us.CreateUser(ctx, users.User{ID: "usr01", Email: "ann@example.com", FirstName: "Ann", LastName: "Lee", PasswordHash: "hash1", Enabled: true})
+		require.NoError(t, err)
+		store := auth.NewSessionStore(dbh)
+		require.NoError(t, store.CreateSession(ctx, auth.Session{TokenHash: "live", UserID: "usr01", ExpiresAt: &later}))
+		require.NoError(t, store.CreateSession(ctx, auth.Session{TokenHash: "stale", UserID: "usr01", ExpiresAt: &earlier}))
+		return store, us
+	}
+
+	t.Run("CreateSession", func(t *testing.T) {
+		store, _ := newStores(t)
+
+		sess, err := store.ReadSession(ctx, "live")
+		require.NoError(t, err)
+		assert.Equal(t, "usr01", sess.UserID)
+		assert.NotNil(t, sess.CreatedAt)
+
+		err = store.CreateSession(ctx, auth.Session{TokenHash: "other", UserID: "usr99", ExpiresAt: &later})
+		assert.True(t, errors.Is(err, datastore.ErrMissingReference))
+	})
+
+	t.Run("ReadSession expired", func(t *testing.T) {
+		store, _ := newStores(t)
+
+		_, err := store.ReadSession(ctx, "stale")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+		_, err = store.ReadSession(ctx, "nope")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("DeleteSession", func(t *testing.T) {
+		store, _ := newStores(t)
+
+		require.NoError(t, store.DeleteSession(ctx, "live"))
+		_, err := store.ReadSession(ctx, "live")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+		err = store.DeleteSession(ctx, "live")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("DeleteUserSessions", func(t *testing.T) {
+		store, _ := newStores(t)
+
+		n, err := store.DeleteUserSessions(ctx, "usr01")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+	})
+
+	t.Run("DeleteExpiredSessions", func(t *testing.T) {
+		store, _ := newStores(t)
+
+		n, err := store.DeleteExpiredSessions(ctx, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+		_, err = store.ReadSession(ctx, "live")
+		assert.NoError(t, err)
+	})
+}
+
+func TestAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	newStore := func(t *testing.T) auth.KeyStore {
+		dbh := dbtest.Open(t)
+
+		us := users.NewUserStore(dbh)
+		err := us.CreateUser(ctx, users.User{ID: "usr01", Email: "ann@example.com", FirstName: "Ann", LastName: "Lee", PasswordHash: "hash1", Enabled: true})
+		require.NoError(t, err)
+		store := auth.NewKeyStore(dbh)
+		require.NoError(t, store.CreateAPIKey(ctx, auth.APIKey{ID: "key01", UserID: "usr01", Name: "warehouse", KeyHash: "hash01", Scopes: auth.Scopes{"GetStock", "ReceiveStock"}}))
+		return store
+	}
+
+	t.Run("CreateAPIKey", func(t *testing.T) {
+		store := newStore(t)
+
+		k, err := store.ReadAPIKeyByHash(ctx, "hash01")
+		require.NoError(t, err)
+		assert.Equal(t, "key01", k.ID)
+		assert.Equal(t, auth.Scopes{"GetStock", "ReceiveStock"}, k.Scopes)
+		assert.NotNil(t, k.CreatedAt)
+		assert.Nil(t, k.LastUsedAt)
+
+		err = store.CreateAPIKey(ctx, auth.APIKey{ID: "key02", UserID: "usr99", Name: "storefront", KeyHash: "hash02", Scopes: auth.Scopes{"ListBooks"}})
+		assert.True(t, errors.Is(err, datastore.ErrMissingReference))
+		_, err = store.ReadAPIKeyByHash(ctx, "hash02")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("ReadAPIKeys", func(t *testing.T) {
+		store := newStore(t)
+		require.NoError(t, store.CreateAPIKey(ctx, auth.APIKey{ID: "key02", UserID: "usr01", Name: "storefront", KeyHash: "hash02", Scopes: auth.Scopes{"ListBooks"}}))
+
+		keys, err := store.ReadAPIKeys(ctx, "usr01")
+		require.NoError(t, err)
+		require.Len(t, keys, 2)
+		assert.Equal(t, "key01", keys[0].ID)
+		keys, err = store.ReadAPIKeys(ctx, "usr99")
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+	})
+
+	t.Run("RotateAPIKey", func(t *testing.T) {
+		store := newStore(t)
+		require.NoError(t, store.TouchAPIKey(ctx, "key01", time.Now()))
+
+		require.NoError(t, store.RotateAPIKey(ctx, "usr01", "key01", "hash03"))
+		_, err := store.ReadAPIKeyByHash(ctx, "hash01")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+		k, err := store.ReadAPIKeyByHash(ctx, "hash03")
+		require.NoError(t, err)
+		assert.Nil(t, k.LastUsedAt, "a rotated key has not been used")
+
+		err = store.RotateAPIKey(ctx, "usr99", "key01", "hash04")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("TouchAPIKey", func(t *testing.T) {
+		store := newStore(t)
+
+		require.NoError(t, store.TouchAPIKey(ctx, "key01", time.Now()))
+		k, err := store.ReadAPIKeyByHash(ctx, "hash01")
+		require.NoError(t, err)
+		require.NotNil(t, k.LastUsedAt)
+		assert.WithinDuration(t, time.Now(), *k.LastUsedAt, time.Minute)
+
+		err = store.TouchAPIKey(ctx, "key99", time.Now())
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("DeleteAPIKey", func(t *testing.T) {
+		store := newStore(t)
+
+		err := store.DeleteAPIKey(ctx, "usr99", "key01")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+		require.NoError(t, store.DeleteAPIKey(ctx, "usr01", "key01"))
+		_, err = store.ReadAPIKeyByHash(ctx, "hash01")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"bookshop/datastore"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type KeyStore struct {
+	db *sqlx.DB
+}
+
+func NewKeyStore(db *sqlx.DB) KeyStore {
+	return KeyStore{db: db}
+}
+
+// CreateAPIKey will add the given API key, returning
+// datastore.ErrMissingReference if its user does not exist.
+func (s *KeyStore) CreateAPIKey(ctx context.Context, k APIKey) error {
+	const sqlSt = `INSERT INTO api_keys (id, user_id, name, key_hash, scopes, created_at) VALUES (?,?,?,?,?,?)`
+	_, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind(sqlSt), k.ID, k.UserID, k.Name, k.KeyHash, k.Scopes, time.Now())
+	if err != nil {
+		if datastore.IsForeignKeyViolation(err) {
+			return datastore.ErrMissingReference
+		}
+		return errors.Wrap(err, "failed creating api key")
+	}
+	return nil
+}
+
+// ReadAPIKeys will return the API keys of the given user, oldest first.
+func (s *KeyStore) ReadAPIKeys(ctx context.Context, userID string) ([]APIKey, error) {
+	const sqlSt = `SELECT * FROM api_keys WHERE user_id = ? ORDER BY created_at, id`
+	keys := []APIKey{}
+	if err := datastore.Conn(ctx, s.db).SelectContext(ctx, &keys, s.db.Rebind(sqlSt), userID); err != nil {
+		return nil, errors.Wrap(err, "failed to read api keys")
+	}
+	return keys, nil
+}
+
+// ReadAPIKeyByHash will return the API key with the given key hash, returning
+// datastore.ErrNotFound if it does not exist.
+func (s *KeyStore) ReadAPIKeyByHash(ctx context.Context, keyHash string) (APIKey, error) {
+	k := APIKey{}
+	err := datastore.Conn(ctx, s.db).GetContext(ctx, &k, s.db.Rebind(`SELECT * FROM api_keys WHERE key_hash = ?`), keyHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return APIKey{}, datastore.ErrNotFound
+		}
+		return APIKey{}, errors.Wrap(err, "failed to read api key")
+	}
+	return k, nil
+}
+
+// RotateAPIKey will replace the key hash of the given user's API key, returning
+// datastore.ErrNotFound if the user has no such key.
+func (s *KeyStore) RotateAPIKey(ctx context.Context, userID, id, keyHash string) error {
+	const sqlSt = `UPDATE api_keys SET key_hash = ?, last_used_at = NULL WHERE id = ? AND user_id = ?`
+	return s.exec(ctx, sqlSt, keyHash, id, userID)
+}
+
+// DeleteAPIKey will revoke the given user's API key, returning
+// datastore.ErrNotFound if the user has no such key.
+func (s *KeyStore) DeleteAPIKey(ctx context.Context, userID, id string) error {
+	return s.exec(ctx, `DELETE FROM api_keys WHERE id = ? AND user_id = ?`, id, userID)
+}
+
+// TouchAPIKey will record the API key as last used at the given time, returning
+// datastore.ErrNotFound if it does not exist.
+func (s *KeyStore) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	return s.exec(ctx, `UPDATE api_keys SET last_used_at = ? WHERE id = ?`, at, id)
+}
+
+func (s *KeyStore) exec(ctx context.Context, qry string, args ...interface{}) error {
+	res, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind(qry), args...)
+	if err != nil {
+		return errors.Wrap(err, "failed changing api key")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return datastore.ErrNotFound
+	}
+	return nil
+}
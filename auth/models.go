@@ -0,0 +1,167 @@
+// Package auth keeps the server-side sessions users sign in with and the API
+// keys other services call with, and carries the signed in user through
+// request contexts.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"bookshop/users"
+)
+
+// TTL is how long a session lasts before it has to be refreshed.
+const TTL = 24 * time.Hour
+
+// Session is the model representing a row in the sessions table. The token
+// itself is handed to the user and never stored.
+type Session struct {
+	TokenHash string     `db:"token_hash"`
+	UserID    string     `db:"user_id"`
+	CreatedAt *time.Time `db:"created_at"`
+	ExpiresAt *time.Time `db:"expires_at"`
+}
+
+// Credentials are what a user is given on signing in: the bearer token for
+// their session and when it expires.
+type Credentials struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NewToken returns a new random session token.
+func NewToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hash a session token is stored and looked up by.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// KeyPrefix starts every API key, telling them apart from session tokens.
+const KeyPrefix = "bsk_"
+
+// MaxKeyNameLength is the longest name an API key may have.
+const MaxKeyNameLength = 64
+
+// APIKey is the model representing a row in the api_keys table. A key acts for
+// the user it belongs to but may only call the operations in its scopes. Like
+// a session token, the key itself is handed out once and never stored.
+type APIKey struct {
+	ID         string     `db:"id" json:"id"`
+	UserID     string     `db:"user_id" json:"user_id"`
+	Name       string     `db:"name" json:"name"`
+	KeyHash    string     `db:"key_hash" json:"-"`
+	Scopes     Scopes     `db:"scopes" json:"scopes"`
+	CreatedAt  *time.Time `db:"created_at" json:"created_at,omitempty"`
+	LastUsedAt *time.Time `db:"last_used_at" json:"last_used_at,omitempty"`
+}
+
+// IssuedKey is an API key along with the key itself, answered with only when
+// the key is created or rotated.
+type IssuedKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// NewAPIKey returns a new random API key.
+func NewAPIKey() (string, error) {
+	token, err := NewToken()
+	if err != nil {
+		return "", err
+	}
+	return KeyPrefix + token, nil
+}
+
+// Scopes are the names of the service operations an API key may call. They are
+// stored as a single comma separated column.
+type Scopes []string
+
+// Allows reports whether op is one of the scopes.
+func (sc Scopes) Allows(op string) bool {
+	for _, s := range sc {
+		if s == op {
+			return true
+		}
+	}
+	return false
+}
+
+// Normalize returns the scopes sorted, with blanks and repeats dropped.
+func (sc Scopes) Normalize() Scopes {
+	seen := map[string]bool{}
+	out := Scopes{}
+	for _, s := range sc {
+		if s = strings.TrimSpace(s); s != "" && !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Value implements driver.Valuer.
+func (sc Scopes) Value() (driver.Value, error) {
+	return strings.Join(sc, ","), nil
+}
+
+// Scan implements sql.Scanner.
+func (sc *Scopes) Scan(src interface{}) error {
+	var s string
+	switch v := src.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into scopes", src)
+	}
+	*sc = Scopes{}
+	if s != "" {
+		*sc = strings.Split(s, ",")
+	}
+	return nil
+}
+
+type userKey struct{}
+
+// WithUser returns a copy of ctx carrying the signed in user.
+func WithUser(ctx context.Context, u users.User) context.Context {
+	return context.WithValue(ctx, userKey{}, u)
+}
+
+// UserFrom returns the signed in user carried by ctx, if any.
+func UserFrom(ctx context.Context) (users.User, bool) {
+	u, ok := ctx.Value(userKey{}).(users.User)
+	return u, ok
+}
+
+type scopesKey struct{}
+
+// WithScopes returns a copy of ctx carrying the scopes of the API key the
+// request was made with.
+func WithScopes(ctx context.Context, scopes Scopes) context.Context {
+	return context.WithValue(ctx, scopesKey{}, scopes)
+}
+
+// ScopesFrom returns the scopes carried by ctx, if the request was made with an
+// API key.
+func ScopesFrom(ctx context.Context) (Scopes, bool) {
+	sc, ok := ctx.Value(scopesKey{}).(Scopes)
+	return sc, ok
+}
@@ -2,6 +2,11 @@ package authors
 
 import (
 	"bookshop/books"
+	"bookshop/datastore"
+	"bookshop/search"
+	"context"
+	"database/sql"
+	"fmt"
 	"strings"
 	"time"
 
@@ -17,69 +22,144 @@ func NewAuthorStore(db *sqlx.DB) AuthorStore {
 	return AuthorStore{db: db}
 }
 
-// ReadAuthors will return a list of all authors.
-func (s *AuthorStore) ReadAuthors() ([]Author, error) {
+// ReadAuthors will return a page of authors matching the query.
+func (s *AuthorStore) ReadAuthors(ctx context.Context, q AuthorQuery) (Page, error) {
+	q = q.WithDefaults()
+
+	var where []string
+	var args []interface{}
+	if q.LastNamePrefix != "" {
+		where = append(where, `lower(a.last_name) LIKE lower(?) ESCAPE '\'`)
+		args = append(args, datastore.EscapeLike(q.LastNamePrefix)+"%")
+	}
+	if q.DOBFrom != nil {
+		where = append(where, datastore.Timestamp(s.db, "a.dob")+" >= "+datastore.Timestamp(s.db, "?"))
+		args = append(args, *q.DOBFrom)
+	}
+	if q.DOBTo != nil {
+		where = append(where, datastore.Timestamp(s.db, "a.dob")+" <= "+datastore.Timestamp(s.db, "?"))
+		args = append(args, *q.DOBTo)
+	}
+
+	col, param := s.sortColumn(q.SortBy)
+	dir, cmp := "ASC", ">"
+	if q.Descending {
+		dir, cmp = "DESC", "<"
+	}
+	if q.Cursor != "" {
+		c, err := datastore.DecodeCursor(q.Cursor, string(q.SortBy), q.Descending)
+		if err != nil {
+			return Page{}, err
+		}
+		where = append(where, fmt.Sprintf(`(%s, a.id) %s (%s, ?)`, col, cmp, param))
+		args = append(args, c.Value, c.ID)
+	}
+
+	qry := "SELECT a.* FROM authors a"
+	if len(where) > 0 {
+		qry += " WHERE " + strings.Join(where, " AND ")
+	}
+	qry += fmt.Sprintf(" ORDER BY %s %s, a.id %s LIMIT ?", col, dir, dir)
+	args = append(args, q.Limit+1)
+
 	auths := []Author{}
-	if err := s.db.Select(&auths, "SELECT * FROM authors ORDER BY last_name ASC"); err != nil {
-		return nil, errors.Wrap(err, "failed to read authors")
+	if err := datastore.Conn(ctx, s.db).SelectContext(ctx, &auths, s.db.Rebind(qry), args...); err != nil {
+		return Page{}, errors.Wrap(err, "failed to read authors")
+	}
+	return newPage(auths, q), nil
+}
+
+// sortColumn returns the column for the sort field and the placeholder used to
+// compare cursor values against it.
+func (s *AuthorStore) sortColumn(field SortField) (string, string) {
+	switch field {
+	case SortByFirstName:
+		return "a.first_name", "?"
+	case SortByDOB:
+		return datastore.Timestamp(s.db, "a.dob"), datastore.CursorTimestamp(s.db)
+	}
+	return "a.last_name", "?"
+}
+
+// newPage trims the extra row fetched to detect a following page and builds
+// the cursor pointing at it.
+func newPage(auths []Author, q AuthorQuery) Page {
+	if len(auths) <= q.Limit {
+		return Page{Authors: auths}
+	}
+	auths = auths[:q.Limit]
+	last := auths[len(auths)-1]
+	return Page{
+		Authors: auths,
+		NextCursor: datastore.Cursor{
+			Sort:  string(q.SortBy),
+			Desc:  q.Descending,
+			Value: last.SortValue(q.SortBy),
+			ID:    last.ID,
+		}.Encode(),
 	}
-	return auths, nil
 }
 
-// ReadAuthorAndBooks will return the given author looked up author ID.
-func (s *AuthorStore) ReadAuthorAndBooks(id string) (Author, error) {
+// ReadAuthorAndBooks will return the given author looked up author ID, returning
+// datastore.ErrNotFound if it does not exist.
+func (s *AuthorStore) ReadAuthorAndBooks(ctx context.Context, id string) (Author, error) {
+	auth := Author{}
+	if err := datastore.Conn(ctx, s.db).GetContext(ctx, &auth, s.db.Rebind("SELECT * FROM authors WHERE id=?"), id); err != nil {
+		if err == sql.ErrNoRows {
+			return Author{}, datastore.ErrNotFound
+		}
+		return Author{}, errors.Wrap(err, "failed to read author")
+	}
+
 	rows := []AuthorAndBook{}
 	sqlSt :=
 		`SELECT a.*,
 				b.id as book_id,
 				b.title as book_title,
-				b.isbn as book_isbn
+				b.isbn as book_isbn,
+				ab.role as book_role
 		FROM authors a, books b, books_authors ab
 		WHERE a.id = ab.author_id
 		AND b.id = ab.book_id
-		AND a.id = $1`
-	if err := s.db.Select(&rows, sqlSt, id); err != nil {
+		AND a.id = ?
+		ORDER BY b.title ASC, b.id ASC, ab.role ASC`
+	if err := datastore.Conn(ctx, s.db).SelectContext(ctx, &rows, s.db.Rebind(sqlSt), id); err != nil {
 		return Author{}, errors.Wrap(err, "failed to read author")
 	}
-	if len(rows) == 0 {
-		return Author{}, nil
-	}
 
-	var bks []books.Book
 	for _, res := range rows {
-		bks = append(bks, books.Book{
+		auth.AddBook(books.Book{
 			ID:    res.BookID,
 			Title: res.BookTitle,
 			ISBN:  books.ISBN(res.BookISBN),
-		})
-	}
-	auth := Author{
-		ID:         rows[0].ID,
-		FirstName:  rows[0].FirstName,
-		MiddleName: rows[0].MiddleName,
-		LastName:   rows[0].LastName,
-		DOB:        rows[0].DOB,
-		UpdatedAt:  rows[0].UpdatedAt,
-		Books:      bks,
+		}, res.BookRole)
 	}
 	return auth, nil
 }
 
-// DeleteAuthor will delete an author by their ID.
-func (s *AuthorStore) DeleteAuthor(id string) error {
+// DeleteAuthor will delete an author by their ID, returning datastore.ErrNotFound
+// if it does not exist.
+func (s *AuthorStore) DeleteAuthor(ctx context.Context, id string) error {
 	if id == "" {
 		return errors.New("no id submitted to delete")
 	}
-	tx := s.db.MustBegin()
 	qry, args, err := sqlx.In("DELETE FROM authors WHERE id = ?", id)
 	if err != nil {
 		return errors.Wrap(err, "failed deleting authors")
 	}
-	rows, err := tx.Queryx(s.db.Rebind(qry), args...)
+	tx, err := datastore.Begin(ctx, s.db)
 	if err != nil {
+		return errors.Wrap(err, "failed deleting authors")
+	}
+	res, err := tx.ExecContext(ctx, s.db.Rebind(qry), args...)
+	if err != nil {
+		tx.Rollback()
 		return errors.Wrap(err, "failed deleting author")
 	}
-	rows.Close()
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		tx.Rollback()
+		return datastore.ErrNotFound
+	}
 	if err := tx.Commit(); err != nil {
 		return err
 	}
@@ -87,11 +167,14 @@ func (s *AuthorStore) DeleteAuthor(id string) error {
 }
 
 // UpsertAuthors will modify or add the authors in the given list.
-func (s *AuthorStore) UpsertAuthors(auths []Author) error {
+func (s *AuthorStore) UpsertAuthors(ctx context.Context, auths []Author) error {
 	if len(auths) == 0 {
 		return errors.New("no authors to upsert")
 	}
-	tx := s.db.MustBegin()
+	tx, err := datastore.Begin(ctx, s.db)
+	if err != nil {
+		return errors.Wrap(err, "failed upserting authors")
+	}
 	const sqlSetPre = `INSERT INTO authors (id, first_name, middle_name, last_name, dob, updated_at) VALUES `
 
 	const sqlSetPost = ` ON CONFLICT(id) DO
@@ -119,14 +202,80 @@ func (s *AuthorStore) UpsertAuthors(auths []Author) error {
 	joinedRows := strings.Join(qryRows, ",")
 	joinedQuery := sqlSetPre + joinedRows + sqlSetPost
 
-	var err error
 	var rows *sqlx.Rows
-	if rows, err = tx.Queryx(s.db.Rebind(joinedQuery), qryArgs...); err != nil {
+	if rows, err = tx.QueryxContext(ctx, s.db.Rebind(joinedQuery), qryArgs...); err == nil {
+		err = rows.Close()
+	}
+	if err != nil {
+		tx.Rollback()
+		// UNIQUE(first_name, middle_name, last_name, dob) is the only constraint
+		// besides the primary key that the upsert can trip over
+		if datastore.IsUniqueViolation(err) {
+			return datastore.ErrDuplicate
+		}
 		return errors.Wrap(err, "failed upserting authors")
 	}
-	rows.Close()
 	if err := tx.Commit(); err != nil {
 		return err
 	}
 	return nil
 }
+
+// UpdateAuthor will change the names and date of birth of the author, returning
+// datastore.ErrNotFound if it does not exist and datastore.ErrDuplicate if
+// another author shares the same name and date of birth.
+func (s *AuthorStore) UpdateAuthor(ctx context.Context, a Author) error {
+	const sqlSt = `UPDATE authors SET first_name = ?, middle_name = ?, last_name = ?, dob = ?, updated_at = ? WHERE id = ?`
+	res, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind(sqlSt), a.FirstName, a.MiddleName, a.LastName, a.DOB, time.Now(), a.ID)
+	if err != nil {
+		if datastore.IsUniqueViolation(err) {
+			return datastore.ErrDuplicate
+		}
+		return errors.Wrap(err, "failed updating author")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return datastore.ErrNotFound
+	}
+	return nil
+}
+
+// SearchAuthors will return the authors whose names match the full text query,
+// ranked by relevance.
+func (s *AuthorStore) SearchAuthors(ctx context.Context, query string, limit int) ([]search.Result, error) {
+	// the name expression must match the authors_name_search index
+	sqlSt :=
+		`SELECT 'author' AS kind,
+				a.id,
+				n.name AS title,
+				ts_headline('simple', n.name, q, $3) AS snippet,
+				ts_rank(to_tsvector('simple', a.first_name || ' ' || a.middle_name || ' ' || a.last_name), q) AS rank
+		FROM authors a,
+			plainto_tsquery('simple', $1) q,
+			LATERAL (SELECT concat_ws(' ', a.first_name, NULLIF(a.middle_name, ''), a.last_name) AS name) n
+		WHERE to_tsvector('simple', a.first_name || ' ' || a.middle_name || ' ' || a.last_name) @@ q
+		ORDER BY rank DESC, a.last_name ASC
+		LIMIT $2`
+	args := []interface{}{query, limit, datastore.HeadlineOptions}
+	if datastore.IsSQLite(s.db) {
+		sqlSt =
+			`SELECT 'author' AS kind,
+					aus.id,
+					aus.name AS title,
+					highlight(authors_search, 1, ?, ?) AS snippet,
+					-bm25(authors_search) AS rank
+			FROM authors_search aus
+			WHERE authors_search MATCH ?
+			ORDER BY rank DESC, aus.name ASC
+			LIMIT ?`
+		args = []interface{}{search.MatchStart, search.MatchStop, datastore.FTSQuery(query), limit}
+	}
+
+	results := []search.Result{}
+	if err := datastore.Conn(ctx, s.db).SelectContext(ctx, &results, sqlSt, args...); err != nil {
+		return nil, errors.Wrap(err, "failed to search authors")
+	}
+	for i := range results {
+		results[i].Snippet = search.Snippet(results[i].Snippet)
+	}
+	return results, nil
+}
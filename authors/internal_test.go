@@ -3,24 +3,27 @@
 package authors_test
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
-	"os"
 	"testing"
 	"time"
 
 	"bookshop/authors"
 	"bookshop/books"
+	"bookshop/datastore"
+	"bookshop/datastore/dbtest"
+	"bookshop/search"
 
 	"github.com/jmoiron/sqlx"
-	"github.com/robojandro/go-pgtesthelper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestAuthors(t *testing.T) {
-	h, dbh := initializeTestDB(t)
-	defer h.CleanUp()
+	ctx := context.Background()
+	dbh := initializeTestDB(t)
 
 	store := authors.NewAuthorStore(dbh)
 
@@ -28,8 +31,9 @@ func TestAuthors(t *testing.T) {
 	require.NoError(t, err)
 
 	t.Run("ReadAuthors", func(t *testing.T) {
-		auths, err := store.ReadAuthors()
+		page, err := store.ReadAuthors(ctx, authors.AuthorQuery{})
 		require.NoError(t, err)
+		auths := page.Authors
 		assert.NotNil(t, auths)
 		assert.Equal(t, "0b5babb0-96d8-11ea-bb37-0242ac130002", auths[0].ID)
 		assert.Equal(t, "first", auths[0].FirstName)
@@ -37,7 +41,7 @@ func TestAuthors(t *testing.T) {
 	})
 
 	t.Run("ReadAuthorAndBooks", func(t *testing.T) {
-		auth, err := store.ReadAuthorAndBooks("0b5babb0-96d8-11ea-bb37-0242ac130002")
+		auth, err := store.ReadAuthorAndBooks(ctx, "0b5babb0-96d8-11ea-bb37-0242ac130002")
 		require.NoError(t, err)
 		assert.NotNil(t, auth)
 		assert.Equal(t, "0b5babb0-96d8-11ea-bb37-0242ac130002", auth.ID)
@@ -48,7 +52,51 @@ func TestAuthors(t *testing.T) {
 		assert.Equal(t, "titleA", auth.Books[0].Title)
 		assert.Equal(t, "978-3-16-148410-0", auth.Books[0].ISBN.String())
 		assert.Equal(t, "titleB", auth.Books[1].Title)
-		assert.Equal(t, "444-4-44-444444-4", auth.Books[1].ISBN.String())
+		assert.Equal(t, "978-0-306-40615-7", auth.Books[1].ISBN.String())
+	})
+
+	t.Run("ReadAuthorAndBooksNotFound", func(t *testing.T) {
+		_, err := store.ReadAuthorAndBooks(ctx, "unknown")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("ReadAuthorWithoutBooks", func(t *testing.T) {
+		err := store.UpsertAuthors(ctx, []authors.Author{
+			{
+				ID:        "nobooks",
+				FirstName: "first4",
+				LastName:  "last4",
+				DOB:       &dt,
+			},
+		})
+		require.NoError(t, err)
+
+		auth, err := store.ReadAuthorAndBooks(ctx, "nobooks")
+		require.NoError(t, err)
+		assert.Equal(t, "first4", auth.FirstName)
+		assert.Empty(t, auth.Books)
+
+		err = store.DeleteAuthor(ctx, "nobooks")
+		require.NoError(t, err)
+	})
+
+	t.Run("SearchAuthors", func(t *testing.T) {
+		results, err := store.SearchAuthors(ctx, "LAST", 10)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, search.KindAuthor, results[0].Kind)
+		assert.Equal(t, "first middle last", results[0].Title)
+		assert.Equal(t, "first middle <mark>last</mark>", results[0].Snippet)
+	})
+
+	t.Run("UpdateAuthorNotFound", func(t *testing.T) {
+		err := store.UpdateAuthor(ctx, authors.Author{ID: "11111111-96d8-11ea-bb37-0242ac130002", FirstName: "new", LastName: "new", DOB: &dt})
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("DeleteAuthorNotFound", func(t *testing.T) {
+		err := store.DeleteAuthor(ctx, "unknown")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
 	})
 
 	t.Run("DeleteBooks", func(t *testing.T) {
@@ -61,14 +109,15 @@ func TestAuthors(t *testing.T) {
 				DOB:        &dt,
 			},
 		}
-		err = store.UpsertAuthors(upsert)
+		err = store.UpsertAuthors(ctx, upsert)
 		require.NoError(t, err)
 
-		err = store.DeleteAuthor("def03")
+		err = store.DeleteAuthor(ctx, "def03")
 		require.NoError(t, err)
 
-		res, err := store.ReadAuthors()
+		page, err := store.ReadAuthors(ctx, authors.AuthorQuery{})
 		require.NoError(t, err)
+		res := page.Authors
 
 		found := map[string]bool{}
 		for _, r := range res {
@@ -77,9 +126,22 @@ func TestAuthors(t *testing.T) {
 		assert.False(t, found["def03"])
 	})
 
+	t.Run("UpsertDuplicate", func(t *testing.T) {
+		upsert := []authors.Author{
+			{
+				ID:         "dup01",
+				FirstName:  "first",
+				MiddleName: "middle",
+				LastName:   "last",
+				DOB:        &dt,
+			},
+		}
+		err := store.UpsertAuthors(ctx, upsert)
+		assert.True(t, errors.Is(err, datastore.ErrDuplicate))
+	})
+
 	t.Run("Upsert", func(t *testing.T) {
-		err := h.CleanTables([]string{"books", "authors"})
-		require.NoError(t, err)
+		dbtest.Truncate(t, dbh, "books", "authors")
 
 		dt, err := time.Parse(authors.DateParsingFormat, "1970-01-01")
 		require.NoError(t, err)
@@ -115,11 +177,12 @@ func TestAuthors(t *testing.T) {
 				DOB:        &dt,
 			},
 		}
-		err = store.UpsertAuthors(upsert)
+		err = store.UpsertAuthors(ctx, upsert)
 		require.NoError(t, err)
 
-		res, err := store.ReadAuthors()
+		page, err := store.ReadAuthors(ctx, authors.AuthorQuery{})
 		require.NoError(t, err)
+		res := page.Authors
 		require.Len(t, res, 2)
 
 		for i, u := range upsert {
@@ -130,28 +193,40 @@ func TestAuthors(t *testing.T) {
 			assert.Equal(t, upsert[i].DOB, u.DOB)
 		}
 	})
-}
 
-func initializeTestDB(t *testing.T) (*pgtesthelper.Helper, *sqlx.DB) {
-	var (
-		schemaPath = "../sql/authors_books.sql"
-		keepDB     = false
-		dbPrefix   = "books_testing"
-		dbUser     = ""
-		dbPass     = ""
-	)
-
-	if dbUser = os.Getenv("bookshop_dbuser"); dbUser == "" {
-		t.Skip("missing env variable bookshop_dbuser")
-	}
-	if dbPass = os.Getenv("bookshop_dbpass"); dbPass == "" {
-		t.Skip("missing env variable bookshop_dbpass")
-	}
+	t.Run("ReadAuthorsPaged", func(t *testing.T) {
+		first, err := store.ReadAuthors(ctx, authors.AuthorQuery{Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, first.Authors, 1)
+		assert.Equal(t, "new", first.Authors[0].LastName)
+		require.NotEmpty(t, first.NextCursor)
 
-	h, err := pgtesthelper.NewHelper(schemaPath, dbPrefix, dbUser, dbPass, keepDB)
-	require.NoError(t, err)
+		second, err := store.ReadAuthors(ctx, authors.AuthorQuery{Limit: 1, Cursor: first.NextCursor})
+		require.NoError(t, err)
+		require.Len(t, second.Authors, 1)
+		assert.Equal(t, "update", second.Authors[0].LastName)
+		assert.Empty(t, second.NextCursor)
+	})
+
+	t.Run("ReadAuthorsFiltered", func(t *testing.T) {
+		page, err := store.ReadAuthors(ctx, authors.AuthorQuery{LastNamePrefix: "UP"})
+		require.NoError(t, err)
+		require.Len(t, page.Authors, 1)
+		assert.Equal(t, "abc01", page.Authors[0].ID)
+
+		later := dt.AddDate(1, 0, 0)
+		page, err = store.ReadAuthors(ctx, authors.AuthorQuery{DOBFrom: &later})
+		require.NoError(t, err)
+		assert.Empty(t, page.Authors)
+
+		page, err = store.ReadAuthors(ctx, authors.AuthorQuery{DOBFrom: &dt, DOBTo: &dt})
+		require.NoError(t, err)
+		assert.Len(t, page.Authors, 2)
+	})
+}
 
-	dbh, err := h.CreateTempDB()
+func initializeTestDB(t *testing.T) *sqlx.DB {
+	dbh := dbtest.Postgres(t, "authors_testing")
 
 	mockData, err := ioutil.ReadFile("./testdata/mockdb.json")
 	require.NoError(t, err)
@@ -162,7 +237,7 @@ func initializeTestDB(t *testing.T) (*pgtesthelper.Helper, *sqlx.DB) {
 	err = insertTestData(dbh, data)
 	require.NoError(t, err)
 
-	return &h, dbh
+	return dbh
 }
 
 type mockContents struct {
@@ -18,7 +18,36 @@ type Author struct {
 	DOB        *time.Time `db:"dob" json:"dob,omitempty"`
 	UpdatedAt  *time.Time `db:"updated_at" json:"updated_at,omitempty"`
 
-	Books []books.Book `json:"books,omitempty"`
+	Books       []books.Book                `json:"books,omitempty"`
+	BooksByRole map[books.Role][]books.Book `json:"books_by_role,omitempty"`
+}
+
+// AddBook will list the book among the author's books and under the role they
+// were credited with on it. A book credited in several roles is listed once in
+// Books.
+func (a *Author) AddBook(bk books.Book, role books.Role) {
+	if a.BooksByRole == nil {
+		a.BooksByRole = map[books.Role][]books.Book{}
+	}
+	a.BooksByRole[role] = append(a.BooksByRole[role], bk)
+	for _, b := range a.Books {
+		if b.ID == bk.ID {
+			return
+		}
+	}
+	a.Books = append(a.Books, bk)
+}
+
+// FullName returns the author's first, middle and last names joined by spaces,
+// skipping any that are blank.
+func (a Author) FullName() string {
+	var parts []string
+	for _, p := range []string{a.FirstName, a.MiddleName, a.LastName} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, " ")
 }
 
 // UnmarshalJSON is a custom unmarshaler that allows for passing in
@@ -33,7 +62,8 @@ func (a *Author) UnmarshalJSON(b []byte) error {
 		LastName   string     `db:"last_name" json:"last_name,omitempty"`
 		UpdatedAt  *time.Time `db:"updated_at" json:"updated_at,omitempty"`
 
-		Books []books.Book `json:"books,omitempty"`
+		Books       []books.Book                `json:"books,omitempty"`
+		BooksByRole map[books.Role][]books.Book `json:"books_by_role,omitempty"`
 	}
 	var out value
 	if err := json.Unmarshal(b, &out); err != nil {
@@ -60,11 +90,62 @@ func (a *Author) UnmarshalJSON(b []byte) error {
 	*a = Author{
 		DOB: parsed,
 
-		ID:         out.ID,
-		FirstName:  out.FirstName,
-		MiddleName: out.MiddleName,
-		LastName:   out.LastName,
-		Books:      out.Books,
+		ID:          out.ID,
+		FirstName:   out.FirstName,
+		MiddleName:  out.MiddleName,
+		LastName:    out.LastName,
+		Books:       out.Books,
+		BooksByRole: out.BooksByRole,
+	}
+	return nil
+}
+
+// Patch holds changes to an author's names and date of birth. Fields left nil
+// are unchanged.
+type Patch struct {
+	FirstName  *string    `json:"first_name"`
+	MiddleName *string    `json:"middle_name"`
+	LastName   *string    `json:"last_name"`
+	DOB        *time.Time `json:"dob"`
+}
+
+// Apply returns the author with the changes in the patch made.
+func (p Patch) Apply(a Author) Author {
+	if p.FirstName != nil {
+		a.FirstName = *p.FirstName
+	}
+	if p.MiddleName != nil {
+		a.MiddleName = *p.MiddleName
+	}
+	if p.LastName != nil {
+		a.LastName = *p.LastName
+	}
+	if p.DOB != nil {
+		a.DOB = p.DOB
+	}
+	return a
+}
+
+// UnmarshalJSON is a custom unmarshaler that reads the DOB (date of birth) in
+// the same human readable format as Author: "2006-01-02"
+func (p *Patch) UnmarshalJSON(b []byte) error {
+	type value struct {
+		FirstName  *string `json:"first_name"`
+		MiddleName *string `json:"middle_name"`
+		LastName   *string `json:"last_name"`
+		DOB        *string `json:"dob"`
+	}
+	var out value
+	if err := json.Unmarshal(b, &out); err != nil {
+		return err
+	}
+	*p = Patch{FirstName: out.FirstName, MiddleName: out.MiddleName, LastName: out.LastName}
+	if out.DOB != nil {
+		t, err := time.Parse(DateParsingFormat, *out.DOB)
+		if err != nil {
+			return err
+		}
+		p.DOB = &t
 	}
 	return nil
 }
@@ -80,6 +161,7 @@ type AuthorAndBook struct {
 	BookID     string     `db:"book_id"`
 	BookTitle  string     `db:"book_title"`
 	BookISBN   string     `db:"book_isbn"`
+	BookRole   books.Role `db:"book_role"`
 }
 
 type BookAuth struct {
@@ -0,0 +1,93 @@
+package authors
+
+import (
+	"fmt"
+	"time"
+
+	"bookshop/datastore"
+)
+
+// SortField is a field that author listings can be ordered by.
+type SortField string
+
+const (
+	SortByLastName  SortField = "last_name"
+	SortByFirstName SortField = "first_name"
+	SortByDOB       SortField = "dob"
+)
+
+// AuthorQuery holds the filters, sort order and page position for listing
+// authors. The zero value lists the first page of all authors sorted by last
+// name (ascending).
+type AuthorQuery struct {
+	// LastNamePrefix limits results to last names starting with the value (case insensitive).
+	LastNamePrefix string
+	// DOBFrom and DOBTo limit results to authors born within the range (inclusive).
+	DOBFrom *time.Time
+	DOBTo   *time.Time
+
+	SortBy     SortField
+	Descending bool
+
+	// Limit is the page size, defaulting to datastore.DefaultLimit.
+	Limit int
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+}
+
+// Page is a single page of authors along with the cursor for the following
+// page. NextCursor is blank on the last page.
+type Page struct {
+	Authors    []Author `json:"authors"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+// WithDefaults returns a copy of the query with the default sort field and
+// limit filled in.
+func (q AuthorQuery) WithDefaults() AuthorQuery {
+	if q.SortBy == "" {
+		q.SortBy = SortByLastName
+	}
+	if q.Limit == 0 {
+		q.Limit = datastore.DefaultLimit
+	}
+	return q
+}
+
+// Validate checks the query for unknown sort fields, out of range limits,
+// inverted date ranges and cursors that were not issued for its sort order.
+func (q AuthorQuery) Validate() error {
+	q = q.WithDefaults()
+	switch q.SortBy {
+	case SortByLastName, SortByFirstName, SortByDOB:
+	default:
+		return fmt.Errorf("unknown sort field %q", q.SortBy)
+	}
+	if q.Limit < 1 || q.Limit > datastore.MaxLimit {
+		return fmt.Errorf("limit must be between 1 and %d", datastore.MaxLimit)
+	}
+	if q.DOBFrom != nil && q.DOBTo != nil && q.DOBTo.Before(*q.DOBFrom) {
+		return fmt.Errorf("date of birth range ends before it starts")
+	}
+	if q.Cursor != "" {
+		if _, err := datastore.DecodeCursor(q.Cursor, string(q.SortBy), q.Descending); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SortValue returns the value of the given sort field for the author, as
+// stored in pagination cursors.
+func (a Author) SortValue(field SortField) string {
+	switch field {
+	case SortByFirstName:
+		return a.FirstName
+	case SortByDOB:
+		if a.DOB == nil {
+			return ""
+		}
+		return a.DOB.UTC().Format(time.RFC3339Nano)
+	}
+	return a.LastName
+}
@@ -0,0 +1,146 @@
+package authors_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"bookshop/authors"
+	"bookshop/books"
+	"bookshop/datastore"
+	"bookshop/datastore/dbtest"
+	"bookshop/search"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorsSQLite(t *testing.T) {
+	ctx := context.Background()
+	early, err := time.Parse(authors.DateParsingFormat, "1920-01-02")
+	require.NoError(t, err)
+	late, err := time.Parse(authors.DateParsingFormat, "1947-09-21")
+	require.NoError(t, err)
+
+	newStore := func(t *testing.T) authors.AuthorStore {
+		dbh := dbtest.SQLite(t)
+
+		store := authors.NewAuthorStore(dbh)
+		err = store.UpsertAuthors(ctx, []authors.Author{
+			{ID: "auth01", FirstName: "Frank", LastName: "Herbert", DOB: &early},
+			{ID: "auth02", FirstName: "Stephen", MiddleName: "Edwin", LastName: "King", DOB: &late},
+		})
+		require.NoError(t, err)
+
+		bs := books.NewBookStore(dbh)
+		err = bs.UpsertBooks(ctx, []books.Book{{ID: "ghi03", Title: "Dune", ISBN: "9781861972712"}})
+		require.NoError(t, err)
+		require.NoError(t, bs.LinkBookAuthors(ctx, "ghi03",
+			books.Credit{AuthorID: "auth01", Role: books.RoleAuthor, Sequence: 1},
+			books.Credit{AuthorID: "auth01", Role: books.RoleIllustrator, Sequence: 2},
+		))
+		return store
+	}
+
+	t.Run("ReadAuthors", func(t *testing.T) {
+		store := newStore(t)
+
+		page, err := store.ReadAuthors(ctx, authors.AuthorQuery{Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, page.Authors, 1)
+		assert.Equal(t, "Herbert", page.Authors[0].LastName)
+		assert.True(t, early.Equal(*page.Authors[0].DOB))
+
+		page, err = store.ReadAuthors(ctx, authors.AuthorQuery{Limit: 1, Cursor: page.NextCursor})
+		require.NoError(t, err)
+		require.Len(t, page.Authors, 1)
+		assert.Equal(t, "King", page.Authors[0].LastName)
+		assert.Empty(t, page.NextCursor)
+
+		page, err = store.ReadAuthors(ctx, authors.AuthorQuery{LastNamePrefix: "ki"})
+		require.NoError(t, err)
+		require.Len(t, page.Authors, 1)
+		assert.Equal(t, "auth02", page.Authors[0].ID)
+
+		from := early.AddDate(0, 0, 1)
+		page, err = store.ReadAuthors(ctx, authors.AuthorQuery{DOBFrom: &from})
+		require.NoError(t, err)
+		require.Len(t, page.Authors, 1)
+		assert.Equal(t, "auth02", page.Authors[0].ID)
+
+		page, err = store.ReadAuthors(ctx, authors.AuthorQuery{SortBy: authors.SortByDOB, Descending: true, Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, page.Authors, 1)
+		assert.Equal(t, "auth02", page.Authors[0].ID)
+		page, err = store.ReadAuthors(ctx, authors.AuthorQuery{SortBy: authors.SortByDOB, Descending: true, Cursor: page.NextCursor})
+		require.NoError(t, err)
+		require.Len(t, page.Authors, 1)
+		assert.Equal(t, "auth01", page.Authors[0].ID)
+	})
+
+	t.Run("ReadAuthorAndBooks", func(t *testing.T) {
+		store := newStore(t)
+
+		auth, err := store.ReadAuthorAndBooks(ctx, "auth01")
+		require.NoError(t, err)
+		require.Len(t, auth.Books, 1)
+		assert.Equal(t, "Dune", auth.Books[0].Title)
+		require.Len(t, auth.BooksByRole, 2)
+		assert.Equal(t, "Dune", auth.BooksByRole[books.RoleAuthor][0].Title)
+		assert.Equal(t, "Dune", auth.BooksByRole[books.RoleIllustrator][0].Title)
+
+		auth, err = store.ReadAuthorAndBooks(ctx, "auth02")
+		require.NoError(t, err)
+		assert.Empty(t, auth.Books)
+		assert.Empty(t, auth.BooksByRole)
+
+		_, err = store.ReadAuthorAndBooks(ctx, "unknown")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("UpsertAuthors", func(t *testing.T) {
+		store := newStore(t)
+
+		err := store.UpsertAuthors(ctx, []authors.Author{
+			{ID: "auth03", FirstName: "Frank", LastName: "Herbert", DOB: &early},
+		})
+		assert.True(t, errors.Is(err, datastore.ErrDuplicate))
+	})
+
+	t.Run("UpdateAuthor", func(t *testing.T) {
+		store := newStore(t)
+
+		err := store.UpdateAuthor(ctx, authors.Author{ID: "auth01", FirstName: "Franklin", LastName: "Herbert", DOB: &early})
+		require.NoError(t, err)
+		auth, err := store.ReadAuthorAndBooks(ctx, "auth01")
+		require.NoError(t, err)
+		assert.Equal(t, "Franklin", auth.FirstName)
+
+		// updates never create authors
+		err = store.UpdateAuthor(ctx, authors.Author{ID: "unknown", FirstName: "New", LastName: "Author", DOB: &early})
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+
+		err = store.UpdateAuthor(ctx, authors.Author{ID: "auth01", FirstName: "Stephen", MiddleName: "Edwin", LastName: "King", DOB: &late})
+		assert.True(t, errors.Is(err, datastore.ErrDuplicate))
+	})
+
+	t.Run("DeleteAuthor", func(t *testing.T) {
+		store := newStore(t)
+
+		require.NoError(t, store.DeleteAuthor(ctx, "auth01"))
+		err := store.DeleteAuthor(ctx, "auth01")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("SearchAuthors", func(t *testing.T) {
+		store := newStore(t)
+
+		results, err := store.SearchAuthors(ctx, "edwin king", 10)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, search.KindAuthor, results[0].Kind)
+		assert.Equal(t, "Stephen Edwin King", results[0].Title)
+		assert.Equal(t, "Stephen <mark>Edwin</mark> <mark>King</mark>", results[0].Snippet)
+	})
+}
@@ -1,9 +1,16 @@
 package books
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
 	"strings"
 	"time"
 
+	"bookshop/datastore"
+	"bookshop/money"
+	"bookshop/search"
+
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 	"github.com/pkg/errors"
@@ -17,53 +24,275 @@ func NewBookStore(db *sqlx.DB) BookStore {
 	return BookStore{db: db}
 }
 
-// ReadBooks will return a list of all books.
-func (s *BookStore) ReadBooks() ([]Book, error) {
-	bks := []Book{}
-	if err := s.db.Select(&bks, "SELECT * FROM books ORDER BY title ASC"); err != nil {
-		return nil, errors.Wrap(err, "failed to read books")
+// ReadBooks will return a page of books matching the query.
+func (s *BookStore) ReadBooks(ctx context.Context, q BookQuery) (Page, error) {
+	q = q.WithDefaults()
+
+	var where []string
+	var args []interface{}
+	if q.TitlePrefix != "" {
+		where = append(where, `lower(b.title) LIKE lower(?) ESCAPE '\'`)
+		args = append(args, datastore.EscapeLike(q.TitlePrefix)+"%")
+	}
+	if q.ISBNPrefix != "" {
+		where = append(where, `b.isbn LIKE ? ESCAPE '\'`)
+		args = append(args, datastore.EscapeLike(q.ISBNPrefix)+"%")
+	}
+	if q.AuthorLastName != "" {
+		where = append(where, `EXISTS (
+			SELECT 1 FROM authors a, books_authors ab
+			WHERE a.id = ab.author_id
+			AND ab.book_id = b.id
+			AND lower(a.last_name) = lower(?))`)
+		args = append(args, q.AuthorLastName)
+	}
+	if q.InStock {
+		where = append(where, `EXISTS (
+			SELECT 1 FROM stock s
+			WHERE s.book_id = b.id
+			AND s.on_hand > s.reserved)`)
+	}
+	if q.Category != "" {
+		where = append(where, `EXISTS (
+			SELECT 1 FROM books_categories bc
+			WHERE bc.book_id = b.id
+			AND bc.category_id IN (
+				WITH RECURSIVE tree (id) AS (
+					SELECT id FROM categories WHERE id = ?
+					UNION
+					SELECT c.id FROM categories c, tree t WHERE c.parent_id = t.id)
+				SELECT id FROM tree))`)
+		args = append(args, q.Category)
+	}
+	if q.Tag != "" {
+		where = append(where, `EXISTS (
+			SELECT 1 FROM books_tags bt
+			WHERE bt.book_id = b.id
+			AND bt.tag = ?)`)
+		args = append(args, q.Tag)
+	}
+
+	col, param := s.sortColumn(q.SortBy)
+	dir, cmp := "ASC", ">"
+	if q.Descending {
+		dir, cmp = "DESC", "<"
 	}
-	return bks, nil
+	if q.Cursor != "" {
+		c, err := datastore.DecodeCursor(q.Cursor, string(q.SortBy), q.Descending)
+		if err != nil {
+			return Page{}, err
+		}
+		where = append(where, fmt.Sprintf(`(%s, b.id) %s (%s, ?)`, col, cmp, param))
+		args = append(args, c.Value, c.ID)
+	}
+
+	qry := s.selectBooks()
+	if len(where) > 0 {
+		qry += " WHERE " + strings.Join(where, " AND ")
+	}
+	qry += fmt.Sprintf(" ORDER BY %s %s, b.id %s LIMIT ?", col, dir, dir)
+	args = append([]interface{}{time.Now()}, args...)
+	args = append(args, q.Limit+1)
+
+	rows := []bookRow{}
+	if err := datastore.Conn(ctx, s.db).SelectContext(ctx, &rows, s.db.Rebind(qry), args...); err != nil {
+		return Page{}, errors.Wrap(err, "failed to read books")
+	}
+	bks := make([]Book, len(rows))
+	for i, r := range rows {
+		bks[i] = r.book()
+	}
+	return newPage(bks, q), nil
+}
+
+// bookRow is a books row along with the list price in effect, whose columns
+// are null for books that have not been priced, and the stars and number of
+// its approved reviews, which are null for books without any. The publisher and
+// dimensions are null for books without them.
+type bookRow struct {
+	Book
+	Height        sql.NullInt64   `db:"height_mm"`
+	Width         sql.NullInt64   `db:"width_mm"`
+	Depth         sql.NullInt64   `db:"depth_mm"`
+	PriceAmount   sql.NullInt64   `db:"price_amount"`
+	PriceCurrency sql.NullString  `db:"price_currency"`
+	RatingTotal   sql.NullFloat64 `db:"rating_total"`
+	RatingCount   sql.NullInt64   `db:"rating_count"`
+	PublisherID   sql.NullString  `db:"publisher_id"`
+	PublisherName sql.NullString  `db:"publisher_name"`
 }
 
-// ReadBookByISBN will return the given book looked up by isbn.
-func (s *BookStore) ReadBookByISBN(isbn string) (Book, error) {
-	bk := Book{}
-	row := s.db.QueryRowx("SELECT * FROM books WHERE isbn=$1", isbn)
-	if err := row.Scan(&bk.ID, &bk.Title, &bk.ISBN, &bk.UpdatedAt); err != nil {
-		return bk, errors.Wrap(err, "failed to read book")
+func (r bookRow) book() Book {
+	bk := r.Book
+	rating := NewRating(r.RatingTotal.Float64, int(r.RatingCount.Int64))
+	bk.Rating = &rating
+	if r.PriceAmount.Valid {
+		p := money.New(r.PriceAmount.Int64, money.Currency(r.PriceCurrency.String))
+		bk.ListPrice = &p
+	}
+	if r.PublisherID.Valid {
+		bk.PublisherID = r.PublisherID.String
+		bk.Publisher = &BookPublisher{ID: r.PublisherID.String, Name: r.PublisherName.String}
+	}
+	if r.Height.Valid {
+		bk.Dimensions = &Dimensions{
+			Height: int(r.Height.Int64),
+			Width:  int(r.Width.Int64),
+			Depth:  int(r.Depth.Int64),
+		}
+	}
+	return bk
+}
+
+// selectBooks returns the start of a query for books as b along with the list
+// price in effect at the time bound to its only placeholder and the rating of
+// its approved reviews. The ratings are looked up per book, so a page of books
+// only reads the reviews of the books on it.
+func (s *BookStore) selectBooks() string {
+	effective := datastore.Timestamp(s.db, "bp.effective_from")
+	return `SELECT b.*, p.amount AS price_amount, p.currency AS price_currency,
+		(SELECT SUM(r.rating) FROM reviews r
+			WHERE r.book_id = b.id AND r.status = 'approved') AS rating_total,
+		(SELECT COUNT(*) FROM reviews r
+			WHERE r.book_id = b.id AND r.status = 'approved') AS rating_count,
+		bpub.publisher_id, pub.name AS publisher_name
+	FROM books b
+	LEFT JOIN books_publishers bpub ON bpub.book_id = b.id
+	LEFT JOIN publishers pub ON pub.id = bpub.publisher_id
+	LEFT JOIN book_prices p ON p.id = (
+		SELECT bp.id FROM book_prices bp
+		WHERE bp.book_id = b.id
+		AND ` + effective + ` <= ` + datastore.Timestamp(s.db, "?") + `
+		ORDER BY ` + effective + ` DESC, bp.id DESC
+		LIMIT 1)`
+}
+
+// sortColumn returns the column for the sort field and the placeholder used to
+// compare cursor values against it.
+func (s *BookStore) sortColumn(field SortField) (string, string) {
+	switch field {
+	case SortByISBN:
+		return "b.isbn", "?"
+	case SortByUpdatedAt:
+		return datastore.Timestamp(s.db, "b.updated_at"), datastore.CursorTimestamp(s.db)
+	}
+	return "b.title", "?"
+}
+
+// newPage trims the extra row fetched to detect a following page and builds
+// the cursor pointing at it.
+func newPage(bks []Book, q BookQuery) Page {
+	if len(bks) <= q.Limit {
+		return Page{Books: bks}
+	}
+	bks = bks[:q.Limit]
+	last := bks[len(bks)-1]
+	return Page{
+		Books: bks,
+		NextCursor: datastore.Cursor{
+			Sort:  string(q.SortBy),
+			Desc:  q.Descending,
+			Value: last.SortValue(q.SortBy),
+			ID:    last.ID,
+		}.Encode(),
+	}
+}
+
+// ReadBookByISBN will return the given book looked up by isbn, returning
+// datastore.ErrNotFound if it does not exist.
+func (s *BookStore) ReadBookByISBN(ctx context.Context, isbn string) (Book, error) {
+	row := bookRow{}
+	qry := s.selectBooks() + " WHERE b.isbn=?"
+	if err := datastore.Conn(ctx, s.db).GetContext(ctx, &row, s.db.Rebind(qry), time.Now(), isbn); err != nil {
+		if err == sql.ErrNoRows {
+			return Book{}, datastore.ErrNotFound
+		}
+		return Book{}, errors.Wrap(err, "failed to read book")
+	}
+	return row.book(), nil
+}
+
+// ReadBookByID will return the given book looked up by ID along with its
+// authors, categories and tags.
+func (s *BookStore) ReadBookByID(ctx context.Context, id string) (Book, error) {
+	row := bookRow{}
+	qry := s.selectBooks() + " WHERE b.id=?"
+	if err := datastore.Conn(ctx, s.db).GetContext(ctx, &row, s.db.Rebind(qry), time.Now(), id); err != nil {
+		if err == sql.ErrNoRows {
+			return Book{}, datastore.ErrNotFound
+		}
+		return Book{}, errors.Wrap(err, "failed to read book")
+	}
+	bk := row.book()
+
+	sqlSt :=
+		`SELECT a.id, a.first_name, a.middle_name, a.last_name, ab.role, ab.sequence
+		FROM authors a, books_authors ab
+		WHERE a.id = ab.author_id
+		AND ab.book_id = ?
+		ORDER BY ab.sequence ASC, a.last_name ASC, a.first_name ASC, ab.role ASC`
+	if err := datastore.Conn(ctx, s.db).SelectContext(ctx, &bk.Authors, s.db.Rebind(sqlSt), id); err != nil {
+		return Book{}, errors.Wrap(err, "failed to read book authors")
+	}
+
+	sqlSt =
+		`SELECT c.id, c.name
+		FROM categories c, books_categories bc
+		WHERE c.id = bc.category_id
+		AND bc.book_id = ?
+		ORDER BY lower(c.name) ASC, c.id ASC`
+	if err := datastore.Conn(ctx, s.db).SelectContext(ctx, &bk.Categories, s.db.Rebind(sqlSt), id); err != nil {
+		return Book{}, errors.Wrap(err, "failed to read book categories")
+	}
+	sqlSt = `SELECT tag FROM books_tags WHERE book_id = ? ORDER BY tag ASC`
+	if err := datastore.Conn(ctx, s.db).SelectContext(ctx, &bk.Tags, s.db.Rebind(sqlSt), id); err != nil {
+		return Book{}, errors.Wrap(err, "failed to read book tags")
 	}
 	return bk, nil
 }
 
-// DeleteBooks will delete of books by their ID.
-func (s *BookStore) DeleteBooks(ids ...string) error {
+// DeleteBooks will delete of books by their ID, returning datastore.ErrNotFound
+// if none of them exist.
+func (s *BookStore) DeleteBooks(ctx context.Context, ids ...string) error {
 	if len(ids) == 0 {
 		return errors.New("no ids submitted to delete")
 	}
-	tx := s.db.MustBegin()
 	qry, args, err := sqlx.In("DELETE FROM books WHERE id IN (?)", ids)
 	if err != nil {
 		return errors.Wrap(err, "failed deleting books")
 	}
-	rows, err := tx.Queryx(s.db.Rebind(qry), args...)
+	tx, err := datastore.Begin(ctx, s.db)
 	if err != nil {
 		return errors.Wrap(err, "failed deleting books")
 	}
-	rows.Close()
+	res, err := tx.ExecContext(ctx, s.db.Rebind(qry), args...)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed deleting books")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		tx.Rollback()
+		return datastore.ErrNotFound
+	}
 	if err := tx.Commit(); err != nil {
 		return err
 	}
 	return nil
 }
 
-// UpsertBooks will modify or add the books in the given list.
-func (s *BookStore) UpsertBooks(bks []Book) error {
+// UpsertBooks will modify or add the books in the given list, returning
+// datastore.ErrDuplicate if any of them shares an ISBN with another book.
+func (s *BookStore) UpsertBooks(ctx context.Context, bks []Book) error {
 	if len(bks) == 0 {
 		return errors.New("no books to upsert")
 	}
-	tx := s.db.MustBegin()
-	const sqlSetPre = `INSERT INTO books (id, title, isbn, updated_at) VALUES `
+	tx, err := datastore.Begin(ctx, s.db)
+	if err != nil {
+		return errors.Wrap(err, "failed upserting books")
+	}
+	const sqlSetPre = `INSERT INTO books (id, title, isbn, subtitle, description,
+		published_on, language, page_count, format, edition,
+		height_mm, width_mm, depth_mm, updated_at) VALUES `
 
 	// updated_at = NOW() proves difficult to test, so manually set updated_at for updates
 	const sqlSetPost = ` ON CONFLICT(id) DO
@@ -71,9 +300,19 @@ func (s *BookStore) UpsertBooks(bks []Book) error {
         id = EXCLUDED.id,
         title = EXCLUDED.title,
         isbn = EXCLUDED.isbn,
+        subtitle = EXCLUDED.subtitle,
+        description = EXCLUDED.description,
+        published_on = EXCLUDED.published_on,
+        language = EXCLUDED.language,
+        page_count = EXCLUDED.page_count,
+        format = EXCLUDED.format,
+        edition = EXCLUDED.edition,
+        height_mm = EXCLUDED.height_mm,
+        width_mm = EXCLUDED.width_mm,
+        depth_mm = EXCLUDED.depth_mm,
         updated_at = EXCLUDED.updated_at
     RETURNING *;`
-	const sqlValues = `(?,?,?,?)`
+	const sqlValues = `(?,?,?,?,?,?,?,?,?,?,?,?,?,?)`
 
 	var qryRows []string
 	var qryArgs []interface{}
@@ -82,19 +321,296 @@ func (s *BookStore) UpsertBooks(bks []Book) error {
 		qryArgs = append(qryArgs, b.ID)
 		qryArgs = append(qryArgs, b.Title)
 		qryArgs = append(qryArgs, b.ISBN)
+		qryArgs = append(qryArgs, b.Subtitle, b.Description, b.PublishedOn)
+		qryArgs = append(qryArgs, b.Language, b.PageCount, b.Format, b.Edition)
+		var height, width, depth sql.NullInt64
+		if d := b.Dimensions; d != nil {
+			height = sql.NullInt64{Int64: int64(d.Height), Valid: true}
+			width = sql.NullInt64{Int64: int64(d.Width), Valid: true}
+			depth = sql.NullInt64{Int64: int64(d.Depth), Valid: true}
+		}
+		qryArgs = append(qryArgs, height, width, depth)
 		qryArgs = append(qryArgs, time.Now())
 	}
 	joinedRows := strings.Join(qryRows, ",")
 	joinedQuery := sqlSetPre + joinedRows + sqlSetPost
 
-	var err error
 	var rows *sqlx.Rows
-	if rows, err = tx.Queryx(s.db.Rebind(joinedQuery), qryArgs...); err != nil {
+	if rows, err = tx.QueryxContext(ctx, s.db.Rebind(joinedQuery), qryArgs...); err == nil {
+		err = rows.Close()
+	}
+	if err != nil {
+		tx.Rollback()
+		if datastore.IsUniqueViolation(err) {
+			return datastore.ErrDuplicate
+		}
 		return errors.Wrap(err, "failed upserting books")
 	}
-	rows.Close()
+	if err := s.setPublishers(ctx, tx, bks); err != nil {
+		tx.Rollback()
+		return err
+	}
 	if err := tx.Commit(); err != nil {
 		return err
 	}
 	return nil
 }
+
+// setPublishers replaces the publishers of the given books with the ones they
+// name, returning datastore.ErrMissingReference if a publisher does not exist.
+func (s *BookStore) setPublishers(ctx context.Context, tx *datastore.Tx, bks []Book) error {
+	ids := make([]string, len(bks))
+	var qryRows []string
+	var qryArgs []interface{}
+	for i, b := range bks {
+		ids[i] = b.ID
+		if b.PublisherID != "" {
+			qryRows = append(qryRows, "(?,?)")
+			qryArgs = append(qryArgs, b.ID, b.PublisherID)
+		}
+	}
+
+	qry, args, err := sqlx.In("DELETE FROM books_publishers WHERE book_id IN (?)", ids)
+	if err != nil {
+		return errors.Wrap(err, "failed setting book publishers")
+	}
+	if _, err := tx.ExecContext(ctx, s.db.Rebind(qry), args...); err != nil {
+		return errors.Wrap(err, "failed setting book publishers")
+	}
+	if len(qryRows) == 0 {
+		return nil
+	}
+	qry = "INSERT INTO books_publishers (book_id, publisher_id) VALUES " + strings.Join(qryRows, ",")
+	if _, err := tx.ExecContext(ctx, s.db.Rebind(qry), qryArgs...); err != nil {
+		if datastore.IsForeignKeyViolation(err) {
+			return datastore.ErrMissingReference
+		}
+		return errors.Wrap(err, "failed setting book publishers")
+	}
+	return nil
+}
+
+// LinkBookAuthors will credit authors on the book. Authors already credited in
+// the same role are moved to the given sequence.
+func (s *BookStore) LinkBookAuthors(ctx context.Context, bookID string, credits ...Credit) error {
+	if len(credits) == 0 {
+		return errors.New("no credits submitted to link")
+	}
+	tx, err := datastore.Begin(ctx, s.db)
+	if err != nil {
+		return errors.Wrap(err, "failed linking book authors")
+	}
+	const sqlSetPre = `INSERT INTO books_authors (book_id, author_id, role, sequence) VALUES `
+	const sqlSetPost = ` ON CONFLICT (book_id, author_id, role) DO UPDATE SET sequence = excluded.sequence;`
+	const sqlValues = `(?,?,?,?)`
+
+	var qryRows []string
+	var qryArgs []interface{}
+	for _, c := range credits {
+		qryRows = append(qryRows, sqlValues)
+		qryArgs = append(qryArgs, bookID, c.AuthorID, c.Role, c.Sequence)
+	}
+	joinedQuery := sqlSetPre + strings.Join(qryRows, ",") + sqlSetPost
+
+	if _, err := tx.ExecContext(ctx, s.db.Rebind(joinedQuery), qryArgs...); err != nil {
+		tx.Rollback()
+		if datastore.IsForeignKeyViolation(err) {
+			return datastore.ErrMissingReference
+		}
+		return errors.Wrap(err, "failed linking book authors")
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UnlinkBookAuthor will remove the given author's credit in the role from the
+// book, or all of their credits when the role is empty, returning
+// datastore.ErrNotFound if the author was not credited.
+func (s *BookStore) UnlinkBookAuthor(ctx context.Context, bookID, authorID string, role Role) error {
+	qry := "DELETE FROM books_authors WHERE book_id=? AND author_id=?"
+	args := []interface{}{bookID, authorID}
+	if role != "" {
+		qry += " AND role=?"
+		args = append(args, role)
+	}
+	res, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind(qry), args...)
+	if err != nil {
+		return errors.Wrap(err, "failed unlinking book author")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return datastore.ErrNotFound
+	}
+	return nil
+}
+
+// LinkBookCategories will file the book under the given categories. Categories
+// the book is already filed under are left untouched.
+func (s *BookStore) LinkBookCategories(ctx context.Context, bookID string, categoryIDs ...string) error {
+	if len(categoryIDs) == 0 {
+		return errors.New("no category ids submitted to link")
+	}
+	var qryRows []string
+	var qryArgs []interface{}
+	for _, id := range categoryIDs {
+		qryRows = append(qryRows, `(?,?)`)
+		qryArgs = append(qryArgs, bookID, id)
+	}
+	qry := `INSERT INTO books_categories (book_id, category_id) VALUES ` + strings.Join(qryRows, ",") + ` ON CONFLICT DO NOTHING`
+
+	if _, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind(qry), qryArgs...); err != nil {
+		if datastore.IsForeignKeyViolation(err) {
+			return datastore.ErrMissingReference
+		}
+		return errors.Wrap(err, "failed linking book categories")
+	}
+	return nil
+}
+
+// UnlinkBookCategory will take the book out of the given category, returning
+// datastore.ErrNotFound if it was not filed under it.
+func (s *BookStore) UnlinkBookCategory(ctx context.Context, bookID, categoryID string) error {
+	res, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind("DELETE FROM books_categories WHERE book_id=? AND category_id=?"), bookID, categoryID)
+	if err != nil {
+		return errors.Wrap(err, "failed unlinking book category")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return datastore.ErrNotFound
+	}
+	return nil
+}
+
+// TagBook will give the book the given tags, returning
+// datastore.ErrMissingReference if the book does not exist. Tags the book
+// already has are left untouched.
+func (s *BookStore) TagBook(ctx context.Context, bookID string, tags ...string) error {
+	if len(tags) == 0 {
+		return errors.New("no tags submitted")
+	}
+	var qryRows []string
+	var qryArgs []interface{}
+	for _, tag := range tags {
+		qryRows = append(qryRows, `(?,?)`)
+		qryArgs = append(qryArgs, bookID, tag)
+	}
+	qry := `INSERT INTO books_tags (book_id, tag) VALUES ` + strings.Join(qryRows, ",") + ` ON CONFLICT DO NOTHING`
+
+	if _, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind(qry), qryArgs...); err != nil {
+		if datastore.IsForeignKeyViolation(err) {
+			return datastore.ErrMissingReference
+		}
+		return errors.Wrap(err, "failed tagging book")
+	}
+	return nil
+}
+
+// UntagBook will remove the tag from the book, returning datastore.ErrNotFound
+// if the book does not have it.
+func (s *BookStore) UntagBook(ctx context.Context, bookID, tag string) error {
+	res, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind("DELETE FROM books_tags WHERE book_id=? AND tag=?"), bookID, tag)
+	if err != nil {
+		return errors.Wrap(err, "failed untagging book")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return datastore.ErrNotFound
+	}
+	return nil
+}
+
+// ReadTags will return every tag given to a book along with how many books
+// have it, sorted by name.
+func (s *BookStore) ReadTags(ctx context.Context) ([]Tag, error) {
+	tags := []Tag{}
+	sqlSt := `SELECT tag, count(*) AS books FROM books_tags GROUP BY tag ORDER BY tag ASC`
+	if err := datastore.Conn(ctx, s.db).SelectContext(ctx, &tags, sqlSt); err != nil {
+		return nil, errors.Wrap(err, "failed to read tags")
+	}
+	return tags, nil
+}
+
+// AddBookPrice will record a list price for the book taking effect at the price's
+// effective time, returning datastore.ErrMissingReference if the book does not exist.
+func (s *BookStore) AddBookPrice(ctx context.Context, bookID string, p Price) error {
+	const sqlSt = `INSERT INTO book_prices (book_id, amount, currency, effective_from, created_at) VALUES (?,?,?,?,?)`
+	_, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind(sqlSt),
+		bookID, p.ListPrice.Amount, p.ListPrice.Currency, p.EffectiveFrom, time.Now())
+	if err != nil {
+		if datastore.IsForeignKeyViolation(err) {
+			return datastore.ErrMissingReference
+		}
+		return errors.Wrap(err, "failed adding book price")
+	}
+	return nil
+}
+
+// ReadBookPrices will return the book's list prices ordered by when they take
+// effect, returning datastore.ErrNotFound if the book does not exist.
+func (s *BookStore) ReadBookPrices(ctx context.Context, bookID string) ([]Price, error) {
+	var n int
+	if err := datastore.Conn(ctx, s.db).GetContext(ctx, &n, s.db.Rebind("SELECT count(*) FROM books WHERE id=?"), bookID); err != nil {
+		return nil, errors.Wrap(err, "failed to read book prices")
+	}
+	if n == 0 {
+		return nil, datastore.ErrNotFound
+	}
+
+	rows := []struct {
+		Amount        int64     `db:"amount"`
+		Currency      string    `db:"currency"`
+		EffectiveFrom time.Time `db:"effective_from"`
+	}{}
+	sqlSt := fmt.Sprintf(`SELECT amount, currency, effective_from FROM book_prices
+		WHERE book_id = ?
+		ORDER BY %s ASC, id ASC`, datastore.Timestamp(s.db, "effective_from"))
+	if err := datastore.Conn(ctx, s.db).SelectContext(ctx, &rows, s.db.Rebind(sqlSt), bookID); err != nil {
+		return nil, errors.Wrap(err, "failed to read book prices")
+	}
+
+	prices := make([]Price, len(rows))
+	for i, r := range rows {
+		prices[i] = Price{
+			ListPrice:     money.New(r.Amount, money.Currency(r.Currency)),
+			EffectiveFrom: r.EffectiveFrom,
+		}
+	}
+	return prices, nil
+}
+
+// SearchBooks will return the books whose titles match the full text query,
+// ranked by relevance.
+func (s *BookStore) SearchBooks(ctx context.Context, query string, limit int) ([]search.Result, error) {
+	sqlSt :=
+		`SELECT 'book' AS kind,
+				b.id,
+				b.title,
+				ts_headline('english', b.title, q, $3) AS snippet,
+				ts_rank(to_tsvector('english', b.title), q) AS rank
+		FROM books b, plainto_tsquery('english', $1) q
+		WHERE to_tsvector('english', b.title) @@ q
+		ORDER BY rank DESC, b.title ASC
+		LIMIT $2`
+	args := []interface{}{query, limit, datastore.HeadlineOptions}
+	if datastore.IsSQLite(s.db) {
+		sqlSt =
+			`SELECT 'book' AS kind,
+					bs.id,
+					bs.title,
+					highlight(books_search, 1, ?, ?) AS snippet,
+					-bm25(books_search) AS rank
+			FROM books_search bs
+			WHERE books_search MATCH ?
+			ORDER BY rank DESC, bs.title ASC
+			LIMIT ?`
+		args = []interface{}{search.MatchStart, search.MatchStop, datastore.FTSQuery(query), limit}
+	}
+
+	results := []search.Result{}
+	if err := datastore.Conn(ctx, s.db).SelectContext(ctx, &results, sqlSt, args...); err != nil {
+		return nil, errors.Wrap(err, "failed to search books")
+	}
+	for i := range results {
+		results[i].Snippet = search.Snippet(results[i].Snippet)
+	}
+	return results, nil
+}
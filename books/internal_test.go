@@ -3,28 +3,35 @@
 package books_test
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
-	"os"
 	"testing"
+	"time"
 
+	"bookshop/authors"
 	"bookshop/books"
+	"bookshop/datastore"
+	"bookshop/datastore/dbtest"
+	"bookshop/money"
+	"bookshop/search"
 
 	"github.com/jmoiron/sqlx"
-	"github.com/robojandro/go-pgtesthelper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestBooks(t *testing.T) {
-	h, dbh := initializeTestDB(t)
-	defer h.CleanUp()
+	ctx := context.Background()
+	dbh := initializeTestDB(t)
 
 	store := books.NewBookStore(dbh)
 
 	t.Run("ReadBooks", func(t *testing.T) {
-		bks, err := store.ReadBooks()
+		page, err := store.ReadBooks(ctx, books.BookQuery{})
 		require.NoError(t, err)
+		bks := page.Books
 		assert.NotNil(t, bks)
 		assert.Equal(t, "cb0b9721-7631-4b2a-94a2-493c559da893", bks[0].ID)
 		assert.Equal(t, "titleA", bks[0].Title)
@@ -36,12 +43,72 @@ func TestBooks(t *testing.T) {
 
 	t.Run("ReadBookByISBN", func(t *testing.T) {
 		isbn := "9783161484100"
-		bk, err := store.ReadBookByISBN(isbn)
+		bk, err := store.ReadBookByISBN(ctx, isbn)
 		require.NoError(t, err)
 		assert.NotNil(t, bk)
 		assert.Equal(t, "cb0b9721-7631-4b2a-94a2-493c559da893", bk.ID)
 		assert.Equal(t, "titleA", bk.Title)
 		assert.Equal(t, "9783161484100", string(bk.ISBN))
+
+		_, err = store.ReadBookByISBN(ctx, "9780306406157")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("ReadBookByID", func(t *testing.T) {
+		bk, err := store.ReadBookByID(ctx, "cb0b9721-7631-4b2a-94a2-493c559da893")
+		require.NoError(t, err)
+		assert.Equal(t, "titleA", bk.Title)
+		assert.Equal(t, "9783161484100", string(bk.ISBN))
+
+		require.Len(t, bk.Authors, 1)
+		assert.Equal(t, "0b5babb0-96d8-11ea-bb37-0242ac130002", bk.Authors[0].ID)
+		assert.Equal(t, "last", bk.Authors[0].LastName)
+
+		_, err = store.ReadBookByID(ctx, "unknown")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("SearchBooks", func(t *testing.T) {
+		results, err := store.SearchBooks(ctx, "titleA", 10)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, search.KindBook, results[0].Kind)
+		assert.Equal(t, "cb0b9721-7631-4b2a-94a2-493c559da893", results[0].ID)
+		assert.Equal(t, "<mark>titleA</mark>", results[0].Snippet)
+		assert.True(t, results[0].Rank > 0)
+
+		results, err = store.SearchBooks(ctx, "nothing", 10)
+		require.NoError(t, err)
+		assert.Empty(t, results)
+	})
+
+	t.Run("LinkBookAuthors", func(t *testing.T) {
+		bkID := "cb0b9721-7631-4b2a-94a2-493c559da893"
+		authID := "0b5babb0-96d8-11ea-bb37-0242ac130002"
+
+		// linking an already credited author is a no-op
+		err := store.LinkBookAuthors(ctx, bkID, books.Credit{AuthorID: authID, Role: books.RoleAuthor, Sequence: 1})
+		require.NoError(t, err)
+
+		err = store.LinkBookAuthors(ctx, bkID, books.Credit{AuthorID: "unknown", Role: books.RoleAuthor, Sequence: 1})
+		assert.True(t, errors.Is(err, datastore.ErrMissingReference))
+
+		err = store.UnlinkBookAuthor(ctx, bkID, authID, "")
+		require.NoError(t, err)
+
+		err = store.UnlinkBookAuthor(ctx, bkID, authID, "")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+
+		bk, err := store.ReadBookByID(ctx, bkID)
+		require.NoError(t, err)
+		assert.Empty(t, bk.Authors)
+
+		err = store.LinkBookAuthors(ctx, bkID, books.Credit{AuthorID: authID, Role: books.RoleAuthor, Sequence: 1})
+		require.NoError(t, err)
+
+		bk, err = store.ReadBookByID(ctx, bkID)
+		require.NoError(t, err)
+		assert.Len(t, bk.Authors, 1)
 	})
 
 	t.Run("DeleteBooks", func(t *testing.T) {
@@ -57,14 +124,15 @@ func TestBooks(t *testing.T) {
 				ISBN:  "4444444444444",
 			},
 		}
-		err := store.UpsertBooks(bks)
+		err := store.UpsertBooks(ctx, bks)
 		require.NoError(t, err)
 
-		err = store.DeleteBooks("def03", "ghi04")
+		err = store.DeleteBooks(ctx, "def03", "ghi04")
 		require.NoError(t, err)
 
-		res, err := store.ReadBooks()
+		page, err := store.ReadBooks(ctx, books.BookQuery{})
 		require.NoError(t, err)
+		res := page.Books
 
 		found := map[string]bool{}
 		for _, r := range res {
@@ -74,12 +142,16 @@ func TestBooks(t *testing.T) {
 		assert.False(t, found["ghi04"])
 	})
 
+	t.Run("DeleteBooksNotFound", func(t *testing.T) {
+		err := store.DeleteBooks(ctx, "unknown")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
 	t.Run("Upsert", func(t *testing.T) {
-		err := h.CleanTables([]string{"books"})
-		require.NoError(t, err)
+		dbtest.Truncate(t, dbh, "books")
 
 		bks := mockContents{
-			[]books.Book{
+			Books: []books.Book{
 				books.Book{
 					ID:    "abc01",
 					Title: "titleA",
@@ -88,7 +160,7 @@ func TestBooks(t *testing.T) {
 			},
 		}
 
-		err = insertTestData(dbh, bks)
+		err := insertTestData(dbh, bks)
 		require.NoError(t, err)
 
 		upsert := []books.Book{
@@ -103,11 +175,12 @@ func TestBooks(t *testing.T) {
 				ISBN:  "0000000000000",
 			},
 		}
-		err = store.UpsertBooks(upsert)
+		err = store.UpsertBooks(ctx, upsert)
 		require.NoError(t, err)
 
-		res, err := store.ReadBooks()
+		page, err := store.ReadBooks(ctx, books.BookQuery{})
 		require.NoError(t, err)
+		res := page.Books
 		require.Len(t, res, 2)
 
 		assert.Equal(t, upsert[0].ID, res[0].ID)
@@ -118,28 +191,71 @@ func TestBooks(t *testing.T) {
 		assert.Equal(t, upsert[1].Title, res[1].Title)
 		assert.Equal(t, upsert[1].ISBN, res[1].ISBN)
 	})
-}
 
-func initializeTestDB(t *testing.T) (*pgtesthelper.Helper, *sqlx.DB) {
-	var (
-		schemaPath = "../sql/authors_books.sql"
-		keepDB     = false
-		dbPrefix   = "books_testing"
-		dbUser     = ""
-		dbPass     = ""
-	)
-
-	if dbUser = os.Getenv("bookshop_dbuser"); dbUser == "" {
-		t.Skip("missing env variable bookshop_dbuser")
-	}
-	if dbPass = os.Getenv("bookshop_dbpass"); dbPass == "" {
-		t.Skip("missing env variable bookshop_dbuser")
-	}
+	t.Run("ReadBooksPaged", func(t *testing.T) {
+		first, err := store.ReadBooks(ctx, books.BookQuery{Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, first.Books, 1)
+		assert.Equal(t, "titleXXXX", first.Books[0].Title)
+		require.NotEmpty(t, first.NextCursor)
 
-	h, err := pgtesthelper.NewHelper(schemaPath, dbPrefix, dbUser, dbPass, keepDB)
-	require.NoError(t, err)
+		second, err := store.ReadBooks(ctx, books.BookQuery{Limit: 1, Cursor: first.NextCursor})
+		require.NoError(t, err)
+		require.Len(t, second.Books, 1)
+		assert.Equal(t, "titleZZZZ", second.Books[0].Title)
+		assert.Empty(t, second.NextCursor)
 
-	dbh, err := h.CreateTempDB()
+		desc, err := store.ReadBooks(ctx, books.BookQuery{SortBy: books.SortByISBN, Descending: true})
+		require.NoError(t, err)
+		require.Len(t, desc.Books, 2)
+		assert.Equal(t, "9999999999999", string(desc.Books[0].ISBN))
+	})
+
+	t.Run("ReadBooksFiltered", func(t *testing.T) {
+		page, err := store.ReadBooks(ctx, books.BookQuery{TitlePrefix: "TITLEz"})
+		require.NoError(t, err)
+		require.Len(t, page.Books, 1)
+		assert.Equal(t, "zzz00", page.Books[0].ID)
+
+		page, err = store.ReadBooks(ctx, books.BookQuery{ISBNPrefix: "999-9"})
+		require.NoError(t, err)
+		require.Len(t, page.Books, 1)
+		assert.Equal(t, "abc01", page.Books[0].ID)
+
+		page, err = store.ReadBooks(ctx, books.BookQuery{TitlePrefix: "title%"})
+		require.NoError(t, err)
+		assert.Empty(t, page.Books)
+
+		page, err = store.ReadBooks(ctx, books.BookQuery{AuthorLastName: "nobody"})
+		require.NoError(t, err)
+		assert.Empty(t, page.Books)
+	})
+
+	t.Run("Prices", func(t *testing.T) {
+		id := "cb0b9721-7631-4b2a-94a2-493c559da893"
+		now := time.Now()
+		err := store.AddBookPrice(ctx, id, books.Price{ListPrice: money.New(999, money.EUR), EffectiveFrom: now.Add(-time.Hour)})
+		require.NoError(t, err)
+		err = store.AddBookPrice(ctx, id, books.Price{ListPrice: money.New(1299, money.EUR), EffectiveFrom: now.Add(time.Hour)})
+		require.NoError(t, err)
+
+		bk, err := store.ReadBookByID(ctx, id)
+		require.NoError(t, err)
+		require.NotNil(t, bk.ListPrice)
+		assert.Equal(t, money.New(999, money.EUR), *bk.ListPrice)
+
+		prices, err := store.ReadBookPrices(ctx, id)
+		require.NoError(t, err)
+		require.Len(t, prices, 2)
+		assert.Equal(t, money.New(1299, money.EUR), prices[1].ListPrice)
+
+		err = store.AddBookPrice(ctx, "unknown", books.Price{ListPrice: money.New(1, money.EUR), EffectiveFrom: now})
+		assert.True(t, errors.Is(err, datastore.ErrMissingReference))
+	})
+}
+
+func initializeTestDB(t *testing.T) *sqlx.DB {
+	dbh := dbtest.Postgres(t, "books_testing")
 
 	mockData, err := ioutil.ReadFile("./testdata/mockdb.json")
 	require.NoError(t, err)
@@ -151,15 +267,30 @@ func initializeTestDB(t *testing.T) (*pgtesthelper.Helper, *sqlx.DB) {
 	err = insertTestData(dbh, data)
 	require.NoError(t, err)
 
-	return &h, dbh
+	return dbh
 }
 
 type mockContents struct {
-	Books []books.Book `json:"books"`
+	Authors   []authors.Author   `json:"authors"`
+	BookAuths []authors.BookAuth `json:"books_authors"`
+	Books     []books.Book       `json:"books"`
 }
 
 func insertTestData(dbh *sqlx.DB, data mockContents) error {
 	tx := dbh.MustBegin()
+	authIn :=
+		`INSERT INTO authors (id, first_name, middle_name, last_name, dob, updated_at)
+				  VALUES (:id, :first_name, :middle_name, :last_name, :dob, NOW());`
+	for _, auth := range data.Authors {
+		_, err := tx.NamedExec(authIn, auth)
+		if err != nil {
+			if err := tx.Rollback(); err != nil {
+				return err
+			}
+			return err
+		}
+	}
+
 	bookIn :=
 		`INSERT INTO books (id, title, isbn, updated_at)
 			        VALUES (:id, :title, :isbn, NOW());`
@@ -175,5 +306,21 @@ func insertTestData(dbh *sqlx.DB, data mockContents) error {
 	if err := tx.Commit(); err != nil {
 		return err
 	}
+
+	tx2 := dbh.MustBegin()
+	bookAuthIn :=
+		`INSERT INTO books_authors (book_id, author_id) VALUES (:book_id, :author_id);`
+	for _, bkAuth := range data.BookAuths {
+		_, err := tx2.NamedExec(bookAuthIn, bkAuth)
+		if err != nil {
+			if err := tx2.Rollback(); err != nil {
+				return err
+			}
+			return err
+		}
+	}
+	if err := tx2.Commit(); err != nil {
+		return err
+	}
 	return nil
 }
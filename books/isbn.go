@@ -0,0 +1,129 @@
+package books
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrInvalidISBN is returned when a value cannot be parsed as an ISBN-10 or ISBN-13.
+	ErrInvalidISBN = errors.New("invalid isbn")
+)
+
+// ISBN is an International Standard Book Number. Values created by ParseISBN
+// are always stored in their canonical, unhyphenated ISBN-13 form.
+type ISBN string
+
+// ParseISBN validates the given ISBN-10 or ISBN-13 and returns it normalized to
+// an unhyphenated ISBN-13. Hyphens and spaces are ignored and the check digit
+// must be correct.
+func ParseISBN(s string) (ISBN, error) {
+	digs := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(s))
+
+	switch len(digs) {
+	case 10:
+		if !isDigits(digs[:9]) {
+			return "", fmt.Errorf("%w: %q contains non-digit characters", ErrInvalidISBN, s)
+		}
+		check := strings.ToUpper(digs[9:])
+		if check != isbn10CheckDigit(digs[:9]) {
+			return "", fmt.Errorf("%w: %q has a bad check digit", ErrInvalidISBN, s)
+		}
+		body := "978" + digs[:9]
+		return ISBN(body + isbn13CheckDigit(body)), nil
+	case 13:
+		if !isDigits(digs) {
+			return "", fmt.Errorf("%w: %q contains non-digit characters", ErrInvalidISBN, s)
+		}
+		if prefix := digs[:3]; prefix != "978" && prefix != "979" {
+			return "", fmt.Errorf("%w: %q must begin with 978 or 979", ErrInvalidISBN, s)
+		}
+		if digs[12:] != isbn13CheckDigit(digs[:12]) {
+			return "", fmt.Errorf("%w: %q has a bad check digit", ErrInvalidISBN, s)
+		}
+		return ISBN(digs), nil
+	}
+	return "", fmt.Errorf("%w: %q must have 10 or 13 digits", ErrInvalidISBN, s)
+}
+
+// Valid reports whether the value is a canonical ISBN-13.
+func (i ISBN) Valid() bool {
+	parsed, err := ParseISBN(string(i))
+	return err == nil && parsed == i
+}
+
+// ISBN10 returns the unhyphenated ISBN-10 form of the value. Only ISBN-13s
+// with the 978 prefix have an ISBN-10 equivalent.
+func (i ISBN) ISBN10() (string, bool) {
+	if !i.Valid() || !strings.HasPrefix(string(i), "978") {
+		return "", false
+	}
+	body := string(i[3:12])
+	return body + isbn10CheckDigit(body), true
+}
+
+// String prints out the ISBN value in the proper dash delimited format using the
+// registration group and registrant ranges published by the International ISBN
+// Agency. Values that are not canonical ISBN-13s are returned unchanged, and
+// values from groups whose registrant ranges are unknown are split into prefix,
+// group and the remaining digits.
+//
+//	example: 978-3-16-148410-0
+func (i ISBN) String() string {
+	if !i.Valid() {
+		return string(i)
+	}
+	digs := string(i)
+	prefix, rest, check := digs[:3], digs[3:12], digs[12:]
+
+	groupLen := rangeLength(groupRanges[prefix], rest)
+	if groupLen == 0 {
+		return strings.Join([]string{prefix, rest, check}, "-")
+	}
+	group, rest := rest[:groupLen], rest[groupLen:]
+
+	registrantLen := rangeLength(registrantRanges[prefix+"-"+group], rest)
+	if registrantLen == 0 || registrantLen >= len(rest) {
+		return strings.Join([]string{prefix, group, rest, check}, "-")
+	}
+	return strings.Join([]string{prefix, group, rest[:registrantLen], rest[registrantLen:], check}, "-")
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isbn10CheckDigit(body string) string {
+	sum := 0
+	for i, r := range body {
+		sum += (10 - i) * int(r-'0')
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return "X"
+	}
+	return fmt.Sprint(check)
+}
+
+func isbn13CheckDigit(body string) string {
+	sum := 0
+	for i, r := range body {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += weight * int(r-'0')
+	}
+	return fmt.Sprint((10 - sum%10) % 10)
+}
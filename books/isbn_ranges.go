@@ -0,0 +1,113 @@
+package books
+
+// isbnRange maps the 7 digit window starting at a hyphenation point to the
+// length of the element at that point. A length of 0 means the range has not
+// been assigned.
+type isbnRange struct {
+	min, max string
+	length   int
+}
+
+// rangeLength returns the element length for the given digits, or 0 if they do
+// not fall into any of the ranges.
+func rangeLength(ranges []isbnRange, digs string) int {
+	for len(digs) < 7 {
+		digs += "0"
+	}
+	window := digs[:7]
+	for _, r := range ranges {
+		if window >= r.min && window <= r.max {
+			return r.length
+		}
+	}
+	return 0
+}
+
+// groupRanges are the registration group ranges for each EAN.UCC prefix.
+var groupRanges = map[string][]isbnRange{
+	"978": {
+		{"0000000", "5999999", 1},
+		{"6000000", "6499999", 3},
+		{"6500000", "6599999", 2},
+		{"6600000", "6999999", 3},
+		{"7000000", "7999999", 1},
+		{"8000000", "9499999", 2},
+		{"9500000", "9899999", 3},
+		{"9900000", "9989999", 4},
+		{"9990000", "9999999", 5},
+	},
+	"979": {
+		{"0000000", "0999999", 0},
+		{"1000000", "1299999", 2},
+		{"1300000", "7999999", 0},
+		{"8000000", "8999999", 1},
+		{"9000000", "9999999", 0},
+	},
+}
+
+// registrantRanges are the registrant ranges for the most common registration
+// groups, keyed by prefix and group.
+var registrantRanges = map[string][]isbnRange{
+	// English language
+	"978-0": {
+		{"0000000", "1999999", 2},
+		{"2000000", "6999999", 3},
+		{"7000000", "8499999", 4},
+		{"8500000", "8999999", 5},
+		{"9000000", "9499999", 6},
+		{"9500000", "9999999", 7},
+	},
+	// English language
+	"978-1": {
+		{"0000000", "0999999", 2},
+		{"1000000", "3999999", 3},
+		{"4000000", "5499999", 4},
+		{"5500000", "8697999", 5},
+		{"8698000", "9989999", 6},
+		{"9990000", "9999999", 7},
+	},
+	// French language
+	"978-2": {
+		{"0000000", "1999999", 2},
+		{"2000000", "3499999", 3},
+		{"3500000", "3999999", 5},
+		{"4000000", "6999999", 3},
+		{"7000000", "8399999", 4},
+		{"8400000", "8999999", 5},
+		{"9000000", "9499999", 6},
+		{"9500000", "9999999", 7},
+	},
+	// German language
+	"978-3": {
+		{"0000000", "0299999", 2},
+		{"0300000", "0339999", 3},
+		{"0340000", "0369999", 4},
+		{"0370000", "0399999", 5},
+		{"0400000", "1999999", 2},
+		{"2000000", "6999999", 3},
+		{"7000000", "8499999", 4},
+		{"8500000", "8999999", 5},
+		{"9000000", "9499999", 6},
+		{"9500000", "9539999", 7},
+		{"9540000", "9699999", 5},
+		{"9700000", "9849999", 7},
+		{"9850000", "9999999", 5},
+	},
+	// Japan
+	"978-4": {
+		{"0000000", "1999999", 2},
+		{"2000000", "6999999", 3},
+		{"7000000", "8499999", 4},
+		{"8500000", "8999999", 5},
+		{"9000000", "9499999", 6},
+		{"9500000", "9999999", 7},
+	},
+	// France
+	"979-10": {
+		{"0000000", "1999999", 2},
+		{"2000000", "6999999", 3},
+		{"7000000", "8999999", 4},
+		{"9000000", "9759999", 5},
+		{"9760000", "9999999", 6},
+	},
+}
@@ -0,0 +1,80 @@
+package books_test
+
+import (
+	"errors"
+	"testing"
+
+	"bookshop/books"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestISBN(t *testing.T) {
+	t.Run("ParseISBN", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			cases := map[string]books.ISBN{
+				"9783161484100":     "9783161484100",
+				"978-3-16-148410-0": "9783161484100",
+				"978 3 16 148410 0": "9783161484100",
+				"0306406152":        "9780306406157",
+				"0-306-40615-2":     "9780306406157",
+				"080442957X":        "9780804429573",
+				"080442957x":        "9780804429573",
+				"979-10-323-0082-4": "9791032300824",
+			}
+			for in, want := range cases {
+				got, err := books.ParseISBN(in)
+				require.NoError(t, err, in)
+				assert.Equal(t, want, got, in)
+			}
+		})
+
+		t.Run("invalid", func(t *testing.T) {
+			for _, in := range []string{
+				"",
+				"999999999",
+				"9783161484101",
+				"0306406153",
+				"4444444444444",
+				"97831614841OO",
+				"03064061X2",
+			} {
+				_, err := books.ParseISBN(in)
+				assert.True(t, errors.Is(err, books.ErrInvalidISBN), in)
+			}
+		})
+	})
+
+	t.Run("ISBN10", func(t *testing.T) {
+		isbn10, ok := books.ISBN("9780306406157").ISBN10()
+		assert.True(t, ok)
+		assert.Equal(t, "0306406152", isbn10)
+
+		isbn10, ok = books.ISBN("9780804429573").ISBN10()
+		assert.True(t, ok)
+		assert.Equal(t, "080442957X", isbn10)
+
+		_, ok = books.ISBN("9791032300824").ISBN10()
+		assert.False(t, ok)
+	})
+
+	t.Run("String", func(t *testing.T) {
+		cases := map[books.ISBN]string{
+			"9783161484100": "978-3-16-148410-0",
+			"9780306406157": "978-0-306-40615-7",
+			"9781861972712": "978-1-86197-271-2",
+			"9782070360024": "978-2-07-036002-4",
+			"9784873113685": "978-4-87311-368-5",
+			"9791032300824": "979-10-323-0082-4",
+			// registrant ranges for group 80 are not known
+			"9788070000007": "978-80-7000000-7",
+			// values that are not ISBNs are left untouched
+			"12345":         "12345",
+			"4444444444444": "4444444444444",
+		}
+		for in, want := range cases {
+			assert.Equal(t, want, in.String(), string(in))
+		}
+	})
+}
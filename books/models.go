@@ -1,18 +1,16 @@
 package books
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"strings"
 	"time"
-)
-
-type ISBN string
+	"unicode/utf8"
 
-// String prints out the ISBN value in the proper dash delimited format.
-//   example: 978-3-16-148410-0
-func (i ISBN) String() string {
-	digs := []byte(i)
-	return fmt.Sprintf("%s-%s-%s-%s-%s", digs[0:3], digs[3:4], digs[4:6], digs[6:12], digs[len(digs)-1:])
-}
+	"bookshop/money"
+)
 
 // Book is the model representing an book row in the datastore.
 type Book struct {
@@ -21,4 +19,271 @@ type Book struct {
 	ISBN      ISBN       `db:"isbn" json:"isbn,omitempty"`
 	CreatedAt *time.Time `db:"created_at" json:"created_at,omitempty"`
 	UpdatedAt *time.Time `db:"updated_at" json:"updated_at,omitempty"`
+	Details
+
+	// ListPrice is the price in effect, nil for books that have not been priced.
+	ListPrice *money.Money `db:"-" json:"list_price,omitempty"`
+	// Rating sums up the book's approved reviews. It is only filled in when the
+	// book itself is read, not for the books listed under an author, publisher
+	// or category.
+	Rating *Rating `db:"-" json:"rating,omitempty"`
+
+	Authors    []BookAuthor   `json:"authors,omitempty"`
+	Publisher  *BookPublisher `db:"-" json:"publisher,omitempty"`
+	Categories []BookCategory `json:"categories,omitempty"`
+	Tags       []string       `json:"tags,omitempty"`
+}
+
+// Details is the bibliographic information describing a book beyond its title
+// and ISBN. Every field is optional.
+type Details struct {
+	Subtitle    string      `db:"subtitle" json:"subtitle,omitempty"`
+	Description string      `db:"description" json:"description,omitempty"`
+	PublisherID string      `db:"-" json:"-"`
+	PublishedOn *time.Time  `db:"published_on" json:"published_on,omitempty"`
+	Language    string      `db:"language" json:"language,omitempty"`
+	PageCount   int         `db:"page_count" json:"page_count,omitempty"`
+	Format      Format      `db:"format" json:"format,omitempty"`
+	Edition     string      `db:"edition" json:"edition,omitempty"`
+	Dimensions  *Dimensions `db:"-" json:"dimensions,omitempty"`
+}
+
+// Limits on the lengths and sizes of a book's details.
+const (
+	MaxSubtitleLength    = 200
+	MaxDescriptionLength = 5000
+	MaxEditionLength     = 50
+	MaxPageCount         = 100000
+	MaxDimension         = 1000
+)
+
+// Dimensions is the size of a printed book in millimetres.
+type Dimensions struct {
+	Height int `json:"height_mm"`
+	Width  int `json:"width_mm"`
+	Depth  int `json:"depth_mm"`
+}
+
+// Validate returns an error unless every dimension is between 1 and MaxDimension.
+func (d Dimensions) Validate() error {
+	for _, v := range []int{d.Height, d.Width, d.Depth} {
+		if v < 1 || v > MaxDimension {
+			return fmt.Errorf("dimensions must be between 1 and %dmm", MaxDimension)
+		}
+	}
+	return nil
+}
+
+// Patch holds changes to a book's title, ISBN, details and list price. Fields
+// left nil are unchanged.
+type Patch struct {
+	Title       *string      `json:"title"`
+	ISBN        *ISBN        `json:"isbn"`
+	ListPrice   *money.Money `json:"list_price"`
+	Subtitle    *string      `json:"subtitle"`
+	Description *string      `json:"description"`
+	PublisherID *string      `json:"publisher_id"`
+	PublishedOn *time.Time   `json:"published_on"`
+	Language    *string      `json:"language"`
+	PageCount   *int         `json:"page_count"`
+	Format      *Format      `json:"format"`
+	Edition     *string      `json:"edition"`
+	Dimensions  *Dimensions  `json:"dimensions"`
+}
+
+// Apply returns the book with the changes in the patch made.
+func (p Patch) Apply(b Book) Book {
+	if p.Title != nil {
+		b.Title = *p.Title
+	}
+	if p.ISBN != nil {
+		b.ISBN = *p.ISBN
+	}
+	if p.ListPrice != nil {
+		b.ListPrice = p.ListPrice
+	}
+	if p.Subtitle != nil {
+		b.Subtitle = *p.Subtitle
+	}
+	if p.Description != nil {
+		b.Description = *p.Description
+	}
+	if p.PublisherID != nil {
+		b.PublisherID = *p.PublisherID
+	}
+	if p.PublishedOn != nil {
+		b.PublishedOn = p.PublishedOn
+	}
+	if p.Language != nil {
+		b.Language = *p.Language
+	}
+	if p.PageCount != nil {
+		b.PageCount = *p.PageCount
+	}
+	if p.Format != nil {
+		b.Format = *p.Format
+	}
+	if p.Edition != nil {
+		b.Edition = *p.Edition
+	}
+	if p.Dimensions != nil {
+		b.Dimensions = p.Dimensions
+	}
+	return b
+}
+
+// UnmarshalJSON is a custom unmarshaler that reads the publication date in the
+// "2006-01-02" format.
+func (p *Patch) UnmarshalJSON(b []byte) error {
+	type patch Patch
+	var out struct {
+		patch
+		PublishedOn *string `json:"published_on"`
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return err
+	}
+	*p = Patch(out.patch)
+	if out.PublishedOn != nil {
+		t, err := time.Parse("2006-01-02", *out.PublishedOn)
+		if err != nil {
+			return errors.New("published_on must be a date such as 2006-01-02")
+		}
+		p.PublishedOn = &t
+	}
+	return nil
+}
+
+// Format is the form a book is published in.
+type Format string
+
+// Formats a book may be published in.
+const (
+	FormatHardcover Format = "hardcover"
+	FormatPaperback Format = "paperback"
+	FormatEbook     Format = "ebook"
+	FormatAudiobook Format = "audiobook"
+)
+
+// ParseFormat returns the format named by s, ignoring case and surrounding spaces.
+func ParseFormat(s string) (Format, error) {
+	f := Format(strings.ToLower(strings.TrimSpace(s)))
+	switch f {
+	case FormatHardcover, FormatPaperback, FormatEbook, FormatAudiobook:
+		return f, nil
+	}
+	return "", fmt.Errorf("format must be one of %s, %s, %s or %s",
+		FormatHardcover, FormatPaperback, FormatEbook, FormatAudiobook)
+}
+
+// ParseLanguage returns the given ISO 639-1 language code in lower case, such
+// as "en" or "fr".
+func ParseLanguage(s string) (string, error) {
+	lang := strings.ToLower(strings.TrimSpace(s))
+	if len(lang) != 2 || lang[0] < 'a' || lang[0] > 'z' || lang[1] < 'a' || lang[1] > 'z' {
+		return "", errors.New("language must be a two letter ISO 639-1 code")
+	}
+	return lang, nil
+}
+
+// Price is a list price of a book and the time it takes effect. A book's
+// prices are kept so that past and scheduled prices can be looked up.
+type Price struct {
+	ListPrice     money.Money `json:"list_price"`
+	EffectiveFrom time.Time   `json:"effective_from"`
+}
+
+// Rating is the average star rating of a book's approved reviews, to two
+// decimal places, and how many there are. Both are zero for unreviewed books.
+type Rating struct {
+	Average float64 `json:"average"`
+	Count   int     `json:"count"`
+}
+
+// NewRating returns the rating of a book whose approved reviews' stars add up to
+// total over count reviews.
+func NewRating(total float64, count int) Rating {
+	if count == 0 {
+		return Rating{}
+	}
+	return Rating{Average: math.Round(total/float64(count)*100) / 100, Count: count}
+}
+
+// BookAuthor is the model representing an author credited on a book, along
+// with the part they played and where they come in the book's credits.
+type BookAuthor struct {
+	ID         string `db:"id" json:"id,omitempty"`
+	FirstName  string `db:"first_name" json:"first_name,omitempty"`
+	MiddleName string `db:"middle_name" json:"middle_name,omitempty"`
+	LastName   string `db:"last_name" json:"last_name,omitempty"`
+	Role       Role   `db:"role" json:"role,omitempty"`
+	Sequence   int    `db:"sequence" json:"sequence,omitempty"`
+}
+
+// Role is the part an author played in making a book.
+type Role string
+
+// Roles an author may be credited with on a book.
+const (
+	RoleAuthor      Role = "author"
+	RoleTranslator  Role = "translator"
+	RoleIllustrator Role = "illustrator"
+	RoleEditor      Role = "editor"
+	RoleNarrator    Role = "narrator"
+)
+
+// MaxSequence is the highest sequence number an author can be credited at.
+const MaxSequence = 1000
+
+// ParseRole returns the role named by s, ignoring case and surrounding spaces.
+func ParseRole(s string) (Role, error) {
+	r := Role(strings.ToLower(strings.TrimSpace(s)))
+	switch r {
+	case RoleAuthor, RoleTranslator, RoleIllustrator, RoleEditor, RoleNarrator:
+		return r, nil
+	}
+	return "", fmt.Errorf("role must be one of %s, %s, %s, %s or %s",
+		RoleAuthor, RoleTranslator, RoleIllustrator, RoleEditor, RoleNarrator)
+}
+
+// Credit credits an author on a book in a role. Credits are listed in order of
+// their sequence numbers, which start at 1.
+type Credit struct {
+	AuthorID string `db:"author_id" json:"author_id"`
+	Role     Role   `db:"role" json:"role"`
+	Sequence int    `db:"sequence" json:"sequence"`
+}
+
+// BookPublisher is the model representing the publisher of a book.
+type BookPublisher struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// BookCategory is the model representing a category a book is filed under.
+type BookCategory struct {
+	ID   string `db:"id" json:"id"`
+	Name string `db:"name" json:"name"`
+}
+
+// MaxTagLength is the most characters a tag may have.
+const MaxTagLength = 50
+
+// Tag is a free-form label given to books along with how many books have it.
+type Tag struct {
+	Name  string `db:"tag" json:"name"`
+	Books int    `db:"books" json:"books"`
+}
+
+// ParseTag returns the tag in the form it is stored: in lower case with runs of
+// spaces collapsed.
+func ParseTag(s string) (string, error) {
+	tag := strings.ToLower(strings.Join(strings.Fields(s), " "))
+	if tag == "" {
+		return "", errors.New("tag cannot be blank")
+	}
+	if utf8.RuneCountInString(tag) > MaxTagLength {
+		return "", fmt.Errorf("tag must be at most %d characters long", MaxTagLength)
+	}
+	return tag, nil
 }
@@ -0,0 +1,108 @@
+package books
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"bookshop/datastore"
+)
+
+// SortField is a field that book listings can be ordered by.
+type SortField string
+
+const (
+	SortByTitle     SortField = "title"
+	SortByISBN      SortField = "isbn"
+	SortByUpdatedAt SortField = "updated_at"
+)
+
+// BookQuery holds the filters, sort order and page position for listing books.
+// The zero value lists the first page of all books sorted by title (ascending).
+type BookQuery struct {
+	// TitlePrefix limits results to titles starting with the value (case insensitive).
+	TitlePrefix string
+	// ISBNPrefix limits results to ISBNs starting with the value, ignoring hyphens and spaces.
+	ISBNPrefix string
+	// AuthorLastName limits results to books credited to an author with the
+	// given last name (case insensitive).
+	AuthorLastName string
+	// InStock limits results to books with copies available.
+	InStock bool
+	// Category limits results to books filed under the category with the
+	// given ID or any category beneath it.
+	Category string
+	// Tag limits results to books with the given tag.
+	Tag string
+
+	SortBy     SortField
+	Descending bool
+
+	// Limit is the page size, defaulting to datastore.DefaultLimit.
+	Limit int
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+}
+
+// Page is a single page of books along with the cursor for the following page.
+// NextCursor is blank on the last page.
+type Page struct {
+	Books      []Book `json:"books"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// WithDefaults returns a copy of the query with the default sort field and
+// limit filled in.
+func (q BookQuery) WithDefaults() BookQuery {
+	if q.SortBy == "" {
+		q.SortBy = SortByTitle
+	}
+	if q.Limit == 0 {
+		q.Limit = datastore.DefaultLimit
+	}
+	q.ISBNPrefix = strings.NewReplacer("-", "", " ", "").Replace(q.ISBNPrefix)
+	if tag, err := ParseTag(q.Tag); err == nil {
+		q.Tag = tag
+	}
+	return q
+}
+
+// Validate checks the query for unknown sort fields, tags that are too long,
+// out of range limits and cursors that were not issued for its sort order.
+func (q BookQuery) Validate() error {
+	q = q.WithDefaults()
+	switch q.SortBy {
+	case SortByTitle, SortByISBN, SortByUpdatedAt:
+	default:
+		return fmt.Errorf("unknown sort field %q", q.SortBy)
+	}
+	if q.Tag != "" {
+		if _, err := ParseTag(q.Tag); err != nil {
+			return err
+		}
+	}
+	if q.Limit < 1 || q.Limit > datastore.MaxLimit {
+		return fmt.Errorf("limit must be between 1 and %d", datastore.MaxLimit)
+	}
+	if q.Cursor != "" {
+		if _, err := datastore.DecodeCursor(q.Cursor, string(q.SortBy), q.Descending); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SortValue returns the value of the given sort field for the book, as stored
+// in pagination cursors.
+func (b Book) SortValue(field SortField) string {
+	switch field {
+	case SortByISBN:
+		return string(b.ISBN)
+	case SortByUpdatedAt:
+		if b.UpdatedAt == nil {
+			return ""
+		}
+		return b.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return b.Title
+}
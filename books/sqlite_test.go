@@ -0,0 +1,301 @@
+package books_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"bookshop/authors"
+	"bookshop/books"
+	"bookshop/datastore"
+	"bookshop/datastore/dbtest"
+	"bookshop/money"
+	"bookshop/publishers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBooksSQLite(t *testing.T) {
+	ctx := context.Background()
+	newStore := func(t *testing.T) books.BookStore {
+		dbh := dbtest.SQLite(t)
+
+		dt, err := time.Parse(authors.DateParsingFormat, "1920-01-02")
+		require.NoError(t, err)
+		as := authors.NewAuthorStore(dbh)
+		err = as.UpsertAuthors(ctx, []authors.Author{
+			{ID: "auth01", FirstName: "Frank", LastName: "Herbert", DOB: &dt},
+			{ID: "auth02", FirstName: "John", LastName: "Schoenherr", DOB: &dt},
+		})
+		require.NoError(t, err)
+
+		ps := publishers.NewPublisherStore(dbh)
+		err = ps.UpsertPublishers(ctx, []publishers.Publisher{{ID: "pub01", Name: "Chilton Books"}})
+		require.NoError(t, err)
+
+		store := books.NewBookStore(dbh)
+		err = store.UpsertBooks(ctx, []books.Book{
+			{ID: "abc01", Title: "titleA", ISBN: "9783161484100"},
+			{ID: "def02", Title: "Dune Messiah", ISBN: "9780306406157"},
+			{ID: "ghi03", Title: "Dune", ISBN: "9781861972712"},
+		})
+		require.NoError(t, err)
+		require.NoError(t, store.LinkBookAuthors(ctx, "def02", books.Credit{AuthorID: "auth01", Role: books.RoleAuthor, Sequence: 1}))
+		require.NoError(t, store.LinkBookAuthors(ctx, "ghi03", books.Credit{AuthorID: "auth01", Role: books.RoleAuthor, Sequence: 1}))
+		return store
+	}
+
+	t.Run("ReadBooks", func(t *testing.T) {
+		store := newStore(t)
+
+		page, err := store.ReadBooks(ctx, books.BookQuery{Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, page.Books, 2)
+		assert.Equal(t, "Dune", page.Books[0].Title)
+		assert.Equal(t, "Dune Messiah", page.Books[1].Title)
+		assert.NotNil(t, page.Books[0].UpdatedAt)
+
+		page, err = store.ReadBooks(ctx, books.BookQuery{Limit: 2, Cursor: page.NextCursor})
+		require.NoError(t, err)
+		require.Len(t, page.Books, 1)
+		assert.Equal(t, "titleA", page.Books[0].Title)
+		assert.Empty(t, page.NextCursor)
+
+		page, err = store.ReadBooks(ctx, books.BookQuery{TitlePrefix: "DUNE m"})
+		require.NoError(t, err)
+		require.Len(t, page.Books, 1)
+		assert.Equal(t, "def02", page.Books[0].ID)
+
+		page, err = store.ReadBooks(ctx, books.BookQuery{AuthorLastName: "herbert", SortBy: books.SortByISBN, Descending: true})
+		require.NoError(t, err)
+		require.Len(t, page.Books, 2)
+		assert.Equal(t, "ghi03", page.Books[0].ID)
+
+		first, err := store.ReadBooks(ctx, books.BookQuery{SortBy: books.SortByUpdatedAt, Limit: 1})
+		require.NoError(t, err)
+		rest, err := store.ReadBooks(ctx, books.BookQuery{SortBy: books.SortByUpdatedAt, Cursor: first.NextCursor})
+		require.NoError(t, err)
+		assert.Len(t, rest.Books, 2)
+	})
+
+	t.Run("ReadBookByID", func(t *testing.T) {
+		store := newStore(t)
+
+		bk, err := store.ReadBookByID(ctx, "ghi03")
+		require.NoError(t, err)
+		assert.Equal(t, "Dune", bk.Title)
+		require.Len(t, bk.Authors, 1)
+		assert.Equal(t, "Herbert", bk.Authors[0].LastName)
+
+		_, err = store.ReadBookByID(ctx, "unknown")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("Upsert", func(t *testing.T) {
+		store := newStore(t)
+
+		err := store.UpsertBooks(ctx, []books.Book{
+			{ID: "abc01", Title: "titleA updated", ISBN: "9783161484100"},
+		})
+		require.NoError(t, err)
+		bk, err := store.ReadBookByISBN(ctx, "9783161484100")
+		require.NoError(t, err)
+		assert.Equal(t, "titleA updated", bk.Title)
+
+		err = store.UpsertBooks(ctx, []books.Book{
+			{ID: "zzz00", Title: "titleZ", ISBN: "9783161484100"},
+		})
+		assert.True(t, errors.Is(err, datastore.ErrDuplicate))
+	})
+
+	t.Run("details", func(t *testing.T) {
+		store := newStore(t)
+
+		on := time.Date(1965, 8, 1, 0, 0, 0, 0, time.UTC)
+		details := books.Details{
+			Subtitle:    "Book One",
+			Description: "Set on the desert planet Arrakis.",
+			PublishedOn: &on,
+			Language:    "en",
+			PageCount:   412,
+			Format:      books.FormatHardcover,
+			Edition:     "First edition",
+			Dimensions:  &books.Dimensions{Height: 216, Width: 140, Depth: 38},
+		}
+		err := store.UpsertBooks(ctx, []books.Book{
+			{ID: "ghi03", Title: "Dune", ISBN: "9781861972712", Details: details},
+		})
+		require.NoError(t, err)
+
+		bk, err := store.ReadBookByID(ctx, "ghi03")
+		require.NoError(t, err)
+		require.NotNil(t, bk.PublishedOn)
+		assert.True(t, on.Equal(*bk.PublishedOn))
+		bk.PublishedOn = &on
+		assert.Equal(t, details, bk.Details)
+
+		page, err := store.ReadBooks(ctx, books.BookQuery{TitlePrefix: "dune"})
+		require.NoError(t, err)
+		require.Len(t, page.Books, 2)
+		assert.Equal(t, details.Dimensions, page.Books[0].Dimensions)
+		assert.Nil(t, page.Books[1].Dimensions)
+		assert.Nil(t, page.Books[1].PublishedOn)
+
+		err = store.UpsertBooks(ctx, []books.Book{
+			{ID: "ghi03", Title: "Dune", ISBN: "9781861972712"},
+		})
+		require.NoError(t, err)
+		bk, err = store.ReadBookByID(ctx, "ghi03")
+		require.NoError(t, err)
+		assert.Equal(t, books.Details{}, bk.Details)
+	})
+
+	t.Run("publisher", func(t *testing.T) {
+		store := newStore(t)
+		err := store.UpsertBooks(ctx, []books.Book{
+			{ID: "ghi03", Title: "Dune", ISBN: "9781861972712", Details: books.Details{PublisherID: "pub01"}},
+		})
+		require.NoError(t, err)
+		bk, err := store.ReadBookByID(ctx, "ghi03")
+		require.NoError(t, err)
+		assert.Equal(t, &books.BookPublisher{ID: "pub01", Name: "Chilton Books"}, bk.Publisher)
+		assert.Equal(t, "pub01", bk.PublisherID)
+
+		err = store.UpsertBooks(ctx, []books.Book{
+			{ID: "ghi03", Title: "Dune", ISBN: "9781861972712", Details: books.Details{PublisherID: "nope"}},
+		})
+		assert.True(t, errors.Is(err, datastore.ErrMissingReference))
+		bk, err = store.ReadBookByID(ctx, "ghi03")
+		require.NoError(t, err)
+		assert.NotNil(t, bk.Publisher, "a failed upsert leaves the publisher alone")
+
+		err = store.UpsertBooks(ctx, []books.Book{
+			{ID: "ghi03", Title: "Dune", ISBN: "9781861972712"},
+		})
+		require.NoError(t, err)
+		bk, err = store.ReadBookByID(ctx, "ghi03")
+		require.NoError(t, err)
+		assert.Nil(t, bk.Publisher)
+	})
+
+	t.Run("LinkBookAuthors", func(t *testing.T) {
+		store := newStore(t)
+
+		err := store.LinkBookAuthors(ctx, "abc01", books.Credit{AuthorID: "unknown", Role: books.RoleAuthor, Sequence: 1})
+		assert.True(t, errors.Is(err, datastore.ErrMissingReference))
+
+		require.NoError(t, store.UnlinkBookAuthor(ctx, "ghi03", "auth01", ""))
+		err = store.UnlinkBookAuthor(ctx, "ghi03", "auth01", "")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("credits in order", func(t *testing.T) {
+		store := newStore(t)
+
+		// relinking a credit moves it
+		err := store.LinkBookAuthors(ctx, "ghi03",
+			books.Credit{AuthorID: "auth01", Role: books.RoleAuthor, Sequence: 2},
+			books.Credit{AuthorID: "auth01", Role: books.RoleIllustrator, Sequence: 3},
+			books.Credit{AuthorID: "auth02", Role: books.RoleIllustrator, Sequence: 1},
+		)
+		require.NoError(t, err)
+
+		bk, err := store.ReadBookByID(ctx, "ghi03")
+		require.NoError(t, err)
+		require.Len(t, bk.Authors, 3)
+		assert.Equal(t, books.BookAuthor{ID: "auth02", FirstName: "John", LastName: "Schoenherr", Role: books.RoleIllustrator, Sequence: 1}, bk.Authors[0])
+		assert.Equal(t, books.RoleAuthor, bk.Authors[1].Role)
+		assert.Equal(t, 2, bk.Authors[1].Sequence)
+		assert.Equal(t, books.RoleIllustrator, bk.Authors[2].Role)
+
+		require.NoError(t, store.UnlinkBookAuthor(ctx, "ghi03", "auth01", books.RoleIllustrator))
+		err = store.UnlinkBookAuthor(ctx, "ghi03", "auth01", books.RoleIllustrator)
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+		bk, err = store.ReadBookByID(ctx, "ghi03")
+		require.NoError(t, err)
+		assert.Len(t, bk.Authors, 2)
+	})
+
+	t.Run("DeleteBooks", func(t *testing.T) {
+		store := newStore(t)
+
+		require.NoError(t, store.DeleteBooks(ctx, "def02", "unknown"))
+		err := store.DeleteBooks(ctx, "def02")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+
+		results, err := store.SearchBooks(ctx, "messiah", 10)
+		require.NoError(t, err)
+		assert.Empty(t, results)
+	})
+
+	t.Run("SearchBooks", func(t *testing.T) {
+		store := newStore(t)
+
+		results, err := store.SearchBooks(ctx, "DUNE", 10)
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, "ghi03", results[0].ID)
+		assert.Equal(t, "<mark>Dune</mark>", results[0].Snippet)
+		assert.Equal(t, "<mark>Dune</mark> Messiah", results[1].Snippet)
+
+		results, err = store.SearchBooks(ctx, "dune messiah", 10)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+
+		// markup in titles is escaped in snippets
+		err = store.UpsertBooks(ctx, []books.Book{{ID: "abc01", Title: `<img src=x onerror="alert(1)"> Sandworms`, ISBN: "9783161484100"}})
+		require.NoError(t, err)
+		results, err = store.SearchBooks(ctx, "sandworms", 10)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, `<img src=x onerror="alert(1)"> Sandworms`, results[0].Title)
+		assert.Equal(t, `&lt;img src=x onerror=&#34;alert(1)&#34;&gt; <mark>Sandworms</mark>`, results[0].Snippet)
+	})
+
+	t.Run("prices", func(t *testing.T) {
+		store := newStore(t)
+
+		bk, err := store.ReadBookByID(ctx, "ghi03")
+		require.NoError(t, err)
+		assert.Nil(t, bk.ListPrice)
+
+		now := time.Now()
+		for _, p := range []books.Price{
+			{ListPrice: money.New(1099, money.USD), EffectiveFrom: now.Add(-48 * time.Hour)},
+			{ListPrice: money.New(1299, money.USD), EffectiveFrom: now.Add(-time.Hour)},
+			{ListPrice: money.New(1499, money.USD), EffectiveFrom: now.Add(24 * time.Hour)},
+		} {
+			require.NoError(t, store.AddBookPrice(ctx, "ghi03", p))
+		}
+
+		// the scheduled price has not taken effect yet
+		bk, err = store.ReadBookByID(ctx, "ghi03")
+		require.NoError(t, err)
+		require.NotNil(t, bk.ListPrice)
+		assert.Equal(t, money.New(1299, money.USD), *bk.ListPrice)
+
+		bk, err = store.ReadBookByISBN(ctx, "9781861972712")
+		require.NoError(t, err)
+		assert.Equal(t, money.New(1299, money.USD), *bk.ListPrice)
+
+		page, err := store.ReadBooks(ctx, books.BookQuery{TitlePrefix: "dune"})
+		require.NoError(t, err)
+		require.Len(t, page.Books, 2)
+		assert.Equal(t, money.New(1299, money.USD), *page.Books[0].ListPrice)
+		assert.Nil(t, page.Books[1].ListPrice)
+
+		prices, err := store.ReadBookPrices(ctx, "ghi03")
+		require.NoError(t, err)
+		require.Len(t, prices, 3)
+		assert.Equal(t, int64(1099), prices[0].ListPrice.Amount)
+		assert.Equal(t, int64(1499), prices[2].ListPrice.Amount)
+		assert.WithinDuration(t, now.Add(24*time.Hour), prices[2].EffectiveFrom, time.Second)
+
+		_, err = store.ReadBookPrices(ctx, "unknown")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+		err = store.AddBookPrice(ctx, "unknown", books.Price{ListPrice: money.New(1, money.USD), EffectiveFrom: now})
+		assert.True(t, errors.Is(err, datastore.ErrMissingReference))
+	})
+}
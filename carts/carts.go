@@ -0,0 +1,156 @@
+package carts
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"bookshop/datastore"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type CartStore struct {
+	db *sqlx.DB
+}
+
+func NewCartStore(db *sqlx.DB) CartStore {
+	return CartStore{db: db}
+}
+
+// CreateCart will add the given cart without any items, returning
+// datastore.ErrDuplicate if its token is already in use.
+func (s *CartStore) CreateCart(ctx context.Context, c Cart) error {
+	const sqlSt = `INSERT INTO carts (token, created_at, updated_at, expires_at) VALUES (?,?,?,?)`
+	now := time.Now()
+	if _, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind(sqlSt), c.Token, now, now, c.ExpiresAt); err != nil {
+		if datastore.IsUniqueViolation(err) {
+			return datastore.ErrDuplicate
+		}
+		return errors.Wrap(err, "failed creating cart")
+	}
+	return nil
+}
+
+// ReadCart will return the cart with the given token along with its items,
+// returning datastore.ErrNotFound if it does not exist or has expired.
+func (s *CartStore) ReadCart(ctx context.Context, token string) (Cart, error) {
+	sqlSt := `SELECT * FROM carts WHERE token = ? AND ` +
+		datastore.Timestamp(s.db, "expires_at") + ` > ` + datastore.Timestamp(s.db, "?")
+	c := Cart{}
+	if err := datastore.Conn(ctx, s.db).GetContext(ctx, &c, s.db.Rebind(sqlSt), token, time.Now()); err != nil {
+		if err == sql.ErrNoRows {
+			return Cart{}, datastore.ErrNotFound
+		}
+		return Cart{}, errors.Wrap(err, "failed to read cart")
+	}
+
+	c.Items = []Item{}
+	sqlSt = `SELECT book_id, quantity, added_at FROM cart_items
+		WHERE cart_token = ?
+		ORDER BY ` + datastore.Timestamp(s.db, "added_at") + ` ASC, book_id ASC`
+	if err := datastore.Conn(ctx, s.db).SelectContext(ctx, &c.Items, s.db.Rebind(sqlSt), token); err != nil {
+		return Cart{}, errors.Wrap(err, "failed to read cart items")
+	}
+	return c, nil
+}
+
+// AddCartItems will add the items to the cart, adding their quantities to those
+// of books already in it. It returns datastore.ErrMissingReference if the cart or
+// any of the books does not exist, and datastore.ErrCheckViolation if a book's
+// quantity would go over MaxQuantity.
+func (s *CartStore) AddCartItems(ctx context.Context, token string, items ...Item) error {
+	return s.upsertItems(ctx, token, items, "cart_items.quantity + EXCLUDED.quantity")
+}
+
+// SetCartItem will put the item in the cart, replacing the quantity of the book
+// if it is already in it. It returns the same errors as AddCartItems.
+func (s *CartStore) SetCartItem(ctx context.Context, token string, item Item) error {
+	return s.upsertItems(ctx, token, []Item{item}, "EXCLUDED.quantity")
+}
+
+func (s *CartStore) upsertItems(ctx context.Context, token string, items []Item, quantity string) error {
+	if len(items) == 0 {
+		return errors.New("no cart items to upsert")
+	}
+	const sqlSetPre = `INSERT INTO cart_items (cart_token, book_id, quantity, added_at) VALUES `
+	sqlSetPost := ` ON CONFLICT(cart_token, book_id) DO
+	UPDATE SET quantity = ` + quantity
+	const sqlValues = `(?,?,?,?)`
+
+	var qryRows []string
+	var qryArgs []interface{}
+	for _, item := range items {
+		qryRows = append(qryRows, sqlValues)
+		qryArgs = append(qryArgs, token, item.BookID, item.Quantity, time.Now())
+	}
+	joinedQuery := sqlSetPre + strings.Join(qryRows, ",") + sqlSetPost
+
+	if _, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind(joinedQuery), qryArgs...); err != nil {
+		if datastore.IsForeignKeyViolation(err) {
+			return datastore.ErrMissingReference
+		}
+		if datastore.IsCheckViolation(err) {
+			return datastore.ErrCheckViolation
+		}
+		return errors.Wrap(err, "failed upserting cart items")
+	}
+	return nil
+}
+
+// DeleteCartItem will take the book out of the cart, returning
+// datastore.ErrNotFound if it was not in it.
+func (s *CartStore) DeleteCartItem(ctx context.Context, token, bookID string) error {
+	res, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind("DELETE FROM cart_items WHERE cart_token=? AND book_id=?"), token, bookID)
+	if err != nil {
+		return errors.Wrap(err, "failed deleting cart item")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return datastore.ErrNotFound
+	}
+	return nil
+}
+
+// TouchCart will mark the cart as changed and keep it until the given expiry,
+// returning datastore.ErrNotFound if it does not exist.
+func (s *CartStore) TouchCart(ctx context.Context, token string, expiresAt time.Time) error {
+	const sqlSt = `UPDATE carts SET updated_at = ?, expires_at = ? WHERE token = ?`
+	res, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind(sqlSt), time.Now(), expiresAt, token)
+	if err != nil {
+		return errors.Wrap(err, "failed updating cart")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return datastore.ErrNotFound
+	}
+	return nil
+}
+
+// DeleteCart will delete the cart and its items, returning datastore.ErrNotFound
+// if it does not exist.
+func (s *CartStore) DeleteCart(ctx context.Context, token string) error {
+	res, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind("DELETE FROM carts WHERE token=?"), token)
+	if err != nil {
+		return errors.Wrap(err, "failed deleting cart")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return datastore.ErrNotFound
+	}
+	return nil
+}
+
+// DeleteExpiredCarts will delete the carts that expired before the given time
+// along with their items, returning how many were deleted.
+func (s *CartStore) DeleteExpiredCarts(ctx context.Context, before time.Time) (int64, error) {
+	sqlSt := `DELETE FROM carts WHERE ` + datastore.Timestamp(s.db, "expires_at") + ` <= ` + datastore.Timestamp(s.db, "?")
+	res, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind(sqlSt), before)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed deleting expired carts")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed deleting expired carts")
+	}
+	return n, nil
+}
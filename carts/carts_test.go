@@ -0,0 +1,108 @@
+package carts_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"bookshop/books"
+	"bookshop/carts"
+	"bookshop/datastore"
+	"bookshop/datastore/dbtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCarts(t *testing.T) {
+	ctx := context.Background()
+	newStores := func(t *testing.T) (carts.CartStore, books.BookStore) {
+		dbh := dbtest.Open(t)
+
+		bs := books.NewBookStore(dbh)
+		err := bs.UpsertBooks(ctx, []books.Book{
+			{ID: "abc01", Title: "titleA", ISBN: "9783161484100"},
+			{ID: "def02", Title: "Dune", ISBN: "9781861972712"},
+		})
+		require.NoError(t, err)
+
+		store := carts.NewCartStore(dbh)
+		expires := time.Now().Add(carts.TTL)
+		require.NoError(t, store.CreateCart(ctx, carts.Cart{Token: "tok01", ExpiresAt: &expires}))
+		return store, bs
+	}
+
+	t.Run("CreateCart", func(t *testing.T) {
+		store, _ := newStores(t)
+
+		c, err := store.ReadCart(ctx, "tok01")
+		require.NoError(t, err)
+		assert.Equal(t, "tok01", c.Token)
+		assert.NotNil(t, c.CreatedAt)
+		assert.Empty(t, c.Items)
+
+		expires := time.Now().Add(time.Hour)
+		err = store.CreateCart(ctx, carts.Cart{Token: "tok01", ExpiresAt: &expires})
+		assert.True(t, errors.Is(err, datastore.ErrDuplicate))
+
+		_, err = store.ReadCart(ctx, "unknown")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("items", func(t *testing.T) {
+		store, _ := newStores(t)
+
+		require.NoError(t, store.AddCartItems(ctx, "tok01", carts.Item{BookID: "def02", Quantity: 2}))
+		require.NoError(t, store.AddCartItems(ctx, "tok01", carts.Item{BookID: "def02", Quantity: 3}, carts.Item{BookID: "abc01", Quantity: 1}))
+
+		c, err := store.ReadCart(ctx, "tok01")
+		require.NoError(t, err)
+		require.Len(t, c.Items, 2)
+		quantities := map[string]int{}
+		for _, item := range c.Items {
+			quantities[item.BookID] = item.Quantity
+		}
+		assert.Equal(t, map[string]int{"def02": 5, "abc01": 1}, quantities)
+
+		require.NoError(t, store.SetCartItem(ctx, "tok01", carts.Item{BookID: "def02", Quantity: 1}))
+		err = store.SetCartItem(ctx, "tok01", carts.Item{BookID: "def02", Quantity: carts.MaxQuantity + 1})
+		assert.True(t, errors.Is(err, datastore.ErrCheckViolation))
+		err = store.AddCartItems(ctx, "tok01", carts.Item{BookID: "unknown", Quantity: 1})
+		assert.True(t, errors.Is(err, datastore.ErrMissingReference))
+
+		require.NoError(t, store.DeleteCartItem(ctx, "tok01", "abc01"))
+		err = store.DeleteCartItem(ctx, "tok01", "abc01")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+
+		c, err = store.ReadCart(ctx, "tok01")
+		require.NoError(t, err)
+		require.Len(t, c.Items, 1)
+		assert.Equal(t, 1, c.Items[0].Quantity)
+	})
+
+	t.Run("deleted books leave carts", func(t *testing.T) {
+		store, bs := newStores(t)
+		require.NoError(t, store.AddCartItems(ctx, "tok01", carts.Item{BookID: "def02", Quantity: 2}))
+		require.NoError(t, bs.DeleteBooks(ctx, "def02"))
+
+		c, err := store.ReadCart(ctx, "tok01")
+		require.NoError(t, err)
+		assert.Empty(t, c.Items)
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		store, _ := newStores(t)
+		require.NoError(t, store.AddCartItems(ctx, "tok01", carts.Item{BookID: "def02", Quantity: 2}))
+
+		require.NoError(t, store.TouchCart(ctx, "tok01", time.Now().Add(-time.Minute)))
+		_, err := store.ReadCart(ctx, "tok01")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+
+		n, err := store.DeleteExpiredCarts(ctx, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+		err = store.DeleteCart(ctx, "tok01")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+}
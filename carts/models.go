@@ -0,0 +1,82 @@
+package carts
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sort"
+	"time"
+
+	"bookshop/money"
+)
+
+// TTL is how long a cart is kept after it was last changed.
+const TTL = 7 * 24 * time.Hour
+
+// MaxQuantity is the most copies of a book a cart can hold.
+const MaxQuantity = 99
+
+// Cart is the model representing a row in the carts table along with its
+// items. Carts are anonymous: whoever holds a cart's token may use it.
+type Cart struct {
+	Token     string     `db:"token" json:"token"`
+	CreatedAt *time.Time `db:"created_at" json:"created_at,omitempty"`
+	UpdatedAt *time.Time `db:"updated_at" json:"updated_at,omitempty"`
+	ExpiresAt *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+
+	Items []Item `json:"items"`
+	// Totals holds the sum of the priced items in each currency.
+	Totals []money.Money `json:"totals"`
+}
+
+// Item is the model representing a book in a cart. The title and prices are
+// those of the book when the cart is read.
+type Item struct {
+	BookID   string     `db:"book_id" json:"book_id"`
+	Quantity int        `db:"quantity" json:"quantity"`
+	AddedAt  *time.Time `db:"added_at" json:"added_at,omitempty"`
+
+	Title     string       `db:"-" json:"title,omitempty"`
+	UnitPrice *money.Money `db:"-" json:"unit_price,omitempty"`
+	LineTotal *money.Money `db:"-" json:"line_total,omitempty"`
+}
+
+// NewToken returns a new random cart token.
+func NewToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// WithTotals returns the cart with the line totals of its priced items and the
+// cart totals per currency filled in.
+func (c Cart) WithTotals() (Cart, error) {
+	c.Items = append([]Item{}, c.Items...)
+	totals := map[money.Currency]money.Money{}
+	for i, item := range c.Items {
+		item.LineTotal = nil
+		if item.UnitPrice != nil {
+			line, err := item.UnitPrice.Mul(int64(item.Quantity))
+			if err != nil {
+				return Cart{}, err
+			}
+			sum, err := line.Add(money.New(totals[line.Currency].Amount, line.Currency))
+			if err != nil {
+				return Cart{}, err
+			}
+			totals[line.Currency] = sum
+			item.LineTotal = &line
+		}
+		c.Items[i] = item
+	}
+
+	c.Totals = []money.Money{}
+	for _, t := range totals {
+		c.Totals = append(c.Totals, t)
+	}
+	sort.Slice(c.Totals, func(i, j int) bool {
+		return c.Totals[i].Currency < c.Totals[j].Currency
+	})
+	return c, nil
+}
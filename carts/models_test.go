@@ -0,0 +1,42 @@
+package carts_test
+
+import (
+	"testing"
+
+	"bookshop/carts"
+	"bookshop/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCartTotals(t *testing.T) {
+	usd, eur := money.New(1000, money.USD), money.New(250, money.EUR)
+	c := carts.Cart{Items: []carts.Item{
+		{BookID: "abc01", Quantity: 2, UnitPrice: &usd},
+		{BookID: "def02", Quantity: 3, UnitPrice: &eur},
+		{BookID: "ghi03", Quantity: 1, UnitPrice: &usd},
+		{BookID: "jkl04", Quantity: 1},
+	}}
+
+	c, err := c.WithTotals()
+	require.NoError(t, err)
+	assert.Equal(t, money.New(2000, money.USD), *c.Items[0].LineTotal)
+	assert.Equal(t, money.New(750, money.EUR), *c.Items[1].LineTotal)
+	assert.Nil(t, c.Items[3].LineTotal)
+	assert.Equal(t, []money.Money{money.New(750, money.EUR), money.New(3000, money.USD)}, c.Totals)
+
+	empty, err := carts.Cart{}.WithTotals()
+	require.NoError(t, err)
+	assert.Empty(t, empty.Totals)
+	assert.NotNil(t, empty.Totals)
+}
+
+func TestNewToken(t *testing.T) {
+	a, err := carts.NewToken()
+	require.NoError(t, err)
+	b, err := carts.NewToken()
+	require.NoError(t, err)
+	assert.Len(t, a, 32)
+	assert.NotEqual(t, a, b)
+}
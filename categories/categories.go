@@ -0,0 +1,95 @@
+package categories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"bookshop/datastore"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type CategoryStore struct {
+	db *sqlx.DB
+}
+
+func NewCategoryStore(db *sqlx.DB) CategoryStore {
+	return CategoryStore{db: db}
+}
+
+// CreateCategory will add the given category, returning datastore.ErrDuplicate
+// if its parent already has a category with the same name and
+// datastore.ErrMissingReference if its parent does not exist.
+func (s *CategoryStore) CreateCategory(ctx context.Context, c Category) error {
+	const sqlSt = `INSERT INTO categories (id, parent_id, name, created_at, updated_at) VALUES (?,?,?,?,?)`
+	now := time.Now()
+	_, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind(sqlSt), c.ID, c.ParentID, c.Name, now, now)
+	return s.writeErr(err, "failed creating category")
+}
+
+// ReadCategory will return the category with the given ID, without its
+// subcategories, returning datastore.ErrNotFound if it does not exist.
+func (s *CategoryStore) ReadCategory(ctx context.Context, id string) (Category, error) {
+	c := Category{}
+	err := datastore.Conn(ctx, s.db).GetContext(ctx, &c, s.db.Rebind("SELECT * FROM categories WHERE id=?"), id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Category{}, datastore.ErrNotFound
+		}
+		return Category{}, errors.Wrap(err, "failed to read category")
+	}
+	return c, nil
+}
+
+// ReadCategories will return every category, sorted by name, for arranging
+// into a tree.
+func (s *CategoryStore) ReadCategories(ctx context.Context) ([]Category, error) {
+	cats := []Category{}
+	if err := datastore.Conn(ctx, s.db).SelectContext(ctx, &cats, "SELECT * FROM categories ORDER BY lower(name) ASC, id ASC"); err != nil {
+		return nil, errors.Wrap(err, "failed to read categories")
+	}
+	return cats, nil
+}
+
+// UpdateCategory will rename the category and move it under its parent,
+// returning datastore.ErrNotFound if it does not exist along with the errors
+// of CreateCategory.
+func (s *CategoryStore) UpdateCategory(ctx context.Context, c Category) error {
+	const sqlSt = `UPDATE categories SET parent_id = ?, name = ?, updated_at = ? WHERE id = ?`
+	res, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind(sqlSt), c.ParentID, c.Name, time.Now(), c.ID)
+	if err != nil {
+		return s.writeErr(err, "failed updating category")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return datastore.ErrNotFound
+	}
+	return nil
+}
+
+// DeleteCategory will delete the category and its links to books, returning
+// datastore.ErrNotFound if it does not exist. Categories with subcategories
+// cannot be deleted.
+func (s *CategoryStore) DeleteCategory(ctx context.Context, id string) error {
+	res, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind("DELETE FROM categories WHERE id = ?"), id)
+	if err != nil {
+		return errors.Wrap(err, "failed deleting category")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return datastore.ErrNotFound
+	}
+	return nil
+}
+
+func (s *CategoryStore) writeErr(err error, msg string) error {
+	switch {
+	case err == nil:
+		return nil
+	case datastore.IsUniqueViolation(err):
+		return datastore.ErrDuplicate
+	case datastore.IsForeignKeyViolation(err):
+		return datastore.ErrMissingReference
+	}
+	return errors.Wrap(err, msg)
+}
@@ -0,0 +1,136 @@
+package categories_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bookshop/books"
+	"bookshop/categories"
+	"bookshop/datastore"
+	"bookshop/datastore/dbtest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCategories(t *testing.T) {
+	ctx := context.Background()
+	fiction, scifi := "fic01", "sci02"
+	newStores := func(t *testing.T) (categories.CategoryStore, books.BookStore) {
+		dbh := dbtest.Open(t)
+
+		cs, bs := categories.NewCategoryStore(dbh), books.NewBookStore(dbh)
+		require.NoError(t, cs.CreateCategory(ctx, categories.Category{ID: fiction, Name: "Fiction"}))
+		require.NoError(t, cs.CreateCategory(ctx, categories.Category{ID: scifi, ParentID: &fiction, Name: "Science Fiction"}))
+		err := bs.UpsertBooks(ctx, []books.Book{
+			{ID: "abc01", Title: "Dune", ISBN: "9781861972712"},
+			{ID: "def02", Title: "Emma", ISBN: "9780141439587"},
+		})
+		require.NoError(t, err)
+		return cs, bs
+	}
+
+	t.Run("CreateCategory", func(t *testing.T) {
+		store, _ := newStores(t)
+
+		c, err := store.ReadCategory(ctx, scifi)
+		require.NoError(t, err)
+		assert.Equal(t, &fiction, c.ParentID)
+		assert.NotNil(t, c.CreatedAt)
+
+		err = store.CreateCategory(ctx, categories.Category{ID: "dup03", ParentID: &fiction, Name: "science fiction"})
+		assert.True(t, errors.Is(err, datastore.ErrDuplicate), "siblings have different names")
+		err = store.CreateCategory(ctx, categories.Category{ID: "dup03", Name: "FICTION"})
+		assert.True(t, errors.Is(err, datastore.ErrDuplicate), "as do categories at the top of the tree")
+		require.NoError(t, store.CreateCategory(ctx, categories.Category{ID: "fic03", ParentID: &scifi, Name: "Fiction"}))
+
+		unknown := "unknown"
+		err = store.CreateCategory(ctx, categories.Category{ID: "poe04", ParentID: &unknown, Name: "Poetry"})
+		assert.True(t, errors.Is(err, datastore.ErrMissingReference))
+
+		_, err = store.ReadCategory(ctx, "unknown")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("UpdateCategory", func(t *testing.T) {
+		store, _ := newStores(t)
+
+		require.NoError(t, store.UpdateCategory(ctx, categories.Category{ID: scifi, Name: "SF"}))
+		cats, err := store.ReadCategories(ctx)
+		require.NoError(t, err)
+		tree := categories.Tree(cats)
+		require.Len(t, tree, 2)
+		assert.Equal(t, "Fiction", tree[0].Name)
+		assert.Equal(t, "SF", tree[1].Name)
+
+		err = store.UpdateCategory(ctx, categories.Category{ID: scifi, Name: "fiction"})
+		assert.True(t, errors.Is(err, datastore.ErrDuplicate))
+		err = store.UpdateCategory(ctx, categories.Category{ID: "unknown", Name: "Poetry"})
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("DeleteCategory", func(t *testing.T) {
+		store, bs := newStores(t)
+		require.NoError(t, bs.LinkBookCategories(ctx, "abc01", scifi))
+
+		assert.Error(t, store.DeleteCategory(ctx, fiction), "fiction has subcategories")
+		require.NoError(t, store.DeleteCategory(ctx, scifi))
+		bk, err := bs.ReadBookByID(ctx, "abc01")
+		require.NoError(t, err)
+		assert.Empty(t, bk.Categories)
+
+		err = store.DeleteCategory(ctx, scifi)
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("book categories", func(t *testing.T) {
+		_, bs := newStores(t)
+		require.NoError(t, bs.LinkBookCategories(ctx, "abc01", scifi, scifi))
+		require.NoError(t, bs.LinkBookCategories(ctx, "def02", fiction))
+		err := bs.LinkBookCategories(ctx, "abc01", "unknown")
+		assert.True(t, errors.Is(err, datastore.ErrMissingReference))
+
+		bk, err := bs.ReadBookByID(ctx, "abc01")
+		require.NoError(t, err)
+		assert.Equal(t, []books.BookCategory{{ID: scifi, Name: "Science Fiction"}}, bk.Categories)
+
+		page, err := bs.ReadBooks(ctx, books.BookQuery{Category: fiction})
+		require.NoError(t, err)
+		require.Len(t, page.Books, 2, "fiction includes science fiction")
+		page, err = bs.ReadBooks(ctx, books.BookQuery{Category: scifi})
+		require.NoError(t, err)
+		require.Len(t, page.Books, 1)
+		assert.Equal(t, "abc01", page.Books[0].ID)
+
+		require.NoError(t, bs.UnlinkBookCategory(ctx, "abc01", scifi))
+		err = bs.UnlinkBookCategory(ctx, "abc01", scifi)
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		_, bs := newStores(t)
+		require.NoError(t, bs.TagBook(ctx, "abc01", "space", "classic"))
+		require.NoError(t, bs.TagBook(ctx, "def02", "classic"))
+		require.NoError(t, bs.TagBook(ctx, "def02", "classic"))
+		err := bs.TagBook(ctx, "abc99", "space")
+		assert.True(t, errors.Is(err, datastore.ErrMissingReference))
+
+		bk, err := bs.ReadBookByID(ctx, "abc01")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"classic", "space"}, bk.Tags)
+
+		tags, err := bs.ReadTags(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []books.Tag{{Name: "classic", Books: 2}, {Name: "space", Books: 1}}, tags)
+
+		page, err := bs.ReadBooks(ctx, books.BookQuery{Tag: " Space "})
+		require.NoError(t, err)
+		require.Len(t, page.Books, 1)
+		assert.Equal(t, "abc01", page.Books[0].ID)
+
+		require.NoError(t, bs.UntagBook(ctx, "abc01", "space"))
+		err = bs.UntagBook(ctx, "abc01", "space")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+}
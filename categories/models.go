@@ -0,0 +1,108 @@
+package categories
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxNameLength is the most characters a category's name may have.
+const MaxNameLength = 100
+
+// Category is the model representing a row in the categories table. Categories
+// form a tree, e.g. Fiction > Science Fiction; those without a parent are at
+// the top of it.
+type Category struct {
+	ID        string     `db:"id" json:"id"`
+	ParentID  *string    `db:"parent_id" json:"parent_id"`
+	Name      string     `db:"name" json:"name"`
+	CreatedAt *time.Time `db:"created_at" json:"created_at,omitempty"`
+	UpdatedAt *time.Time `db:"updated_at" json:"updated_at,omitempty"`
+
+	// Children are the category's subcategories, filled in when the tree is built.
+	Children []Category `db:"-" json:"children,omitempty"`
+}
+
+// Validate checks the category has a name that is not too long and is not its
+// own parent.
+func (c Category) Validate() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return errors.New("category name cannot be blank")
+	}
+	if utf8.RuneCountInString(c.Name) > MaxNameLength {
+		return fmt.Errorf("category name must be at most %d characters long", MaxNameLength)
+	}
+	if c.ParentID != nil && *c.ParentID == c.ID {
+		return errors.New("category cannot be its own parent")
+	}
+	return nil
+}
+
+// Tree arranges the categories under their parents, returning those at the top
+// of the tree. Subcategories are sorted by name.
+func Tree(cats []Category) []Category {
+	return children(byParent(cats), "")
+}
+
+// Subtree returns the category with the given ID along with the categories
+// beneath it, and whether it was found.
+func Subtree(cats []Category, id string) (Category, bool) {
+	for _, c := range cats {
+		if c.ID == id {
+			c.Children = children(byParent(cats), id)
+			return c, true
+		}
+	}
+	return Category{}, false
+}
+
+// Descendants returns the IDs of the category with the given ID and of every
+// category beneath it.
+func Descendants(cats []Category, id string) map[string]bool {
+	parents := byParent(cats)
+	ids := map[string]bool{id: true}
+	next := []string{id}
+	for len(next) > 0 {
+		parent := next[0]
+		next = next[1:]
+		for _, c := range parents[parent] {
+			if !ids[c.ID] {
+				ids[c.ID] = true
+				next = append(next, c.ID)
+			}
+		}
+	}
+	return ids
+}
+
+// byParent groups the categories by the ID of their parent, with those at the
+// top of the tree under "".
+func byParent(cats []Category) map[string][]Category {
+	parents := map[string][]Category{}
+	for _, c := range cats {
+		parent := ""
+		if c.ParentID != nil {
+			parent = *c.ParentID
+		}
+		parents[parent] = append(parents[parent], c)
+	}
+	return parents
+}
+
+func children(parents map[string][]Category, id string) []Category {
+	kids := append([]Category(nil), parents[id]...)
+	sort.Slice(kids, func(i, j int) bool {
+		ni, nj := strings.ToLower(kids[i].Name), strings.ToLower(kids[j].Name)
+		if ni != nj {
+			return ni < nj
+		}
+		return kids[i].ID < kids[j].ID
+	})
+	for i := range kids {
+		kids[i].Children = children(parents, kids[i].ID)
+	}
+	return kids
+}
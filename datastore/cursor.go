@@ -0,0 +1,60 @@
+package datastore
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+const (
+	// DefaultLimit is the page size used when a query does not ask for one.
+	DefaultLimit = 50
+	// MaxLimit is the largest page size a query may ask for.
+	MaxLimit = 200
+)
+
+var (
+	// ErrInvalidCursor is returned when a cursor cannot be decoded or does not
+	// match the sort order of the query it was submitted with.
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// Cursor marks the position of the last row of a page for keyset pagination.
+// Rows are always ordered by the sort field and then by ID so that the pair is
+// unique.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d,omitempty"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// Encode returns the opaque string form of the cursor handed out to clients.
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses a cursor previously created by Cursor.Encode and checks
+// that it was issued for the given sort order.
+func DecodeCursor(s, sort string, desc bool) (Cursor, error) {
+	var c Cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+	if c.Sort != sort || c.Desc != desc || c.ID == "" {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// EscapeLike escapes the LIKE wildcards in s so it can be used as a literal
+// prefix in a LIKE pattern.
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -3,10 +3,17 @@ package datastore
 import (
 	"fmt"
 
+	"bookshop/search"
+
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
+	_ "modernc.org/sqlite"
 )
 
+// HeadlineOptions are the ts_headline options used to mark the matched terms
+// of search snippets with search.MatchStart and search.MatchStop.
+const HeadlineOptions = "StartSel=" + search.MatchStart + ", StopSel=" + search.MatchStop + ", HighlightAll=true"
+
 type Datastore struct {
 	DB *sqlx.DB
 }
@@ -22,3 +29,17 @@ func ConnectDB(dbUser, dbPass, dbName string) (*sqlx.DB, error) {
 	}
 	return db, nil
 }
+
+// ConnectSQLite opens the SQLite database file at the given path, creating it
+// if needed. The path ":memory:" opens a throwaway in-memory database. SQLite
+// only allows a single writer, so the pool is limited to one connection and
+// transactions take the write lock when they begin.
+func ConnectSQLite(path string) (*sqlx.DB, error) {
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite&_txlock=immediate"
+	db, err := sqlx.Connect(SQLite, dsn)
+	if err != nil {
+		return nil, errors.Wrap(err, "db connection error")
+	}
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
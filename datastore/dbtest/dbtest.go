@@ -0,0 +1,90 @@
+// Package dbtest opens migrated databases for store tests.
+package dbtest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bookshop/datastore"
+	"bookshop/migrations"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/robojandro/go-pgtesthelper"
+	"github.com/stretchr/testify/require"
+)
+
+// open opens the database Open returns, SQLite unless the tests are built with
+// the int tag.
+var open = SQLite
+
+// Open returns a migrated database for a store test, closed or dropped when the
+// test ends. It is an in-memory SQLite database, or a temporary Postgres one
+// named after the test when the tests are built with the int tag.
+func Open(t testing.TB) *sqlx.DB {
+	t.Helper()
+	return open(t)
+}
+
+// SQLite returns an in-memory SQLite database with every migration applied,
+// closed when the test ends.
+func SQLite(t testing.TB) *sqlx.DB {
+	t.Helper()
+	dbh, err := datastore.ConnectSQLite(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { dbh.Close() })
+	migrate(t, dbh)
+	return dbh
+}
+
+// Postgres returns a temporary Postgres database, named with the prefix, with
+// every migration applied, dropped when the test ends. The test is skipped
+// unless the bookshop_dbuser and bookshop_dbpass environment variables are set.
+func Postgres(t testing.TB, prefix string) *sqlx.DB {
+	t.Helper()
+	dbh := EmptyPostgres(t, prefix)
+	migrate(t, dbh)
+	return dbh
+}
+
+// EmptyPostgres returns a temporary Postgres database like Postgres does, but
+// without any migrations applied.
+func EmptyPostgres(t testing.TB, prefix string) *sqlx.DB {
+	t.Helper()
+	dbUser, dbPass := os.Getenv("bookshop_dbuser"), os.Getenv("bookshop_dbpass")
+	if dbUser == "" {
+		t.Skip("missing env variable bookshop_dbuser")
+	}
+	if dbPass == "" {
+		t.Skip("missing env variable bookshop_dbpass")
+	}
+
+	// the helper insists on a schema file, the tables come from the migrations
+	schemaPath := filepath.Join(t.TempDir(), "empty.sql")
+	require.NoError(t, ioutil.WriteFile(schemaPath, nil, 0o644))
+
+	h, err := pgtesthelper.NewHelper(schemaPath, prefix, dbUser, dbPass, false)
+	require.NoError(t, err)
+	dbh, err := h.CreateTempDB()
+	require.NoError(t, err)
+	t.Cleanup(func() { h.CleanUp() })
+	return dbh
+}
+
+func migrate(t testing.TB, dbh *sqlx.DB) {
+	t.Helper()
+	m, err := migrations.NewMigrator(dbh)
+	require.NoError(t, err)
+	_, err = m.Up()
+	require.NoError(t, err)
+}
+
+// Truncate empties the tables of a Postgres database along with the tables
+// referencing them.
+func Truncate(t testing.TB, dbh *sqlx.DB, tables ...string) {
+	t.Helper()
+	_, err := dbh.Exec("TRUNCATE TABLE " + strings.Join(tables, ", ") + " CASCADE")
+	require.NoError(t, err)
+}
@@ -0,0 +1,19 @@
+//go:build int
+// +build int
+
+package dbtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func init() {
+	open = func(t testing.TB) *sqlx.DB {
+		// name the database after the top level test, as packages are tested at the same time
+		name := strings.SplitN(t.Name(), "/", 2)[0]
+		return Postgres(t, strings.ToLower(name)+"_testing")
+	}
+}
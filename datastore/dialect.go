@@ -0,0 +1,48 @@
+package datastore
+
+import (
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Driver names of the supported databases. Stores use them to pick the SQL
+// that differs between the two.
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+)
+
+// IsSQLite reports whether the connection is to a SQLite database.
+func IsSQLite(db *sqlx.DB) bool {
+	return db.DriverName() == SQLite
+}
+
+// Timestamp wraps a timestamp column or placeholder so that it compares and
+// sorts chronologically. SQLite keeps timestamps as text, so they are converted
+// to julian day numbers there.
+func Timestamp(db *sqlx.DB, expr string) string {
+	if IsSQLite(db) {
+		return "julianday(" + expr + ")"
+	}
+	return expr
+}
+
+// CursorTimestamp returns the placeholder for a timestamp taken from a Cursor,
+// which holds it in RFC 3339 form.
+func CursorTimestamp(db *sqlx.DB) string {
+	if IsSQLite(db) {
+		return "julianday(?)"
+	}
+	return "?::timestamp"
+}
+
+// FTSQuery turns free text into a SQLite FTS5 query matching rows that contain
+// every word, mirroring postgres' plainto_tsquery.
+func FTSQuery(text string) string {
+	var terms []string
+	for _, f := range strings.Fields(text) {
+		terms = append(terms, `"`+strings.ReplaceAll(f, `"`, `""`)+`"`)
+	}
+	return strings.Join(terms, " ")
+}
@@ -0,0 +1,81 @@
+package datastore
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	// foreignKeyViolation is the postgres error code raised when a REFERENCES constraint fails.
+	foreignKeyViolation = "23503"
+	// uniqueViolation is the postgres error code raised when a UNIQUE constraint fails.
+	uniqueViolation = "23505"
+	// checkViolation is the postgres error code raised when a CHECK constraint fails.
+	checkViolation = "23514"
+	// serializationFailure is the postgres error code raised when concurrent
+	// serializable transactions conflict.
+	serializationFailure = "40001"
+	// deadlockDetected is the postgres error code raised when a transaction is
+	// picked to break a deadlock.
+	deadlockDetected = "40P01"
+)
+
+var (
+	// ErrDuplicate is returned by stores when a write would violate a unique constraint.
+	ErrDuplicate = errors.New("record already exists")
+	// ErrMissingReference is returned by stores when a write refers to a record that does not exist.
+	ErrMissingReference = errors.New("referenced record does not exist")
+	// ErrNotFound is returned by stores when the requested record does not exist.
+	ErrNotFound = errors.New("record not found")
+	// ErrCheckViolation is returned by stores when a write would violate a CHECK constraint.
+	ErrCheckViolation = errors.New("record fails a check constraint")
+)
+
+// IsUniqueViolation reports whether the given error was raised by the database
+// because of a UNIQUE or PRIMARY KEY constraint, which postgres does not tell apart.
+func IsUniqueViolation(err error) bool {
+	return hasCode(err, uniqueViolation) ||
+		hasSQLiteCode(err, sqlite3.SQLITE_CONSTRAINT_UNIQUE) ||
+		hasSQLiteCode(err, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY)
+}
+
+// IsForeignKeyViolation reports whether the given error was raised by the
+// database because of a REFERENCES constraint.
+func IsForeignKeyViolation(err error) bool {
+	return hasCode(err, foreignKeyViolation) || hasSQLiteCode(err, sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY)
+}
+
+// IsCheckViolation reports whether the given error was raised by the database
+// because of a CHECK constraint.
+func IsCheckViolation(err error) bool {
+	return hasCode(err, checkViolation) || hasSQLiteCode(err, sqlite3.SQLITE_CONSTRAINT_CHECK)
+}
+
+// IsSerializationFailure reports whether the given error means the transaction
+// conflicted with a concurrent one and may succeed if retried.
+func IsSerializationFailure(err error) bool {
+	if hasCode(err, serializationFailure) || hasCode(err, deadlockDetected) {
+		return true
+	}
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code()&0xff == sqlite3.SQLITE_BUSY
+}
+
+func hasCode(err error, code pq.ErrorCode) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == code
+	}
+	return false
+}
+
+func hasSQLiteCode(err error, code int) bool {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == code
+	}
+	return false
+}
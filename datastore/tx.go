@@ -0,0 +1,120 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// maxAttempts is how many times a unit of work is tried before a serialization
+// failure is given up on, waiting retryDelay longer after each attempt.
+const (
+	maxAttempts = 3
+	retryDelay  = 20 * time.Millisecond
+)
+
+type txKey struct{}
+
+// Queryer is satisfied by both *sqlx.DB and *sqlx.Tx.
+type Queryer interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+}
+
+// Conn returns the transaction of the unit of work running in ctx, or db when
+// there is none. Stores run their queries through it.
+func Conn(ctx context.Context, db *sqlx.DB) Queryer {
+	if tx, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return tx
+	}
+	return db
+}
+
+// Tx is a transaction begun by a store method.
+type Tx struct {
+	*sqlx.Tx
+	joined bool
+}
+
+// Begin starts a transaction for a store method. Inside a unit of work it joins
+// the unit's transaction instead, and Commit and Rollback are left to the unit
+// of work.
+func Begin(ctx context.Context, db *sqlx.DB) (*Tx, error) {
+	if tx, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return &Tx{Tx: tx, joined: true}, nil
+	}
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx}, nil
+}
+
+// Commit will commit the transaction unless it belongs to a unit of work.
+func (t *Tx) Commit() error {
+	if t.joined {
+		return nil
+	}
+	return t.Tx.Commit()
+}
+
+// Rollback will roll back the transaction unless it belongs to a unit of work,
+// which rolls back once the error that caused this reaches it.
+func (t *Tx) Rollback() error {
+	if t.joined {
+		return nil
+	}
+	return t.Tx.Rollback()
+}
+
+// UnitOfWork runs several store calls in one transaction.
+type UnitOfWork struct {
+	db *sqlx.DB
+}
+
+func NewUnitOfWork(db *sqlx.DB) UnitOfWork {
+	return UnitOfWork{db: db}
+}
+
+// Do will run fn in a transaction that the stores join through the context it
+// is given, committing if fn returns nil and rolling back otherwise. On postgres
+// the transaction is serializable, and fn is run again from the start when it
+// fails to serialize. A Do nested inside another joins the outer transaction.
+func (u *UnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return fn(ctx)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := u.attempt(ctx, fn)
+		if err == nil || !IsSerializationFailure(err) || attempt == maxAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * retryDelay):
+		}
+	}
+}
+
+func (u *UnitOfWork) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	// SQLite transactions take the write lock when they begin, which already
+	// makes them serializable
+	var opts *sql.TxOptions
+	if !IsSQLite(u.db) {
+		opts = &sql.TxOptions{Isolation: sql.LevelSerializable}
+	}
+	tx, err := u.db.BeginTxx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,105 @@
+package datastore_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bookshop/books"
+	"bookshop/datastore"
+	"bookshop/datastore/dbtest"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnitOfWork(t *testing.T) {
+	ctx := context.Background()
+
+	newStore := func(t *testing.T) (datastore.UnitOfWork, books.BookStore) {
+		dbh := dbtest.SQLite(t)
+		return datastore.NewUnitOfWork(dbh), books.NewBookStore(dbh)
+	}
+	bk := books.Book{ID: "abc01", Title: "Dune", ISBN: "9781861972712"}
+
+	t.Run("commit", func(t *testing.T) {
+		uow, store := newStore(t)
+
+		err := uow.Do(ctx, func(ctx context.Context) error {
+			if err := store.UpsertBooks(ctx, []books.Book{bk}); err != nil {
+				return err
+			}
+			_, err := store.ReadBookByID(ctx, bk.ID)
+			return err
+		})
+		require.NoError(t, err)
+
+		_, err = store.ReadBookByID(ctx, bk.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		uow, store := newStore(t)
+
+		err := uow.Do(ctx, func(ctx context.Context) error {
+			if err := store.UpsertBooks(ctx, []books.Book{bk}); err != nil {
+				return err
+			}
+			return store.LinkBookAuthors(ctx, bk.ID, books.Credit{AuthorID: "unknown", Role: books.RoleAuthor, Sequence: 1})
+		})
+		assert.True(t, errors.Is(err, datastore.ErrMissingReference))
+
+		_, err = store.ReadBookByID(ctx, bk.ID)
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		uow, store := newStore(t)
+
+		err := uow.Do(ctx, func(ctx context.Context) error {
+			err := uow.Do(ctx, func(ctx context.Context) error {
+				return store.UpsertBooks(ctx, []books.Book{bk})
+			})
+			if err != nil {
+				return err
+			}
+			return errors.New("outer failure")
+		})
+		assert.Error(t, err)
+
+		_, err = store.ReadBookByID(ctx, bk.ID)
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("retries serialization failures", func(t *testing.T) {
+		uow, _ := newStore(t)
+		conflict := &pq.Error{Code: "40001"}
+
+		var attempts int
+		err := uow.Do(ctx, func(ctx context.Context) error {
+			if attempts++; attempts == 1 {
+				return conflict
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, attempts)
+
+		attempts = 0
+		err = uow.Do(ctx, func(ctx context.Context) error {
+			attempts++
+			return conflict
+		})
+		assert.True(t, datastore.IsSerializationFailure(err))
+		assert.Equal(t, 3, attempts)
+
+		attempts = 0
+		err = uow.Do(ctx, func(ctx context.Context) error {
+			attempts++
+			return errors.New("not retried")
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, attempts)
+	})
+}
@@ -1,14 +1,34 @@
 module bookshop
 
-go 1.14
+go 1.20
 
 require (
 	github.com/gorilla/mux v1.7.4
 	github.com/jmoiron/sqlx v1.2.0
-	github.com/kr/pretty v0.2.0
 	github.com/lib/pq v1.5.1
 	github.com/pkg/errors v0.9.1
 	github.com/robojandro/go-pgtesthelper v0.0.3
 	github.com/satori/go.uuid v1.2.0
 	github.com/stretchr/testify v1.5.1
+	golang.org/x/crypto v0.18.0
+	modernc.org/sqlite v1.29.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.3.0 // indirect
+	github.com/hashicorp/golang-lru/v2 v2.0.7 // indirect
+	github.com/mattn/go-isatty v0.0.16 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	golang.org/x/sys v0.16.0 // indirect
+	gopkg.in/yaml.v2 v2.2.2 // indirect
+	modernc.org/gc/v3 v3.0.0-20240107210532-573471604cb6 // indirect
+	modernc.org/libc v1.41.0 // indirect
+	modernc.org/mathutil v1.6.0 // indirect
+	modernc.org/memory v1.7.2 // indirect
+	modernc.org/strutil v1.2.0 // indirect
+	modernc.org/token v1.1.0 // indirect
 )
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"bookshop/auth"
+	"bookshop/service"
+
+	"github.com/gorilla/mux"
+)
+
+// publicRoutes are the routes that may be called without signing in: reading
+// the catalogue, anonymous carts, registering and signing in. Every other route
+// answers a request without a valid session token with a 401.
+var publicRoutes = map[string]bool{
+	"ListBooks":      true,
+	"GetBook":        true,
+	"ListBookPrices": true,
+	"GetStock":       true,
+	"ListAuthors":    true,
+	"GetAuthor":      true,
+	"ListCategories": true,
+	"GetCategory":    true,
+	"ListPublishers": true,
+	"GetPublisher":   true,
+	"ListTags":       true,
+	"ListReviews":    true,
+	"GetReview":      true,
+	"Search":         true,
+
+	"CreateCart":     true,
+	"GetCart":        true,
+	"AddCartItem":    true,
+	"SetCartItem":    true,
+	"RemoveCartItem": true,
+	"MergeCarts":     true,
+
+	"RegisterUser": true,
+	"Login":        true,
+}
+
+type loginBody struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// authenticate puts the user whose session token or API key is in the
+// Authorization header in the request context, along with the key's scopes for
+// a key. Requests with credentials that are not valid are refused, as are
+// requests without any unless their route is public.
+func (s *HTTPServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, token, err := credentials(r)
+		if err != nil {
+			s.handleError(w, r, "service", err)
+			return
+		}
+
+		ctx := r.Context()
+		switch scheme {
+		case "":
+			if route := mux.CurrentRoute(r); route == nil || !publicRoutes[route.GetName()] {
+				s.handleError(w, r, "service", service.ErrUnauthenticated)
+				return
+			}
+		case schemeBearer:
+			u, err := s.svc.Authenticate(ctx, token)
+			if err != nil {
+				s.handleError(w, r, "service", err)
+				return
+			}
+			ctx = auth.WithUser(ctx, u)
+		case schemeAPIKey:
+			u, k, err := s.svc.AuthenticateKey(ctx, token)
+			if err != nil {
+				s.handleError(w, r, "service", err)
+				return
+			}
+			ctx = auth.WithScopes(auth.WithUser(ctx, u), k.Scopes)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// The Authorization header schemes: "Bearer <session token>" for users and
+// "ApiKey <key>" for other services.
+const (
+	schemeBearer = "bearer"
+	schemeAPIKey = "apikey"
+)
+
+// credentials returns the scheme, in lower case, and the token in the request's
+// Authorization header, or blanks if there is no header.
+func credentials(r *http.Request) (string, string, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", "", nil
+	}
+	scheme, token, ok := strings.Cut(header, " ")
+	scheme, token = strings.ToLower(scheme), strings.TrimSpace(token)
+	if !ok || (scheme != schemeBearer && scheme != schemeAPIKey) || token == "" {
+		return "", "", service.ErrUnauthenticated
+	}
+	return scheme, token, nil
+}
+
+// bearerToken returns the session token in the request's Authorization header.
+func bearerToken(r *http.Request) (string, error) {
+	scheme, token, err := credentials(r)
+	if err != nil {
+		return "", err
+	}
+	if scheme != schemeBearer {
+		return "", service.ErrUnauthenticated
+	}
+	return token, nil
+}
+
+// Login signs a user in with their email address and password, answering with
+// a session token to send as "Authorization: Bearer <token>".
+func (s *HTTPServer) Login(w http.ResponseWriter, r *http.Request) {
+	var body loginBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+	if strings.TrimSpace(body.Email) == "" || body.Password == "" {
+		s.handleError(w, r, "request", errors.New("email and password cannot be blank"))
+		return
+	}
+
+	creds, err := s.svc.Login(r.Context(), body.Email, body.Password)
+	s.serveCredentials(w, r, creds, err)
+}
+
+// RefreshSession swaps the session token the request is signed with for a new
+// one that expires later. The old token stops working.
+func (s *HTTPServer) RefreshSession(w http.ResponseWriter, r *http.Request) {
+	token, err := bearerToken(r)
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	creds, err := s.svc.RefreshSession(r.Context(), token)
+	s.serveCredentials(w, r, creds, err)
+}
+
+// Logout ends the session the request is signed with.
+func (s *HTTPServer) Logout(w http.ResponseWriter, r *http.Request) {
+	token, err := bearerToken(r)
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	if err := s.svc.Logout(r.Context(), token); err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	s.serve(w, r, []byte{})
+}
+
+// CurrentUser answers with the signed in user.
+func (s *HTTPServer) CurrentUser(w http.ResponseWriter, r *http.Request) {
+	u, ok := auth.UserFrom(r.Context())
+	if !ok {
+		s.handleError(w, r, "service", service.ErrUnauthenticated)
+		return
+	}
+	s.serveUser(w, r, u, nil)
+}
+
+// RevokeSessions ends every session of a user, signing them out everywhere.
+func (s *HTTPServer) RevokeSessions(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["user_id"]
+	if strings.TrimSpace(userID) == "" {
+		s.handleError(w, r, "request", errors.New("user ID cannot be blank"))
+		return
+	}
+
+	if err := s.svc.RevokeSessions(r.Context(), userID); err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	s.serve(w, r, []byte{})
+}
+
+// serveCredentials answers with the session credentials, or with the error of
+// the service call that returned them.
+func (s *HTTPServer) serveCredentials(w http.ResponseWriter, r *http.Request, creds auth.Credentials, err error) {
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	credsResp, err := json.Marshal(creds)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+	s.serve(w, r, credsResp)
+}
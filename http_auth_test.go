@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"bookshop/auth"
+	"bookshop/service"
+	"bookshop/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPServerAuth(t *testing.T) {
+	mockCreds = auth.Credentials{Token: "token02", ExpiresAt: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	t.Run("middleware", func(t *testing.T) {
+		t.Run("anonymous reads", func(t *testing.T) {
+			mockBooksErr = nil
+			resp := makeRequestWithToken(t, "", "GET", "/books", "")
+			assert.Equal(t, http.StatusOK, resp.Code)
+		})
+
+		t.Run("anonymous writes", func(t *testing.T) {
+			for _, req := range [][2]string{
+				{"DELETE", "/books/abc01"},
+				{"DELETE", "/authors/auth01"},
+				{"POST", "/books"},
+				{"GET", "/orders"},
+				{"GET", "/users/usr01"},
+			} {
+				resp := makeRequestWithToken(t, "", req[0], req[1], "")
+				assert.Equal(t, http.StatusUnauthorized, resp.Code, req)
+				assert.Equal(t, "Bearer", resp.Header().Get("WWW-Authenticate"))
+			}
+		})
+
+		t.Run("bad token", func(t *testing.T) {
+			resp := makeRequestWithToken(t, "expired", "GET", "/books", "")
+			assert.Equal(t, http.StatusUnauthorized, resp.Code, "a token is checked even on public routes")
+
+			req, err := http.NewRequest("GET", "/books", nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+			rec := httptest.NewRecorder()
+			NewHTTPServer(&mockService{}, Timeouts{}).ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		})
+
+		t.Run("current user", func(t *testing.T) {
+			resp := makeRequest(t, "GET", "/auth/me", "")
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var content users.User
+			err := json.NewDecoder(resp.Body).Decode(&content)
+			require.NoError(t, err)
+			assert.Equal(t, mockSessionUser, content)
+		})
+	})
+
+	t.Run("login", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockSessionErr = nil
+			resp := makeRequestWithToken(t, "", "POST", "/auth/login", `{"email": "ann@example.com", "password": "correct horse"}`)
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, []interface{}{"Login", "ann@example.com", "correct horse"}, mockSessionCall)
+
+			var content auth.Credentials
+			err := json.NewDecoder(resp.Body).Decode(&content)
+			require.NoError(t, err)
+			assert.Equal(t, mockCreds, content)
+		})
+
+		t.Run("wrong password", func(t *testing.T) {
+			mockSessionErr = service.ErrUnauthenticated
+			resp := makeRequestWithToken(t, "", "POST", "/auth/login", `{"email": "ann@example.com", "password": "wrong"}`)
+			assert.Equal(t, http.StatusUnauthorized, resp.Code)
+		})
+
+		t.Run("blank", func(t *testing.T) {
+			mockSessionErr = nil
+			resp := makeRequestWithToken(t, "", "POST", "/auth/login", `{"email": "ann@example.com"}`)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	})
+
+	t.Run("refresh", func(t *testing.T) {
+		mockSessionErr = nil
+		resp := makeRequest(t, "POST", "/auth/refresh", "")
+		require.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, []interface{}{"RefreshSession", mockToken}, mockSessionCall)
+
+		resp = makeRequestWithToken(t, "", "POST", "/auth/refresh", "")
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	})
+
+	t.Run("logout", func(t *testing.T) {
+		mockSessionErr = nil
+		resp := makeRequest(t, "POST", "/auth/logout", "")
+		require.Equal(t, http.StatusAccepted, resp.Code)
+		assert.Equal(t, []interface{}{"Logout", mockToken}, mockSessionCall)
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		mockSessionErr = nil
+		resp := makeRequest(t, "DELETE", "/users/usr01/sessions", "")
+		require.Equal(t, http.StatusAccepted, resp.Code)
+		assert.Equal(t, []interface{}{"RevokeSessions", "usr01"}, mockSessionCall)
+
+		mockSessionErr = service.NewErrNotFound("user", "usr01")
+		resp = makeRequest(t, "DELETE", "/users/usr01/sessions", "")
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+	})
+}
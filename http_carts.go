@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"bookshop/carts"
+
+	"github.com/gorilla/mux"
+)
+
+type cartItemBody struct {
+	BookID   string `json:"book_id"`
+	Quantity int    `json:"quantity"`
+}
+
+type mergeBody struct {
+	Token string `json:"token"`
+}
+
+// CreateCart starts a new empty cart, answering with its token.
+func (s *HTTPServer) CreateCart(w http.ResponseWriter, r *http.Request) {
+	c, err := s.svc.CreateCart(r.Context())
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	created, err := json.Marshal(c)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	s.serve(w, r, created)
+}
+
+// GetCart answers a request for a cart with its priced items and totals.
+func (s *HTTPServer) GetCart(w http.ResponseWriter, r *http.Request) {
+	token := mux.Vars(r)["token"]
+	if strings.TrimSpace(token) == "" {
+		s.handleError(w, r, "request", errors.New("cart token cannot be blank"))
+		return
+	}
+
+	c, err := s.svc.GetCart(r.Context(), token)
+	s.serveCart(w, r, c, err)
+}
+
+// AddCartItem puts copies of the book_id in the request in a cart.
+func (s *HTTPServer) AddCartItem(w http.ResponseWriter, r *http.Request) {
+	token := mux.Vars(r)["token"]
+	if strings.TrimSpace(token) == "" {
+		s.handleError(w, r, "request", errors.New("cart token cannot be blank"))
+		return
+	}
+
+	var item cartItemBody
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+	if strings.TrimSpace(item.BookID) == "" {
+		s.handleError(w, r, "request", errors.New("book ID cannot be blank"))
+		return
+	}
+
+	c, err := s.svc.AddCartItem(r.Context(), token, item.BookID, item.Quantity)
+	s.serveCart(w, r, c, err)
+}
+
+// SetCartItem changes how many copies of a book are in a cart.
+func (s *HTTPServer) SetCartItem(w http.ResponseWriter, r *http.Request) {
+	token, bkID, err := cartItemVars(r)
+	if err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	var item cartItemBody
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	c, err := s.svc.SetCartItem(r.Context(), token, bkID, item.Quantity)
+	s.serveCart(w, r, c, err)
+}
+
+// RemoveCartItem takes a book out of a cart.
+func (s *HTTPServer) RemoveCartItem(w http.ResponseWriter, r *http.Request) {
+	token, bkID, err := cartItemVars(r)
+	if err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	c, err := s.svc.RemoveCartItem(r.Context(), token, bkID)
+	s.serveCart(w, r, c, err)
+}
+
+// MergeCarts moves the items of the cart whose token is in the request into
+// another, deleting the emptied cart.
+func (s *HTTPServer) MergeCarts(w http.ResponseWriter, r *http.Request) {
+	token := mux.Vars(r)["token"]
+	if strings.TrimSpace(token) == "" {
+		s.handleError(w, r, "request", errors.New("cart token cannot be blank"))
+		return
+	}
+
+	var body mergeBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+	if strings.TrimSpace(body.Token) == "" {
+		s.handleError(w, r, "request", errors.New("token of the cart to merge cannot be blank"))
+		return
+	}
+
+	c, err := s.svc.MergeCarts(r.Context(), token, body.Token)
+	s.serveCart(w, r, c, err)
+}
+
+// serveCart answers with the cart, or with the error of the service call that
+// returned it.
+func (s *HTTPServer) serveCart(w http.ResponseWriter, r *http.Request, c carts.Cart, err error) {
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	cartResp, err := json.Marshal(c)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+	s.serve(w, r, cartResp)
+}
+
+func cartItemVars(r *http.Request) (string, string, error) {
+	vars := mux.Vars(r)
+	token, bkID := vars["token"], vars["book_id"]
+	if strings.TrimSpace(token) == "" {
+		return "", "", errors.New("cart token cannot be blank")
+	}
+	if strings.TrimSpace(bkID) == "" {
+		return "", "", errors.New("book ID cannot be blank")
+	}
+	return token, bkID, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"bookshop/carts"
+	"bookshop/money"
+	"bookshop/service"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPServerCarts(t *testing.T) {
+	price := money.New(1299, money.USD)
+	total := money.New(2598, money.USD)
+	mockCart = carts.Cart{
+		Token: "tok01",
+		Items: []carts.Item{
+			{BookID: "abc01", Quantity: 2, Title: "Dune", UnitPrice: &price, LineTotal: &total},
+		},
+		Totals: []money.Money{total},
+	}
+
+	t.Run("POST", func(t *testing.T) {
+		mockCartErr = nil
+		resp := makeRequest(t, "POST", "/carts", "")
+		require.Equal(t, http.StatusCreated, resp.Code)
+
+		var content carts.Cart
+		err := json.NewDecoder(resp.Body).Decode(&content)
+		require.NoError(t, err)
+		assert.Equal(t, mockCart, content)
+	})
+
+	t.Run("GET", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockCartErr = nil
+			resp := makeRequest(t, "GET", "/carts/tok01", "")
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, []interface{}{"GetCart", "tok01"}, mockCartCall)
+
+			var content carts.Cart
+			err := json.NewDecoder(resp.Body).Decode(&content)
+			require.NoError(t, err)
+			assert.Equal(t, mockCart, content)
+		})
+
+		t.Run("expired", func(t *testing.T) {
+			mockCartErr = service.NewErrNotFound("cart", "tok01")
+			resp := makeRequest(t, "GET", "/carts/tok01", "")
+			assert.Equal(t, http.StatusNotFound, resp.Code)
+		})
+	})
+
+	t.Run("items", func(t *testing.T) {
+		t.Run("POST", func(t *testing.T) {
+			mockCartErr = nil
+			resp := makeRequest(t, "POST", "/carts/tok01/items", `{"book_id": "abc01", "quantity": 2}`)
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, []interface{}{"AddCartItem", "tok01", "abc01", 2}, mockCartCall)
+		})
+
+		t.Run("POST without book", func(t *testing.T) {
+			mockCartErr = nil
+			resp := makeRequest(t, "POST", "/carts/tok01/items", `{"quantity": 2}`)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+
+		t.Run("POST unknown book", func(t *testing.T) {
+			mockCartErr = service.ErrInvalidReference
+			resp := makeRequest(t, "POST", "/carts/tok01/items", `{"book_id": "nope", "quantity": 2}`)
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+		})
+
+		t.Run("PUT", func(t *testing.T) {
+			mockCartErr = nil
+			resp := makeRequest(t, "PUT", "/carts/tok01/items/abc01", `{"quantity": 5}`)
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, []interface{}{"SetCartItem", "tok01", "abc01", 5}, mockCartCall)
+		})
+
+		t.Run("PUT invalid quantity", func(t *testing.T) {
+			mockCartErr = service.NewErrInvalid("quantity", errors.New("must be between 1 and 99"))
+			resp := makeRequest(t, "PUT", "/carts/tok01/items/abc01", `{"quantity": 0}`)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+
+		t.Run("DELETE", func(t *testing.T) {
+			mockCartErr = nil
+			resp := makeRequest(t, "DELETE", "/carts/tok01/items/abc01", "")
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, []interface{}{"RemoveCartItem", "tok01", "abc01"}, mockCartCall)
+		})
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockCartErr = nil
+			resp := makeRequest(t, "POST", "/carts/tok01/merge", `{"token": "tok02"}`)
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, []interface{}{"MergeCarts", "tok01", "tok02"}, mockCartCall)
+		})
+
+		t.Run("missing token", func(t *testing.T) {
+			mockCartErr = nil
+			resp := makeRequest(t, "POST", "/carts/tok01/merge", `{}`)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	})
+}
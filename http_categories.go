@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"bookshop/categories"
+
+	"github.com/gorilla/mux"
+)
+
+// categoryBody is a category's name and the ID of its parent, which is null
+// for categories at the top of the tree.
+type categoryBody struct {
+	Name     string  `json:"name"`
+	ParentID *string `json:"parent_id"`
+}
+
+// AddCategory adds a category generating its UUID, answering with the new
+// category.
+func (s *HTTPServer) AddCategory(w http.ResponseWriter, r *http.Request) {
+	var body categoryBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	c, err := s.svc.AddCategory(r.Context(), categories.Category{Name: body.Name, ParentID: body.ParentID})
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	added, err := json.Marshal(c)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	s.serve(w, r, added)
+}
+
+// GetCategory answers a request for a category along with the categories
+// beneath it.
+func (s *HTTPServer) GetCategory(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["category_id"]
+	if strings.TrimSpace(id) == "" {
+		s.handleError(w, r, "request", errors.New("category ID cannot be blank"))
+		return
+	}
+
+	c, err := s.svc.GetCategory(r.Context(), id)
+	s.serveCategory(w, r, c, err)
+}
+
+// ListCategories answers with the category tree.
+func (s *HTTPServer) ListCategories(w http.ResponseWriter, r *http.Request) {
+	cats, err := s.svc.ListCategories(r.Context())
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	catList, err := json.Marshal(cats)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+	s.serve(w, r, catList)
+}
+
+// UpdateCategory renames a category and moves it beneath the parent in the
+// request, or to the top of the tree if the parent is null.
+func (s *HTTPServer) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["category_id"]
+	if strings.TrimSpace(id) == "" {
+		s.handleError(w, r, "request", errors.New("category ID cannot be blank"))
+		return
+	}
+
+	var body categoryBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	c, err := s.svc.UpdateCategory(r.Context(), categories.Category{ID: id, Name: body.Name, ParentID: body.ParentID})
+	s.serveCategory(w, r, c, err)
+}
+
+// RemoveCategory removes a category that has no subcategories.
+func (s *HTTPServer) RemoveCategory(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["category_id"]
+	if strings.TrimSpace(id) == "" {
+		s.handleError(w, r, "request", errors.New("category ID cannot be blank"))
+		return
+	}
+
+	if err := s.svc.RemoveCategory(r.Context(), id); err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// AddBookCategory files a book under a category.
+func (s *HTTPServer) AddBookCategory(w http.ResponseWriter, r *http.Request) {
+	bkID, catID, err := bookVars(r, "category_id", "category ID")
+	if err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	if err := s.svc.AddBookCategory(r.Context(), bkID, catID); err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// RemoveBookCategory takes a book out of a category.
+func (s *HTTPServer) RemoveBookCategory(w http.ResponseWriter, r *http.Request) {
+	bkID, catID, err := bookVars(r, "category_id", "category ID")
+	if err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	if err := s.svc.RemoveBookCategory(r.Context(), bkID, catID); err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// TagBook gives a book a tag.
+func (s *HTTPServer) TagBook(w http.ResponseWriter, r *http.Request) {
+	bkID, tag, err := bookVars(r, "tag", "tag")
+	if err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	if err := s.svc.TagBook(r.Context(), bkID, tag); err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// UntagBook removes a tag from a book.
+func (s *HTTPServer) UntagBook(w http.ResponseWriter, r *http.Request) {
+	bkID, tag, err := bookVars(r, "tag", "tag")
+	if err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	if err := s.svc.UntagBook(r.Context(), bkID, tag); err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ListTags answers with every tag given to a book and how many books have it.
+func (s *HTTPServer) ListTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := s.svc.ListTags(r.Context())
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	tagList, err := json.Marshal(tags)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+	s.serve(w, r, tagList)
+}
+
+// bookVars returns the book ID and the named path variable of the request,
+// described by what in the error returned when either is blank.
+func bookVars(r *http.Request, name, what string) (string, string, error) {
+	vars := mux.Vars(r)
+	bkID, v := vars["book_id"], vars[name]
+	if strings.TrimSpace(bkID) == "" {
+		return "", "", errors.New("book ID cannot be blank")
+	}
+	if strings.TrimSpace(v) == "" {
+		return "", "", errors.New(what + " cannot be blank")
+	}
+	return bkID, v, nil
+}
+
+// serveCategory answers with the category, or with the error of the service
+// call that returned it.
+func (s *HTTPServer) serveCategory(w http.ResponseWriter, r *http.Request, c categories.Category, err error) {
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	catResp, err := json.Marshal(c)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+	s.serve(w, r, catResp)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"bookshop/books"
+	"bookshop/categories"
+	"bookshop/service"
+	"bookshop/users"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPServerCategories(t *testing.T) {
+	fiction := "fic01"
+	mockCategory = categories.Category{ID: "sci02", ParentID: &fiction, Name: "Science Fiction"}
+
+	t.Run("POST", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockCategoryErr = nil
+			resp := makeRequest(t, "POST", "/categories", `{"name": "Science Fiction", "parent_id": "fic01"}`)
+			require.Equal(t, http.StatusCreated, resp.Code)
+			assert.Equal(t, []interface{}{"AddCategory", categories.Category{Name: "Science Fiction", ParentID: &fiction}}, mockCategoryCall)
+
+			var content categories.Category
+			err := json.NewDecoder(resp.Body).Decode(&content)
+			require.NoError(t, err)
+			assert.Equal(t, mockCategory, content)
+		})
+
+		t.Run("duplicate", func(t *testing.T) {
+			mockCategoryErr = service.NewErrDuplicateCategory("Science Fiction")
+			resp := makeRequest(t, "POST", "/categories", `{"name": "Science Fiction", "parent_id": "fic01"}`)
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+		})
+
+		t.Run("forbidden", func(t *testing.T) {
+			mockCategoryErr = service.NewErrForbidden(users.PermEditBooks)
+			resp := makeRequest(t, "POST", "/categories", `{"name": "Poetry"}`)
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+		})
+	})
+
+	t.Run("GET", func(t *testing.T) {
+		t.Run("tree", func(t *testing.T) {
+			mockCategoryErr = nil
+			mockCategories = []categories.Category{{ID: fiction, Name: "Fiction", Children: []categories.Category{mockCategory}}}
+			resp := makeRequestWithToken(t, "", "GET", "/categories", "")
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var content []categories.Category
+			err := json.NewDecoder(resp.Body).Decode(&content)
+			require.NoError(t, err)
+			assert.Equal(t, mockCategories, content)
+		})
+
+		t.Run("one", func(t *testing.T) {
+			mockCategoryErr = nil
+			resp := makeRequestWithToken(t, "", "GET", "/categories/sci02", "")
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, []interface{}{"GetCategory", "sci02"}, mockCategoryCall)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			mockCategoryErr = service.NewErrNotFound("category", "sci02")
+			resp := makeRequest(t, "GET", "/categories/sci02", "")
+			assert.Equal(t, http.StatusNotFound, resp.Code)
+		})
+	})
+
+	t.Run("PATCH", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockCategoryErr = nil
+			resp := makeRequest(t, "PATCH", "/categories/sci02", `{"name": "SF", "parent_id": null}`)
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, []interface{}{"UpdateCategory", categories.Category{ID: "sci02", Name: "SF"}}, mockCategoryCall)
+		})
+
+		t.Run("beneath itself", func(t *testing.T) {
+			mockCategoryErr = service.NewErrInvalid("parent_id", errors.New("category cannot be moved beneath itself"))
+			resp := makeRequest(t, "PATCH", "/categories/fic01", `{"name": "Fiction", "parent_id": "sci02"}`)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	})
+
+	t.Run("DELETE", func(t *testing.T) {
+		mockCategoryErr = nil
+		resp := makeRequest(t, "DELETE", "/categories/sci02", "")
+		require.Equal(t, http.StatusAccepted, resp.Code)
+		assert.Equal(t, []interface{}{"RemoveCategory", "sci02"}, mockCategoryCall)
+	})
+
+	t.Run("book categories", func(t *testing.T) {
+		mockCategoryErr = nil
+		resp := makeRequest(t, "PUT", "/books/abc01/categories/sci02", "")
+		require.Equal(t, http.StatusAccepted, resp.Code)
+		assert.Equal(t, []interface{}{"AddBookCategory", "abc01", "sci02"}, mockCategoryCall)
+
+		resp = makeRequest(t, "DELETE", "/books/abc01/categories/sci02", "")
+		require.Equal(t, http.StatusAccepted, resp.Code)
+		assert.Equal(t, []interface{}{"RemoveBookCategory", "abc01", "sci02"}, mockCategoryCall)
+
+		mockCategoryErr = service.ErrInvalidReference
+		resp = makeRequest(t, "PUT", "/books/abc01/categories/unknown", "")
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		mockCategoryErr = nil
+		resp := makeRequest(t, "PUT", "/books/abc01/tags/space%20opera", "")
+		require.Equal(t, http.StatusAccepted, resp.Code)
+		assert.Equal(t, []interface{}{"TagBook", "abc01", "space opera"}, mockCategoryCall)
+
+		resp = makeRequest(t, "DELETE", "/books/abc01/tags/space", "")
+		require.Equal(t, http.StatusAccepted, resp.Code)
+		assert.Equal(t, []interface{}{"UntagBook", "abc01", "space"}, mockCategoryCall)
+
+		mockTags = []books.Tag{{Name: "space", Books: 2}}
+		resp = makeRequestWithToken(t, "", "GET", "/tags", "")
+		require.Equal(t, http.StatusOK, resp.Code)
+		var content []books.Tag
+		err := json.NewDecoder(resp.Body).Decode(&content)
+		require.NoError(t, err)
+		assert.Equal(t, mockTags, content)
+	})
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"bookshop/auth"
+
+	"github.com/gorilla/mux"
+)
+
+type keyBody struct {
+	Name   string      `json:"name"`
+	Scopes auth.Scopes `json:"scopes"`
+}
+
+// CreateAPIKey issues a user an API key for the operations named in its scopes,
+// answering with the key. It is the only time the key is shown.
+func (s *HTTPServer) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["user_id"]
+	if strings.TrimSpace(userID) == "" {
+		s.handleError(w, r, "request", errors.New("user ID cannot be blank"))
+		return
+	}
+
+	var body keyBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+	name := strings.TrimSpace(body.Name)
+	if name == "" {
+		s.handleError(w, r, "request", errors.New("api key name cannot be blank"))
+		return
+	}
+	if len(body.Scopes) == 0 {
+		s.handleError(w, r, "request", errors.New("api key scopes cannot be empty"))
+		return
+	}
+
+	k, err := s.svc.CreateAPIKey(r.Context(), userID, name, body.Scopes)
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	created, err := json.Marshal(k)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	s.serve(w, r, created)
+}
+
+// ListAPIKeys answers with a user's API keys, without the keys themselves.
+func (s *HTTPServer) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["user_id"]
+	if strings.TrimSpace(userID) == "" {
+		s.handleError(w, r, "request", errors.New("user ID cannot be blank"))
+		return
+	}
+
+	keys, err := s.svc.ListAPIKeys(r.Context(), userID)
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	keysResp, err := json.Marshal(keys)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+	s.serve(w, r, keysResp)
+}
+
+// RotateAPIKey replaces a user's API key with a new one, answering with the new
+// key. The old key stops working.
+func (s *HTTPServer) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, keyID := mux.Vars(r)["user_id"], mux.Vars(r)["key_id"]
+	if strings.TrimSpace(userID) == "" || strings.TrimSpace(keyID) == "" {
+		s.handleError(w, r, "request", errors.New("user ID and api key ID cannot be blank"))
+		return
+	}
+
+	k, err := s.svc.RotateAPIKey(r.Context(), userID, keyID)
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	rotated, err := json.Marshal(k)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+	s.serve(w, r, rotated)
+}
+
+// RevokeAPIKey deletes a user's API key.
+func (s *HTTPServer) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, keyID := mux.Vars(r)["user_id"], mux.Vars(r)["key_id"]
+	if strings.TrimSpace(userID) == "" || strings.TrimSpace(keyID) == "" {
+		s.handleError(w, r, "request", errors.New("user ID and api key ID cannot be blank"))
+		return
+	}
+
+	if err := s.svc.RevokeAPIKey(r.Context(), userID, keyID); err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	s.serve(w, r, []byte{})
+}
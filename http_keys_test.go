@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"bookshop/auth"
+	"bookshop/service"
+	"bookshop/stock"
+	"bookshop/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPServerKeys(t *testing.T) {
+	mockIssued = auth.IssuedKey{
+		APIKey: auth.APIKey{ID: "key01", UserID: "usr01", Name: "warehouse", KeyHash: "secret hash", Scopes: auth.Scopes{"ReceiveStock"}},
+		Key:    mockKey,
+	}
+
+	t.Run("middleware", func(t *testing.T) {
+		t.Run("in scope", func(t *testing.T) {
+			mockKeyScopes = auth.Scopes{"ReceiveStock", "ListBooks"}
+			mockStockErr, mockBooksErr = nil, nil
+
+			resp := makeRequestWithKey(t, mockKey, "POST", "/books/abc01/stock", `{"kind": "receive", "quantity": 2, "reason": "delivery"}`)
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, stock.Receive, mockStockMove)
+			resp = makeRequestWithKey(t, mockKey, "GET", "/books", "")
+			assert.Equal(t, http.StatusOK, resp.Code)
+		})
+
+		t.Run("out of scope", func(t *testing.T) {
+			mockKeyScopes = auth.Scopes{"ReceiveStock"}
+			mockStockErr = nil
+			mockStockMove = ""
+
+			resp := makeRequestWithKey(t, mockKey, "POST", "/books/abc01/stock", `{"kind": "adjust", "quantity": -2, "reason": "count"}`)
+			assert.Equal(t, http.StatusForbidden, resp.Code, "scopes are checked per service call, not per route")
+			assert.Empty(t, mockStockMove)
+			resp = makeRequestWithKey(t, mockKey, "GET", "/books", "")
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+		})
+
+		t.Run("bad key", func(t *testing.T) {
+			resp := makeRequestWithKey(t, "bsk_revoked", "GET", "/books", "")
+			assert.Equal(t, http.StatusUnauthorized, resp.Code)
+			resp = makeRequestWithKey(t, mockKey, "POST", "/auth/logout", "")
+			assert.Equal(t, http.StatusUnauthorized, resp.Code, "a key is not a session")
+		})
+	})
+
+	t.Run("POST", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockKeyErr = nil
+			resp := makeRequest(t, "POST", "/users/usr01/keys", `{"name": " warehouse ", "scopes": ["ReceiveStock"]}`)
+			require.Equal(t, http.StatusCreated, resp.Code)
+			assert.Equal(t, []interface{}{"CreateAPIKey", "usr01", "warehouse", auth.Scopes{"ReceiveStock"}}, mockKeyCall)
+			assert.NotContains(t, resp.Body.String(), "secret hash")
+
+			var content auth.IssuedKey
+			err := json.NewDecoder(resp.Body).Decode(&content)
+			require.NoError(t, err)
+			assert.Equal(t, mockKey, content.Key)
+			assert.Equal(t, "key01", content.ID)
+		})
+
+		t.Run("missing fields", func(t *testing.T) {
+			mockKeyErr = nil
+			for _, body := range []string{`{"scopes": ["ReceiveStock"]}`, `{"name": "warehouse"}`, `{"name": "warehouse", "scopes": "ReceiveStock"}`} {
+				resp := makeRequest(t, "POST", "/users/usr01/keys", body)
+				assert.Equal(t, http.StatusBadRequest, resp.Code, body)
+			}
+		})
+
+		t.Run("not an admin", func(t *testing.T) {
+			mockKeyErr = service.NewErrForbidden(users.PermManageUsers)
+			defer func() { mockKeyErr = nil }()
+			resp := makeRequest(t, "POST", "/users/usr01/keys", `{"name": "warehouse", "scopes": ["ReceiveStock"]}`)
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+		})
+	})
+
+	t.Run("GET", func(t *testing.T) {
+		mockKeyErr = nil
+		mockKeys = []auth.APIKey{mockIssued.APIKey}
+		resp := makeRequest(t, "GET", "/users/usr01/keys", "")
+		require.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, []interface{}{"ListAPIKeys", "usr01"}, mockKeyCall)
+		assert.NotContains(t, resp.Body.String(), mockKey)
+	})
+
+	t.Run("rotate", func(t *testing.T) {
+		mockKeyErr = nil
+		resp := makeRequest(t, "POST", "/users/usr01/keys/key01/rotate", "")
+		require.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, []interface{}{"RotateAPIKey", "usr01", "key01"}, mockKeyCall)
+
+		mockKeyErr = service.NewErrNotFound("api key", "key01")
+		defer func() { mockKeyErr = nil }()
+		resp = makeRequest(t, "POST", "/users/usr01/keys/key01/rotate", "")
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+	})
+
+	t.Run("DELETE", func(t *testing.T) {
+		mockKeyErr = nil
+		resp := makeRequest(t, "DELETE", "/users/usr01/keys/key01", "")
+		require.Equal(t, http.StatusAccepted, resp.Code)
+		assert.Equal(t, []interface{}{"RevokeAPIKey", "usr01", "key01"}, mockKeyCall)
+	})
+}
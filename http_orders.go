@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"bookshop/orders"
+
+	"github.com/gorilla/mux"
+)
+
+// orderBody is a request to place an order, either for the listed items or for
+// the contents of a cart.
+type orderBody struct {
+	CartToken string         `json:"cart_token"`
+	Items     []cartItemBody `json:"items"`
+}
+
+type orderStatusBody struct {
+	Status string `json:"status"`
+}
+
+// PlaceOrder orders the items in the request, or checks out the cart whose
+// cart_token is in the request, answering with the pending order.
+func (s *HTTPServer) PlaceOrder(w http.ResponseWriter, r *http.Request) {
+	var body orderBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+	token := strings.TrimSpace(body.CartToken)
+	if (token == "") == (len(body.Items) == 0) {
+		s.handleError(w, r, "request", errors.New("an order needs either items or a cart token"))
+		return
+	}
+
+	var o orders.Order
+	var err error
+	if token != "" {
+		o, err = s.svc.CheckoutCart(r.Context(), token)
+	} else {
+		var lines []orders.Line
+		for _, item := range body.Items {
+			if strings.TrimSpace(item.BookID) == "" {
+				s.handleError(w, r, "request", errors.New("book ID cannot be blank"))
+				return
+			}
+			lines = append(lines, orders.Line{BookID: item.BookID, Quantity: item.Quantity})
+		}
+		o, err = s.svc.PlaceOrder(r.Context(), lines)
+	}
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	placed, err := json.Marshal(o)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	s.serve(w, r, placed)
+}
+
+// GetOrder answers a request for an order with its lines and totals.
+func (s *HTTPServer) GetOrder(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["order_id"]
+	if strings.TrimSpace(id) == "" {
+		s.handleError(w, r, "request", errors.New("order ID cannot be blank"))
+		return
+	}
+
+	o, err := s.svc.GetOrder(r.Context(), id)
+	s.serveOrder(w, r, o, err)
+}
+
+// ListOrders answers requests to list a page of orders, newest first, filtered
+// by the status and user_id query parameters. Pages are selected with the limit
+// and cursor parameters.
+func (s *HTTPServer) ListOrders(w http.ResponseWriter, r *http.Request) {
+	q, err := parseOrderQuery(r.URL.Query())
+	if err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	page, err := s.svc.ListOrders(r.Context(), q)
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	orderList, err := json.Marshal(page)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+	s.serve(w, r, orderList)
+}
+
+// CancelOrder cancels an order that has not been paid for yet.
+func (s *HTTPServer) CancelOrder(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["order_id"]
+	if strings.TrimSpace(id) == "" {
+		s.handleError(w, r, "request", errors.New("order ID cannot be blank"))
+		return
+	}
+
+	o, err := s.svc.CancelOrder(r.Context(), id)
+	s.serveOrder(w, r, o, err)
+}
+
+// UpdateOrderStatus moves an order on to the status in the request.
+func (s *HTTPServer) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["order_id"]
+	if strings.TrimSpace(id) == "" {
+		s.handleError(w, r, "request", errors.New("order ID cannot be blank"))
+		return
+	}
+
+	var body orderStatusBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+	status, err := orders.ParseStatus(body.Status)
+	if err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	o, err := s.svc.UpdateOrderStatus(r.Context(), id, status)
+	s.serveOrder(w, r, o, err)
+}
+
+// serveOrder answers with the order, or with the error of the service call
+// that returned it.
+func (s *HTTPServer) serveOrder(w http.ResponseWriter, r *http.Request, o orders.Order, err error) {
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	orderResp, err := json.Marshal(o)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+	s.serve(w, r, orderResp)
+}
+
+func parseOrderQuery(v url.Values) (orders.OrderQuery, error) {
+	q := orders.OrderQuery{UserID: v.Get("user_id"), Cursor: v.Get("cursor")}
+
+	var err error
+	if raw := v.Get("status"); raw != "" {
+		if q.Status, err = orders.ParseStatus(raw); err != nil {
+			return q, err
+		}
+	}
+	if q.Limit, err = parseLimit(v.Get("limit")); err != nil {
+		return q, err
+	}
+	return q, nil
+}
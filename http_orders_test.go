@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"bookshop/auth"
+	"bookshop/books"
+	"bookshop/money"
+	"bookshop/orders"
+	"bookshop/service"
+	"bookshop/users"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPServerOrders(t *testing.T) {
+	total := money.New(2598, money.USD)
+	mockOrder = orders.Order{
+		ID:     "ord01",
+		Status: orders.Pending,
+		Lines: []orders.Line{
+			{BookID: "abc01", Title: "Dune", ISBN: "9781861972712", Quantity: 2, UnitPrice: money.New(1299, money.USD), LineTotal: total},
+		},
+		Totals: []money.Money{total},
+	}
+
+	t.Run("POST", func(t *testing.T) {
+		t.Run("items", func(t *testing.T) {
+			mockOrderErr = nil
+			resp := makeRequest(t, "POST", "/orders", `{"items": [{"book_id": "abc01", "quantity": 2}]}`)
+			require.Equal(t, http.StatusCreated, resp.Code)
+			assert.Equal(t, []interface{}{"PlaceOrder", []orders.Line{{BookID: "abc01", Quantity: 2}}}, mockOrderCall)
+
+			var content orders.Order
+			err := json.NewDecoder(resp.Body).Decode(&content)
+			require.NoError(t, err)
+			assert.Equal(t, mockOrder, content)
+		})
+
+		t.Run("cart", func(t *testing.T) {
+			mockOrderErr = nil
+			resp := makeRequest(t, "POST", "/orders", `{"cart_token": "tok01"}`)
+			require.Equal(t, http.StatusCreated, resp.Code)
+			assert.Equal(t, []interface{}{"CheckoutCart", "tok01"}, mockOrderCall)
+		})
+
+		t.Run("items and cart", func(t *testing.T) {
+			mockOrderErr, mockOrderCall = nil, nil
+			for _, body := range []string{
+				`{}`,
+				`{"cart_token": "tok01", "items": [{"book_id": "abc01", "quantity": 2}]}`,
+				`{"items": [{"quantity": 2}]}`,
+			} {
+				resp := makeRequest(t, "POST", "/orders", body)
+				assert.Equal(t, http.StatusBadRequest, resp.Code, body)
+			}
+			assert.Nil(t, mockOrderCall)
+		})
+
+		t.Run("not for sale", func(t *testing.T) {
+			mockOrderErr = service.NewErrInvalid("book", errors.New("book abc01 is not for sale"))
+			resp := makeRequest(t, "POST", "/orders", `{"items": [{"book_id": "abc01", "quantity": 2}]}`)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	})
+
+	t.Run("GET", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockOrderErr = nil
+			resp := makeRequest(t, "GET", "/orders/ord01", "")
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, []interface{}{"GetOrder", "ord01"}, mockOrderCall)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			mockOrderErr = service.NewErrNotFound("order", "ord01")
+			resp := makeRequest(t, "GET", "/orders/ord01", "")
+			assert.Equal(t, http.StatusNotFound, resp.Code)
+		})
+	})
+
+	t.Run("list", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockOrderErr = nil
+			mockOrders = orders.Page{Orders: []orders.Order{mockOrder}, NextCursor: "next"}
+			resp := makeRequest(t, "GET", "/orders?status=Paid&limit=5&user_id=usr02", "")
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, []interface{}{"ListOrders", orders.OrderQuery{Status: orders.Paid, UserID: "usr02", Limit: 5}}, mockOrderCall)
+
+			var content orders.Page
+			err := json.NewDecoder(resp.Body).Decode(&content)
+			require.NoError(t, err)
+			assert.Equal(t, mockOrders, content)
+		})
+
+		t.Run("unknown status", func(t *testing.T) {
+			mockOrderErr = nil
+			resp := makeRequest(t, "GET", "/orders?status=lost", "")
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockOrderErr = nil
+			resp := makeRequest(t, "POST", "/orders/ord01/cancel", "")
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, []interface{}{"CancelOrder", "ord01"}, mockOrderCall)
+		})
+
+		t.Run("illegal transition", func(t *testing.T) {
+			mockOrderErr = service.NewErrIllegalTransition("ord01", orders.Shipped, orders.Cancelled)
+			resp := makeRequest(t, "POST", "/orders/ord01/cancel", "")
+			assert.Equal(t, http.StatusConflict, resp.Code)
+		})
+	})
+
+	t.Run("status", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockOrderErr = nil
+			resp := makeRequest(t, "PUT", "/orders/ord01/status", `{"status": "paid"}`)
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, []interface{}{"UpdateOrderStatus", "ord01", orders.Paid}, mockOrderCall)
+		})
+
+		t.Run("unknown status", func(t *testing.T) {
+			mockOrderErr = nil
+			resp := makeRequest(t, "PUT", "/orders/ord01/status", `{"status": "lost"}`)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+
+		t.Run("illegal transition", func(t *testing.T) {
+			mockOrderErr = service.NewErrIllegalTransition("ord01", orders.Cancelled, orders.Paid)
+			resp := makeRequest(t, "PUT", "/orders/ord01/status", `{"status": "paid"}`)
+			assert.Equal(t, http.StatusConflict, resp.Code)
+		})
+	})
+}
+
+func TestHTTPServerOrdersOwnership(t *testing.T) {
+	ctx := context.Background()
+	srv, svc := newMemoryServer()
+	staff := auth.WithUser(ctx, users.User{ID: "usr00", Role: users.RoleStaff, Permissions: users.RolePermissions[users.RoleStaff]})
+	price := money.New(1299, money.USD)
+	bk, err := svc.AddBook(staff, "Dune", "9781861972712", books.Details{}, &price)
+	require.NoError(t, err)
+	signIn := func(email string) string {
+		_, err := svc.RegisterUser(ctx, users.User{Email: email, FirstName: "First", LastName: "Last"}, "correct horse")
+		require.NoError(t, err)
+		creds, err := svc.Login(ctx, email, "correct horse")
+		require.NoError(t, err)
+		return creds.Token
+	}
+	annToken, bobToken := signIn("ann@example.com"), signIn("bob@example.com")
+
+	resp := makeRequestTo(t, srv, annToken, "POST", "/orders", `{"items": [{"book_id": "`+bk.ID+`", "quantity": 1}]}`)
+	require.Equal(t, http.StatusCreated, resp.Code)
+	var placed orders.Order
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&placed))
+
+	for _, req := range [][3]string{
+		{"GET", "/orders/" + placed.ID, ""},
+		{"POST", "/orders/" + placed.ID + "/cancel", ""},
+		{"PUT", "/orders/" + placed.ID + "/status", `{"status": "paid"}`},
+	} {
+		resp := makeRequestTo(t, srv, bobToken, req[0], req[1], req[2])
+		assert.Equal(t, http.StatusForbidden, resp.Code, req[:2])
+	}
+	resp = makeRequestTo(t, srv, bobToken, "GET", "/orders", "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	var page orders.Page
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	assert.Empty(t, page.Orders, "users only list their own orders")
+
+	resp = makeRequestTo(t, srv, annToken, "PUT", "/orders/"+placed.ID+"/status", `{"status": "paid"}`)
+	assert.Equal(t, http.StatusForbidden, resp.Code, "only staff move orders on")
+	resp = makeRequestTo(t, srv, annToken, "GET", "/orders", "")
+	require.Equal(t, http.StatusOK, resp.Code)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	require.Len(t, page.Orders, 1)
+	assert.Equal(t, placed.ID, page.Orders[0].ID)
+	resp = makeRequestTo(t, srv, annToken, "POST", "/orders/"+placed.ID+"/cancel", "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+}
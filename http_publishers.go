@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"bookshop/publishers"
+
+	"github.com/gorilla/mux"
+)
+
+// publisherBody is a publisher's name and website.
+type publisherBody struct {
+	Name    string `json:"name"`
+	Website string `json:"website"`
+}
+
+// AddPublisher adds a publisher generating its UUID if it doesn't already exist.
+func (s *HTTPServer) AddPublisher(w http.ResponseWriter, r *http.Request) {
+	var body publisherBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	created, err := s.svc.AddPublisher(r.Context(), publishers.Publisher{Name: body.Name, Website: body.Website})
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	added, err := json.Marshal(created)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	s.serve(w, r, added)
+}
+
+// GetPublisher answers a request for a single publisher and their books.
+func (s *HTTPServer) GetPublisher(w http.ResponseWriter, r *http.Request) {
+	pubID := mux.Vars(r)["publisher_id"]
+	if strings.TrimSpace(pubID) == "" {
+		s.handleError(w, r, "request", errors.New("publisher ID cannot be blank"))
+		return
+	}
+
+	pub, err := s.svc.GetPublisher(r.Context(), pubID)
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	pubResp, err := json.Marshal(pub)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+	s.serve(w, r, pubResp)
+}
+
+// ListPublishers answers requests to list a page of publishers (minus books)
+// filtered by the name query parameter. Pages are selected with the limit and
+// cursor parameters.
+func (s *HTTPServer) ListPublishers(w http.ResponseWriter, r *http.Request) {
+	q, err := parsePublisherQuery(r.URL.Query())
+	if err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	pubs, err := s.svc.ListPublishers(r.Context(), q)
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	pubList, err := json.Marshal(pubs)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+	s.serve(w, r, pubList)
+}
+
+// RemovePublisher removes a publisher by its UUID if it exists.
+func (s *HTTPServer) RemovePublisher(w http.ResponseWriter, r *http.Request) {
+	pubID := mux.Vars(r)["publisher_id"]
+	if strings.TrimSpace(pubID) == "" {
+		s.handleError(w, r, "request", errors.New("publisher ID cannot be blank"))
+		return
+	}
+
+	if err := s.svc.RemovePublisher(r.Context(), pubID); err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	s.serve(w, r, []byte{})
+}
+
+// UpdatePublisher updates a publisher based on its UUID.
+func (s *HTTPServer) UpdatePublisher(w http.ResponseWriter, r *http.Request) {
+	pubID := mux.Vars(r)["publisher_id"]
+	if strings.TrimSpace(pubID) == "" {
+		s.handleError(w, r, "request", errors.New("publisher ID cannot be blank"))
+		return
+	}
+
+	var body publisherBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	pub := publishers.Publisher{ID: pubID, Name: body.Name, Website: body.Website}
+	if err := s.svc.UpdatePublisher(r.Context(), pub); err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	s.serve(w, r, []byte{})
+}
+
+func parsePublisherQuery(v url.Values) (publishers.PublisherQuery, error) {
+	q := publishers.PublisherQuery{
+		NamePrefix: v.Get("name"),
+		Cursor:     v.Get("cursor"),
+	}
+
+	var err error
+	if q.Limit, err = parseLimit(v.Get("limit")); err != nil {
+		return q, err
+	}
+	return q, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"bookshop/books"
+	"bookshop/publishers"
+	"bookshop/service"
+	"bookshop/users"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPServerPublishers(t *testing.T) {
+	mockPublisher = publishers.Publisher{ID: "pub01", Name: "Chilton Books", Website: "https://chilton.example.com"}
+
+	t.Run("POST", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockPublisherErr = nil
+			resp := makeRequest(t, "POST", "/publishers", `{"name": "Chilton Books", "website": "https://chilton.example.com"}`)
+			require.Equal(t, http.StatusCreated, resp.Code)
+			assert.Equal(t, []interface{}{"AddPublisher", publishers.Publisher{Name: "Chilton Books", Website: "https://chilton.example.com"}}, mockPublisherCall)
+
+			var content publishers.Publisher
+			err := json.NewDecoder(resp.Body).Decode(&content)
+			require.NoError(t, err)
+			assert.Equal(t, mockPublisher, content)
+		})
+
+		t.Run("duplicate", func(t *testing.T) {
+			mockPublisherErr = service.NewErrDuplicatePublisher("Chilton Books")
+			resp := makeRequest(t, "POST", "/publishers", `{"name": "Chilton Books"}`)
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+		})
+
+		t.Run("invalid", func(t *testing.T) {
+			mockPublisherErr = service.NewErrInvalid("publisher", errors.New("publisher name cannot be blank"))
+			resp := makeRequest(t, "POST", "/publishers", `{"name": " "}`)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+
+		t.Run("forbidden", func(t *testing.T) {
+			mockPublisherErr = service.NewErrForbidden(users.PermEditBooks)
+			resp := makeRequest(t, "POST", "/publishers", `{"name": "Ace"}`)
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+		})
+	})
+
+	t.Run("GET", func(t *testing.T) {
+		t.Run("with books", func(t *testing.T) {
+			mockPublisherErr = nil
+			mockPublisher.Books = []books.Book{{ID: "abc01", Title: "Dune", ISBN: "9780441013593"}}
+			defer func() { mockPublisher.Books = nil }()
+
+			resp := makeRequestWithToken(t, "", "GET", "/publishers/pub01", "")
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, []interface{}{"GetPublisher", "pub01"}, mockPublisherCall)
+
+			var content publishers.Publisher
+			err := json.NewDecoder(resp.Body).Decode(&content)
+			require.NoError(t, err)
+			assert.Equal(t, mockPublisher, content)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			mockPublisherErr = service.NewErrNotFound("publisher", "nope")
+			resp := makeRequestWithToken(t, "", "GET", "/publishers/nope", "")
+			assert.Equal(t, http.StatusNotFound, resp.Code)
+		})
+
+		t.Run("list", func(t *testing.T) {
+			mockPublisherErr = nil
+			mockPublishers = publishers.Page{Publishers: []publishers.Publisher{mockPublisher}, NextCursor: "next"}
+			resp := makeRequestWithToken(t, "", "GET", "/publishers?name=chil&limit=5&cursor=abc", "")
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, []interface{}{"ListPublishers", publishers.PublisherQuery{NamePrefix: "chil", Limit: 5, Cursor: "abc"}}, mockPublisherCall)
+
+			var content publishers.Page
+			err := json.NewDecoder(resp.Body).Decode(&content)
+			require.NoError(t, err)
+			assert.Equal(t, mockPublishers, content)
+		})
+
+		t.Run("bad limit", func(t *testing.T) {
+			resp := makeRequestWithToken(t, "", "GET", "/publishers?limit=lots", "")
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	})
+
+	t.Run("PATCH", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockPublisherErr = nil
+			resp := makeRequest(t, "PATCH", "/publishers/pub01", `{"name": "Chilton Company"}`)
+			require.Equal(t, http.StatusAccepted, resp.Code)
+			assert.Equal(t, []interface{}{"UpdatePublisher", publishers.Publisher{ID: "pub01", Name: "Chilton Company"}}, mockPublisherCall)
+		})
+
+		t.Run("bad body", func(t *testing.T) {
+			resp := makeRequest(t, "PATCH", "/publishers/pub01", `{"name": `)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	})
+
+	t.Run("DELETE", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockPublisherErr = nil
+			resp := makeRequest(t, "DELETE", "/publishers/pub01", "")
+			require.Equal(t, http.StatusAccepted, resp.Code)
+			assert.Equal(t, []interface{}{"RemovePublisher", "pub01"}, mockPublisherCall)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			mockPublisherErr = service.NewErrNotFound("publisher", "pub01")
+			resp := makeRequest(t, "DELETE", "/publishers/pub01", "")
+			assert.Equal(t, http.StatusNotFound, resp.Code)
+		})
+
+		t.Run("signed out", func(t *testing.T) {
+			resp := makeRequestWithToken(t, "", "DELETE", "/publishers/pub01", "")
+			assert.Equal(t, http.StatusUnauthorized, resp.Code)
+		})
+	})
+}
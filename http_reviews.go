@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"bookshop/reviews"
+
+	"github.com/gorilla/mux"
+)
+
+// reviewBody is a rating and review of a book, as written by its reviewer.
+type reviewBody struct {
+	Rating int    `json:"rating"`
+	Body   string `json:"body"`
+}
+
+type reviewStatusBody struct {
+	Status string `json:"status"`
+}
+
+// AddReview adds the signed in user's review of the book, answering with the
+// review awaiting moderation.
+func (s *HTTPServer) AddReview(w http.ResponseWriter, r *http.Request) {
+	bookID := mux.Vars(r)["book_id"]
+	if strings.TrimSpace(bookID) == "" {
+		s.handleError(w, r, "request", errors.New("book ID cannot be blank"))
+		return
+	}
+
+	var body reviewBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	rev, err := s.svc.AddReview(r.Context(), reviews.Review{BookID: bookID, Rating: body.Rating, Body: body.Body})
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	added, err := json.Marshal(rev)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	s.serve(w, r, added)
+}
+
+// GetReview answers a request for one of the book's reviews.
+func (s *HTTPServer) GetReview(w http.ResponseWriter, r *http.Request) {
+	bookID, id, ok := s.reviewVars(w, r)
+	if !ok {
+		return
+	}
+
+	rev, err := s.svc.GetReview(r.Context(), bookID, id)
+	s.serveReview(w, r, rev, err)
+}
+
+// ListReviews answers requests to list a page of the book's reviews, newest
+// first, filtered by the status query parameter. Pages are selected with the
+// limit and cursor parameters.
+func (s *HTTPServer) ListReviews(w http.ResponseWriter, r *http.Request) {
+	bookID := mux.Vars(r)["book_id"]
+	if strings.TrimSpace(bookID) == "" {
+		s.handleError(w, r, "request", errors.New("book ID cannot be blank"))
+		return
+	}
+	q, err := parseReviewQuery(r.URL.Query())
+	if err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	page, err := s.svc.ListReviews(r.Context(), bookID, q)
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	reviewList, err := json.Marshal(page)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+	s.serve(w, r, reviewList)
+}
+
+// UpdateReview replaces the rating and text of the signed in user's review
+// with those in the request.
+func (s *HTTPServer) UpdateReview(w http.ResponseWriter, r *http.Request) {
+	bookID, id, ok := s.reviewVars(w, r)
+	if !ok {
+		return
+	}
+
+	var body reviewBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	rev, err := s.svc.UpdateReview(r.Context(), reviews.Review{ID: id, BookID: bookID, Rating: body.Rating, Body: body.Body})
+	s.serveReview(w, r, rev, err)
+}
+
+// ModerateReview moves a review to the status in the request.
+func (s *HTTPServer) ModerateReview(w http.ResponseWriter, r *http.Request) {
+	bookID, id, ok := s.reviewVars(w, r)
+	if !ok {
+		return
+	}
+
+	var body reviewStatusBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+	status, err := reviews.ParseStatus(body.Status)
+	if err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	rev, err := s.svc.ModerateReview(r.Context(), bookID, id, status)
+	s.serveReview(w, r, rev, err)
+}
+
+// RemoveReview deletes one of the book's reviews.
+func (s *HTTPServer) RemoveReview(w http.ResponseWriter, r *http.Request) {
+	bookID, id, ok := s.reviewVars(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.svc.RemoveReview(r.Context(), bookID, id); err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// reviewVars returns the book and review IDs in the request path, answering
+// the request with an error if either is blank.
+func (s *HTTPServer) reviewVars(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	vars := mux.Vars(r)
+	if strings.TrimSpace(vars["book_id"]) == "" {
+		s.handleError(w, r, "request", errors.New("book ID cannot be blank"))
+		return "", "", false
+	}
+	if strings.TrimSpace(vars["review_id"]) == "" {
+		s.handleError(w, r, "request", errors.New("review ID cannot be blank"))
+		return "", "", false
+	}
+	return vars["book_id"], vars["review_id"], true
+}
+
+// serveReview answers with the review, or with the error of the service call
+// that returned it.
+func (s *HTTPServer) serveReview(w http.ResponseWriter, r *http.Request, rev reviews.Review, err error) {
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	reviewResp, err := json.Marshal(rev)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+	s.serve(w, r, reviewResp)
+}
+
+func parseReviewQuery(v url.Values) (reviews.ReviewQuery, error) {
+	q := reviews.ReviewQuery{Cursor: v.Get("cursor")}
+
+	var err error
+	if raw := v.Get("status"); raw != "" {
+		if q.Status, err = reviews.ParseStatus(raw); err != nil {
+			return q, err
+		}
+	}
+	if q.Limit, err = parseLimit(v.Get("limit")); err != nil {
+		return q, err
+	}
+	return q, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"bookshop/reviews"
+	"bookshop/service"
+	"bookshop/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPServerReviews(t *testing.T) {
+	mockReview = reviews.Review{ID: "rev01", BookID: "abc01", UserID: "usr01", Rating: 4, Body: "Gripping.", Status: reviews.Pending}
+
+	t.Run("POST", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockReviewErr = nil
+			resp := makeRequest(t, "POST", "/books/abc01/reviews", `{"rating": 4, "body": "Gripping."}`)
+			require.Equal(t, http.StatusCreated, resp.Code)
+			assert.Equal(t, []interface{}{"AddReview", reviews.Review{BookID: "abc01", Rating: 4, Body: "Gripping."}}, mockReviewCall)
+
+			var content reviews.Review
+			err := json.NewDecoder(resp.Body).Decode(&content)
+			require.NoError(t, err)
+			assert.Equal(t, mockReview, content)
+		})
+
+		t.Run("already reviewed", func(t *testing.T) {
+			mockReviewErr = service.NewErrDuplicateReview("abc01")
+			resp := makeRequest(t, "POST", "/books/abc01/reviews", `{"rating": 4}`)
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+		})
+
+		t.Run("anonymous", func(t *testing.T) {
+			mockReviewErr, mockReviewCall = nil, nil
+			resp := makeRequestWithToken(t, "", "POST", "/books/abc01/reviews", `{"rating": 4}`)
+			assert.Equal(t, http.StatusUnauthorized, resp.Code)
+			assert.Nil(t, mockReviewCall)
+		})
+	})
+
+	t.Run("GET", func(t *testing.T) {
+		t.Run("anonymous", func(t *testing.T) {
+			mockReviewErr = nil
+			resp := makeRequestWithToken(t, "", "GET", "/books/abc01/reviews/rev01", "")
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, []interface{}{"GetReview", "abc01", "rev01"}, mockReviewCall)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			mockReviewErr = service.NewErrNotFound("review", "rev01")
+			resp := makeRequest(t, "GET", "/books/abc01/reviews/rev01", "")
+			assert.Equal(t, http.StatusNotFound, resp.Code)
+		})
+	})
+
+	t.Run("list", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockReviewErr = nil
+			mockReviews = reviews.Page{Reviews: []reviews.Review{mockReview}, NextCursor: "next"}
+			resp := makeRequestWithToken(t, "", "GET", "/books/abc01/reviews?status=Pending&limit=5", "")
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, []interface{}{"ListReviews", "abc01", reviews.ReviewQuery{Status: reviews.Pending, Limit: 5}}, mockReviewCall)
+
+			var content reviews.Page
+			err := json.NewDecoder(resp.Body).Decode(&content)
+			require.NoError(t, err)
+			assert.Equal(t, mockReviews, content)
+		})
+
+		t.Run("unknown status", func(t *testing.T) {
+			mockReviewErr = nil
+			resp := makeRequest(t, "GET", "/books/abc01/reviews?status=lost", "")
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	})
+
+	t.Run("PATCH", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockReviewErr = nil
+			resp := makeRequest(t, "PATCH", "/books/abc01/reviews/rev01", `{"rating": 2, "body": "Dragged."}`)
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, []interface{}{"UpdateReview", reviews.Review{ID: "rev01", BookID: "abc01", Rating: 2, Body: "Dragged."}}, mockReviewCall)
+		})
+
+		t.Run("not owner", func(t *testing.T) {
+			mockReviewErr = service.NewErrNotOwner("review", "rev01")
+			resp := makeRequest(t, "PATCH", "/books/abc01/reviews/rev01", `{"rating": 2}`)
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+		})
+	})
+
+	t.Run("status", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockReviewErr = nil
+			resp := makeRequest(t, "PUT", "/books/abc01/reviews/rev01/status", `{"status": "approved"}`)
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, []interface{}{"ModerateReview", "abc01", "rev01", reviews.Approved}, mockReviewCall)
+		})
+
+		t.Run("unknown status", func(t *testing.T) {
+			mockReviewErr, mockReviewCall = nil, nil
+			resp := makeRequest(t, "PUT", "/books/abc01/reviews/rev01/status", `{"status": "hidden"}`)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			assert.Nil(t, mockReviewCall)
+		})
+
+		t.Run("forbidden", func(t *testing.T) {
+			mockReviewErr = service.NewErrForbidden(users.PermModerateReviews)
+			resp := makeRequest(t, "PUT", "/books/abc01/reviews/rev01/status", `{"status": "approved"}`)
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+		})
+	})
+
+	t.Run("DELETE", func(t *testing.T) {
+		mockReviewErr = nil
+		resp := makeRequest(t, "DELETE", "/books/abc01/reviews/rev01", "")
+		require.Equal(t, http.StatusAccepted, resp.Code)
+		assert.Equal(t, []interface{}{"RemoveReview", "abc01", "rev01"}, mockReviewCall)
+	})
+}
@@ -1,213 +1,788 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
+	"bookshop/authors"
 	"bookshop/books"
+	"bookshop/money"
 	"bookshop/service"
+	"bookshop/stock"
 
 	"github.com/gorilla/mux"
 )
 
 // HTTPServer is a struct for encompassing a book service and router for answering http requests.
 type HTTPServer struct {
-	svc    service.SVC
-	router *mux.Router
+	svc      service.SVC
+	router   *mux.Router
+	timeouts Timeouts
 }
 
-// NewHTTPServer returns an HTTPServer type value and sets up routing.
-func NewHTTPServer(svc service.SVC) *HTTPServer {
+// Timeouts bounds how long a request may spend in service calls before it is
+// answered with a 504. Routes are named after their handlers, and those missing
+// from Routes use Default. A zero duration means no timeout.
+type Timeouts struct {
+	Default time.Duration
+	Routes  map[string]time.Duration
+}
+
+// NewHTTPServer returns an HTTPServer type value and sets up routing. Requests
+// made with an API key may only make the service calls in the key's scopes.
+func NewHTTPServer(svc service.SVC, timeouts Timeouts) *HTTPServer {
 	r := mux.NewRouter()
 	s := HTTPServer{
-		svc:    svc,
-		router: r,
+		svc:      service.Scoped(svc),
+		router:   r,
+		timeouts: timeouts,
 	}
 
 	bookRouter := s.router.PathPrefix("/books").Subrouter()
 	{
-		bookRouter.Methods(http.MethodGet).HandlerFunc(s.ListBooks)
-		bookRouter.Methods(http.MethodPost).HandlerFunc(s.AddBook)
-		bookRouter.Methods(http.MethodPatch).HandlerFunc(s.UpdateBook)
+		bookRouter.Methods(http.MethodPut).Path("/{book_id}/authors/{author_id}").HandlerFunc(s.AddBookAuthor).Name("AddBookAuthor")
+		bookRouter.Methods(http.MethodDelete).Path("/{book_id}/authors/{author_id}").HandlerFunc(s.RemoveBookAuthor).Name("RemoveBookAuthor")
+
+		bookRouter.Methods(http.MethodPut).Path("/{book_id}/categories/{category_id}").HandlerFunc(s.AddBookCategory).Name("AddBookCategory")
+		bookRouter.Methods(http.MethodDelete).Path("/{book_id}/categories/{category_id}").HandlerFunc(s.RemoveBookCategory).Name("RemoveBookCategory")
+		bookRouter.Methods(http.MethodPut).Path("/{book_id}/tags/{tag}").HandlerFunc(s.TagBook).Name("TagBook")
+		bookRouter.Methods(http.MethodDelete).Path("/{book_id}/tags/{tag}").HandlerFunc(s.UntagBook).Name("UntagBook")
+
+		bookRouter.Methods(http.MethodGet).Path("/{book_id}/prices").HandlerFunc(s.ListBookPrices).Name("ListBookPrices")
+		bookRouter.Methods(http.MethodPost).Path("/{book_id}/prices").HandlerFunc(s.SetBookPrice).Name("SetBookPrice")
+
+		bookRouter.Methods(http.MethodPut).Path("/{book_id}/reviews/{review_id}/status").HandlerFunc(s.ModerateReview).Name("ModerateReview")
+		bookRouter.Methods(http.MethodGet).Path("/{book_id}/reviews/{review_id}").HandlerFunc(s.GetReview).Name("GetReview")
+		bookRouter.Methods(http.MethodPatch).Path("/{book_id}/reviews/{review_id}").HandlerFunc(s.UpdateReview).Name("UpdateReview")
+		bookRouter.Methods(http.MethodDelete).Path("/{book_id}/reviews/{review_id}").HandlerFunc(s.RemoveReview).Name("RemoveReview")
+		bookRouter.Methods(http.MethodGet).Path("/{book_id}/reviews").HandlerFunc(s.ListReviews).Name("ListReviews")
+		bookRouter.Methods(http.MethodPost).Path("/{book_id}/reviews").HandlerFunc(s.AddReview).Name("AddReview")
 
-		bookRouter.Methods(http.MethodDelete).Path("/{book_id}").HandlerFunc(s.RemoveBook)
+		bookRouter.Methods(http.MethodGet).Path("/{book_id}/stock").HandlerFunc(s.GetStock).Name("GetStock")
+		bookRouter.Methods(http.MethodPost).Path("/{book_id}/stock").HandlerFunc(s.MoveStock).Name("MoveStock")
+
+		bookRouter.Methods(http.MethodGet).Path("/{book_id}").HandlerFunc(s.GetBook).Name("GetBook")
+		bookRouter.Methods(http.MethodDelete).Path("/{book_id}").HandlerFunc(s.RemoveBook).Name("RemoveBook")
+
+		bookRouter.Methods(http.MethodGet).HandlerFunc(s.ListBooks).Name("ListBooks")
+		bookRouter.Methods(http.MethodPost).HandlerFunc(s.AddBook).Name("AddBook")
+		bookRouter.Methods(http.MethodPatch).HandlerFunc(s.UpdateBook).Name("UpdateBook")
 	}
 
 	authRouter := s.router.PathPrefix("/authors").Subrouter()
 	{
-		authRouter.Methods(http.MethodGet).Path("/{author_id}").HandlerFunc(s.GetAuthor)
-		authRouter.Methods(http.MethodDelete).Path("/{author_id}").HandlerFunc(s.RemoveAuthor)
+		authRouter.Methods(http.MethodGet).Path("/{author_id}").HandlerFunc(s.GetAuthor).Name("GetAuthor")
+		authRouter.Methods(http.MethodPatch).Path("/{author_id}").HandlerFunc(s.UpdateAuthor).Name("UpdateAuthor")
+		authRouter.Methods(http.MethodDelete).Path("/{author_id}").HandlerFunc(s.RemoveAuthor).Name("RemoveAuthor")
 
-		authRouter.Methods(http.MethodGet).HandlerFunc(s.ListAuthors)
+		authRouter.Methods(http.MethodGet).HandlerFunc(s.ListAuthors).Name("ListAuthors")
+		authRouter.Methods(http.MethodPost).HandlerFunc(s.AddAuthor).Name("AddAuthor")
 	}
+	publisherRouter := s.router.PathPrefix("/publishers").Subrouter()
+	{
+		publisherRouter.Methods(http.MethodGet).Path("/{publisher_id}").HandlerFunc(s.GetPublisher).Name("GetPublisher")
+		publisherRouter.Methods(http.MethodPatch).Path("/{publisher_id}").HandlerFunc(s.UpdatePublisher).Name("UpdatePublisher")
+		publisherRouter.Methods(http.MethodDelete).Path("/{publisher_id}").HandlerFunc(s.RemovePublisher).Name("RemovePublisher")
+
+		publisherRouter.Methods(http.MethodGet).HandlerFunc(s.ListPublishers).Name("ListPublishers")
+		publisherRouter.Methods(http.MethodPost).HandlerFunc(s.AddPublisher).Name("AddPublisher")
+	}
+	categoryRouter := s.router.PathPrefix("/categories").Subrouter()
+	{
+		categoryRouter.Methods(http.MethodGet).Path("/{category_id}").HandlerFunc(s.GetCategory).Name("GetCategory")
+		categoryRouter.Methods(http.MethodPatch).Path("/{category_id}").HandlerFunc(s.UpdateCategory).Name("UpdateCategory")
+		categoryRouter.Methods(http.MethodDelete).Path("/{category_id}").HandlerFunc(s.RemoveCategory).Name("RemoveCategory")
+
+		categoryRouter.Methods(http.MethodGet).HandlerFunc(s.ListCategories).Name("ListCategories")
+		categoryRouter.Methods(http.MethodPost).HandlerFunc(s.AddCategory).Name("AddCategory")
+	}
+
+	cartRouter := s.router.PathPrefix("/carts").Subrouter()
+	{
+		cartRouter.Methods(http.MethodPut).Path("/{token}/items/{book_id}").HandlerFunc(s.SetCartItem).Name("SetCartItem")
+		cartRouter.Methods(http.MethodDelete).Path("/{token}/items/{book_id}").HandlerFunc(s.RemoveCartItem).Name("RemoveCartItem")
+		cartRouter.Methods(http.MethodPost).Path("/{token}/items").HandlerFunc(s.AddCartItem).Name("AddCartItem")
+		cartRouter.Methods(http.MethodPost).Path("/{token}/merge").HandlerFunc(s.MergeCarts).Name("MergeCarts")
+
+		cartRouter.Methods(http.MethodGet).Path("/{token}").HandlerFunc(s.GetCart).Name("GetCart")
+
+		cartRouter.Methods(http.MethodPost).HandlerFunc(s.CreateCart).Name("CreateCart")
+	}
+
+	orderRouter := s.router.PathPrefix("/orders").Subrouter()
+	{
+		orderRouter.Methods(http.MethodPost).Path("/{order_id}/cancel").HandlerFunc(s.CancelOrder).Name("CancelOrder")
+		orderRouter.Methods(http.MethodPut).Path("/{order_id}/status").HandlerFunc(s.UpdateOrderStatus).Name("UpdateOrderStatus")
+
+		orderRouter.Methods(http.MethodGet).Path("/{order_id}").HandlerFunc(s.GetOrder).Name("GetOrder")
+
+		orderRouter.Methods(http.MethodGet).HandlerFunc(s.ListOrders).Name("ListOrders")
+		orderRouter.Methods(http.MethodPost).HandlerFunc(s.PlaceOrder).Name("PlaceOrder")
+	}
+
+	userRouter := s.router.PathPrefix("/users").Subrouter()
+	{
+		userRouter.Methods(http.MethodPut).Path("/{user_id}/password").HandlerFunc(s.ChangePassword).Name("ChangePassword")
+		userRouter.Methods(http.MethodPut).Path("/{user_id}/enabled").HandlerFunc(s.SetUserEnabled).Name("SetUserEnabled")
+		userRouter.Methods(http.MethodPut).Path("/{user_id}/role").HandlerFunc(s.SetUserRole).Name("SetUserRole")
+		userRouter.Methods(http.MethodDelete).Path("/{user_id}/sessions").HandlerFunc(s.RevokeSessions).Name("RevokeSessions")
+		userRouter.Methods(http.MethodPost).Path("/{user_id}/keys/{key_id}/rotate").HandlerFunc(s.RotateAPIKey).Name("RotateAPIKey")
+		userRouter.Methods(http.MethodDelete).Path("/{user_id}/keys/{key_id}").HandlerFunc(s.RevokeAPIKey).Name("RevokeAPIKey")
+		userRouter.Methods(http.MethodGet).Path("/{user_id}/keys").HandlerFunc(s.ListAPIKeys).Name("ListAPIKeys")
+		userRouter.Methods(http.MethodPost).Path("/{user_id}/keys").HandlerFunc(s.CreateAPIKey).Name("CreateAPIKey")
+
+		userRouter.Methods(http.MethodGet).Path("/{user_id}").HandlerFunc(s.GetUser).Name("GetUser")
+		userRouter.Methods(http.MethodPatch).Path("/{user_id}").HandlerFunc(s.UpdateUser).Name("UpdateUser")
+
+		userRouter.Methods(http.MethodPost).HandlerFunc(s.RegisterUser).Name("RegisterUser")
+	}
+
+	sessionRouter := s.router.PathPrefix("/auth").Subrouter()
+	{
+		sessionRouter.Methods(http.MethodPost).Path("/login").HandlerFunc(s.Login).Name("Login")
+		sessionRouter.Methods(http.MethodPost).Path("/refresh").HandlerFunc(s.RefreshSession).Name("RefreshSession")
+		sessionRouter.Methods(http.MethodPost).Path("/logout").HandlerFunc(s.Logout).Name("Logout")
+		sessionRouter.Methods(http.MethodGet).Path("/me").HandlerFunc(s.CurrentUser).Name("CurrentUser")
+	}
+
+	s.router.Methods(http.MethodGet).Path("/tags").HandlerFunc(s.ListTags).Name("ListTags")
+	s.router.Methods(http.MethodGet).Path("/search").HandlerFunc(s.Search).Name("Search")
+	s.router.Use(s.withTimeout, s.authenticate)
 	return &s
 }
 
+// routeNames returns the names of the server's routes, which the route timeouts
+// refer to.
+func (s *HTTPServer) routeNames() map[string]bool {
+	names := map[string]bool{}
+	s.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if name := route.GetName(); name != "" {
+			names[name] = true
+		}
+		return nil
+	})
+	return names
+}
+
+// withTimeout gives the request context the deadline configured for its route.
+func (s *HTTPServer) withTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := s.timeouts.Default
+		if route := mux.CurrentRoute(r); route != nil {
+			if d, ok := s.timeouts.Routes[route.GetName()]; ok {
+				timeout = d
+			}
+		}
+		if timeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 // ServeHTTP wraps router ServeHTTP calls calls.
 func (s *HTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	s.router.ServeHTTP(w, r)
 }
 
+// AddAuthor adds an author generating its UUID if it doesn't already exist.
+func (s *HTTPServer) AddAuthor(w http.ResponseWriter, r *http.Request) {
+	var auth authors.Author
+	if err := json.NewDecoder(r.Body).Decode(&auth); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	if err := validateAuthor(auth); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	created, err := s.svc.AddAuthor(r.Context(), auth)
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	added, err := json.Marshal(created)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	s.serve(w, r, added)
+}
+
 // GetAuthor answers a request for a single Author and their books.
 func (s *HTTPServer) GetAuthor(w http.ResponseWriter, r *http.Request) {
 	authID := mux.Vars(r)["author_id"]
 	if strings.TrimSpace(authID) == "" {
-		s.handleError(w, "request", errors.New("author ID cannot be blank"))
+		s.handleError(w, r, "request", errors.New("author ID cannot be blank"))
 		return
 	}
 
-	auth, err := s.svc.GetAuthor(authID)
+	auth, err := s.svc.GetAuthor(r.Context(), authID)
 	if err != nil {
-		s.handleError(w, "service", err)
+		s.handleError(w, r, "service", err)
 		return
 	}
 
 	authResp, err := json.Marshal(auth)
 	if err != nil {
-		s.handleError(w, "other", err)
+		s.handleError(w, r, "other", err)
 		return
 	}
-	s.serve(w, authResp)
+	s.serve(w, r, authResp)
 }
 
-// ListAuthors answers requests to list all authors (minus books).
+// ListAuthors answers requests to list a page of authors (minus books) filtered
+// by the last_name, dob_from and dob_to query parameters and ordered by the sort
+// and order parameters. Pages are selected with the limit and cursor parameters.
 func (s *HTTPServer) ListAuthors(w http.ResponseWriter, r *http.Request) {
-	auths, err := s.svc.ListAuthors()
+	q, err := parseAuthorQuery(r.URL.Query())
+	if err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	auths, err := s.svc.ListAuthors(r.Context(), q)
 	if err != nil {
-		s.handleError(w, "service", err)
+		s.handleError(w, r, "service", err)
 		return
 	}
 
 	authList, err := json.Marshal(auths)
 	if err != nil {
-		s.handleError(w, "other", err)
+		s.handleError(w, r, "other", err)
 		return
 	}
 
-	s.serve(w, authList)
+	s.serve(w, r, authList)
 }
 
 // RemoveAuthor removes an author by its UUID if it exists.
 func (s *HTTPServer) RemoveAuthor(w http.ResponseWriter, r *http.Request) {
 	authID := mux.Vars(r)["author_id"]
 	if strings.TrimSpace(authID) == "" {
-		s.handleError(w, "request", errors.New("author ID cannot be blank"))
+		s.handleError(w, r, "request", errors.New("author ID cannot be blank"))
 		return
 	}
 
-	if err := s.svc.RemoveAuthor(authID); err != nil {
-		s.handleError(w, "service", err)
+	if err := s.svc.RemoveAuthor(r.Context(), authID); err != nil {
+		s.handleError(w, r, "service", err)
 		return
 	}
 
 	w.WriteHeader(http.StatusAccepted)
-	s.serve(w, []byte{})
+	s.serve(w, r, []byte{})
+}
+
+// UpdateAuthor updates an author based on its UUID, changing only the fields
+// present in the request body.
+func (s *HTTPServer) UpdateAuthor(w http.ResponseWriter, r *http.Request) {
+	authID := mux.Vars(r)["author_id"]
+	if strings.TrimSpace(authID) == "" {
+		s.handleError(w, r, "request", errors.New("author ID cannot be blank"))
+		return
+	}
+
+	var p authors.Patch
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	if err := s.svc.UpdateAuthor(r.Context(), authID, p); err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	s.serve(w, r, []byte{})
+}
+
+func validateAuthor(auth authors.Author) error {
+	if strings.TrimSpace(auth.FirstName) == "" {
+		return errors.New("author first name cannot be blank")
+	}
+	if strings.TrimSpace(auth.LastName) == "" {
+		return errors.New("author last name cannot be blank")
+	}
+	if auth.DOB == nil {
+		return errors.New("author date of birth cannot be blank")
+	}
+	return nil
 }
 
 type bookBody struct {
-	ID    string `json:"id"`
-	Title string `json:"title"`
-	ISBN  string `json:"isbn"`
+	ID        string       `json:"id"`
+	Title     string       `json:"title"`
+	ISBN      string       `json:"isbn"`
+	ListPrice *money.Money `json:"list_price"`
+	AuthorIDs []string     `json:"author_ids"`
+
+	Subtitle    string            `json:"subtitle"`
+	Description string            `json:"description"`
+	PublisherID string            `json:"publisher_id"`
+	PublishedOn string            `json:"published_on"`
+	Language    string            `json:"language"`
+	PageCount   int               `json:"page_count"`
+	Format      string            `json:"format"`
+	Edition     string            `json:"edition"`
+	Dimensions  *books.Dimensions `json:"dimensions"`
+}
+
+// details returns the book's details, reading the publication date in the
+// "2006-01-02" format.
+func (b bookBody) details() (books.Details, error) {
+	d := books.Details{
+		Subtitle:    b.Subtitle,
+		Description: b.Description,
+		PublisherID: b.PublisherID,
+		Language:    b.Language,
+		PageCount:   b.PageCount,
+		Format:      books.Format(b.Format),
+		Edition:     b.Edition,
+		Dimensions:  b.Dimensions,
+	}
+	if b.PublishedOn != "" {
+		t, err := time.Parse(authors.DateParsingFormat, b.PublishedOn)
+		if err != nil {
+			return books.Details{}, errors.New("published_on must be a date such as 2006-01-02")
+		}
+		d.PublishedOn = &t
+	}
+	return d, nil
 }
 
 // AddBook adds a book generating its UUID if it doesn't already exist.
 func (s *HTTPServer) AddBook(w http.ResponseWriter, r *http.Request) {
 	var bk bookBody
 	if err := json.NewDecoder(r.Body).Decode(&bk); err != nil {
-		s.handleError(w, "request", err)
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	details, err := bk.details()
+	if err != nil {
+		s.handleError(w, r, "request", err)
 		return
 	}
 
-	created, err := s.svc.AddBook(bk.Title, bk.ISBN)
+	created, err := s.svc.AddBook(r.Context(), bk.Title, bk.ISBN, details, bk.ListPrice, bk.AuthorIDs...)
 	if err != nil {
-		s.handleError(w, "service", err)
+		s.handleError(w, r, "service", err)
 		return
 	}
 
 	added, err := json.Marshal(created)
 	if err != nil {
-		s.handleError(w, "other", err)
+		s.handleError(w, r, "other", err)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	s.serve(w, added)
+	s.serve(w, r, added)
+}
+
+// GetBook answers a request for a single book and its authors.
+func (s *HTTPServer) GetBook(w http.ResponseWriter, r *http.Request) {
+	bkID := mux.Vars(r)["book_id"]
+	if strings.TrimSpace(bkID) == "" {
+		s.handleError(w, r, "request", errors.New("book ID cannot be blank"))
+		return
+	}
+
+	bk, err := s.svc.GetBook(r.Context(), bkID)
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	bkResp, err := json.Marshal(bk)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+	s.serve(w, r, bkResp)
+}
+
+// AddBookAuthor credits an author on a book, in the role and at the sequence
+// given by the optional role and sequence query parameters.
+func (s *HTTPServer) AddBookAuthor(w http.ResponseWriter, r *http.Request) {
+	bkID, authID, err := bookAuthorVars(r)
+	if err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+	var seq int
+	if v := r.URL.Query().Get("sequence"); v != "" {
+		if seq, err = strconv.Atoi(v); err != nil || seq < 1 {
+			s.handleError(w, r, "request", errors.New("sequence must be a positive number"))
+			return
+		}
+	}
+
+	role := books.Role(r.URL.Query().Get("role"))
+	if err := s.svc.AddBookAuthor(r.Context(), bkID, authID, role, seq); err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	s.serve(w, r, []byte{})
+}
+
+// RemoveBookAuthor removes an author's credit in the role given by the optional
+// role query parameter from a book, or all of their credits on it.
+func (s *HTTPServer) RemoveBookAuthor(w http.ResponseWriter, r *http.Request) {
+	bkID, authID, err := bookAuthorVars(r)
+	if err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	role := books.Role(r.URL.Query().Get("role"))
+	if err := s.svc.RemoveBookAuthor(r.Context(), bkID, authID, role); err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	s.serve(w, r, []byte{})
+}
+
+func bookAuthorVars(r *http.Request) (string, string, error) {
+	vars := mux.Vars(r)
+	bkID, authID := vars["book_id"], vars["author_id"]
+	if strings.TrimSpace(bkID) == "" {
+		return "", "", errors.New("book ID cannot be blank")
+	}
+	if strings.TrimSpace(authID) == "" {
+		return "", "", errors.New("author ID cannot be blank")
+	}
+	return bkID, authID, nil
 }
 
-// ListBooks answers requests to list books.
+// ListBookPrices answers a request for the list prices a book has had or is
+// scheduled to have.
+func (s *HTTPServer) ListBookPrices(w http.ResponseWriter, r *http.Request) {
+	bkID := mux.Vars(r)["book_id"]
+	if strings.TrimSpace(bkID) == "" {
+		s.handleError(w, r, "request", errors.New("book ID cannot be blank"))
+		return
+	}
+
+	prices, err := s.svc.ListBookPrices(r.Context(), bkID)
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	pricesResp, err := json.Marshal(prices)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+	s.serve(w, r, pricesResp)
+}
+
+// SetBookPrice lists a book at a new price from the effective_from time in the
+// request, or immediately if it has none.
+func (s *HTTPServer) SetBookPrice(w http.ResponseWriter, r *http.Request) {
+	bkID := mux.Vars(r)["book_id"]
+	if strings.TrimSpace(bkID) == "" {
+		s.handleError(w, r, "request", errors.New("book ID cannot be blank"))
+		return
+	}
+
+	var p books.Price
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	if err := s.svc.SetBookPrice(r.Context(), bkID, p); err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	s.serve(w, r, []byte{})
+}
+
+// GetStock answers a request for the stock level of a book and its latest
+// ledger entries.
+func (s *HTTPServer) GetStock(w http.ResponseWriter, r *http.Request) {
+	bkID := mux.Vars(r)["book_id"]
+	if strings.TrimSpace(bkID) == "" {
+		s.handleError(w, r, "request", errors.New("book ID cannot be blank"))
+		return
+	}
+
+	lvl, err := s.svc.GetStock(r.Context(), bkID)
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	lvlResp, err := json.Marshal(lvl)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+	s.serve(w, r, lvlResp)
+}
+
+type stockBody struct {
+	Kind     stock.Kind `json:"kind"`
+	Quantity int        `json:"quantity"`
+	Reason   string     `json:"reason"`
+}
+
+// MoveStock receives, adjusts, reserves or releases copies of a book according
+// to the kind of movement requested, answering with the new stock level.
+func (s *HTTPServer) MoveStock(w http.ResponseWriter, r *http.Request) {
+	bkID := mux.Vars(r)["book_id"]
+	if strings.TrimSpace(bkID) == "" {
+		s.handleError(w, r, "request", errors.New("book ID cannot be blank"))
+		return
+	}
+
+	var body stockBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	var move func(ctx context.Context, bookID string, quantity int, reason string) (stock.Level, error)
+	switch body.Kind {
+	case stock.Receive:
+		move = s.svc.ReceiveStock
+	case stock.Adjust:
+		move = s.svc.AdjustStock
+	case stock.Reserve:
+		move = s.svc.ReserveStock
+	case stock.Release:
+		move = s.svc.ReleaseStock
+	default:
+		s.handleError(w, r, "request", fmt.Errorf("unknown stock movement %q", body.Kind))
+		return
+	}
+
+	lvl, err := move(r.Context(), bkID, body.Quantity, body.Reason)
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	lvlResp, err := json.Marshal(lvl)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+	s.serve(w, r, lvlResp)
+}
+
+// ListBooks answers requests to list a page of books filtered by the title, isbn,
+// author, in_stock, category and tag query parameters and ordered by the sort and order
+// parameters. Pages are selected with the limit and cursor parameters.
 func (s *HTTPServer) ListBooks(w http.ResponseWriter, r *http.Request) {
-	bks, err := s.svc.ListBooks()
+	q, err := parseBookQuery(r.URL.Query())
+	if err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	bks, err := s.svc.ListBooks(r.Context(), q)
 	if err != nil {
-		s.handleError(w, "service", err)
+		s.handleError(w, r, "service", err)
 		return
 	}
 
 	bkList, err := json.Marshal(bks)
 	if err != nil {
-		s.handleError(w, "other", err)
+		s.handleError(w, r, "other", err)
 		return
 	}
 
-	s.serve(w, bkList)
+	s.serve(w, r, bkList)
 }
 
 // RemoveBook removes a book by its UUID if it exists.
 func (s *HTTPServer) RemoveBook(w http.ResponseWriter, r *http.Request) {
 	bkID := mux.Vars(r)["book_id"]
 	if strings.TrimSpace(bkID) == "" {
-		s.handleError(w, "request", errors.New("book ID cannot be blank"))
+		s.handleError(w, r, "request", errors.New("book ID cannot be blank"))
 		return
 	}
 
-	if err := s.svc.RemoveBooks(bkID); err != nil {
-		s.handleError(w, "service", err)
+	if err := s.svc.RemoveBooks(r.Context(), bkID); err != nil {
+		s.handleError(w, r, "service", err)
 		return
 	}
 
 	w.WriteHeader(http.StatusAccepted)
-	s.serve(w, []byte{})
+	s.serve(w, r, []byte{})
 }
 
-// UpdateBook updates a book based on it's
+// UpdateBook updates the book with the ID given in the request body, changing
+// only the fields present in it.
 func (s *HTTPServer) UpdateBook(w http.ResponseWriter, r *http.Request) {
-	var bk bookBody
-	if err := json.NewDecoder(r.Body).Decode(&bk); err != nil {
-		s.handleError(w, "request", err)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+	var bk struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &bk); err != nil {
+		s.handleError(w, r, "request", err)
 		return
 	}
-
 	if strings.TrimSpace(bk.ID) == "" {
-		s.handleError(w, "request", errors.New("book ID cannot be blank"))
+		s.handleError(w, r, "request", errors.New("book ID cannot be blank"))
+		return
+	}
+	var p books.Patch
+	if err := json.Unmarshal(body, &p); err != nil {
+		s.handleError(w, r, "request", err)
 		return
 	}
 
-	err := s.svc.UpdateBook(books.Book{
-		ID:    bk.ID,
-		Title: bk.Title,
-		ISBN:  books.ISBN(bk.ISBN),
-	})
-	if err != nil {
-		s.handleError(w, "service", err)
+	if err := s.svc.UpdateBook(r.Context(), bk.ID, p); err != nil {
+		s.handleError(w, r, "service", err)
 		return
 	}
 	w.WriteHeader(http.StatusAccepted)
-	s.serve(w, []byte{})
+	s.serve(w, r, []byte{})
+}
+
+// Search answers full text search requests across books and authors using the
+// q query parameter, returning at most limit results ordered by relevance.
+func (s *HTTPServer) Search(w http.ResponseWriter, r *http.Request) {
+	qry := r.URL.Query()
+	if strings.TrimSpace(qry.Get("q")) == "" {
+		s.handleError(w, r, "request", errors.New("search query cannot be blank"))
+		return
+	}
+	limit, err := parseLimit(qry.Get("limit"))
+	if err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	results, err := s.svc.Search(r.Context(), qry.Get("q"), limit)
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	resultList, err := json.Marshal(results)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+	s.serve(w, r, resultList)
+}
+
+func parseAuthorQuery(v url.Values) (authors.AuthorQuery, error) {
+	q := authors.AuthorQuery{
+		LastNamePrefix: v.Get("last_name"),
+		SortBy:         authors.SortField(v.Get("sort")),
+		Cursor:         v.Get("cursor"),
+	}
+
+	var err error
+	if q.Descending, err = parseOrder(v.Get("order")); err != nil {
+		return q, err
+	}
+	if q.Limit, err = parseLimit(v.Get("limit")); err != nil {
+		return q, err
+	}
+	for param, dst := range map[string]**time.Time{"dob_from": &q.DOBFrom, "dob_to": &q.DOBTo} {
+		if raw := v.Get(param); raw != "" {
+			t, err := time.Parse(authors.DateParsingFormat, raw)
+			if err != nil {
+				return q, fmt.Errorf("%s must be formatted as %s", param, authors.DateParsingFormat)
+			}
+			*dst = &t
+		}
+	}
+	return q, nil
 }
 
-func (s *HTTPServer) serve(w http.ResponseWriter, v []byte) {
+func parseBookQuery(v url.Values) (books.BookQuery, error) {
+	q := books.BookQuery{
+		TitlePrefix:    v.Get("title"),
+		ISBNPrefix:     v.Get("isbn"),
+		AuthorLastName: v.Get("author"),
+		Category:       v.Get("category"),
+		Tag:            v.Get("tag"),
+		SortBy:         books.SortField(v.Get("sort")),
+		Cursor:         v.Get("cursor"),
+	}
+
+	var err error
+	if q.Descending, err = parseOrder(v.Get("order")); err != nil {
+		return q, err
+	}
+	if q.Limit, err = parseLimit(v.Get("limit")); err != nil {
+		return q, err
+	}
+	if raw := v.Get("in_stock"); raw != "" {
+		if q.InStock, err = strconv.ParseBool(raw); err != nil {
+			return q, errors.New("in_stock must be true or false")
+		}
+	}
+	return q, nil
+}
+
+// parseOrder reports whether the order parameter asks for descending results.
+func parseOrder(order string) (bool, error) {
+	switch strings.ToLower(order) {
+	case "", "asc":
+		return false, nil
+	case "desc":
+		return true, nil
+	}
+	return false, errors.New("order must be asc or desc")
+}
+
+func parseLimit(limit string) (int, error) {
+	if limit == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(limit)
+	if err != nil || n < 1 {
+		return 0, errors.New("limit must be a positive number")
+	}
+	return n, nil
+}
+
+func (s *HTTPServer) serve(w http.ResponseWriter, r *http.Request, v []byte) {
 	_, err := w.Write(v)
 	if err != nil {
-		s.handleError(w, "other", err)
+		s.handleError(w, r, "other", err)
 		return
 	}
 }
 
-func (s *HTTPServer) handleError(w http.ResponseWriter, kind string, err error) {
+func (s *HTTPServer) handleError(w http.ResponseWriter, r *http.Request, kind string, err error) {
 	log.Print(err)
 	code := http.StatusInternalServerError
 
@@ -215,6 +790,41 @@ func (s *HTTPServer) handleError(w http.ResponseWriter, kind string, err error)
 		code = http.StatusUnprocessableEntity
 	}
 
+	if kind == "service" && errors.Is(err, service.ErrInvalidReference) {
+		code = http.StatusUnprocessableEntity
+	}
+
+	if kind == "service" && errors.Is(err, service.ErrNotFound) {
+		code = http.StatusNotFound
+	}
+
+	if kind == "service" && errors.Is(err, service.ErrInvalid) {
+		code = http.StatusBadRequest
+	}
+
+	if kind == "service" && errors.Is(err, service.ErrInsufficientStock) {
+		code = http.StatusConflict
+	}
+
+	if kind == "service" && errors.Is(err, service.ErrIllegalTransition) {
+		code = http.StatusConflict
+	}
+
+	if kind == "service" && errors.Is(err, service.ErrUnauthenticated) {
+		code = http.StatusUnauthorized
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+
+	if kind == "service" && errors.Is(err, service.ErrForbidden) {
+		code = http.StatusForbidden
+	}
+
+	// drivers report a statement cancelled by the deadline in their own terms,
+	// so check the request context as well as the error
+	if kind == "service" && (errors.Is(err, context.DeadlineExceeded) || errors.Is(r.Context().Err(), context.DeadlineExceeded)) {
+		code = http.StatusGatewayTimeout
+	}
+
 	if kind == "request" {
 		code = http.StatusBadRequest
 	}
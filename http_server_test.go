@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -8,9 +9,19 @@ import (
 	"testing"
 	"time"
 
+	"bookshop/auth"
 	"bookshop/authors"
 	"bookshop/books"
+	"bookshop/carts"
+	"bookshop/categories"
+	"bookshop/money"
+	"bookshop/orders"
+	"bookshop/publishers"
+	"bookshop/reviews"
+	"bookshop/search"
 	"bookshop/service"
+	"bookshop/stock"
+	"bookshop/users"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -34,14 +45,40 @@ func TestHTTPServer(t *testing.T) {
 					},
 				}
 
+				mockCursor = "next"
+				defer func() { mockCursor = "" }()
+
 				resp := makeRequest(t, "GET", "/authors", "")
 				require.Equal(t, http.StatusOK, resp.Code)
 
-				var content []authors.Author
+				var content authors.Page
 				err := json.NewDecoder(resp.Body).Decode(&content)
 				require.NoError(t, err)
-				require.Len(t, content, 1)
-				assert.Equal(t, mockAuths, content)
+				require.Len(t, content.Authors, 1)
+				assert.Equal(t, mockAuths, content.Authors)
+				assert.Equal(t, "next", content.NextCursor)
+			})
+
+			t.Run("query parameters", func(t *testing.T) {
+				mockAuthErr = nil
+				resp := makeRequest(t, "GET", "/authors?last_name=La&dob_from=1960-01-01&dob_to=1980-12-31&sort=dob&order=desc&limit=10&cursor=abc", "")
+				require.Equal(t, http.StatusOK, resp.Code)
+
+				assert.Equal(t, "La", mockAuthQuery.LastNamePrefix)
+				assert.Equal(t, "1960-01-01", mockAuthQuery.DOBFrom.Format(authors.DateParsingFormat))
+				assert.Equal(t, "1980-12-31", mockAuthQuery.DOBTo.Format(authors.DateParsingFormat))
+				assert.Equal(t, authors.SortByDOB, mockAuthQuery.SortBy)
+				assert.True(t, mockAuthQuery.Descending)
+				assert.Equal(t, 10, mockAuthQuery.Limit)
+				assert.Equal(t, "abc", mockAuthQuery.Cursor)
+			})
+
+			t.Run("bad query parameters", func(t *testing.T) {
+				mockAuthErr = nil
+				for _, qry := range []string{"dob_from=1960", "order=sideways", "limit=ten", "limit=0"} {
+					resp := makeRequest(t, "GET", "/authors?"+qry, "")
+					assert.Equal(t, http.StatusBadRequest, resp.Code, qry)
+				}
 			})
 		})
 	})
@@ -79,6 +116,149 @@ func TestHTTPServer(t *testing.T) {
 				assert.Equal(t, mockAuth.Books[0].Title, content.Books[0].Title)
 				assert.Equal(t, mockAuth.Books[0].ISBN, content.Books[0].ISBN)
 			})
+
+			t.Run("books by role", func(t *testing.T) {
+				mockAuthErr = nil
+				mockAuth = authors.Author{ID: "auth01", LastName: "Last"}
+				mockAuth.AddBook(books.Book{ID: "abc01", Title: "titleA"}, books.RoleAuthor)
+				mockAuth.AddBook(books.Book{ID: "abc01", Title: "titleA"}, books.RoleIllustrator)
+				mockAuth.AddBook(books.Book{ID: "def02", Title: "titleB"}, books.RoleTranslator)
+
+				resp := makeRequest(t, "GET", "/authors/auth01", "")
+				require.Equal(t, http.StatusOK, resp.Code)
+
+				var content struct {
+					Books       []books.Book            `json:"books"`
+					BooksByRole map[string][]books.Book `json:"books_by_role"`
+				}
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&content))
+				assert.Len(t, content.Books, 2)
+				require.Len(t, content.BooksByRole, 3)
+				assert.Equal(t, "abc01", content.BooksByRole["illustrator"][0].ID)
+				assert.Equal(t, "def02", content.BooksByRole["translator"][0].ID)
+			})
+		})
+
+		t.Run("POST", func(t *testing.T) {
+			t.Run("happy", func(t *testing.T) {
+				mockAuthErr = nil
+				mockAuth = authors.Author{
+					ID:         "auth01",
+					FirstName:  "First",
+					MiddleName: "Middle",
+					LastName:   "Last",
+					DOB:        &dt,
+				}
+
+				resp := makeRequest(t, "POST", "/authors", `{
+				  "first_name": "First",
+				  "middle_name": "Middle",
+				  "last_name": "Last",
+				  "dob": "1970-01-01"
+				}`)
+				require.Equal(t, http.StatusCreated, resp.Code)
+
+				var content authors.Author
+				err := json.NewDecoder(resp.Body).Decode(&content)
+				require.NoError(t, err)
+				assert.Equal(t, mockAuth.ID, content.ID)
+				assert.Equal(t, mockAuth.LastName, content.LastName)
+				assert.Equal(t, "1970-01-01", content.DOB.Format(authors.DateParsingFormat))
+			})
+
+			t.Run("bad dob", func(t *testing.T) {
+				mockAuthErr = nil
+				resp := makeRequest(t, "POST", "/authors", `{
+				  "first_name": "First",
+				  "last_name": "Last",
+				  "dob": "01/01/1970"
+				}`)
+				require.Equal(t, http.StatusBadRequest, resp.Code)
+			})
+
+			t.Run("missing last name", func(t *testing.T) {
+				mockAuthErr = nil
+				resp := makeRequest(t, "POST", "/authors", `{
+				  "first_name": "First",
+				  "dob": "1970-01-01"
+				}`)
+				require.Equal(t, http.StatusBadRequest, resp.Code)
+			})
+
+			t.Run("duplicate", func(t *testing.T) {
+				mockAuth = authors.Author{}
+				mockAuthErr = service.NewErrDuplicateAuthor("First Middle Last")
+
+				resp := makeRequest(t, "POST", "/authors", `{
+				  "first_name": "First",
+				  "middle_name": "Middle",
+				  "last_name": "Last",
+				  "dob": "1970-01-01"
+				}`)
+				require.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+				assert.Equal(t, mockAuthErr.Error(), strings.TrimSpace(resp.Body.String()))
+			})
+
+			t.Run("forbidden", func(t *testing.T) {
+				mockAuth = authors.Author{}
+				mockAuthErr = service.NewErrForbidden(users.PermEditBooks)
+				resp := makeRequest(t, "POST", "/authors", `{
+				  "first_name": "First",
+				  "last_name": "Last",
+				  "dob": "1970-01-01"
+				}`)
+				require.Equal(t, http.StatusForbidden, resp.Code)
+			})
+		})
+
+		t.Run("PATCH", func(t *testing.T) {
+			t.Run("happy", func(t *testing.T) {
+				mockAuthErr = nil
+				resp := makeRequest(t, "PATCH", "/authors/auth01", `{
+				  "first_name": "Updated",
+				  "last_name": "Last",
+				  "dob": "1970-01-01"
+				}`)
+				require.Equal(t, http.StatusAccepted, resp.Code)
+			})
+
+			t.Run("partial", func(t *testing.T) {
+				mockAuthErr = nil
+				resp := makeRequest(t, "PATCH", "/authors/auth01", `{"middle_name": ""}`)
+				require.Equal(t, http.StatusAccepted, resp.Code)
+				require.NotNil(t, mockAuthPatch.MiddleName)
+				assert.Equal(t, "", *mockAuthPatch.MiddleName)
+				assert.Nil(t, mockAuthPatch.FirstName)
+				assert.Nil(t, mockAuthPatch.DOB)
+			})
+
+			t.Run("invalid dob", func(t *testing.T) {
+				mockAuthErr = nil
+				resp := makeRequest(t, "PATCH", "/authors/auth01", `{"dob": "01/01/1970"}`)
+				require.Equal(t, http.StatusBadRequest, resp.Code)
+			})
+
+			t.Run("not found", func(t *testing.T) {
+				mockAuthErr = service.NewErrNotFound("author", "auth01")
+				resp := makeRequest(t, "PATCH", "/authors/auth01", `{"first_name": "Updated"}`)
+				require.Equal(t, http.StatusNotFound, resp.Code)
+			})
+
+			t.Run("forbidden", func(t *testing.T) {
+				mockAuthErr = service.NewErrForbidden(users.PermEditBooks)
+				resp := makeRequest(t, "PATCH", "/authors/auth01", `{"first_name": "Updated"}`)
+				require.Equal(t, http.StatusForbidden, resp.Code)
+			})
+
+			t.Run("service error", func(t *testing.T) {
+				mockAuthErr = errors.New("service error")
+				resp := makeRequest(t, "PATCH", "/authors/auth01", `{
+				  "first_name": "Updated",
+				  "last_name": "Last",
+				  "dob": "1970-01-01"
+				}`)
+				require.Equal(t, http.StatusInternalServerError, resp.Code)
+			})
 		})
 
 		t.Run("DELETE", func(t *testing.T) {
@@ -93,6 +273,13 @@ func TestHTTPServer(t *testing.T) {
 				require.NoError(t, err)
 			})
 
+			t.Run("not found", func(t *testing.T) {
+				mockAuthErr = service.NewErrNotFound("author", "auth01")
+
+				resp := makeRequest(t, "DELETE", "/authors/auth01", "")
+				assert.Equal(t, http.StatusNotFound, resp.Code)
+			})
+
 			t.Run("service error", func(t *testing.T) {
 				mockAuthErr = errors.New("service error")
 
@@ -122,10 +309,41 @@ func TestHTTPServer(t *testing.T) {
 				resp := makeRequest(t, "GET", "/books", "")
 				require.Equal(t, http.StatusOK, resp.Code)
 
-				var content []books.Book
+				var content books.Page
 				err := json.NewDecoder(resp.Body).Decode(&content)
 				require.NoError(t, err)
-				assert.Equal(t, mockBooks, content)
+				assert.Equal(t, mockBooks, content.Books)
+				assert.Empty(t, content.NextCursor)
+			})
+
+			t.Run("query parameters", func(t *testing.T) {
+				mockBooksErr = nil
+				resp := makeRequest(t, "GET", "/books?title=the&isbn=978-3&author=Last&sort=isbn&order=asc&limit=5&cursor=abc&in_stock=true&category=cat01&tag=space", "")
+				require.Equal(t, http.StatusOK, resp.Code)
+
+				assert.Equal(t, books.BookQuery{
+					TitlePrefix:    "the",
+					ISBNPrefix:     "978-3",
+					AuthorLastName: "Last",
+					InStock:        true,
+					Category:       "cat01",
+					Tag:            "space",
+					SortBy:         books.SortByISBN,
+					Limit:          5,
+					Cursor:         "abc",
+				}, mockBookQuery)
+			})
+
+			t.Run("bad in_stock", func(t *testing.T) {
+				mockBooksErr = nil
+				resp := makeRequest(t, "GET", "/books?in_stock=maybe", "")
+				assert.Equal(t, http.StatusBadRequest, resp.Code)
+			})
+
+			t.Run("invalid query", func(t *testing.T) {
+				mockBooksErr = service.NewErrInvalid("query", errors.New(`unknown sort field "author"`))
+				resp := makeRequest(t, "GET", "/books?sort=author", "")
+				assert.Equal(t, http.StatusBadRequest, resp.Code)
 			})
 
 			t.Run("service error", func(t *testing.T) {
@@ -151,6 +369,12 @@ func TestHTTPServer(t *testing.T) {
 				require.Equal(t, http.StatusBadRequest, resp.Code)
 			})
 
+			t.Run("not found", func(t *testing.T) {
+				mockBooksErr = service.NewErrNotFound("book", "abc01")
+				resp := makeRequest(t, "DELETE", "/books/abc01", "")
+				require.Equal(t, http.StatusNotFound, resp.Code)
+			})
+
 			t.Run("service error", func(t *testing.T) {
 				mockBooksErr = errors.New("service error")
 				resp := makeRequest(t, "DELETE", "/books/abc01", "")
@@ -167,6 +391,67 @@ func TestHTTPServer(t *testing.T) {
 				  "isbn": "999999999"
 				}`)
 				require.Equal(t, http.StatusAccepted, resp.Code)
+				require.NotNil(t, mockPatch.Title)
+				assert.Equal(t, "UPDATED TITLE", *mockPatch.Title)
+				assert.Nil(t, mockPatch.ListPrice)
+				assert.Nil(t, mockPatch.Subtitle, "left out fields are unchanged")
+				assert.Nil(t, mockPatch.PublisherID)
+			})
+
+			t.Run("price", func(t *testing.T) {
+				mockBooksErr = nil
+				resp := makeRequest(t, "PATCH", "/books", `{
+				  "id": "abc01",
+				  "title": "UPDATED TITLE",
+				  "isbn": "999999999",
+				  "list_price": {"amount": 1299, "currency": "GBP"}
+				}`)
+				require.Equal(t, http.StatusAccepted, resp.Code)
+				require.NotNil(t, mockPatch.ListPrice)
+				assert.Equal(t, money.New(1299, money.GBP), *mockPatch.ListPrice)
+			})
+
+			t.Run("details", func(t *testing.T) {
+				mockBooksErr = nil
+				resp := makeRequest(t, "PATCH", "/books", `{
+				  "id": "abc01",
+				  "title": "UPDATED TITLE",
+				  "isbn": "999999999",
+				  "publisher_id": "pub01",
+				  "published_on": "1965-08-01",
+				  "format": "hardcover",
+				  "dimensions": {"height_mm": 216, "width_mm": 140, "depth_mm": 38}
+				}`)
+				require.Equal(t, http.StatusAccepted, resp.Code)
+				require.NotNil(t, mockPatch.PublisherID)
+				assert.Equal(t, "pub01", *mockPatch.PublisherID)
+				require.NotNil(t, mockPatch.Format)
+				assert.Equal(t, books.FormatHardcover, *mockPatch.Format)
+				require.NotNil(t, mockPatch.PublishedOn)
+				assert.Equal(t, time.Date(1965, 8, 1, 0, 0, 0, 0, time.UTC), *mockPatch.PublishedOn)
+				assert.Equal(t, &books.Dimensions{Height: 216, Width: 140, Depth: 38}, mockPatch.Dimensions)
+			})
+
+			t.Run("bad publication date", func(t *testing.T) {
+				mockBooksErr = nil
+				resp := makeRequest(t, "PATCH", "/books", `{
+				  "id": "abc01",
+				  "title": "UPDATED TITLE",
+				  "isbn": "999999999",
+				  "published_on": "1 August 1965"
+				}`)
+				require.Equal(t, http.StatusBadRequest, resp.Code)
+			})
+
+			t.Run("invalid isbn", func(t *testing.T) {
+				mockBooksErr = service.NewErrInvalid("isbn", books.ErrInvalidISBN)
+				resp := makeRequest(t, "PATCH", "/books", `{
+				  "id": "abc01",
+				  "title": "UPDATED TITLE",
+				  "isbn": "999999999"
+				}`)
+				require.Equal(t, http.StatusBadRequest, resp.Code)
+				assert.Equal(t, "invalid isbn: invalid isbn\n", resp.Body.String())
 			})
 
 			t.Run("missing id", func(t *testing.T) {
@@ -208,6 +493,85 @@ func TestHTTPServer(t *testing.T) {
 				assert.Equal(t, mockBook, content)
 			})
 
+			t.Run("price", func(t *testing.T) {
+				mockBooksErr = nil
+				price := money.New(1999, money.USD)
+				mockBook = books.Book{
+					ID:        "abc01",
+					Title:     "title3",
+					ISBN:      "999999999",
+					ListPrice: &price,
+				}
+
+				resp := makeRequest(t, "POST", "/books", `{"title": "title03", "isbn": "999999999", "list_price": {"amount": 1999, "currency": "usd"}}`)
+				require.Equal(t, http.StatusCreated, resp.Code)
+				require.NotNil(t, mockListPrice)
+				assert.Equal(t, price, *mockListPrice)
+
+				var content map[string]interface{}
+				err := json.NewDecoder(resp.Body).Decode(&content)
+				require.NoError(t, err)
+				assert.Equal(t, map[string]interface{}{
+					"amount":    float64(1999),
+					"currency":  "USD",
+					"formatted": "$19.99",
+				}, content["list_price"])
+			})
+
+			t.Run("details", func(t *testing.T) {
+				mockBooksErr = nil
+				on := time.Date(1965, 8, 1, 0, 0, 0, 0, time.UTC)
+				mockBook = books.Book{
+					ID:    "abc01",
+					Title: "Dune",
+					ISBN:  "9781861972712",
+					Details: books.Details{
+						Subtitle:    "Book One",
+						PublishedOn: &on,
+						Language:    "en",
+						PageCount:   412,
+						Format:      books.FormatHardcover,
+					},
+				}
+
+				resp := makeRequest(t, "POST", "/books", `{
+				  "title": "Dune",
+				  "isbn": "9781861972712",
+				  "subtitle": "Book One",
+				  "published_on": "1965-08-01",
+				  "language": "en",
+				  "page_count": 412,
+				  "format": "hardcover"
+				}`)
+				require.Equal(t, http.StatusCreated, resp.Code)
+				assert.Equal(t, mockBook.Details, mockDetails)
+
+				var content map[string]interface{}
+				err := json.NewDecoder(resp.Body).Decode(&content)
+				require.NoError(t, err)
+				assert.Equal(t, "Book One", content["subtitle"])
+				assert.Equal(t, "hardcover", content["format"])
+				assert.Equal(t, float64(412), content["page_count"])
+				assert.NotContains(t, content, "dimensions")
+			})
+
+			t.Run("invalid details", func(t *testing.T) {
+				mockBooksErr = service.NewErrInvalid("format", errors.New("format must be one of hardcover, paperback, ebook or audiobook"))
+				resp := makeRequest(t, "POST", "/books", `{"title": "title03", "isbn": "999999999", "format": "scroll"}`)
+				require.Equal(t, http.StatusBadRequest, resp.Code)
+			})
+
+			t.Run("bad price", func(t *testing.T) {
+				mockBooksErr = nil
+				for _, body := range []string{
+					`{"title": "title03", "isbn": "999999999", "list_price": {"amount": 19.99, "currency": "USD"}}`,
+					`{"title": "title03", "isbn": "999999999", "list_price": {"amount": 1999, "currency": "XYZ"}}`,
+				} {
+					resp := makeRequest(t, "POST", "/books", body)
+					assert.Equal(t, http.StatusBadRequest, resp.Code, body)
+				}
+			})
+
 			t.Run("duplicate", func(t *testing.T) {
 				mockBook = books.Book{}
 				mockBooksErr = service.NewErrDuplicate("title03")
@@ -225,58 +589,837 @@ func TestHTTPServer(t *testing.T) {
 				resp := makeRequest(t, "POST", "/books", `{"title": "title03", "isbn": "999999999"}`)
 				require.Equal(t, http.StatusInternalServerError, resp.Code)
 			})
+
+			t.Run("unknown author", func(t *testing.T) {
+				mockBook = books.Book{}
+				mockBooksErr = service.ErrInvalidReference
+
+				resp := makeRequest(t, "POST", "/books", `{"title": "title03", "isbn": "999999999", "author_ids": ["nope"]}`)
+				require.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+			})
+		})
+	})
+
+	t.Run("book", func(t *testing.T) {
+		t.Run("GET", func(t *testing.T) {
+			t.Run("happy", func(t *testing.T) {
+				mockBooksErr = nil
+				mockBook = books.Book{
+					ID:    "abc01",
+					Title: "titleA",
+					ISBN:  "9783161484100",
+					Authors: []books.BookAuthor{
+						{
+							ID:         "auth01",
+							FirstName:  "First",
+							MiddleName: "Middle",
+							LastName:   "Last",
+						},
+					},
+				}
+
+				resp := makeRequest(t, "GET", "/books/abc01", "")
+				require.Equal(t, http.StatusOK, resp.Code)
+
+				var content books.Book
+				err := json.NewDecoder(resp.Body).Decode(&content)
+				require.NoError(t, err)
+				assert.Equal(t, mockBook, content)
+			})
+
+			t.Run("not found", func(t *testing.T) {
+				mockBook = books.Book{}
+				mockBooksErr = service.NewErrNotFound("book", "abc01")
+
+				resp := makeRequest(t, "GET", "/books/abc01", "")
+				assert.Equal(t, http.StatusNotFound, resp.Code)
+				assert.Equal(t, "book not found: abc01\n", resp.Body.String())
+			})
+
+			t.Run("service error", func(t *testing.T) {
+				mockBook = books.Book{}
+				mockBooksErr = errors.New("service error")
+
+				resp := makeRequest(t, "GET", "/books/abc01", "")
+				assert.Equal(t, http.StatusInternalServerError, resp.Code)
+			})
+		})
+
+		t.Run("authors", func(t *testing.T) {
+			t.Run("PUT", func(t *testing.T) {
+				t.Run("happy", func(t *testing.T) {
+					mockBooksErr = nil
+					resp := makeRequest(t, "PUT", "/books/abc01/authors/auth01", "")
+					require.Equal(t, http.StatusAccepted, resp.Code)
+					assert.Equal(t, books.Credit{AuthorID: "auth01"}, mockCredit)
+				})
+
+				t.Run("role and sequence", func(t *testing.T) {
+					mockBooksErr = nil
+					resp := makeRequest(t, "PUT", "/books/abc01/authors/auth01?role=translator&sequence=2", "")
+					require.Equal(t, http.StatusAccepted, resp.Code)
+					assert.Equal(t, books.Credit{AuthorID: "auth01", Role: books.RoleTranslator, Sequence: 2}, mockCredit)
+				})
+
+				t.Run("invalid sequence", func(t *testing.T) {
+					mockBooksErr = nil
+					for _, seq := range []string{"0", "-1", "first"} {
+						resp := makeRequest(t, "PUT", "/books/abc01/authors/auth01?sequence="+seq, "")
+						require.Equal(t, http.StatusBadRequest, resp.Code, seq)
+					}
+				})
+
+				t.Run("invalid role", func(t *testing.T) {
+					mockBooksErr = service.NewErrInvalid("role", errors.New("unknown role"))
+					resp := makeRequest(t, "PUT", "/books/abc01/authors/auth01?role=ghostwriter", "")
+					require.Equal(t, http.StatusBadRequest, resp.Code)
+				})
+
+				t.Run("empty author id", func(t *testing.T) {
+					mockBooksErr = nil
+					resp := makeRequest(t, "PUT", "/books/abc01/authors/ ", "")
+					require.Equal(t, http.StatusBadRequest, resp.Code)
+				})
+
+				t.Run("unknown book or author", func(t *testing.T) {
+					mockBooksErr = service.ErrInvalidReference
+					resp := makeRequest(t, "PUT", "/books/abc01/authors/auth01", "")
+					require.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+				})
+			})
+
+			t.Run("DELETE", func(t *testing.T) {
+				t.Run("happy", func(t *testing.T) {
+					mockBooksErr = nil
+					resp := makeRequest(t, "DELETE", "/books/abc01/authors/auth01", "")
+					require.Equal(t, http.StatusAccepted, resp.Code)
+					assert.Equal(t, books.Credit{AuthorID: "auth01"}, mockCredit)
+				})
+
+				t.Run("in role", func(t *testing.T) {
+					mockBooksErr = nil
+					resp := makeRequest(t, "DELETE", "/books/abc01/authors/auth01?role=illustrator", "")
+					require.Equal(t, http.StatusAccepted, resp.Code)
+					assert.Equal(t, books.Credit{AuthorID: "auth01", Role: books.RoleIllustrator}, mockCredit)
+				})
+
+				t.Run("service error", func(t *testing.T) {
+					mockBooksErr = errors.New("service error")
+					resp := makeRequest(t, "DELETE", "/books/abc01/authors/auth01", "")
+					require.Equal(t, http.StatusInternalServerError, resp.Code)
+				})
+			})
 		})
 	})
 }
 
+func TestHTTPServerPrices(t *testing.T) {
+	t.Run("GET", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockBooksErr = nil
+			mockPrices = []books.Price{
+				{ListPrice: money.New(999, money.EUR), EffectiveFrom: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+				{ListPrice: money.New(1299, money.EUR), EffectiveFrom: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+			}
+
+			resp := makeRequest(t, "GET", "/books/abc01/prices", "")
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var content []books.Price
+			err := json.NewDecoder(resp.Body).Decode(&content)
+			require.NoError(t, err)
+			assert.Equal(t, mockPrices, content)
+		})
+
+		t.Run("book not found", func(t *testing.T) {
+			mockBooksErr = service.NewErrNotFound("book", "abc01")
+			resp := makeRequest(t, "GET", "/books/abc01/prices", "")
+			assert.Equal(t, http.StatusNotFound, resp.Code)
+		})
+	})
+
+	t.Run("POST", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockBooksErr = nil
+			resp := makeRequest(t, "POST", "/books/abc01/prices", `{
+			  "list_price": {"amount": 1499, "currency": "EUR"},
+			  "effective_from": "2030-01-01T00:00:00Z"
+			}`)
+			require.Equal(t, http.StatusAccepted, resp.Code)
+			require.Len(t, mockPrices, 1)
+			assert.Equal(t, money.New(1499, money.EUR), mockPrices[0].ListPrice)
+			assert.Equal(t, 2030, mockPrices[0].EffectiveFrom.Year())
+		})
+
+		t.Run("invalid price", func(t *testing.T) {
+			mockBooksErr = service.NewErrInvalid("price", errors.New("price cannot be negative"))
+			resp := makeRequest(t, "POST", "/books/abc01/prices", `{"list_price": {"amount": -1, "currency": "EUR"}}`)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	})
+}
+
+func TestHTTPServerStock(t *testing.T) {
+	t.Run("GET", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockStockErr = nil
+			mockStock = stock.Level{
+				BookID:   "abc01",
+				OnHand:   5,
+				Reserved: 2,
+				Ledger: []stock.Entry{
+					{ID: 1, BookID: "abc01", Kind: stock.Receive, OnHandDelta: 5, Reason: "delivery"},
+				},
+			}
+
+			resp := makeRequest(t, "GET", "/books/abc01/stock", "")
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var content map[string]interface{}
+			err := json.NewDecoder(resp.Body).Decode(&content)
+			require.NoError(t, err)
+			assert.Equal(t, "abc01", content["book_id"])
+			assert.EqualValues(t, 3, content["available"])
+			assert.Len(t, content["ledger"], 1)
+		})
+
+		t.Run("book not found", func(t *testing.T) {
+			mockStockErr = service.NewErrNotFound("book", "abc01")
+			resp := makeRequest(t, "GET", "/books/abc01/stock", "")
+			assert.Equal(t, http.StatusNotFound, resp.Code)
+		})
+	})
+
+	t.Run("POST", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockStockErr = nil
+			for _, kind := range []stock.Kind{stock.Receive, stock.Adjust, stock.Reserve, stock.Release} {
+				mockStockMove = ""
+				resp := makeRequest(t, "POST", "/books/abc01/stock", `{"kind": "`+string(kind)+`", "quantity": 2, "reason": "count"}`)
+				require.Equal(t, http.StatusOK, resp.Code, kind)
+				assert.Equal(t, kind, mockStockMove)
+			}
+		})
+
+		t.Run("unknown kind", func(t *testing.T) {
+			mockStockErr = nil
+			resp := makeRequest(t, "POST", "/books/abc01/stock", `{"kind": "steal", "quantity": 2}`)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+
+		t.Run("bad body", func(t *testing.T) {
+			mockStockErr = nil
+			resp := makeRequest(t, "POST", "/books/abc01/stock", `{"kind": "receive", "quantity": "two"}`)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+
+		t.Run("invalid movement", func(t *testing.T) {
+			mockStockErr = service.NewErrInvalid("stock movement", errors.New("quantity must be positive"))
+			resp := makeRequest(t, "POST", "/books/abc01/stock", `{"kind": "receive", "quantity": 0}`)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+
+		t.Run("insufficient stock", func(t *testing.T) {
+			mockStockErr = service.NewErrInsufficientStock("abc01")
+			resp := makeRequest(t, "POST", "/books/abc01/stock", `{"kind": "reserve", "quantity": 9}`)
+			assert.Equal(t, http.StatusConflict, resp.Code)
+		})
+
+		t.Run("forbidden", func(t *testing.T) {
+			mockStockErr = service.NewErrForbidden(users.PermManageStock)
+			resp := makeRequest(t, "POST", "/books/abc01/stock", `{"kind": "receive", "quantity": 2}`)
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+		})
+	})
+}
+
+func TestHTTPServerStockLedger(t *testing.T) {
+	ctx := context.Background()
+	srv, svc := newMemoryServer()
+	admin := auth.WithUser(ctx, users.User{ID: "usr00", Role: users.RoleAdmin, Permissions: users.RolePermissions[users.RoleAdmin]})
+	bk, err := svc.AddBook(admin, "Dune", "9781861972712", books.Details{}, nil)
+	require.NoError(t, err)
+	_, err = svc.ReceiveStock(admin, bk.ID, 5, "delivery from Chilton")
+	require.NoError(t, err)
+	signIn := func(email string, role users.Role) string {
+		u, err := svc.RegisterUser(ctx, users.User{Email: email, FirstName: "First", LastName: "Last"}, "correct horse")
+		require.NoError(t, err)
+		require.NoError(t, svc.SetUserRole(admin, u.ID, role))
+		creds, err := svc.Login(ctx, email, "correct horse")
+		require.NoError(t, err)
+		return creds.Token
+	}
+	customerToken, staffToken := signIn("ann@example.com", users.RoleCustomer), signIn("bob@example.com", users.RoleStaff)
+
+	read := func(resp *httptest.ResponseRecorder) stock.Level {
+		require.Equal(t, http.StatusOK, resp.Code)
+		var lvl stock.Level
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&lvl))
+		assert.Equal(t, 5, lvl.OnHand)
+		return lvl
+	}
+	req, err := http.NewRequest("GET", "/books/"+bk.ID+"/stock", nil)
+	require.NoError(t, err)
+	resp := httptest.NewRecorder()
+	srv.ServeHTTP(resp, req)
+	assert.Empty(t, read(resp).Ledger, "anonymous callers see the level only")
+	resp = makeRequestTo(t, srv, customerToken, "GET", "/books/"+bk.ID+"/stock", "")
+	assert.Empty(t, read(resp).Ledger, "customers see the level only")
+	resp = makeRequestTo(t, srv, staffToken, "GET", "/books/"+bk.ID+"/stock", "")
+	ledger := read(resp).Ledger
+	require.Len(t, ledger, 1)
+	assert.Equal(t, "delivery from Chilton", ledger[0].Reason)
+}
+
+func TestHTTPServerSearch(t *testing.T) {
+	t.Run("GET", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockResultsErr = nil
+			mockResults = []search.Result{
+				{
+					Kind:    search.KindBook,
+					ID:      "abc01",
+					Title:   "Dune",
+					Snippet: "<mark>Dune</mark>",
+					Rank:    0.5,
+				},
+			}
+
+			resp := makeRequest(t, "GET", "/search?q=dune", "")
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			var content []search.Result
+			err := json.NewDecoder(resp.Body).Decode(&content)
+			require.NoError(t, err)
+			assert.Equal(t, mockResults, content)
+		})
+
+		t.Run("missing query", func(t *testing.T) {
+			resp := makeRequest(t, "GET", "/search", "")
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+
+		t.Run("service error", func(t *testing.T) {
+			mockResults = nil
+			mockResultsErr = errors.New("service error")
+
+			resp := makeRequest(t, "GET", "/search?q=dune", "")
+			assert.Equal(t, http.StatusInternalServerError, resp.Code)
+		})
+	})
+}
+
+func TestHTTPServerTimeouts(t *testing.T) {
+	mockWait = true
+	defer func() { mockWait = false }()
+
+	doSearch := func(timeouts Timeouts) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/search?q=dune", nil)
+		require.NoError(t, err)
+		resp := httptest.NewRecorder()
+		NewHTTPServer(&mockService{}, timeouts).ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("default", func(t *testing.T) {
+		mockResultsErr = nil
+		resp := doSearch(Timeouts{Default: 10 * time.Millisecond})
+		assert.Equal(t, http.StatusGatewayTimeout, resp.Code)
+	})
+
+	t.Run("route", func(t *testing.T) {
+		mockResultsErr = nil
+		resp := doSearch(Timeouts{
+			Default: time.Hour,
+			Routes:  map[string]time.Duration{"Search": 10 * time.Millisecond},
+		})
+		assert.Equal(t, http.StatusGatewayTimeout, resp.Code)
+	})
+
+	t.Run("driver error", func(t *testing.T) {
+		// the driver reports the cancelled statement without the context error
+		mockResultsErr = errors.New("canceling statement due to user request")
+		defer func() { mockResultsErr = nil }()
+
+		resp := doSearch(Timeouts{Default: 10 * time.Millisecond})
+		assert.Equal(t, http.StatusGatewayTimeout, resp.Code)
+	})
+}
+
+// makeRequest sends a request signed in as mockSessionUser.
 func makeRequest(t *testing.T, kind, path, bodyStr string) *httptest.ResponseRecorder {
+	return makeRequestWithToken(t, mockToken, kind, path, bodyStr)
+}
+
+// makeRequestWithToken sends a request signed with the given session token, or
+// an anonymous request if the token is blank.
+func makeRequestWithToken(t *testing.T, token, kind, path, bodyStr string) *httptest.ResponseRecorder {
+	header := ""
+	if token != "" {
+		header = "Bearer " + token
+	}
+	return makeRequestWithAuthorization(t, header, kind, path, bodyStr)
+}
+
+// makeRequestWithKey sends a request made with the given API key.
+func makeRequestWithKey(t *testing.T, key, kind, path, bodyStr string) *httptest.ResponseRecorder {
+	return makeRequestWithAuthorization(t, "ApiKey "+key, kind, path, bodyStr)
+}
+
+func makeRequestWithAuthorization(t *testing.T, header, kind, path, bodyStr string) *httptest.ResponseRecorder {
 	bd := strings.NewReader(bodyStr)
 	req, err := http.NewRequest(kind, path, bd)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if header != "" {
+		req.Header.Set("Authorization", header)
+	}
 
 	svc := &mockService{}
-	httpServer := NewHTTPServer(svc)
+	httpServer := NewHTTPServer(svc, Timeouts{})
 	resp := httptest.NewRecorder()
 	httpServer.ServeHTTP(resp, req)
 	return resp
 }
 
+// newMemoryServer returns a server over the memory store along with the
+// service behind it, for tests that need the service's own checks.
+func newMemoryServer() (*HTTPServer, *service.Service) {
+	svc := service.NewService(memoryStores())
+	return NewHTTPServer(&svc, Timeouts{}), &svc
+}
+
+// makeRequestTo sends a request signed with the given session token to the
+// server.
+func makeRequestTo(t *testing.T, srv http.Handler, token, kind, path, bodyStr string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(kind, path, strings.NewReader(bodyStr))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp := httptest.NewRecorder()
+	srv.ServeHTTP(resp, req)
+	return resp
+}
+
 type mockService struct{}
 
 var mockAuth authors.Author
 var mockAuths []authors.Author
 var mockAuthErr error
+var mockAuthQuery authors.AuthorQuery
+var mockCursor string
 
-func (m *mockService) GetAuthor(id string) (authors.Author, error) {
+func (m *mockService) AddAuthor(ctx context.Context, auth authors.Author) (authors.Author, error) {
 	return mockAuth, mockAuthErr
 }
 
-func (m *mockService) ListAuthors() ([]authors.Author, error) {
-	return mockAuths, mockAuthErr
+func (m *mockService) GetAuthor(ctx context.Context, id string) (authors.Author, error) {
+	return mockAuth, mockAuthErr
+}
+
+func (m *mockService) ListAuthors(ctx context.Context, q authors.AuthorQuery) (authors.Page, error) {
+	mockAuthQuery = q
+	return authors.Page{Authors: mockAuths, NextCursor: mockCursor}, mockAuthErr
+}
+
+func (m *mockService) RemoveAuthor(ctx context.Context, id string) error {
+	return mockAuthErr
 }
 
-func (m *mockService) RemoveAuthor(id string) error {
+var mockAuthPatch authors.Patch
+
+func (m *mockService) UpdateAuthor(ctx context.Context, id string, p authors.Patch) error {
+	mockAuthPatch = p
 	return mockAuthErr
 }
 
 var mockBook books.Book
 var mockBooks []books.Book
 var mockBooksErr error
+var mockBookQuery books.BookQuery
 
-func (m *mockService) AddBook(title, isbn string) (books.Book, error) {
+func (m *mockService) AddBook(ctx context.Context, title, isbn string, details books.Details, price *money.Money, authorIDs ...string) (books.Book, error) {
+	mockListPrice, mockDetails = price, details
 	return mockBook, mockBooksErr
 }
 
-func (m *mockService) ListBooks() ([]books.Book, error) {
-	return mockBooks, mockBooksErr
+var mockCredit books.Credit
+
+func (m *mockService) AddBookAuthor(ctx context.Context, bookID, authorID string, role books.Role, sequence int) error {
+	mockCredit = books.Credit{AuthorID: authorID, Role: role, Sequence: sequence}
+	return mockBooksErr
+}
+
+func (m *mockService) GetBook(ctx context.Context, id string) (books.Book, error) {
+	return mockBook, mockBooksErr
 }
 
-func (m *mockService) RemoveBooks(ids ...string) error {
+func (m *mockService) RemoveBookAuthor(ctx context.Context, bookID, authorID string, role books.Role) error {
+	mockCredit = books.Credit{AuthorID: authorID, Role: role}
 	return mockBooksErr
 }
 
-func (m *mockService) UpdateBook(bk books.Book) error {
+func (m *mockService) ListBooks(ctx context.Context, q books.BookQuery) (books.Page, error) {
+	mockBookQuery = q
+	return books.Page{Books: mockBooks, NextCursor: mockCursor}, mockBooksErr
+}
+
+func (m *mockService) RemoveBooks(ctx context.Context, ids ...string) error {
+	return mockBooksErr
+}
+
+func (m *mockService) UpdateBook(ctx context.Context, id string, p books.Patch) error {
+	mockPatch = p
+	return mockBooksErr
+}
+
+var mockPatch books.Patch
+
+var mockListPrice *money.Money
+var mockDetails books.Details
+var mockPrices []books.Price
+
+func (m *mockService) ListBookPrices(ctx context.Context, bookID string) ([]books.Price, error) {
+	return mockPrices, mockBooksErr
+}
+
+func (m *mockService) SetBookPrice(ctx context.Context, bookID string, p books.Price) error {
+	mockPrices = []books.Price{p}
 	return mockBooksErr
 }
+
+var mockStock stock.Level
+var mockStockErr error
+var mockStockMove stock.Kind
+
+func (m *mockService) GetStock(ctx context.Context, bookID string) (stock.Level, error) {
+	return mockStock, mockStockErr
+}
+
+func (m *mockService) ReceiveStock(ctx context.Context, bookID string, quantity int, reason string) (stock.Level, error) {
+	mockStockMove = stock.Receive
+	return mockStock, mockStockErr
+}
+
+func (m *mockService) AdjustStock(ctx context.Context, bookID string, change int, reason string) (stock.Level, error) {
+	mockStockMove = stock.Adjust
+	return mockStock, mockStockErr
+}
+
+func (m *mockService) ReserveStock(ctx context.Context, bookID string, quantity int, reason string) (stock.Level, error) {
+	mockStockMove = stock.Reserve
+	return mockStock, mockStockErr
+}
+
+func (m *mockService) ReleaseStock(ctx context.Context, bookID string, quantity int, reason string) (stock.Level, error) {
+	mockStockMove = stock.Release
+	return mockStock, mockStockErr
+}
+
+var mockCart carts.Cart
+var mockCartErr error
+var mockCartCall []interface{}
+
+func (m *mockService) CreateCart(ctx context.Context) (carts.Cart, error) {
+	return mockCart, mockCartErr
+}
+
+func (m *mockService) GetCart(ctx context.Context, token string) (carts.Cart, error) {
+	mockCartCall = []interface{}{"GetCart", token}
+	return mockCart, mockCartErr
+}
+
+func (m *mockService) AddCartItem(ctx context.Context, token, bookID string, quantity int) (carts.Cart, error) {
+	mockCartCall = []interface{}{"AddCartItem", token, bookID, quantity}
+	return mockCart, mockCartErr
+}
+
+func (m *mockService) SetCartItem(ctx context.Context, token, bookID string, quantity int) (carts.Cart, error) {
+	mockCartCall = []interface{}{"SetCartItem", token, bookID, quantity}
+	return mockCart, mockCartErr
+}
+
+func (m *mockService) RemoveCartItem(ctx context.Context, token, bookID string) (carts.Cart, error) {
+	mockCartCall = []interface{}{"RemoveCartItem", token, bookID}
+	return mockCart, mockCartErr
+}
+
+func (m *mockService) MergeCarts(ctx context.Context, token, otherToken string) (carts.Cart, error) {
+	mockCartCall = []interface{}{"MergeCarts", token, otherToken}
+	return mockCart, mockCartErr
+}
+
+var mockCategory categories.Category
+var mockCategories []categories.Category
+var mockTags []books.Tag
+var mockCategoryErr error
+var mockCategoryCall []interface{}
+
+func (m *mockService) AddCategory(ctx context.Context, c categories.Category) (categories.Category, error) {
+	mockCategoryCall = []interface{}{"AddCategory", c}
+	return mockCategory, mockCategoryErr
+}
+
+func (m *mockService) GetCategory(ctx context.Context, id string) (categories.Category, error) {
+	mockCategoryCall = []interface{}{"GetCategory", id}
+	return mockCategory, mockCategoryErr
+}
+
+func (m *mockService) ListCategories(ctx context.Context) ([]categories.Category, error) {
+	mockCategoryCall = []interface{}{"ListCategories"}
+	return mockCategories, mockCategoryErr
+}
+
+func (m *mockService) UpdateCategory(ctx context.Context, c categories.Category) (categories.Category, error) {
+	mockCategoryCall = []interface{}{"UpdateCategory", c}
+	return mockCategory, mockCategoryErr
+}
+
+func (m *mockService) RemoveCategory(ctx context.Context, id string) error {
+	mockCategoryCall = []interface{}{"RemoveCategory", id}
+	return mockCategoryErr
+}
+
+func (m *mockService) AddBookCategory(ctx context.Context, bookID, categoryID string) error {
+	mockCategoryCall = []interface{}{"AddBookCategory", bookID, categoryID}
+	return mockCategoryErr
+}
+
+func (m *mockService) RemoveBookCategory(ctx context.Context, bookID, categoryID string) error {
+	mockCategoryCall = []interface{}{"RemoveBookCategory", bookID, categoryID}
+	return mockCategoryErr
+}
+
+func (m *mockService) TagBook(ctx context.Context, bookID, tag string) error {
+	mockCategoryCall = []interface{}{"TagBook", bookID, tag}
+	return mockCategoryErr
+}
+
+func (m *mockService) UntagBook(ctx context.Context, bookID, tag string) error {
+	mockCategoryCall = []interface{}{"UntagBook", bookID, tag}
+	return mockCategoryErr
+}
+
+func (m *mockService) ListTags(ctx context.Context) ([]books.Tag, error) {
+	mockCategoryCall = []interface{}{"ListTags"}
+	return mockTags, mockCategoryErr
+}
+
+var mockPublisher publishers.Publisher
+var mockPublishers publishers.Page
+var mockPublisherErr error
+var mockPublisherCall []interface{}
+
+func (m *mockService) AddPublisher(ctx context.Context, pub publishers.Publisher) (publishers.Publisher, error) {
+	mockPublisherCall = []interface{}{"AddPublisher", pub}
+	return mockPublisher, mockPublisherErr
+}
+
+func (m *mockService) GetPublisher(ctx context.Context, id string) (publishers.Publisher, error) {
+	mockPublisherCall = []interface{}{"GetPublisher", id}
+	return mockPublisher, mockPublisherErr
+}
+
+func (m *mockService) ListPublishers(ctx context.Context, q publishers.PublisherQuery) (publishers.Page, error) {
+	mockPublisherCall = []interface{}{"ListPublishers", q}
+	return mockPublishers, mockPublisherErr
+}
+
+func (m *mockService) RemovePublisher(ctx context.Context, id string) error {
+	mockPublisherCall = []interface{}{"RemovePublisher", id}
+	return mockPublisherErr
+}
+
+func (m *mockService) UpdatePublisher(ctx context.Context, pub publishers.Publisher) error {
+	mockPublisherCall = []interface{}{"UpdatePublisher", pub}
+	return mockPublisherErr
+}
+
+var mockOrder orders.Order
+var mockOrders orders.Page
+var mockOrderErr error
+var mockOrderCall []interface{}
+
+func (m *mockService) PlaceOrder(ctx context.Context, lines []orders.Line) (orders.Order, error) {
+	mockOrderCall = []interface{}{"PlaceOrder", lines}
+	return mockOrder, mockOrderErr
+}
+
+func (m *mockService) CheckoutCart(ctx context.Context, token string) (orders.Order, error) {
+	mockOrderCall = []interface{}{"CheckoutCart", token}
+	return mockOrder, mockOrderErr
+}
+
+func (m *mockService) GetOrder(ctx context.Context, id string) (orders.Order, error) {
+	mockOrderCall = []interface{}{"GetOrder", id}
+	return mockOrder, mockOrderErr
+}
+
+func (m *mockService) ListOrders(ctx context.Context, q orders.OrderQuery) (orders.Page, error) {
+	mockOrderCall = []interface{}{"ListOrders", q}
+	return mockOrders, mockOrderErr
+}
+
+func (m *mockService) CancelOrder(ctx context.Context, id string) (orders.Order, error) {
+	mockOrderCall = []interface{}{"CancelOrder", id}
+	return mockOrder, mockOrderErr
+}
+
+func (m *mockService) UpdateOrderStatus(ctx context.Context, id string, status orders.Status) (orders.Order, error) {
+	mockOrderCall = []interface{}{"UpdateOrderStatus", id, status}
+	return mockOrder, mockOrderErr
+}
+
+var mockUser users.User
+var mockUserErr error
+var mockUserCall []interface{}
+
+func (m *mockService) RegisterUser(ctx context.Context, u users.User, password string) (users.User, error) {
+	mockUserCall = []interface{}{"RegisterUser", u, password}
+	return mockUser, mockUserErr
+}
+
+func (m *mockService) GetUser(ctx context.Context, id string) (users.User, error) {
+	mockUserCall = []interface{}{"GetUser", id}
+	return mockUser, mockUserErr
+}
+
+func (m *mockService) UpdateUser(ctx context.Context, u users.User) (users.User, error) {
+	mockUserCall = []interface{}{"UpdateUser", u}
+	return mockUser, mockUserErr
+}
+
+func (m *mockService) ChangePassword(ctx context.Context, id, current, next string) error {
+	mockUserCall = []interface{}{"ChangePassword", id, current, next}
+	return mockUserErr
+}
+
+func (m *mockService) SetUserEnabled(ctx context.Context, id string, enabled bool) error {
+	mockUserCall = []interface{}{"SetUserEnabled", id, enabled}
+	return mockUserErr
+}
+
+func (m *mockService) SetUserRole(ctx context.Context, id string, role users.Role) error {
+	mockUserCall = []interface{}{"SetUserRole", id, role}
+	return mockUserErr
+}
+
+const mockToken = "token01"
+
+var mockSessionUser = users.User{ID: "usr01", Email: "ann@example.com", FirstName: "Ann", LastName: "Lee", Enabled: true}
+var mockCreds auth.Credentials
+var mockSessionErr error
+var mockSessionCall []interface{}
+
+func (m *mockService) Login(ctx context.Context, email, password string) (auth.Credentials, error) {
+	mockSessionCall = []interface{}{"Login", email, password}
+	return mockCreds, mockSessionErr
+}
+
+func (m *mockService) Authenticate(ctx context.Context, token string) (users.User, error) {
+	if token != mockToken {
+		return users.User{}, service.ErrUnauthenticated
+	}
+	return mockSessionUser, nil
+}
+
+func (m *mockService) RefreshSession(ctx context.Context, token string) (auth.Credentials, error) {
+	mockSessionCall = []interface{}{"RefreshSession", token}
+	return mockCreds, mockSessionErr
+}
+
+func (m *mockService) Logout(ctx context.Context, token string) error {
+	mockSessionCall = []interface{}{"Logout", token}
+	return mockSessionErr
+}
+
+func (m *mockService) RevokeSessions(ctx context.Context, userID string) error {
+	mockSessionCall = []interface{}{"RevokeSessions", userID}
+	return mockSessionErr
+}
+
+const mockKey = "bsk_key01"
+
+var mockKeyScopes auth.Scopes
+var mockIssued auth.IssuedKey
+var mockKeys []auth.APIKey
+var mockKeyErr error
+var mockKeyCall []interface{}
+
+func (m *mockService) CreateAPIKey(ctx context.Context, userID, name string, scopes auth.Scopes) (auth.IssuedKey, error) {
+	mockKeyCall = []interface{}{"CreateAPIKey", userID, name, scopes}
+	return mockIssued, mockKeyErr
+}
+
+func (m *mockService) ListAPIKeys(ctx context.Context, userID string) ([]auth.APIKey, error) {
+	mockKeyCall = []interface{}{"ListAPIKeys", userID}
+	return mockKeys, mockKeyErr
+}
+
+func (m *mockService) RotateAPIKey(ctx context.Context, userID, id string) (auth.IssuedKey, error) {
+	mockKeyCall = []interface{}{"RotateAPIKey", userID, id}
+	return mockIssued, mockKeyErr
+}
+
+func (m *mockService) RevokeAPIKey(ctx context.Context, userID, id string) error {
+	mockKeyCall = []interface{}{"RevokeAPIKey", userID, id}
+	return mockKeyErr
+}
+
+func (m *mockService) AuthenticateKey(ctx context.Context, key string) (users.User, auth.APIKey, error) {
+	if key != mockKey {
+		return users.User{}, auth.APIKey{}, service.ErrUnauthenticated
+	}
+	return mockSessionUser, auth.APIKey{ID: "key01", UserID: mockSessionUser.ID, Scopes: mockKeyScopes}, nil
+}
+
+var mockReview reviews.Review
+var mockReviews reviews.Page
+var mockReviewErr error
+var mockReviewCall []interface{}
+
+func (m *mockService) AddReview(ctx context.Context, r reviews.Review) (reviews.Review, error) {
+	mockReviewCall = []interface{}{"AddReview", r}
+	return mockReview, mockReviewErr
+}
+
+func (m *mockService) GetReview(ctx context.Context, bookID, id string) (reviews.Review, error) {
+	mockReviewCall = []interface{}{"GetReview", bookID, id}
+	return mockReview, mockReviewErr
+}
+
+func (m *mockService) ListReviews(ctx context.Context, bookID string, q reviews.ReviewQuery) (reviews.Page, error) {
+	mockReviewCall = []interface{}{"ListReviews", bookID, q}
+	return mockReviews, mockReviewErr
+}
+
+func (m *mockService) UpdateReview(ctx context.Context, r reviews.Review) (reviews.Review, error) {
+	mockReviewCall = []interface{}{"UpdateReview", r}
+	return mockReview, mockReviewErr
+}
+
+func (m *mockService) ModerateReview(ctx context.Context, bookID, id string, status reviews.Status) (reviews.Review, error) {
+	mockReviewCall = []interface{}{"ModerateReview", bookID, id, status}
+	return mockReview, mockReviewErr
+}
+
+func (m *mockService) RemoveReview(ctx context.Context, bookID, id string) error {
+	mockReviewCall = []interface{}{"RemoveReview", bookID, id}
+	return mockReviewErr
+}
+
+var mockResults []search.Result
+var mockResultsErr error
+var mockWait bool
+
+func (m *mockService) Search(ctx context.Context, query string, limit int) ([]search.Result, error) {
+	if mockWait {
+		// stand in for a query that runs until its context is done
+		<-ctx.Done()
+		if mockResultsErr != nil {
+			return nil, mockResultsErr
+		}
+		return nil, ctx.Err()
+	}
+	return mockResults, mockResultsErr
+}
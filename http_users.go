@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"bookshop/users"
+
+	"github.com/gorilla/mux"
+)
+
+type userBody struct {
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Password  string `json:"password"`
+}
+
+func (b userBody) user() users.User {
+	return users.User{
+		Email:     strings.TrimSpace(b.Email),
+		FirstName: strings.TrimSpace(b.FirstName),
+		LastName:  strings.TrimSpace(b.LastName),
+	}
+}
+
+type passwordBody struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type enabledBody struct {
+	Enabled *bool `json:"enabled"`
+}
+
+type roleBody struct {
+	Role string `json:"role"`
+}
+
+// RegisterUser adds a user account, answering with the user minus their password.
+func (s *HTTPServer) RegisterUser(w http.ResponseWriter, r *http.Request) {
+	var body userBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+	u := body.user()
+	if err := validateUser(u); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+	if body.Password == "" {
+		s.handleError(w, r, "request", errors.New("user password cannot be blank"))
+		return
+	}
+
+	created, err := s.svc.RegisterUser(r.Context(), u, body.Password)
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	added, err := json.Marshal(created)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	s.serve(w, r, added)
+}
+
+// GetUser answers a request for a user's profile.
+func (s *HTTPServer) GetUser(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["user_id"]
+	if strings.TrimSpace(userID) == "" {
+		s.handleError(w, r, "request", errors.New("user ID cannot be blank"))
+		return
+	}
+
+	u, err := s.svc.GetUser(r.Context(), userID)
+	s.serveUser(w, r, u, err)
+}
+
+// UpdateUser changes a user's email address and names.
+func (s *HTTPServer) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["user_id"]
+	if strings.TrimSpace(userID) == "" {
+		s.handleError(w, r, "request", errors.New("user ID cannot be blank"))
+		return
+	}
+
+	var body userBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+	u := body.user()
+	u.ID = userID
+	if err := validateUser(u); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	updated, err := s.svc.UpdateUser(r.Context(), u)
+	s.serveUser(w, r, updated, err)
+}
+
+// ChangePassword replaces a user's password given their current one, which an
+// admin resetting someone else's may leave out.
+func (s *HTTPServer) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["user_id"]
+	if strings.TrimSpace(userID) == "" {
+		s.handleError(w, r, "request", errors.New("user ID cannot be blank"))
+		return
+	}
+
+	var body passwordBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+	if body.NewPassword == "" {
+		s.handleError(w, r, "request", errors.New("new password cannot be blank"))
+		return
+	}
+
+	if err := s.svc.ChangePassword(r.Context(), userID, body.CurrentPassword, body.NewPassword); err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	s.serve(w, r, []byte{})
+}
+
+// SetUserEnabled enables or disables a user.
+func (s *HTTPServer) SetUserEnabled(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["user_id"]
+	if strings.TrimSpace(userID) == "" {
+		s.handleError(w, r, "request", errors.New("user ID cannot be blank"))
+		return
+	}
+
+	var body enabledBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+	if body.Enabled == nil {
+		s.handleError(w, r, "request", errors.New("enabled must be true or false"))
+		return
+	}
+
+	if err := s.svc.SetUserEnabled(r.Context(), userID, *body.Enabled); err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	s.serve(w, r, []byte{})
+}
+
+// SetUserRole changes a user's role.
+func (s *HTTPServer) SetUserRole(w http.ResponseWriter, r *http.Request) {
+	userID := mux.Vars(r)["user_id"]
+	if strings.TrimSpace(userID) == "" {
+		s.handleError(w, r, "request", errors.New("user ID cannot be blank"))
+		return
+	}
+
+	var body roleBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+	role, err := users.ParseRole(body.Role)
+	if err != nil {
+		s.handleError(w, r, "request", err)
+		return
+	}
+
+	if err := s.svc.SetUserRole(r.Context(), userID, role); err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	s.serve(w, r, []byte{})
+}
+
+// serveUser answers with the user, or with the error of the service call that
+// returned it.
+func (s *HTTPServer) serveUser(w http.ResponseWriter, r *http.Request, u users.User, err error) {
+	if err != nil {
+		s.handleError(w, r, "service", err)
+		return
+	}
+
+	userResp, err := json.Marshal(u)
+	if err != nil {
+		s.handleError(w, r, "other", err)
+		return
+	}
+	s.serve(w, r, userResp)
+}
+
+func validateUser(u users.User) error {
+	if u.Email == "" {
+		return errors.New("user email cannot be blank")
+	}
+	if u.FirstName == "" {
+		return errors.New("user first name cannot be blank")
+	}
+	if u.LastName == "" {
+		return errors.New("user last name cannot be blank")
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"bookshop/service"
+	"bookshop/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPServerUsers(t *testing.T) {
+	mockUser = users.User{ID: "usr01", Email: "ann@example.com", FirstName: "Ann", LastName: "Lee", PasswordHash: "secret hash", Enabled: true}
+
+	t.Run("POST", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockUserErr = nil
+			resp := makeRequest(t, "POST", "/users", `{"email": " ann@example.com", "first_name": "Ann", "last_name": "Lee", "password": "correct horse"}`)
+			require.Equal(t, http.StatusCreated, resp.Code)
+			assert.Equal(t, []interface{}{"RegisterUser", users.User{Email: "ann@example.com", FirstName: "Ann", LastName: "Lee"}, "correct horse"}, mockUserCall)
+			assert.False(t, strings.Contains(resp.Body.String(), "secret hash"), "the password hash is never answered with")
+
+			var content users.User
+			err := json.NewDecoder(resp.Body).Decode(&content)
+			require.NoError(t, err)
+			assert.Equal(t, "usr01", content.ID)
+			assert.True(t, content.Enabled)
+		})
+
+		t.Run("missing fields", func(t *testing.T) {
+			mockUserErr, mockUserCall = nil, nil
+			for _, body := range []string{
+				`{"first_name": "Ann", "last_name": "Lee", "password": "correct horse"}`,
+				`{"email": "ann@example.com", "last_name": "Lee", "password": "correct horse"}`,
+				`{"email": "ann@example.com", "first_name": "Ann", "password": "correct horse"}`,
+				`{"email": "ann@example.com", "first_name": "Ann", "last_name": "Lee"}`,
+			} {
+				resp := makeRequest(t, "POST", "/users", body)
+				assert.Equal(t, http.StatusBadRequest, resp.Code, body)
+			}
+			assert.Nil(t, mockUserCall)
+		})
+
+		t.Run("email taken", func(t *testing.T) {
+			mockUserErr = service.NewErrDuplicateUser("ann@example.com")
+			resp := makeRequest(t, "POST", "/users", `{"email": "ann@example.com", "first_name": "Ann", "last_name": "Lee", "password": "correct horse"}`)
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+		})
+	})
+
+	t.Run("GET", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockUserErr = nil
+			resp := makeRequest(t, "GET", "/users/usr01", "")
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, []interface{}{"GetUser", "usr01"}, mockUserCall)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			mockUserErr = service.NewErrNotFound("user", "usr01")
+			resp := makeRequest(t, "GET", "/users/usr01", "")
+			assert.Equal(t, http.StatusNotFound, resp.Code)
+		})
+	})
+
+	t.Run("PATCH", func(t *testing.T) {
+		mockUserErr = nil
+		resp := makeRequest(t, "PATCH", "/users/usr01", `{"email": "ann@example.org", "first_name": "Ann", "last_name": "Lee"}`)
+		require.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, []interface{}{"UpdateUser", users.User{ID: "usr01", Email: "ann@example.org", FirstName: "Ann", LastName: "Lee"}}, mockUserCall)
+	})
+
+	t.Run("password", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockUserErr = nil
+			resp := makeRequest(t, "PUT", "/users/usr01/password", `{"current_password": "correct horse", "new_password": "battery staple"}`)
+			require.Equal(t, http.StatusAccepted, resp.Code)
+			assert.Equal(t, []interface{}{"ChangePassword", "usr01", "correct horse", "battery staple"}, mockUserCall)
+		})
+
+		t.Run("wrong password", func(t *testing.T) {
+			mockUserErr = service.NewErrInvalid("current password", users.ErrPasswordMismatch)
+			resp := makeRequest(t, "PUT", "/users/usr01/password", `{"current_password": "wrong", "new_password": "battery staple"}`)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+
+		t.Run("reset", func(t *testing.T) {
+			mockUserErr = nil
+			resp := makeRequest(t, "PUT", "/users/usr01/password", `{"new_password": "battery staple"}`)
+			require.Equal(t, http.StatusAccepted, resp.Code)
+			assert.Equal(t, []interface{}{"ChangePassword", "usr01", "", "battery staple"}, mockUserCall)
+		})
+
+		t.Run("missing new password", func(t *testing.T) {
+			mockUserErr = nil
+			resp := makeRequest(t, "PUT", "/users/usr01/password", `{"current_password": "correct horse"}`)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockUserErr = nil
+			resp := makeRequest(t, "PUT", "/users/usr01/enabled", `{"enabled": false}`)
+			require.Equal(t, http.StatusAccepted, resp.Code)
+			assert.Equal(t, []interface{}{"SetUserEnabled", "usr01", false}, mockUserCall)
+		})
+
+		t.Run("missing", func(t *testing.T) {
+			mockUserErr = nil
+			resp := makeRequest(t, "PUT", "/users/usr01/enabled", `{}`)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+	})
+	t.Run("PUT role", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockUserErr = nil
+			resp := makeRequest(t, "PUT", "/users/usr01/role", `{"role": "Staff"}`)
+			require.Equal(t, http.StatusAccepted, resp.Code)
+			assert.Equal(t, []interface{}{"SetUserRole", "usr01", users.RoleStaff}, mockUserCall)
+		})
+
+		t.Run("unknown role", func(t *testing.T) {
+			mockUserErr = nil
+			resp := makeRequest(t, "PUT", "/users/usr01/role", `{"role": "owner"}`)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+
+		t.Run("forbidden", func(t *testing.T) {
+			mockUserErr = service.NewErrForbidden(users.PermManageUsers)
+			defer func() { mockUserErr = nil }()
+			resp := makeRequest(t, "PUT", "/users/usr01/role", `{"role": "admin"}`)
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+		})
+	})
+}
+
+func TestHTTPServerUsersOwnership(t *testing.T) {
+	ctx := context.Background()
+	srv, svc := newMemoryServer()
+	register := func(email string) (users.User, string) {
+		u, err := svc.RegisterUser(ctx, users.User{Email: email, FirstName: "First", LastName: "Last"}, "correct horse")
+		require.NoError(t, err)
+		creds, err := svc.Login(ctx, email, "correct horse")
+		require.NoError(t, err)
+		return u, creds.Token
+	}
+	ann, annToken := register("ann@example.com")
+	_, bobToken := register("bob@example.com")
+
+	for _, req := range [][3]string{
+		{"GET", "/users/" + ann.ID, ""},
+		{"PATCH", "/users/" + ann.ID, `{"email": "bob@example.org", "first_name": "Bob", "last_name": "Lee"}`},
+		{"PUT", "/users/" + ann.ID + "/password", `{"current_password": "correct horse", "new_password": "battery staple"}`},
+		{"PUT", "/users/" + ann.ID + "/enabled", `{"enabled": false}`},
+		{"DELETE", "/users/" + ann.ID + "/sessions", ""},
+	} {
+		resp := makeRequestTo(t, srv, bobToken, req[0], req[1], req[2])
+		assert.Equal(t, http.StatusForbidden, resp.Code, req[:2])
+	}
+
+	resp := makeRequestTo(t, srv, annToken, "GET", "/users/"+ann.ID, "")
+	require.Equal(t, http.StatusOK, resp.Code, "users read themselves")
+	var content users.User
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&content))
+	assert.Equal(t, "ann@example.com", content.Email, "nothing was changed by another user")
+	resp = makeRequestTo(t, srv, annToken, "PUT", "/users/"+ann.ID+"/enabled", `{"enabled": false}`)
+	assert.Equal(t, http.StatusForbidden, resp.Code, "only admins enable or disable users")
+	resp = makeRequestTo(t, srv, annToken, "DELETE", "/users/"+ann.ID+"/sessions", "")
+	assert.Equal(t, http.StatusAccepted, resp.Code, "users sign themselves out everywhere")
+}
@@ -1,38 +1,109 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"bookshop/auth"
 	"bookshop/authors"
 	"bookshop/books"
+	"bookshop/carts"
+	"bookshop/categories"
 	"bookshop/datastore"
+	"bookshop/memstore"
+	"bookshop/migrations"
+	"bookshop/orders"
+	"bookshop/publishers"
+	"bookshop/reviews"
 	"bookshop/service"
+	"bookshop/stock"
+	"bookshop/users"
+
+	"github.com/jmoiron/sqlx"
 )
 
 func main() {
-	var dbUser, dbPass, dbName string
-	if dbUser = os.Getenv("bookshop_dbuser"); dbUser == "" {
-		log.Fatal("missing env variable bookshop_dbuser")
+	store := flag.String("store", "postgres", "datastore backend to use: postgres, sqlite or memory")
+	dbPath := flag.String("db-path", "bookshop.db", "database file used by the sqlite store")
+	queryTimeout := flag.Duration("query-timeout", 5*time.Second, "how long a request may spend querying before it is answered with a 504")
+	cartPurge := flag.Duration("cart-purge-interval", time.Hour, "how often expired carts and sessions are deleted")
+	routeTimeouts := flag.String("route-timeouts", "", "comma separated route=duration pairs overriding -query-timeout, e.g. Search=10s")
+	admin := flag.String("admin", "", "email address of an admin the memory store starts with, signing in with the password in the bookshop_admin_password env variable")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [migrate up|down [n]|status | role <email> <role>]\n", os.Args[0])
+		flag.PrintDefaults()
 	}
-	if dbPass = os.Getenv("bookshop_dbpass"); dbPass == "" {
-		log.Fatal("missing env variable bookshop_dbpass")
+	flag.Parse()
+
+	if flag.Arg(0) == "migrate" {
+		if *store == "memory" {
+			log.Fatal("the memory store has no schema to migrate")
+		}
+		if err := migrate(connectDB(*store, *dbPath), flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	if dbName = os.Getenv("bookshop_dbname"); dbName == "" {
-		log.Fatal("missing env variable bookshop_dbname")
+	if flag.Arg(0) == "role" {
+		if *store == "memory" {
+			log.Fatal("the memory store has no users to change")
+		}
+		data := connectDB(*store, *dbPath)
+		if *store == "sqlite" {
+			if err := migrate(data, []string{"up"}); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if err := setRole(sqlStores(data), flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *admin != "" && *store != "memory" {
+		log.Fatal("-admin is only used by the memory store, make admins of other stores with the role subcommand")
 	}
 
-	data, err := datastore.ConnectDB(dbUser, dbPass, dbName)
-	if err != nil {
-		panic(err)
+	var stores service.Stores
+	switch *store {
+	case "memory":
+		stores = memoryStores()
+		if *admin != "" {
+			if err := bootstrapAdmin(stores, *admin, os.Getenv("bookshop_admin_password")); err != nil {
+				log.Fatal(err)
+			}
+		}
+	case "sqlite":
+		// nothing else manages an embedded database, so keep it up to date
+		data := connectDB(*store, *dbPath)
+		if err := migrate(data, []string{"up"}); err != nil {
+			log.Fatal(err)
+		}
+		stores = sqlStores(data)
+	case "postgres":
+		stores = sqlStores(connectDB(*store, *dbPath))
+	default:
+		log.Fatalf("unknown store %q", *store)
 	}
 
-	bookStore := books.NewBookStore(data)
-	authStore := authors.NewAuthorStore(data)
-	service := service.NewService(&authStore, &bookStore)
-	httpServer := NewHTTPServer(&service)
+	service := service.NewService(stores)
+	httpServer := NewHTTPServer(&service, Timeouts{})
+	timeouts, err := parseTimeouts(*queryTimeout, *routeTimeouts, httpServer.routeNames())
+	if err != nil {
+		log.Fatal(err)
+	}
+	httpServer.timeouts = timeouts
+	go purgeExpired(&service, *cartPurge)
 
 	srv := &http.Server{
 		Handler:      httpServer,
@@ -43,3 +114,191 @@ func main() {
 
 	log.Fatal(srv.ListenAndServe())
 }
+
+func sqlStores(data *sqlx.DB) service.Stores {
+	as, bs, ss := authors.NewAuthorStore(data), books.NewBookStore(data), stock.NewStockStore(data)
+	cs, ords, us := carts.NewCartStore(data), orders.NewOrderStore(data), users.NewUserStore(data)
+	sess, keys, revs := auth.NewSessionStore(data), auth.NewKeyStore(data), reviews.NewReviewStore(data)
+	cats, pubs := categories.NewCategoryStore(data), publishers.NewPublisherStore(data)
+	uow := datastore.NewUnitOfWork(data)
+	return service.Stores{Authors: &as, Books: &bs, Carts: &cs, Categories: &cats, Keys: &keys, Orders: &ords, Publishers: &pubs, Reviews: &revs, Sessions: &sess, Stock: &ss, Users: &us, Work: &uow}
+}
+
+func memoryStores() service.Stores {
+	db := memstore.NewDB()
+	as, bs, ss := memstore.NewAuthorStore(db), memstore.NewBookStore(db), memstore.NewStockStore(db)
+	cs, ords, us := memstore.NewCartStore(db), memstore.NewOrderStore(db), memstore.NewUserStore(db)
+	sess, keys, revs := memstore.NewSessionStore(db), memstore.NewKeyStore(db), memstore.NewReviewStore(db)
+	cats, pubs := memstore.NewCategoryStore(db), memstore.NewPublisherStore(db)
+	uow := memstore.NewUnitOfWork(db)
+	return service.Stores{Authors: &as, Books: &bs, Carts: &cs, Categories: &cats, Keys: &keys, Orders: &ords, Publishers: &pubs, Reviews: &revs, Sessions: &sess, Stock: &ss, Users: &us, Work: &uow}
+}
+
+// purgeExpired deletes expired carts and sessions every interval for as long as
+// the server runs.
+func purgeExpired(svc *service.Service, interval time.Duration) {
+	purges := []struct {
+		kind  string
+		purge func(ctx context.Context) (int64, error)
+	}{
+		{"carts", svc.PurgeExpiredCarts},
+		{"sessions", svc.PurgeExpiredSessions},
+	}
+	for range time.Tick(interval) {
+		for _, p := range purges {
+			n, err := p.purge(context.Background())
+			if err != nil {
+				log.Printf("failed purging expired %s: %v", p.kind, err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("purged %d expired %s", n, p.kind)
+			}
+		}
+	}
+}
+
+// migrate runs the migrate subcommand given by args against the database.
+func migrate(data *sqlx.DB, args []string) error {
+	m, err := migrations.NewMigrator(data)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("missing migrate command: up, down or status")
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up()
+		for _, mig := range applied {
+			log.Printf("applied migration %04d_%s", mig.Version, mig.Name)
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of migrations to roll back: %s", args[1])
+			}
+		}
+		for i := 0; i < steps; i++ {
+			mig, err := m.Down()
+			if err != nil {
+				return err
+			}
+			log.Printf("rolled back migration %04d_%s", mig.Version, mig.Name)
+		}
+		return nil
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = "applied " + st.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", st.Version, st.Name, applied)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown migrate command %q", args[0])
+}
+
+// setRole runs the role subcommand given by args, changing the role of the user
+// with the given email address. It is how the first admin is made.
+func setRole(stores service.Stores, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("role needs an email address and a role: customer, staff or admin")
+	}
+	role, err := users.ParseRole(args[1])
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	u, err := stores.Users.ReadUserByEmail(ctx, users.NormalizeEmail(args[0]))
+	if err != nil {
+		return fmt.Errorf("failed finding user %s: %w", args[0], err)
+	}
+	if err := stores.Users.SetUserRole(ctx, u.ID, role); err != nil {
+		return err
+	}
+	log.Printf("%s is now %s", u.Email, role)
+	return nil
+}
+
+// bootstrapAdmin registers the admin given by the -admin flag with the password.
+// It is how the memory store, which starts without any users, gets its first
+// admin.
+func bootstrapAdmin(stores service.Stores, email, password string) error {
+	if password == "" {
+		return fmt.Errorf("missing env variable bookshop_admin_password for admin %s", email)
+	}
+	svc := service.NewService(stores)
+	if _, err := svc.RegisterUser(context.Background(), users.User{Email: email}, password); err != nil {
+		return fmt.Errorf("failed registering admin %s: %w", email, err)
+	}
+	return setRole(stores, []string{email, string(users.RoleAdmin)})
+}
+
+// parseTimeouts builds the route timeouts from the -route-timeouts flag value,
+// refusing routes that are not among the given route names and timeouts that
+// are not positive.
+func parseTimeouts(def time.Duration, routes string, names map[string]bool) (Timeouts, error) {
+	timeouts := Timeouts{Default: def, Routes: map[string]time.Duration{}}
+	if routes == "" {
+		return timeouts, nil
+	}
+	for _, pair := range strings.Split(routes, ",") {
+		name, raw, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return timeouts, fmt.Errorf("route timeout %q must be formatted as route=duration", pair)
+		}
+		if !names[name] {
+			return timeouts, fmt.Errorf("unknown route %q in route timeouts", name)
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return timeouts, fmt.Errorf("invalid timeout for route %s: %v", name, err)
+		}
+		if d <= 0 {
+			return timeouts, fmt.Errorf("timeout for route %s must be positive", name)
+		}
+		timeouts.Routes[name] = d
+	}
+	return timeouts, nil
+}
+
+func connectDB(store, dbPath string) *sqlx.DB {
+	if store == "sqlite" {
+		data, err := datastore.ConnectSQLite(dbPath)
+		if err != nil {
+			panic(err)
+		}
+		return data
+	}
+	if store != "postgres" {
+		log.Fatalf("unknown store %q", store)
+	}
+
+	var dbUser, dbPass, dbName string
+	if dbUser = os.Getenv("bookshop_dbuser"); dbUser == "" {
+		log.Fatal("missing env variable bookshop_dbuser")
+	}
+	if dbPass = os.Getenv("bookshop_dbpass"); dbPass == "" {
+		log.Fatal("missing env variable bookshop_dbpass")
+	}
+	if dbName = os.Getenv("bookshop_dbname"); dbName == "" {
+		log.Fatal("missing env variable bookshop_dbname")
+	}
+
+	data, err := datastore.ConnectDB(dbUser, dbPass, dbName)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
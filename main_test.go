@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"bookshop/datastore"
+	"bookshop/service"
+	"bookshop/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBootstrapAdmin(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("happy", func(t *testing.T) {
+		stores := memoryStores()
+		require.NoError(t, bootstrapAdmin(stores, "Ann@Example.com", "correct horse"))
+
+		svc := service.NewService(stores)
+		creds, err := svc.Login(ctx, "ann@example.com", "correct horse")
+		require.NoError(t, err)
+		u, err := svc.Authenticate(ctx, creds.Token)
+		require.NoError(t, err)
+		assert.Equal(t, users.RoleAdmin, u.Role)
+		assert.True(t, u.Can(users.PermManageUsers))
+	})
+
+	t.Run("missing password", func(t *testing.T) {
+		stores := memoryStores()
+		err := bootstrapAdmin(stores, "ann@example.com", "")
+		assert.EqualError(t, err, "missing env variable bookshop_admin_password for admin ann@example.com")
+		_, err = stores.Users.ReadUserByEmail(ctx, "ann@example.com")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound), "no user is made")
+	})
+
+	t.Run("invalid email", func(t *testing.T) {
+		err := bootstrapAdmin(memoryStores(), "ann", "correct horse")
+		assert.True(t, errors.Is(err, service.ErrInvalid))
+	})
+}
+
+func TestParseTimeouts(t *testing.T) {
+	names := NewHTTPServer(&mockService{}, Timeouts{}).routeNames()
+
+	t.Run("happy", func(t *testing.T) {
+		timeouts, err := parseTimeouts(2*time.Second, "Search=10s, ListBooks=5s", names)
+		require.NoError(t, err)
+		assert.Equal(t, 2*time.Second, timeouts.Default)
+		assert.Equal(t, map[string]time.Duration{"Search": 10 * time.Second, "ListBooks": 5 * time.Second}, timeouts.Routes)
+
+		timeouts, err = parseTimeouts(2*time.Second, "", names)
+		require.NoError(t, err)
+		assert.Empty(t, timeouts.Routes)
+	})
+
+	for name, tc := range map[string]struct{ routes, err string }{
+		"unknown route": {"search=10s", `unknown route "search" in route timeouts`},
+		"blank route":   {"=10s", `route timeout "=10s" must be formatted as route=duration`},
+		"no duration":   {"Search", `route timeout "Search" must be formatted as route=duration`},
+		"bad duration":  {"Search=ten", `invalid timeout for route Search: time: invalid duration "ten"`},
+		"zero":          {"Search=0s", "timeout for route Search must be positive"},
+		"negative":      {"Search=-1s", "timeout for route Search must be positive"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseTimeouts(2*time.Second, tc.routes, names)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+
+	"bookshop/authors"
+	"bookshop/books"
+	"bookshop/datastore"
+	"bookshop/search"
+
+	"github.com/pkg/errors"
+)
+
+// AuthorStore is an in-memory implementation of the author datastore.
+type AuthorStore struct {
+	db *DB
+}
+
+func NewAuthorStore(db *DB) AuthorStore {
+	return AuthorStore{db: db}
+}
+
+// ReadAuthors will return a page of authors matching the query.
+func (s *AuthorStore) ReadAuthors(ctx context.Context, q authors.AuthorQuery) (authors.Page, error) {
+	q = q.WithDefaults()
+	var c datastore.Cursor
+	if q.Cursor != "" {
+		var err error
+		if c, err = datastore.DecodeCursor(q.Cursor, string(q.SortBy), q.Descending); err != nil {
+			return authors.Page{}, err
+		}
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	auths := []authors.Author{}
+	for _, a := range s.db.authors {
+		if q.LastNamePrefix != "" && !hasPrefixFold(a.LastName, q.LastNamePrefix) {
+			continue
+		}
+		if q.DOBFrom != nil && compareTimes(a.DOB, q.DOBFrom) < 0 {
+			continue
+		}
+		if q.DOBTo != nil && compareTimes(a.DOB, q.DOBTo) > 0 {
+			continue
+		}
+		if q.Cursor != "" && !pastCursor(compareAuthorCursor(a, q.SortBy, c), q.Descending) {
+			continue
+		}
+		auths = append(auths, a)
+	}
+
+	sort.Slice(auths, func(i, j int) bool {
+		cmp := compareAuthors(auths[i], auths[j], q.SortBy)
+		if q.Descending {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+
+	if len(auths) <= q.Limit {
+		return authors.Page{Authors: auths}, nil
+	}
+	auths = auths[:q.Limit]
+	last := auths[len(auths)-1]
+	return authors.Page{
+		Authors: auths,
+		NextCursor: datastore.Cursor{
+			Sort:  string(q.SortBy),
+			Desc:  q.Descending,
+			Value: last.SortValue(q.SortBy),
+			ID:    last.ID,
+		}.Encode(),
+	}, nil
+}
+
+// ReadAuthorAndBooks will return the given author looked up author ID, returning
+// datastore.ErrNotFound if it does not exist.
+func (s *AuthorStore) ReadAuthorAndBooks(ctx context.Context, id string) (authors.Author, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	auth, ok := s.db.authors[id]
+	if !ok {
+		return authors.Author{}, datastore.ErrNotFound
+	}
+	var crs []credit
+	for cr := range s.db.credits {
+		if cr.authorID == id {
+			crs = append(crs, cr)
+		}
+	}
+	sort.Slice(crs, func(i, j int) bool {
+		bi, bj := s.db.books[crs[i].bookID], s.db.books[crs[j].bookID]
+		if bi.Title != bj.Title {
+			return bi.Title < bj.Title
+		}
+		if bi.ID != bj.ID {
+			return bi.ID < bj.ID
+		}
+		return crs[i].role < crs[j].role
+	})
+	for _, cr := range crs {
+		bk := s.db.books[cr.bookID]
+		auth.AddBook(books.Book{
+			ID:    bk.ID,
+			Title: bk.Title,
+			ISBN:  bk.ISBN,
+		}, cr.role)
+	}
+	return auth, nil
+}
+
+// DeleteAuthor will delete an author by their ID along with their book
+// credits, returning datastore.ErrNotFound if it does not exist.
+func (s *AuthorStore) DeleteAuthor(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("no id submitted to delete")
+	}
+	defer s.db.write(ctx, authorsTable|creditsTable)()
+
+	if _, ok := s.db.authors[id]; !ok {
+		return datastore.ErrNotFound
+	}
+	delete(s.db.authors, id)
+	for cr := range s.db.credits {
+		if cr.authorID == id {
+			delete(s.db.credits, cr)
+		}
+	}
+	return nil
+}
+
+// UpsertAuthors will modify or add the authors in the given list. No changes
+// are made if any of them shares a name and date of birth with another author.
+func (s *AuthorStore) UpsertAuthors(ctx context.Context, auths []authors.Author) error {
+	if len(auths) == 0 {
+		return errors.New("no authors to upsert")
+	}
+	for _, a := range auths {
+		if a.DOB == nil {
+			return errors.New("failed upserting authors: dob cannot be null")
+		}
+	}
+
+	defer s.db.write(ctx, authorsTable)()
+
+	next := make(map[string]authors.Author, len(s.db.authors)+len(auths))
+	for id, a := range s.db.authors {
+		next[id] = a
+	}
+	for _, a := range auths {
+		dob := *a.DOB
+		a.DOB = &dob
+		a.UpdatedAt = now()
+		a.Books, a.BooksByRole = nil, nil
+		next[a.ID] = a
+	}
+
+	seen := map[string]string{}
+	for id, a := range next {
+		key := authorKey(a)
+		if other, ok := seen[key]; ok && other != id {
+			return datastore.ErrDuplicate
+		}
+		seen[key] = id
+	}
+	s.db.authors = next
+	return nil
+}
+
+// UpdateAuthor will change the names and date of birth of the author, returning
+// datastore.ErrNotFound if it does not exist and datastore.ErrDuplicate if
+// another author shares the same name and date of birth.
+func (s *AuthorStore) UpdateAuthor(ctx context.Context, a authors.Author) error {
+	if a.DOB == nil {
+		return errors.New("failed updating author: dob cannot be null")
+	}
+	defer s.db.write(ctx, authorsTable)()
+
+	if _, ok := s.db.authors[a.ID]; !ok {
+		return datastore.ErrNotFound
+	}
+	key := authorKey(a)
+	for id, other := range s.db.authors {
+		if id != a.ID && authorKey(other) == key {
+			return datastore.ErrDuplicate
+		}
+	}
+	dob := *a.DOB
+	a.DOB, a.UpdatedAt = &dob, now()
+	a.Books, a.BooksByRole = nil, nil
+	s.db.authors[a.ID] = a
+	return nil
+}
+
+// authorKey returns the name and date of birth that no two authors may share.
+func authorKey(a authors.Author) string {
+	return a.FirstName + "\x00" + a.MiddleName + "\x00" + a.LastName + "\x00" + a.DOB.UTC().String()
+}
+
+// SearchAuthors will return the authors whose names contain every word of the
+// query, ranked by relevance.
+func (s *AuthorStore) SearchAuthors(ctx context.Context, query string, limit int) ([]search.Result, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	results := []search.Result{}
+	for _, a := range s.db.authors {
+		if res, ok := matchTerms(a.FullName(), query); ok {
+			res.Kind = search.KindAuthor
+			res.ID = a.ID
+			results = append(results, res)
+		}
+	}
+	return sortResults(results, limit), nil
+}
+
+func compareAuthors(a, b authors.Author, field authors.SortField) int {
+	var cmp int
+	switch field {
+	case authors.SortByFirstName:
+		cmp = compareStrings(a.FirstName, b.FirstName)
+	case authors.SortByDOB:
+		cmp = compareTimes(a.DOB, b.DOB)
+	default:
+		cmp = compareStrings(a.LastName, b.LastName)
+	}
+	if cmp != 0 {
+		return cmp
+	}
+	return compareStrings(a.ID, b.ID)
+}
+
+func compareAuthorCursor(a authors.Author, field authors.SortField, c datastore.Cursor) int {
+	return compareCursor(a.SortValue(field), a.DOB, a.ID, c, field == authors.SortByDOB)
+}
@@ -0,0 +1,551 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"bookshop/books"
+	"bookshop/carts"
+	"bookshop/categories"
+	"bookshop/datastore"
+	"bookshop/money"
+	"bookshop/reviews"
+	"bookshop/search"
+
+	"github.com/pkg/errors"
+)
+
+// BookStore is an in-memory implementation of the book datastore.
+type BookStore struct {
+	db *DB
+}
+
+func NewBookStore(db *DB) BookStore {
+	return BookStore{db: db}
+}
+
+// ReadBooks will return a page of books matching the query.
+func (s *BookStore) ReadBooks(ctx context.Context, q books.BookQuery) (books.Page, error) {
+	q = q.WithDefaults()
+	var c datastore.Cursor
+	if q.Cursor != "" {
+		var err error
+		if c, err = datastore.DecodeCursor(q.Cursor, string(q.SortBy), q.Descending); err != nil {
+			return books.Page{}, err
+		}
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	at, ratings := time.Now(), s.ratings()
+	var filedUnder map[string]bool
+	if q.Category != "" {
+		filedUnder = categories.Descendants(categoryList(s.db), q.Category)
+	}
+	bks := []books.Book{}
+	for _, b := range s.db.books {
+		if q.TitlePrefix != "" && !hasPrefixFold(b.Title, q.TitlePrefix) {
+			continue
+		}
+		if q.ISBNPrefix != "" && !strings.HasPrefix(string(b.ISBN), q.ISBNPrefix) {
+			continue
+		}
+		if q.AuthorLastName != "" && !s.creditedTo(b.ID, q.AuthorLastName) {
+			continue
+		}
+		if q.InStock && s.db.stock[b.ID].Available() < 1 {
+			continue
+		}
+		if q.Category != "" && !s.filed(b.ID, filedUnder) {
+			continue
+		}
+		if _, ok := s.db.tags[bookTag{bookID: b.ID, tag: q.Tag}]; q.Tag != "" && !ok {
+			continue
+		}
+		if q.Cursor != "" && !pastCursor(compareBookCursor(b, q.SortBy, c), q.Descending) {
+			continue
+		}
+		b.ListPrice, b.Rating = s.listPrice(b.ID, at), ratingOf(ratings, b.ID)
+		b.Publisher = s.publisher(b.PublisherID)
+		bks = append(bks, b)
+	}
+
+	sort.Slice(bks, func(i, j int) bool {
+		cmp := compareBooks(bks[i], bks[j], q.SortBy)
+		if q.Descending {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+
+	if len(bks) <= q.Limit {
+		return books.Page{Books: bks}, nil
+	}
+	bks = bks[:q.Limit]
+	last := bks[len(bks)-1]
+	return books.Page{
+		Books: bks,
+		NextCursor: datastore.Cursor{
+			Sort:  string(q.SortBy),
+			Desc:  q.Descending,
+			Value: last.SortValue(q.SortBy),
+			ID:    last.ID,
+		}.Encode(),
+	}, nil
+}
+
+// ReadBookByISBN will return the given book looked up by isbn, returning
+// datastore.ErrNotFound if it does not exist.
+func (s *BookStore) ReadBookByISBN(ctx context.Context, isbn string) (books.Book, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, b := range s.db.books {
+		if string(b.ISBN) == isbn {
+			b.ListPrice, b.Rating = s.listPrice(b.ID, time.Now()), ratingOf(s.ratings(), b.ID)
+			b.Publisher = s.publisher(b.PublisherID)
+			return b, nil
+		}
+	}
+	return books.Book{}, datastore.ErrNotFound
+}
+
+// ReadBookByID will return the given book looked up by ID along with its
+// authors, categories and tags, returning datastore.ErrNotFound if it does not exist.
+func (s *BookStore) ReadBookByID(ctx context.Context, id string) (books.Book, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	bk, ok := s.db.books[id]
+	if !ok {
+		return books.Book{}, datastore.ErrNotFound
+	}
+	bk.ListPrice, bk.Rating = s.listPrice(id, time.Now()), ratingOf(s.ratings(), id)
+	bk.Publisher = s.publisher(bk.PublisherID)
+	for cr, seq := range s.db.credits {
+		if cr.bookID != id {
+			continue
+		}
+		a := s.db.authors[cr.authorID]
+		bk.Authors = append(bk.Authors, books.BookAuthor{
+			ID:         a.ID,
+			FirstName:  a.FirstName,
+			MiddleName: a.MiddleName,
+			LastName:   a.LastName,
+			Role:       cr.role,
+			Sequence:   seq,
+		})
+	}
+	sort.Slice(bk.Authors, func(i, j int) bool {
+		ai, aj := bk.Authors[i], bk.Authors[j]
+		if ai.Sequence != aj.Sequence {
+			return ai.Sequence < aj.Sequence
+		}
+		if ai.LastName != aj.LastName {
+			return ai.LastName < aj.LastName
+		}
+		if ai.FirstName != aj.FirstName {
+			return ai.FirstName < aj.FirstName
+		}
+		return ai.Role < aj.Role
+	})
+	for f := range s.db.filings {
+		if f.bookID == id {
+			c := s.db.categories[f.categoryID]
+			bk.Categories = append(bk.Categories, books.BookCategory{ID: c.ID, Name: c.Name})
+		}
+	}
+	sort.Slice(bk.Categories, func(i, j int) bool {
+		ni, nj := strings.ToLower(bk.Categories[i].Name), strings.ToLower(bk.Categories[j].Name)
+		if ni != nj {
+			return ni < nj
+		}
+		return bk.Categories[i].ID < bk.Categories[j].ID
+	})
+	for bt := range s.db.tags {
+		if bt.bookID == id {
+			bk.Tags = append(bk.Tags, bt.tag)
+		}
+	}
+	sort.Strings(bk.Tags)
+	return bk, nil
+}
+
+// DeleteBooks will delete books by their ID along with their author credits,
+// categories, tags, prices, cart items, stock and reviews, returning datastore.ErrNotFound if none of them exist.
+func (s *BookStore) DeleteBooks(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return errors.New("no ids submitted to delete")
+	}
+	defer s.db.write(ctx, booksTable|creditsTable|filingsTable|tagsTable|pricesTable|cartsTable|stockTable|ledgerTable|reviewsTable)()
+
+	deleted := map[string]bool{}
+	for _, id := range ids {
+		if _, ok := s.db.books[id]; ok {
+			delete(s.db.books, id)
+			deleted[id] = true
+		}
+	}
+	if len(deleted) == 0 {
+		return datastore.ErrNotFound
+	}
+	for cr := range s.db.credits {
+		if deleted[cr.bookID] {
+			delete(s.db.credits, cr)
+		}
+	}
+	for f := range s.db.filings {
+		if deleted[f.bookID] {
+			delete(s.db.filings, f)
+		}
+	}
+	for bt := range s.db.tags {
+		if deleted[bt.bookID] {
+			delete(s.db.tags, bt)
+		}
+	}
+	prices := s.db.prices[:0:0]
+	for _, p := range s.db.prices {
+		if !deleted[p.bookID] {
+			prices = append(prices, p)
+		}
+	}
+	s.db.prices = prices
+	for token, c := range s.db.carts {
+		items := []carts.Item{}
+		for _, item := range c.Items {
+			if !deleted[item.BookID] {
+				items = append(items, item)
+			}
+		}
+		c.Items = items
+		s.db.carts[token] = c
+	}
+	for id := range deleted {
+		delete(s.db.stock, id)
+	}
+	ledger := s.db.ledger[:0:0]
+	for _, e := range s.db.ledger {
+		if !deleted[e.BookID] {
+			ledger = append(ledger, e)
+		}
+	}
+	s.db.ledger = ledger
+	for id, r := range s.db.reviews {
+		if deleted[r.BookID] {
+			delete(s.db.reviews, id)
+		}
+	}
+	return nil
+}
+
+// UpsertBooks will modify or add the books in the given list. No changes are
+// made if any of them shares an ISBN with another book.
+func (s *BookStore) UpsertBooks(ctx context.Context, bks []books.Book) error {
+	if len(bks) == 0 {
+		return errors.New("no books to upsert")
+	}
+	defer s.db.write(ctx, booksTable)()
+
+	next := make(map[string]books.Book, len(s.db.books)+len(bks))
+	for id, b := range s.db.books {
+		next[id] = b
+	}
+	for _, b := range bks {
+		b.UpdatedAt = now()
+		b.ListPrice, b.Rating = nil, nil
+		b.Authors, b.Categories, b.Tags, b.Publisher = nil, nil, nil, nil
+		if _, ok := s.db.publishers[b.PublisherID]; b.PublisherID != "" && !ok {
+			return datastore.ErrMissingReference
+		}
+		if b.Dimensions != nil {
+			d := *b.Dimensions
+			b.Dimensions = &d
+		}
+		next[b.ID] = b
+	}
+
+	seen := map[books.ISBN]string{}
+	for id, b := range next {
+		if other, ok := seen[b.ISBN]; ok && other != id {
+			return datastore.ErrDuplicate
+		}
+		seen[b.ISBN] = id
+	}
+	s.db.books = next
+	return nil
+}
+
+// LinkBookAuthors will credit authors on the book. Authors already credited in
+// the same role are moved to the given sequence.
+func (s *BookStore) LinkBookAuthors(ctx context.Context, bookID string, credits ...books.Credit) error {
+	if len(credits) == 0 {
+		return errors.New("no credits submitted to link")
+	}
+	defer s.db.write(ctx, creditsTable)()
+
+	if _, ok := s.db.books[bookID]; !ok {
+		return datastore.ErrMissingReference
+	}
+	for _, c := range credits {
+		if _, ok := s.db.authors[c.AuthorID]; !ok {
+			return datastore.ErrMissingReference
+		}
+	}
+	for _, c := range credits {
+		s.db.credits[credit{bookID: bookID, authorID: c.AuthorID, role: c.Role}] = c.Sequence
+	}
+	return nil
+}
+
+// UnlinkBookAuthor will remove the given author's credit in the role from the
+// book, or all of their credits when the role is empty, returning
+// datastore.ErrNotFound if the author was not credited.
+func (s *BookStore) UnlinkBookAuthor(ctx context.Context, bookID, authorID string, role books.Role) error {
+	defer s.db.write(ctx, creditsTable)()
+
+	var found bool
+	for cr := range s.db.credits {
+		if cr.bookID == bookID && cr.authorID == authorID && (role == "" || cr.role == role) {
+			delete(s.db.credits, cr)
+			found = true
+		}
+	}
+	if !found {
+		return datastore.ErrNotFound
+	}
+	return nil
+}
+
+// LinkBookCategories will file the book under the given categories. Categories
+// the book is already filed under are left untouched.
+func (s *BookStore) LinkBookCategories(ctx context.Context, bookID string, categoryIDs ...string) error {
+	if len(categoryIDs) == 0 {
+		return errors.New("no category ids submitted to link")
+	}
+	defer s.db.write(ctx, filingsTable)()
+
+	if _, ok := s.db.books[bookID]; !ok {
+		return datastore.ErrMissingReference
+	}
+	for _, id := range categoryIDs {
+		if _, ok := s.db.categories[id]; !ok {
+			return datastore.ErrMissingReference
+		}
+	}
+	for _, id := range categoryIDs {
+		s.db.filings[filing{bookID: bookID, categoryID: id}] = struct{}{}
+	}
+	return nil
+}
+
+// UnlinkBookCategory will take the book out of the given category, returning
+// datastore.ErrNotFound if it was not filed under it.
+func (s *BookStore) UnlinkBookCategory(ctx context.Context, bookID, categoryID string) error {
+	defer s.db.write(ctx, filingsTable)()
+
+	f := filing{bookID: bookID, categoryID: categoryID}
+	if _, ok := s.db.filings[f]; !ok {
+		return datastore.ErrNotFound
+	}
+	delete(s.db.filings, f)
+	return nil
+}
+
+// TagBook will give the book the given tags, returning
+// datastore.ErrMissingReference if the book does not exist. Tags the book
+// already has are left untouched.
+func (s *BookStore) TagBook(ctx context.Context, bookID string, tags ...string) error {
+	if len(tags) == 0 {
+		return errors.New("no tags submitted")
+	}
+	defer s.db.write(ctx, tagsTable)()
+
+	if _, ok := s.db.books[bookID]; !ok {
+		return datastore.ErrMissingReference
+	}
+	for _, tag := range tags {
+		s.db.tags[bookTag{bookID: bookID, tag: tag}] = struct{}{}
+	}
+	return nil
+}
+
+// UntagBook will remove the tag from the book, returning datastore.ErrNotFound
+// if the book does not have it.
+func (s *BookStore) UntagBook(ctx context.Context, bookID, tag string) error {
+	defer s.db.write(ctx, tagsTable)()
+
+	bt := bookTag{bookID: bookID, tag: tag}
+	if _, ok := s.db.tags[bt]; !ok {
+		return datastore.ErrNotFound
+	}
+	delete(s.db.tags, bt)
+	return nil
+}
+
+// ReadTags will return every tag given to a book along with how many books
+// have it, sorted by name.
+func (s *BookStore) ReadTags(ctx context.Context) ([]books.Tag, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	counts := map[string]int{}
+	for bt := range s.db.tags {
+		counts[bt.tag]++
+	}
+	tags := make([]books.Tag, 0, len(counts))
+	for tag, n := range counts {
+		tags = append(tags, books.Tag{Name: tag, Books: n})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+// AddBookPrice will record a list price for the book taking effect at the price's
+// effective time, returning datastore.ErrMissingReference if the book does not exist.
+func (s *BookStore) AddBookPrice(ctx context.Context, bookID string, p books.Price) error {
+	defer s.db.write(ctx, pricesTable)()
+
+	if _, ok := s.db.books[bookID]; !ok {
+		return datastore.ErrMissingReference
+	}
+	s.db.prices = append(s.db.prices, bookPrice{bookID: bookID, Price: p})
+	return nil
+}
+
+// ReadBookPrices will return the book's list prices ordered by when they take
+// effect, returning datastore.ErrNotFound if the book does not exist.
+func (s *BookStore) ReadBookPrices(ctx context.Context, bookID string) ([]books.Price, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	if _, ok := s.db.books[bookID]; !ok {
+		return nil, datastore.ErrNotFound
+	}
+	prices := []books.Price{}
+	for _, p := range s.db.prices {
+		if p.bookID == bookID {
+			prices = append(prices, p.Price)
+		}
+	}
+	sort.SliceStable(prices, func(i, j int) bool {
+		return prices[i].EffectiveFrom.Before(prices[j].EffectiveFrom)
+	})
+	return prices, nil
+}
+
+// SearchBooks will return the books whose titles contain every word of the
+// query, ranked by relevance.
+func (s *BookStore) SearchBooks(ctx context.Context, query string, limit int) ([]search.Result, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	results := []search.Result{}
+	for _, b := range s.db.books {
+		if res, ok := matchTerms(b.Title, query); ok {
+			res.Kind = search.KindBook
+			res.ID = b.ID
+			results = append(results, res)
+		}
+	}
+	return sortResults(results, limit), nil
+}
+
+// publisher returns the publisher with the given ID as shown on its books, or
+// nil for books without one. The caller must hold the read lock.
+func (s *BookStore) publisher(id string) *books.BookPublisher {
+	p, ok := s.db.publishers[id]
+	if !ok {
+		return nil
+	}
+	return &books.BookPublisher{ID: p.ID, Name: p.Name}
+}
+
+// listPrice returns the book's list price in effect at the given time, or nil
+// if it has none. The caller must hold the read lock.
+func (s *BookStore) listPrice(bookID string, at time.Time) *money.Money {
+	var current *bookPrice
+	for i, p := range s.db.prices {
+		if p.bookID != bookID || p.EffectiveFrom.After(at) {
+			continue
+		}
+		// later entries win ties, as they do in the database
+		if current == nil || !p.EffectiveFrom.Before(current.EffectiveFrom) {
+			current = &s.db.prices[i]
+		}
+	}
+	if current == nil {
+		return nil
+	}
+	price := current.ListPrice
+	return &price
+}
+
+// ratings returns the rating of each book with approved reviews. The caller
+// must hold the read lock.
+func (s *BookStore) ratings() map[string]books.Rating {
+	totals, counts := map[string]float64{}, map[string]int{}
+	for _, r := range s.db.reviews {
+		if r.Status == reviews.Approved {
+			totals[r.BookID] += float64(r.Rating)
+			counts[r.BookID]++
+		}
+	}
+	ratings := make(map[string]books.Rating, len(counts))
+	for id, n := range counts {
+		ratings[id] = books.NewRating(totals[id], n)
+	}
+	return ratings
+}
+
+// ratingOf returns the book's rating from ratings, which is zero for books
+// without approved reviews.
+func ratingOf(ratings map[string]books.Rating, id string) *books.Rating {
+	r := ratings[id]
+	return &r
+}
+
+// filed reports whether the book is filed under any of the given categories.
+// The caller must hold the read lock.
+func (s *BookStore) filed(bookID string, categoryIDs map[string]bool) bool {
+	for f := range s.db.filings {
+		if f.bookID == bookID && categoryIDs[f.categoryID] {
+			return true
+		}
+	}
+	return false
+}
+
+// creditedTo reports whether the book is credited to an author with the given
+// last name. The caller must hold the read lock.
+func (s *BookStore) creditedTo(bookID, lastName string) bool {
+	for cr := range s.db.credits {
+		if cr.bookID == bookID && strings.EqualFold(s.db.authors[cr.authorID].LastName, lastName) {
+			return true
+		}
+	}
+	return false
+}
+
+func compareBooks(a, b books.Book, field books.SortField) int {
+	var cmp int
+	switch field {
+	case books.SortByISBN:
+		cmp = compareStrings(string(a.ISBN), string(b.ISBN))
+	case books.SortByUpdatedAt:
+		cmp = compareTimes(a.UpdatedAt, b.UpdatedAt)
+	default:
+		cmp = compareStrings(a.Title, b.Title)
+	}
+	if cmp != 0 {
+		return cmp
+	}
+	return compareStrings(a.ID, b.ID)
+}
+
+func compareBookCursor(b books.Book, field books.SortField, c datastore.Cursor) int {
+	return compareCursor(b.SortValue(field), b.UpdatedAt, b.ID, c, field == books.SortByUpdatedAt)
+}
@@ -0,0 +1,174 @@
+package memstore
+
+import (
+	"context"
+	"time"
+
+	"bookshop/carts"
+	"bookshop/datastore"
+)
+
+// CartStore is an in-memory implementation of the cart datastore. Carts are
+// kept with their items, which are replaced rather than changed in place.
+type CartStore struct {
+	db *DB
+}
+
+func NewCartStore(db *DB) CartStore {
+	return CartStore{db: db}
+}
+
+// CreateCart will add the given cart without any items, returning
+// datastore.ErrDuplicate if its token is already in use.
+func (s *CartStore) CreateCart(ctx context.Context, c carts.Cart) error {
+	defer s.db.write(ctx, cartsTable)()
+
+	if _, ok := s.db.carts[c.Token]; ok {
+		return datastore.ErrDuplicate
+	}
+	c.CreatedAt, c.UpdatedAt = now(), now()
+	c.Items, c.Totals = []carts.Item{}, nil
+	s.db.carts[c.Token] = c
+	return nil
+}
+
+// ReadCart will return the cart with the given token along with its items,
+// returning datastore.ErrNotFound if it does not exist or has expired.
+func (s *CartStore) ReadCart(ctx context.Context, token string) (carts.Cart, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	c, ok := s.live(token)
+	if !ok {
+		return carts.Cart{}, datastore.ErrNotFound
+	}
+	c.Items = append([]carts.Item{}, c.Items...)
+	return c, nil
+}
+
+// AddCartItems will add the items to the cart, adding their quantities to those
+// of books already in it. It returns datastore.ErrMissingReference if the cart or
+// any of the books does not exist, and datastore.ErrCheckViolation if a book's
+// quantity would go over carts.MaxQuantity.
+func (s *CartStore) AddCartItems(ctx context.Context, token string, items ...carts.Item) error {
+	defer s.db.write(ctx, cartsTable)()
+	return s.upsertItems(token, items, true)
+}
+
+// SetCartItem will put the item in the cart, replacing the quantity of the book
+// if it is already in it. It returns the same errors as AddCartItems.
+func (s *CartStore) SetCartItem(ctx context.Context, token string, item carts.Item) error {
+	defer s.db.write(ctx, cartsTable)()
+	return s.upsertItems(token, []carts.Item{item}, false)
+}
+
+func (s *CartStore) upsertItems(token string, items []carts.Item, add bool) error {
+	c, ok := s.db.carts[token]
+	if !ok {
+		return datastore.ErrMissingReference
+	}
+	next := append([]carts.Item{}, c.Items...)
+	for _, item := range items {
+		if _, ok := s.db.books[item.BookID]; !ok {
+			return datastore.ErrMissingReference
+		}
+		found := false
+		for i := range next {
+			if next[i].BookID == item.BookID {
+				if add {
+					next[i].Quantity += item.Quantity
+				} else {
+					next[i].Quantity = item.Quantity
+				}
+				item = next[i]
+				found = true
+				break
+			}
+		}
+		if !found {
+			item.AddedAt = now()
+			item.Title, item.UnitPrice, item.LineTotal = "", nil, nil
+			next = append(next, item)
+		}
+		if item.Quantity < 1 || item.Quantity > carts.MaxQuantity {
+			return datastore.ErrCheckViolation
+		}
+	}
+	c.Items = next
+	s.db.carts[token] = c
+	return nil
+}
+
+// DeleteCartItem will take the book out of the cart, returning
+// datastore.ErrNotFound if it was not in it.
+func (s *CartStore) DeleteCartItem(ctx context.Context, token, bookID string) error {
+	defer s.db.write(ctx, cartsTable)()
+
+	c, ok := s.db.carts[token]
+	if !ok {
+		return datastore.ErrNotFound
+	}
+	next := []carts.Item{}
+	for _, item := range c.Items {
+		if item.BookID != bookID {
+			next = append(next, item)
+		}
+	}
+	if len(next) == len(c.Items) {
+		return datastore.ErrNotFound
+	}
+	c.Items = next
+	s.db.carts[token] = c
+	return nil
+}
+
+// TouchCart will mark the cart as changed and keep it until the given expiry,
+// returning datastore.ErrNotFound if it does not exist.
+func (s *CartStore) TouchCart(ctx context.Context, token string, expiresAt time.Time) error {
+	defer s.db.write(ctx, cartsTable)()
+
+	c, ok := s.db.carts[token]
+	if !ok {
+		return datastore.ErrNotFound
+	}
+	c.UpdatedAt, c.ExpiresAt = now(), &expiresAt
+	s.db.carts[token] = c
+	return nil
+}
+
+// DeleteCart will delete the cart and its items, returning datastore.ErrNotFound
+// if it does not exist.
+func (s *CartStore) DeleteCart(ctx context.Context, token string) error {
+	defer s.db.write(ctx, cartsTable)()
+
+	if _, ok := s.db.carts[token]; !ok {
+		return datastore.ErrNotFound
+	}
+	delete(s.db.carts, token)
+	return nil
+}
+
+// DeleteExpiredCarts will delete the carts that expired before the given time
+// along with their items, returning how many were deleted.
+func (s *CartStore) DeleteExpiredCarts(ctx context.Context, before time.Time) (int64, error) {
+	defer s.db.write(ctx, cartsTable)()
+
+	var n int64
+	for token, c := range s.db.carts {
+		if c.ExpiresAt != nil && !c.ExpiresAt.After(before) {
+			delete(s.db.carts, token)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// live returns the cart with the given token unless it has expired. The caller
+// must hold the read lock.
+func (s *CartStore) live(token string) (carts.Cart, bool) {
+	c, ok := s.db.carts[token]
+	if !ok || (c.ExpiresAt != nil && !c.ExpiresAt.After(time.Now())) {
+		return carts.Cart{}, false
+	}
+	return c, true
+}
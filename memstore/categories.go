@@ -0,0 +1,141 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"bookshop/categories"
+	"bookshop/datastore"
+
+	"github.com/pkg/errors"
+)
+
+// CategoryStore is an in-memory implementation of the category datastore.
+type CategoryStore struct {
+	db *DB
+}
+
+func NewCategoryStore(db *DB) CategoryStore {
+	return CategoryStore{db: db}
+}
+
+// CreateCategory will add the given category, returning datastore.ErrDuplicate
+// if its parent already has a category with the same name and
+// datastore.ErrMissingReference if its parent does not exist.
+func (s *CategoryStore) CreateCategory(ctx context.Context, c categories.Category) error {
+	defer s.db.write(ctx, categoriesTable)()
+
+	if _, ok := s.db.categories[c.ID]; ok {
+		return datastore.ErrDuplicate
+	}
+	if err := s.check(c); err != nil {
+		return err
+	}
+	c.CreatedAt, c.UpdatedAt, c.Children = now(), now(), nil
+	s.db.categories[c.ID] = c
+	return nil
+}
+
+// ReadCategory will return the category with the given ID, without its
+// subcategories, returning datastore.ErrNotFound if it does not exist.
+func (s *CategoryStore) ReadCategory(ctx context.Context, id string) (categories.Category, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	c, ok := s.db.categories[id]
+	if !ok {
+		return categories.Category{}, datastore.ErrNotFound
+	}
+	return c, nil
+}
+
+// ReadCategories will return every category, sorted by name, for arranging
+// into a tree.
+func (s *CategoryStore) ReadCategories(ctx context.Context) ([]categories.Category, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return categoryList(s.db), nil
+}
+
+// UpdateCategory will rename the category and move it under its parent,
+// returning datastore.ErrNotFound if it does not exist along with the errors
+// of CreateCategory.
+func (s *CategoryStore) UpdateCategory(ctx context.Context, c categories.Category) error {
+	defer s.db.write(ctx, categoriesTable)()
+
+	current, ok := s.db.categories[c.ID]
+	if !ok {
+		return datastore.ErrNotFound
+	}
+	if err := s.check(c); err != nil {
+		return err
+	}
+	current.ParentID, current.Name, current.UpdatedAt = c.ParentID, c.Name, now()
+	s.db.categories[c.ID] = current
+	return nil
+}
+
+// DeleteCategory will delete the category and its links to books, returning
+// datastore.ErrNotFound if it does not exist. Categories with subcategories
+// cannot be deleted.
+func (s *CategoryStore) DeleteCategory(ctx context.Context, id string) error {
+	defer s.db.write(ctx, categoriesTable|filingsTable)()
+
+	if _, ok := s.db.categories[id]; !ok {
+		return datastore.ErrNotFound
+	}
+	for _, c := range s.db.categories {
+		if c.ParentID != nil && *c.ParentID == id {
+			return errors.New("failed deleting category: it has subcategories")
+		}
+	}
+	delete(s.db.categories, id)
+	for f := range s.db.filings {
+		if f.categoryID == id {
+			delete(s.db.filings, f)
+		}
+	}
+	return nil
+}
+
+// check applies the constraints of the categories table to the category. The
+// caller must hold the write lock.
+func (s *CategoryStore) check(c categories.Category) error {
+	if c.ParentID != nil {
+		if _, ok := s.db.categories[*c.ParentID]; !ok {
+			return datastore.ErrMissingReference
+		}
+	}
+	for _, other := range s.db.categories {
+		if other.ID != c.ID && sameParent(other.ParentID, c.ParentID) && strings.EqualFold(other.Name, c.Name) {
+			return datastore.ErrDuplicate
+		}
+	}
+	return nil
+}
+
+func sameParent(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// categoryList returns every category in db sorted by name. The caller must
+// hold the read lock.
+func categoryList(db *DB) []categories.Category {
+	cats := make([]categories.Category, 0, len(db.categories))
+	for _, c := range db.categories {
+		cats = append(cats, c)
+	}
+	sort.Slice(cats, func(i, j int) bool {
+		ni, nj := strings.ToLower(cats[i].Name), strings.ToLower(cats[j].Name)
+		if ni != nj {
+			return ni < nj
+		}
+		return cats[i].ID < cats[j].ID
+	})
+	return cats
+}
@@ -0,0 +1,383 @@
+// Package memstore provides in-memory implementations of the bookshop
+// datastores. They follow the same rules as the database backed stores
+// (unique ISBNs, unique author name and date of birth, cascading deletes of
+// book credits and the same sort orders) and are safe for concurrent use.
+package memstore
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bookshop/auth"
+	"bookshop/authors"
+	"bookshop/books"
+	"bookshop/carts"
+	"bookshop/categories"
+	"bookshop/datastore"
+	"bookshop/orders"
+	"bookshop/publishers"
+	"bookshop/reviews"
+	"bookshop/search"
+	"bookshop/stock"
+	"bookshop/users"
+)
+
+// credit credits an author on a book in a role. Credits map to their sequence
+// numbers.
+type credit struct {
+	bookID   string
+	authorID string
+	role     books.Role
+}
+
+// filing files a book under a category.
+type filing struct {
+	bookID     string
+	categoryID string
+}
+
+type bookTag struct {
+	bookID string
+	tag    string
+}
+
+type bookPrice struct {
+	bookID string
+	books.Price
+}
+
+// DB holds the tables shared by the in-memory stores.
+type DB struct {
+	// work is held by each write and for the whole of a unit of work, so units
+	// of work are isolated from other writes. mu guards the tables themselves.
+	work     sync.Mutex
+	mu       sync.RWMutex
+	authors  map[string]authors.Author
+	books    map[string]books.Book
+	credits  map[credit]int
+	prices   []bookPrice
+	carts    map[string]carts.Cart
+	orders   map[string]orders.Order
+	stock    map[string]stock.Level
+	ledger   []stock.Entry
+	users    map[string]users.User
+	sessions map[string]auth.Session
+	apiKeys  map[string]auth.APIKey
+	reviews  map[string]reviews.Review
+
+	categories map[string]categories.Category
+	filings    map[filing]struct{}
+	tags       map[bookTag]struct{}
+	publishers map[string]publishers.Publisher
+
+	// saved holds the tables the running unit of work has copied before first
+	// writing them, and undo the functions putting those copies back.
+	saved table
+	undo  []func()
+}
+
+// NewDB returns an empty DB.
+func NewDB() *DB {
+	return &DB{
+		authors:  map[string]authors.Author{},
+		books:    map[string]books.Book{},
+		credits:  map[credit]int{},
+		carts:    map[string]carts.Cart{},
+		orders:   map[string]orders.Order{},
+		stock:    map[string]stock.Level{},
+		users:    map[string]users.User{},
+		sessions: map[string]auth.Session{},
+		apiKeys:  map[string]auth.APIKey{},
+		reviews:  map[string]reviews.Review{},
+
+		categories: map[string]categories.Category{},
+		filings:    map[filing]struct{}{},
+		tags:       map[bookTag]struct{}{},
+		publishers: map[string]publishers.Publisher{},
+	}
+}
+
+type workKey struct{}
+
+// table names one of a DB's tables. Store writes name the tables they change,
+// including the rows they cascade to, so a unit of work only copies those.
+type table uint
+
+const (
+	authorsTable table = 1 << iota
+	booksTable
+	creditsTable
+	pricesTable
+	cartsTable
+	ordersTable
+	stockTable
+	ledgerTable
+	usersTable
+	sessionsTable
+	apiKeysTable
+	reviewsTable
+	categoriesTable
+	filingsTable
+	tagsTable
+	publishersTable
+)
+
+// write locks the tables for a store write that changes the given tables,
+// returning the function that unlocks them. Writes made by a unit of work
+// already hold db.work, and save the tables they change first.
+func (db *DB) write(ctx context.Context, written table) func() {
+	if ctx.Value(workKey{}) == db {
+		db.mu.Lock()
+		db.save(written)
+		return db.mu.Unlock
+	}
+	db.work.Lock()
+	db.mu.Lock()
+	return func() {
+		db.mu.Unlock()
+		db.work.Unlock()
+	}
+}
+
+// save copies each of the given tables the running unit of work has not yet
+// saved, so it can undo its writes. The caller must hold mu.
+func (db *DB) save(written table) {
+	for t := table(1); t <= written; t <<= 1 {
+		if written&t == 0 || db.saved&t != 0 {
+			continue
+		}
+		db.saved |= t
+		db.undo = append(db.undo, db.copyTable(t))
+	}
+}
+
+// copyTable copies the table, returning the function that puts the copy back.
+// Cart items and order lines are replaced rather than changed in place, so the
+// copies share them safely.
+func (db *DB) copyTable(t table) func() {
+	switch t {
+	case authorsTable:
+		saved := copyMap(db.authors)
+		return func() { db.authors = saved }
+	case booksTable:
+		saved := copyMap(db.books)
+		return func() { db.books = saved }
+	case creditsTable:
+		saved := copyMap(db.credits)
+		return func() { db.credits = saved }
+	case pricesTable:
+		saved := append([]bookPrice(nil), db.prices...)
+		return func() { db.prices = saved }
+	case cartsTable:
+		saved := copyMap(db.carts)
+		return func() { db.carts = saved }
+	case ordersTable:
+		saved := copyMap(db.orders)
+		return func() { db.orders = saved }
+	case stockTable:
+		saved := copyMap(db.stock)
+		return func() { db.stock = saved }
+	case ledgerTable:
+		saved := append([]stock.Entry(nil), db.ledger...)
+		return func() { db.ledger = saved }
+	case usersTable:
+		saved := copyMap(db.users)
+		return func() { db.users = saved }
+	case sessionsTable:
+		saved := copyMap(db.sessions)
+		return func() { db.sessions = saved }
+	case apiKeysTable:
+		saved := copyMap(db.apiKeys)
+		return func() { db.apiKeys = saved }
+	case reviewsTable:
+		saved := copyMap(db.reviews)
+		return func() { db.reviews = saved }
+	case categoriesTable:
+		saved := copyMap(db.categories)
+		return func() { db.categories = saved }
+	case filingsTable:
+		saved := copyMap(db.filings)
+		return func() { db.filings = saved }
+	case tagsTable:
+		saved := copyMap(db.tags)
+		return func() { db.tags = saved }
+	case publishersTable:
+		saved := copyMap(db.publishers)
+		return func() { db.publishers = saved }
+	}
+	panic("memstore: unknown table")
+}
+
+func copyMap[K comparable, V any](m map[K]V) map[K]V {
+	c := make(map[K]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// UnitOfWork runs several store calls as one change to a DB.
+type UnitOfWork struct {
+	db *DB
+}
+
+func NewUnitOfWork(db *DB) UnitOfWork {
+	return UnitOfWork{db: db}
+}
+
+// Do will run fn while holding off other writes, undoing everything it wrote
+// if it returns an error. Each table fn writes is copied before its first
+// write, so a unit of work costs as much as the tables it changes. Reads from
+// outside the unit of work may see its writes before it finishes. A Do nested
+// inside another joins the outer one.
+func (u *UnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(workKey{}) == u.db {
+		return fn(ctx)
+	}
+	u.db.work.Lock()
+	defer u.db.work.Unlock()
+
+	u.db.saved, u.db.undo = 0, nil
+	err := fn(context.WithValue(ctx, workKey{}, u.db))
+	u.db.mu.Lock()
+	if err != nil {
+		for _, undo := range u.db.undo {
+			undo()
+		}
+	}
+	u.db.saved, u.db.undo = 0, nil
+	u.db.mu.Unlock()
+	return err
+}
+
+// now returns the current time truncated to the microsecond precision of a
+// postgres timestamp.
+func now() *time.Time {
+	t := time.Now().UTC().Truncate(time.Microsecond)
+	return &t
+}
+
+// compareStrings and compareTimes return -1, 0 or 1 like strings.Compare.
+func compareStrings(a, b string) int {
+	return strings.Compare(a, b)
+}
+
+func compareTimes(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	case a.Before(*b):
+		return -1
+	case a.After(*b):
+		return 1
+	}
+	return 0
+}
+
+// compareCursor compares a row's sort value and ID against a cursor position.
+// Time values are compared as times since their cursor form is RFC 3339.
+func compareCursor(value string, t *time.Time, id string, c datastore.Cursor, isTime bool) int {
+	cmp := compareStrings(value, c.Value)
+	if isTime {
+		ct, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return 1
+		}
+		cmp = compareTimes(t, &ct)
+	}
+	if cmp != 0 {
+		return cmp
+	}
+	return compareStrings(id, c.ID)
+}
+
+// pastCursor reports whether a row comes after the cursor in the given direction.
+func pastCursor(cmp int, desc bool) bool {
+	if desc {
+		return cmp < 0
+	}
+	return cmp > 0
+}
+
+// matchTerms splits text into words and returns a search result with the query
+// terms found in it highlighted in an HTML snippet, ranked by the share of words
+// that matched.
+// Every term must be present for the text to match, mirroring plainto_tsquery.
+func matchTerms(text, query string) (search.Result, bool) {
+	terms := words(query)
+	if len(terms) == 0 {
+		return search.Result{}, false
+	}
+	found := map[string]bool{}
+	tokens := strings.Fields(text)
+	hits := 0
+	for i, tok := range tokens {
+		w := normalizeWord(tok)
+		for _, term := range terms {
+			if w == term {
+				found[term] = true
+				hits++
+				tokens[i] = search.MatchStart + tok + search.MatchStop
+				break
+			}
+		}
+	}
+	if len(found) != len(uniq(terms)) {
+		return search.Result{}, false
+	}
+	return search.Result{
+		Title:   text,
+		Snippet: search.Snippet(strings.Join(tokens, " ")),
+		Rank:    float64(hits) / float64(len(tokens)),
+	}, true
+}
+
+func words(s string) []string {
+	var out []string
+	for _, f := range strings.Fields(s) {
+		if w := normalizeWord(f); w != "" {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+func normalizeWord(s string) string {
+	return strings.ToLower(strings.TrimFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r > 127)
+	}))
+}
+
+func uniq(ss []string) map[string]bool {
+	m := map[string]bool{}
+	for _, s := range ss {
+		m[s] = true
+	}
+	return m
+}
+
+// sortResults orders search results by rank (descending) and then title.
+func sortResults(results []search.Result, limit int) []search.Result {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].Title < results[j].Title
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// hasPrefixFold reports whether s begins with prefix, ignoring case.
+func hasPrefixFold(s, prefix string) bool {
+	return strings.HasPrefix(strings.ToLower(s), strings.ToLower(prefix))
+}
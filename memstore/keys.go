@@ -0,0 +1,108 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"bookshop/auth"
+	"bookshop/datastore"
+)
+
+// KeyStore is an in-memory implementation of the API key datastore.
+type KeyStore struct {
+	db *DB
+}
+
+func NewKeyStore(db *DB) KeyStore {
+	return KeyStore{db: db}
+}
+
+// CreateAPIKey will add the given API key, returning
+// datastore.ErrMissingReference if its user does not exist.
+func (s *KeyStore) CreateAPIKey(ctx context.Context, k auth.APIKey) error {
+	defer s.db.write(ctx, apiKeysTable)()
+
+	if _, ok := s.db.users[k.UserID]; !ok {
+		return datastore.ErrMissingReference
+	}
+	k.CreatedAt, k.LastUsedAt = now(), nil
+	k.Scopes = append(auth.Scopes{}, k.Scopes...)
+	s.db.apiKeys[k.ID] = k
+	return nil
+}
+
+// ReadAPIKeys will return the API keys of the given user, oldest first.
+func (s *KeyStore) ReadAPIKeys(ctx context.Context, userID string) ([]auth.APIKey, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	keys := []auth.APIKey{}
+	for _, k := range s.db.apiKeys {
+		if k.UserID == userID {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if c := compareTimes(keys[i].CreatedAt, keys[j].CreatedAt); c != 0 {
+			return c < 0
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys, nil
+}
+
+// ReadAPIKeyByHash will return the API key with the given key hash, returning
+// datastore.ErrNotFound if it does not exist.
+func (s *KeyStore) ReadAPIKeyByHash(ctx context.Context, keyHash string) (auth.APIKey, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, k := range s.db.apiKeys {
+		if k.KeyHash == keyHash {
+			return k, nil
+		}
+	}
+	return auth.APIKey{}, datastore.ErrNotFound
+}
+
+// RotateAPIKey will replace the key hash of the given user's API key, returning
+// datastore.ErrNotFound if the user has no such key.
+func (s *KeyStore) RotateAPIKey(ctx context.Context, userID, id, keyHash string) error {
+	defer s.db.write(ctx, apiKeysTable)()
+
+	k, ok := s.db.apiKeys[id]
+	if !ok || k.UserID != userID {
+		return datastore.ErrNotFound
+	}
+	k.KeyHash, k.LastUsedAt = keyHash, nil
+	s.db.apiKeys[id] = k
+	return nil
+}
+
+// DeleteAPIKey will revoke the given user's API key, returning
+// datastore.ErrNotFound if the user has no such key.
+func (s *KeyStore) DeleteAPIKey(ctx context.Context, userID, id string) error {
+	defer s.db.write(ctx, apiKeysTable)()
+
+	if k, ok := s.db.apiKeys[id]; !ok || k.UserID != userID {
+		return datastore.ErrNotFound
+	}
+	delete(s.db.apiKeys, id)
+	return nil
+}
+
+// TouchAPIKey will record the API key as last used at the given time, returning
+// datastore.ErrNotFound if it does not exist.
+func (s *KeyStore) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	defer s.db.write(ctx, apiKeysTable)()
+
+	k, ok := s.db.apiKeys[id]
+	if !ok {
+		return datastore.ErrNotFound
+	}
+	at = at.UTC().Truncate(time.Microsecond)
+	k.LastUsedAt = &at
+	s.db.apiKeys[id] = k
+	return nil
+}
@@ -0,0 +1,991 @@
+package memstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"bookshop/auth"
+	"bookshop/authors"
+	"bookshop/books"
+	"bookshop/carts"
+	"bookshop/categories"
+	"bookshop/datastore"
+	"bookshop/memstore"
+	"bookshop/money"
+	"bookshop/orders"
+	"bookshop/publishers"
+	"bookshop/reviews"
+	"bookshop/search"
+	"bookshop/service"
+	"bookshop/stock"
+	"bookshop/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ service.AuthorDataStore   = &memstore.AuthorStore{}
+	_ service.BookDataStore     = &memstore.BookStore{}
+	_ service.OrderDataStore    = &memstore.OrderStore{}
+	_ service.UserDataStore     = &memstore.UserStore{}
+	_ service.SessionDataStore  = &memstore.SessionStore{}
+	_ service.KeyDataStore      = &memstore.KeyStore{}
+	_ service.ReviewDataStore   = &memstore.ReviewStore{}
+	_ service.CategoryDataStore = &memstore.CategoryStore{}
+)
+
+func TestMemstore(t *testing.T) {
+	// catalogue changes need a signed in admin or member of staff
+	admin := users.User{ID: "usr01", Role: users.RoleAdmin, Permissions: users.RolePermissions[users.RoleAdmin]}
+	ctx := auth.WithUser(context.Background(), admin)
+	dt, err := time.Parse(authors.DateParsingFormat, "1970-01-01")
+	require.NoError(t, err)
+
+	newStores := func(t *testing.T) (*memstore.AuthorStore, *memstore.BookStore) {
+		db := memstore.NewDB()
+		as, bs := memstore.NewAuthorStore(db), memstore.NewBookStore(db)
+
+		err := as.UpsertAuthors(ctx, []authors.Author{
+			{ID: "auth01", FirstName: "first", MiddleName: "middle", LastName: "last", DOB: &dt},
+			{ID: "auth02", FirstName: "Frank", LastName: "Herbert", DOB: &dt},
+		})
+		require.NoError(t, err)
+		err = bs.UpsertBooks(ctx, []books.Book{
+			{ID: "abc01", Title: "titleA", ISBN: "9783161484100"},
+			{ID: "def02", Title: "Dune Messiah", ISBN: "9780306406157"},
+			{ID: "ghi03", Title: "Dune", ISBN: "9781861972712"},
+		})
+		require.NoError(t, err)
+		require.NoError(t, bs.LinkBookAuthors(ctx, "abc01", books.Credit{AuthorID: "auth01", Role: books.RoleAuthor, Sequence: 1}))
+		require.NoError(t, bs.LinkBookAuthors(ctx, "def02", books.Credit{AuthorID: "auth02", Role: books.RoleAuthor, Sequence: 1}))
+		require.NoError(t, bs.LinkBookAuthors(ctx, "ghi03",
+			books.Credit{AuthorID: "auth02", Role: books.RoleAuthor, Sequence: 1},
+			books.Credit{AuthorID: "auth02", Role: books.RoleEditor, Sequence: 2},
+		))
+		return &as, &bs
+	}
+
+	t.Run("authors", func(t *testing.T) {
+		t.Run("ReadAuthorAndBooks", func(t *testing.T) {
+			as, _ := newStores(t)
+
+			auth, err := as.ReadAuthorAndBooks(ctx, "auth02")
+			require.NoError(t, err)
+			assert.Equal(t, "Herbert", auth.LastName)
+			require.Len(t, auth.Books, 2)
+			assert.Equal(t, "Dune", auth.Books[0].Title)
+			assert.Equal(t, "Dune Messiah", auth.Books[1].Title)
+			require.Len(t, auth.BooksByRole[books.RoleAuthor], 2)
+			require.Len(t, auth.BooksByRole[books.RoleEditor], 1)
+			assert.Equal(t, "Dune", auth.BooksByRole[books.RoleEditor][0].Title)
+
+			_, err = as.ReadAuthorAndBooks(ctx, "unknown")
+			assert.True(t, errors.Is(err, datastore.ErrNotFound))
+		})
+
+		t.Run("UpsertAuthors", func(t *testing.T) {
+			as, _ := newStores(t)
+
+			err := as.UpsertAuthors(ctx, []authors.Author{
+				{ID: "auth01", FirstName: "update", LastName: "update", DOB: &dt},
+			})
+			require.NoError(t, err)
+			auth, err := as.ReadAuthorAndBooks(ctx, "auth01")
+			require.NoError(t, err)
+			assert.Equal(t, "update", auth.FirstName)
+			assert.NotNil(t, auth.UpdatedAt)
+
+			// same name and date of birth as auth02
+			err = as.UpsertAuthors(ctx, []authors.Author{
+				{ID: "auth03", FirstName: "new", LastName: "new", DOB: &dt},
+				{ID: "auth04", FirstName: "Frank", LastName: "Herbert", DOB: &dt},
+			})
+			assert.True(t, errors.Is(err, datastore.ErrDuplicate))
+
+			// nothing from the failed batch was written
+			page, err := as.ReadAuthors(ctx, authors.AuthorQuery{})
+			require.NoError(t, err)
+			assert.Len(t, page.Authors, 2)
+		})
+
+		t.Run("UpdateAuthor", func(t *testing.T) {
+			as, _ := newStores(t)
+
+			err := as.UpdateAuthor(ctx, authors.Author{ID: "auth01", FirstName: "update", LastName: "update", DOB: &dt})
+			require.NoError(t, err)
+			auth, err := as.ReadAuthorAndBooks(ctx, "auth01")
+			require.NoError(t, err)
+			assert.Equal(t, "update", auth.FirstName)
+
+			// updates never create authors
+			err = as.UpdateAuthor(ctx, authors.Author{ID: "unknown", FirstName: "new", LastName: "new", DOB: &dt})
+			assert.True(t, errors.Is(err, datastore.ErrNotFound))
+
+			// same name and date of birth as auth02
+			err = as.UpdateAuthor(ctx, authors.Author{ID: "auth01", FirstName: "Frank", LastName: "Herbert", DOB: &dt})
+			assert.True(t, errors.Is(err, datastore.ErrDuplicate))
+		})
+
+		t.Run("ReadAuthors", func(t *testing.T) {
+			as, _ := newStores(t)
+
+			page, err := as.ReadAuthors(ctx, authors.AuthorQuery{Limit: 1})
+			require.NoError(t, err)
+			require.Len(t, page.Authors, 1)
+			assert.Equal(t, "Herbert", page.Authors[0].LastName)
+			require.NotEmpty(t, page.NextCursor)
+
+			page, err = as.ReadAuthors(ctx, authors.AuthorQuery{Limit: 1, Cursor: page.NextCursor})
+			require.NoError(t, err)
+			require.Len(t, page.Authors, 1)
+			assert.Equal(t, "last", page.Authors[0].LastName)
+			assert.Empty(t, page.NextCursor)
+
+			page, err = as.ReadAuthors(ctx, authors.AuthorQuery{LastNamePrefix: "HER"})
+			require.NoError(t, err)
+			require.Len(t, page.Authors, 1)
+			assert.Equal(t, "auth02", page.Authors[0].ID)
+
+			later := dt.AddDate(0, 0, 1)
+			page, err = as.ReadAuthors(ctx, authors.AuthorQuery{DOBFrom: &later})
+			require.NoError(t, err)
+			assert.Empty(t, page.Authors)
+
+			page, err = as.ReadAuthors(ctx, authors.AuthorQuery{SortBy: authors.SortByFirstName, Descending: true})
+			require.NoError(t, err)
+			require.Len(t, page.Authors, 2)
+			assert.Equal(t, "first", page.Authors[0].FirstName)
+		})
+
+		t.Run("DeleteAuthor", func(t *testing.T) {
+			as, bs := newStores(t)
+
+			err := as.DeleteAuthor(ctx, "auth02")
+			require.NoError(t, err)
+
+			bk, err := bs.ReadBookByID(ctx, "ghi03")
+			require.NoError(t, err)
+			assert.Empty(t, bk.Authors)
+
+			err = as.DeleteAuthor(ctx, "auth02")
+			assert.True(t, errors.Is(err, datastore.ErrNotFound))
+		})
+
+		t.Run("SearchAuthors", func(t *testing.T) {
+			as, _ := newStores(t)
+
+			results, err := as.SearchAuthors(ctx, "herbert", 10)
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			assert.Equal(t, search.KindAuthor, results[0].Kind)
+			assert.Equal(t, "Frank Herbert", results[0].Title)
+			assert.Equal(t, "Frank <mark>Herbert</mark>", results[0].Snippet)
+		})
+	})
+
+	t.Run("books", func(t *testing.T) {
+		t.Run("ReadBookByID", func(t *testing.T) {
+			_, bs := newStores(t)
+
+			bk, err := bs.ReadBookByID(ctx, "abc01")
+			require.NoError(t, err)
+			assert.Equal(t, "titleA", bk.Title)
+			require.Len(t, bk.Authors, 1)
+			assert.Equal(t, "auth01", bk.Authors[0].ID)
+
+			_, err = bs.ReadBookByID(ctx, "unknown")
+			assert.True(t, errors.Is(err, datastore.ErrNotFound))
+		})
+
+		t.Run("ReadBookByISBN", func(t *testing.T) {
+			_, bs := newStores(t)
+
+			bk, err := bs.ReadBookByISBN(ctx, "9783161484100")
+			require.NoError(t, err)
+			assert.Equal(t, "abc01", bk.ID)
+
+			_, err = bs.ReadBookByISBN(ctx, "9791032300824")
+			assert.True(t, errors.Is(err, datastore.ErrNotFound))
+		})
+
+		t.Run("UpsertBooks", func(t *testing.T) {
+			_, bs := newStores(t)
+
+			err := bs.UpsertBooks(ctx, []books.Book{
+				{ID: "zzz00", Title: "titleZ", ISBN: "9783161484100"},
+			})
+			assert.True(t, errors.Is(err, datastore.ErrDuplicate))
+
+			// moving an ISBN between books in the same batch is allowed
+			err = bs.UpsertBooks(ctx, []books.Book{
+				{ID: "abc01", Title: "titleA", ISBN: "9791032300824"},
+				{ID: "zzz00", Title: "titleZ", ISBN: "9783161484100"},
+			})
+			require.NoError(t, err)
+
+			dims := &books.Dimensions{Height: 216, Width: 140, Depth: 38}
+			err = bs.UpsertBooks(ctx, []books.Book{
+				{ID: "zzz00", Title: "titleZ", ISBN: "9783161484100", Details: books.Details{Format: books.FormatEbook, Dimensions: dims}},
+			})
+			require.NoError(t, err)
+			dims.Height = 1
+			bk, err := bs.ReadBookByID(ctx, "zzz00")
+			require.NoError(t, err)
+			assert.Equal(t, books.FormatEbook, bk.Format)
+			assert.Equal(t, &books.Dimensions{Height: 216, Width: 140, Depth: 38}, bk.Dimensions)
+		})
+
+		t.Run("ReadBooks", func(t *testing.T) {
+			_, bs := newStores(t)
+
+			page, err := bs.ReadBooks(ctx, books.BookQuery{Limit: 2})
+			require.NoError(t, err)
+			require.Len(t, page.Books, 2)
+			assert.Equal(t, "Dune", page.Books[0].Title)
+			assert.Equal(t, "Dune Messiah", page.Books[1].Title)
+
+			page, err = bs.ReadBooks(ctx, books.BookQuery{Limit: 2, Cursor: page.NextCursor})
+			require.NoError(t, err)
+			require.Len(t, page.Books, 1)
+			assert.Equal(t, "titleA", page.Books[0].Title)
+			assert.Empty(t, page.NextCursor)
+
+			page, err = bs.ReadBooks(ctx, books.BookQuery{AuthorLastName: "herbert", SortBy: books.SortByISBN, Descending: true})
+			require.NoError(t, err)
+			require.Len(t, page.Books, 2)
+			assert.Equal(t, "ghi03", page.Books[0].ID)
+
+			page, err = bs.ReadBooks(ctx, books.BookQuery{ISBNPrefix: "978-3"})
+			require.NoError(t, err)
+			require.Len(t, page.Books, 1)
+			assert.Equal(t, "abc01", page.Books[0].ID)
+
+			first, err := bs.ReadBooks(ctx, books.BookQuery{SortBy: books.SortByUpdatedAt, Limit: 1})
+			require.NoError(t, err)
+			rest, err := bs.ReadBooks(ctx, books.BookQuery{SortBy: books.SortByUpdatedAt, Cursor: first.NextCursor})
+			require.NoError(t, err)
+			assert.Len(t, rest.Books, 2)
+		})
+
+		t.Run("LinkBookAuthors", func(t *testing.T) {
+			_, bs := newStores(t)
+
+			// relinking a credit moves it
+			err := bs.LinkBookAuthors(ctx, "abc01",
+				books.Credit{AuthorID: "auth01", Role: books.RoleAuthor, Sequence: 2},
+				books.Credit{AuthorID: "auth02", Role: books.RoleAuthor, Sequence: 1},
+				books.Credit{AuthorID: "auth02", Role: books.RoleTranslator, Sequence: 3},
+			)
+			require.NoError(t, err)
+			bk, err := bs.ReadBookByID(ctx, "abc01")
+			require.NoError(t, err)
+			require.Len(t, bk.Authors, 3)
+			assert.Equal(t, books.BookAuthor{ID: "auth02", FirstName: "Frank", LastName: "Herbert", Role: books.RoleAuthor, Sequence: 1}, bk.Authors[0])
+			assert.Equal(t, "auth01", bk.Authors[1].ID)
+			assert.Equal(t, books.RoleTranslator, bk.Authors[2].Role)
+
+			err = bs.LinkBookAuthors(ctx, "abc01", books.Credit{AuthorID: "unknown", Role: books.RoleAuthor, Sequence: 1})
+			assert.True(t, errors.Is(err, datastore.ErrMissingReference))
+			err = bs.LinkBookAuthors(ctx, "unknown", books.Credit{AuthorID: "auth01", Role: books.RoleAuthor, Sequence: 1})
+			assert.True(t, errors.Is(err, datastore.ErrMissingReference))
+
+			err = bs.UnlinkBookAuthor(ctx, "abc01", "auth02", books.RoleTranslator)
+			require.NoError(t, err)
+			err = bs.UnlinkBookAuthor(ctx, "abc01", "auth02", books.RoleTranslator)
+			assert.True(t, errors.Is(err, datastore.ErrNotFound))
+			err = bs.UnlinkBookAuthor(ctx, "abc01", "auth02", "")
+			require.NoError(t, err)
+			err = bs.UnlinkBookAuthor(ctx, "abc01", "auth02", "")
+			assert.True(t, errors.Is(err, datastore.ErrNotFound))
+		})
+
+		t.Run("DeleteBooks", func(t *testing.T) {
+			as, bs := newStores(t)
+
+			err := bs.DeleteBooks(ctx, "def02", "unknown")
+			require.NoError(t, err)
+
+			auth, err := as.ReadAuthorAndBooks(ctx, "auth02")
+			require.NoError(t, err)
+			require.Len(t, auth.Books, 1)
+			assert.Equal(t, "Dune", auth.Books[0].Title)
+
+			err = bs.DeleteBooks(ctx, "def02")
+			assert.True(t, errors.Is(err, datastore.ErrNotFound))
+		})
+
+		t.Run("prices", func(t *testing.T) {
+			_, bs := newStores(t)
+			now := time.Now()
+			require.NoError(t, bs.AddBookPrice(ctx, "ghi03", books.Price{ListPrice: money.New(1299, money.GBP), EffectiveFrom: now.Add(-time.Hour)}))
+			require.NoError(t, bs.AddBookPrice(ctx, "ghi03", books.Price{ListPrice: money.New(1499, money.GBP), EffectiveFrom: now.Add(time.Hour)}))
+			err := bs.AddBookPrice(ctx, "unknown", books.Price{ListPrice: money.New(1, money.GBP), EffectiveFrom: now})
+			assert.True(t, errors.Is(err, datastore.ErrMissingReference))
+
+			bk, err := bs.ReadBookByID(ctx, "ghi03")
+			require.NoError(t, err)
+			require.NotNil(t, bk.ListPrice)
+			assert.Equal(t, money.New(1299, money.GBP), *bk.ListPrice)
+
+			prices, err := bs.ReadBookPrices(ctx, "ghi03")
+			require.NoError(t, err)
+			assert.Len(t, prices, 2)
+
+			require.NoError(t, bs.DeleteBooks(ctx, "ghi03"))
+			_, err = bs.ReadBookPrices(ctx, "ghi03")
+			assert.True(t, errors.Is(err, datastore.ErrNotFound))
+		})
+
+		t.Run("SearchBooks", func(t *testing.T) {
+			_, bs := newStores(t)
+
+			results, err := bs.SearchBooks(ctx, "DUNE", 10)
+			require.NoError(t, err)
+			require.Len(t, results, 2)
+			assert.Equal(t, "ghi03", results[0].ID)
+			assert.Equal(t, "<mark>Dune</mark>", results[0].Snippet)
+			assert.Equal(t, "<mark>Dune</mark> Messiah", results[1].Snippet)
+
+			results, err = bs.SearchBooks(ctx, "dune messiah", 10)
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+
+			// markup in titles is escaped in snippets
+			err = bs.UpsertBooks(ctx, []books.Book{{ID: "abc01", Title: `Sandworms & <script>alert("spice")</script>`, ISBN: "9783161484100"}})
+			require.NoError(t, err)
+			results, err = bs.SearchBooks(ctx, "sandworms", 10)
+			require.NoError(t, err)
+			require.Len(t, results, 1)
+			assert.Equal(t, `<mark>Sandworms</mark> &amp; &lt;script&gt;alert(&#34;spice&#34;)&lt;/script&gt;`, results[0].Snippet)
+
+			results, err = bs.SearchBooks(ctx, "dune", 1)
+			require.NoError(t, err)
+			assert.Len(t, results, 1)
+		})
+	})
+
+	t.Run("stock", func(t *testing.T) {
+		t.Run("ApplyMovement", func(t *testing.T) {
+			db := memstore.NewDB()
+			bs, ss := memstore.NewBookStore(db), memstore.NewStockStore(db)
+			err := bs.UpsertBooks(ctx, []books.Book{{ID: "abc01", Title: "titleA", ISBN: "9783161484100"}})
+			require.NoError(t, err)
+
+			lvl, err := ss.ApplyMovement(ctx, stock.Movement{BookID: "abc01", Kind: stock.Receive, Quantity: 2, Reason: "delivery"})
+			require.NoError(t, err)
+			assert.Equal(t, 2, lvl.OnHand)
+
+			_, err = ss.ApplyMovement(ctx, stock.Movement{BookID: "abc01", Kind: stock.Reserve, Quantity: 3, Reason: "order"})
+			assert.True(t, errors.Is(err, datastore.ErrCheckViolation))
+			_, err = ss.ApplyMovement(ctx, stock.Movement{BookID: "unknown", Kind: stock.Receive, Quantity: 1, Reason: "delivery"})
+			assert.True(t, errors.Is(err, datastore.ErrMissingReference))
+
+			lvl, err = ss.ReadStock(ctx, "abc01")
+			require.NoError(t, err)
+			assert.Equal(t, 2, lvl.Available())
+			require.Len(t, lvl.Ledger, 1)
+			assert.Equal(t, "delivery", lvl.Ledger[0].Reason)
+
+			page, err := bs.ReadBooks(ctx, books.BookQuery{InStock: true})
+			require.NoError(t, err)
+			assert.Len(t, page.Books, 1)
+
+			require.NoError(t, bs.DeleteBooks(ctx, "abc01"))
+			_, err = ss.ReadStock(ctx, "abc01")
+			assert.True(t, errors.Is(err, datastore.ErrNotFound))
+		})
+
+		t.Run("ReadBooks in stock", func(t *testing.T) {
+			_, bs := newStores(t)
+			page, err := bs.ReadBooks(ctx, books.BookQuery{InStock: true})
+			require.NoError(t, err)
+			assert.Empty(t, page.Books)
+		})
+	})
+
+	t.Run("carts", func(t *testing.T) {
+		newService := func(t *testing.T) service.Service {
+			db := memstore.NewDB()
+			as, bs, cs, uow := memstore.NewAuthorStore(db), memstore.NewBookStore(db), memstore.NewCartStore(db), memstore.NewUnitOfWork(db)
+			err := bs.UpsertBooks(ctx, []books.Book{
+				{ID: "abc01", Title: "titleA", ISBN: "9783161484100"},
+				{ID: "ghi03", Title: "Dune", ISBN: "9781861972712"},
+			})
+			require.NoError(t, err)
+			for _, id := range []string{"abc01", "ghi03"} {
+				err := bs.AddBookPrice(ctx, id, books.Price{ListPrice: money.New(1000, money.USD), EffectiveFrom: time.Now().Add(-time.Hour)})
+				require.NoError(t, err)
+			}
+			return service.NewService(service.Stores{Authors: &as, Books: &bs, Carts: &cs, Work: &uow})
+		}
+
+		t.Run("items and totals", func(t *testing.T) {
+			svc := newService(t)
+			c, err := svc.CreateCart(ctx)
+			require.NoError(t, err)
+
+			_, err = svc.AddCartItem(ctx, c.Token, "ghi03", 2)
+			require.NoError(t, err)
+			c, err = svc.AddCartItem(ctx, c.Token, "ghi03", 1)
+			require.NoError(t, err)
+			require.Len(t, c.Items, 1)
+			assert.Equal(t, 3, c.Items[0].Quantity)
+			assert.Equal(t, []money.Money{money.New(3000, money.USD)}, c.Totals)
+
+			_, err = svc.SetCartItem(ctx, c.Token, "ghi03", carts.MaxQuantity+1)
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+			_, err = svc.AddCartItem(ctx, c.Token, "ghi03", carts.MaxQuantity)
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+
+			c, err = svc.RemoveCartItem(ctx, c.Token, "ghi03")
+			require.NoError(t, err)
+			assert.Empty(t, c.Items)
+			assert.Empty(t, c.Totals)
+		})
+
+		t.Run("merge", func(t *testing.T) {
+			svc := newService(t)
+			a, err := svc.CreateCart(ctx)
+			require.NoError(t, err)
+			b, err := svc.CreateCart(ctx)
+			require.NoError(t, err)
+			_, err = svc.AddCartItem(ctx, a.Token, "ghi03", 1)
+			require.NoError(t, err)
+			_, err = svc.AddCartItem(ctx, b.Token, "ghi03", 2)
+			require.NoError(t, err)
+			_, err = svc.AddCartItem(ctx, b.Token, "abc01", 1)
+			require.NoError(t, err)
+
+			merged, err := svc.MergeCarts(ctx, a.Token, b.Token)
+			require.NoError(t, err)
+			require.Len(t, merged.Items, 2)
+			assert.Equal(t, []money.Money{money.New(4000, money.USD)}, merged.Totals)
+
+			_, err = svc.GetCart(ctx, b.Token)
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+
+		t.Run("expiry", func(t *testing.T) {
+			db := memstore.NewDB()
+			cs := memstore.NewCartStore(db)
+			expired := time.Now().Add(-time.Minute)
+			require.NoError(t, cs.CreateCart(ctx, carts.Cart{Token: "tok01", ExpiresAt: &expired}))
+
+			_, err := cs.ReadCart(ctx, "tok01")
+			assert.True(t, errors.Is(err, datastore.ErrNotFound))
+			n, err := cs.DeleteExpiredCarts(ctx, time.Now())
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), n)
+		})
+	})
+
+	t.Run("orders", func(t *testing.T) {
+		newService := func(t *testing.T) (service.Service, *memstore.BookStore) {
+			db := memstore.NewDB()
+			bs, cs, ords, uow := memstore.NewBookStore(db), memstore.NewCartStore(db), memstore.NewOrderStore(db), memstore.NewUnitOfWork(db)
+			us := memstore.NewUserStore(db)
+			for _, id := range []string{admin.ID, "usr02"} {
+				require.NoError(t, us.CreateUser(ctx, users.User{ID: id, Email: id + "@example.com", Enabled: true}))
+			}
+			err := bs.UpsertBooks(ctx, []books.Book{
+				{ID: "abc01", Title: "titleA", ISBN: "9783161484100"},
+				{ID: "ghi03", Title: "Dune", ISBN: "9781861972712"},
+			})
+			require.NoError(t, err)
+			err = bs.AddBookPrice(ctx, "ghi03", books.Price{ListPrice: money.New(1000, money.USD), EffectiveFrom: time.Now().Add(-time.Hour)})
+			require.NoError(t, err)
+			return service.NewService(service.Stores{Books: &bs, Carts: &cs, Orders: &ords, Work: &uow}), &bs
+		}
+
+		t.Run("place and change", func(t *testing.T) {
+			svc, bs := newService(t)
+			o, err := svc.PlaceOrder(ctx, []orders.Line{{BookID: "ghi03", Quantity: 2}})
+			require.NoError(t, err)
+			assert.Equal(t, []money.Money{money.New(2000, money.USD)}, o.Totals)
+
+			// the order keeps what was agreed when the book changes or goes
+			err = svc.SetBookPrice(ctx, "ghi03", books.Price{ListPrice: money.New(5000, money.USD)})
+			require.NoError(t, err)
+			require.NoError(t, bs.DeleteBooks(ctx, "ghi03"))
+			got, err := svc.GetOrder(ctx, o.ID)
+			require.NoError(t, err)
+			assert.Equal(t, "Dune", got.Lines[0].Title)
+			assert.Equal(t, money.New(1000, money.USD), got.Lines[0].UnitPrice)
+
+			_, err = svc.UpdateOrderStatus(ctx, o.ID, orders.Paid)
+			require.NoError(t, err)
+			_, err = svc.CancelOrder(ctx, o.ID)
+			assert.True(t, errors.Is(err, service.ErrIllegalTransition))
+
+			page, err := svc.ListOrders(ctx, orders.OrderQuery{Status: orders.Paid})
+			require.NoError(t, err)
+			require.Len(t, page.Orders, 1)
+			assert.Equal(t, o.ID, page.Orders[0].ID)
+		})
+
+		t.Run("owners", func(t *testing.T) {
+			svc, _ := newService(t)
+			customer := auth.WithUser(context.Background(), users.User{ID: "usr02", Role: users.RoleCustomer})
+			o, err := svc.PlaceOrder(ctx, []orders.Line{{BookID: "ghi03", Quantity: 1}})
+			require.NoError(t, err)
+			assert.Equal(t, admin.ID, o.UserID)
+
+			_, err = svc.GetOrder(customer, o.ID)
+			assert.True(t, errors.Is(err, service.ErrForbidden))
+			_, err = svc.CancelOrder(customer, o.ID)
+			assert.True(t, errors.Is(err, service.ErrForbidden))
+			page, err := svc.ListOrders(customer, orders.OrderQuery{})
+			require.NoError(t, err)
+			assert.Empty(t, page.Orders, "customers only see their own orders")
+
+			mine, err := svc.PlaceOrder(customer, []orders.Line{{BookID: "ghi03", Quantity: 1}})
+			require.NoError(t, err)
+			page, err = svc.ListOrders(customer, orders.OrderQuery{})
+			require.NoError(t, err)
+			require.Len(t, page.Orders, 1)
+			assert.Equal(t, mine.ID, page.Orders[0].ID)
+			_, err = svc.UpdateOrderStatus(customer, mine.ID, orders.Paid)
+			assert.True(t, errors.Is(err, service.ErrForbidden))
+			_, err = svc.CancelOrder(customer, mine.ID)
+			assert.NoError(t, err)
+		})
+
+		t.Run("not for sale", func(t *testing.T) {
+			svc, _ := newService(t)
+			_, err := svc.PlaceOrder(ctx, []orders.Line{{BookID: "ghi03", Quantity: 1}, {BookID: "abc01", Quantity: 1}})
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+
+			page, err := svc.ListOrders(ctx, orders.OrderQuery{})
+			require.NoError(t, err)
+			assert.Empty(t, page.Orders)
+		})
+
+		t.Run("checkout", func(t *testing.T) {
+			svc, _ := newService(t)
+			c, err := svc.CreateCart(ctx)
+			require.NoError(t, err)
+			_, err = svc.AddCartItem(ctx, c.Token, "ghi03", 3)
+			require.NoError(t, err)
+
+			o, err := svc.CheckoutCart(ctx, c.Token)
+			require.NoError(t, err)
+			require.Len(t, o.Lines, 1)
+			assert.Equal(t, 3, o.Lines[0].Quantity)
+
+			_, err = svc.GetCart(ctx, c.Token)
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+
+		t.Run("paging", func(t *testing.T) {
+			db := memstore.NewDB()
+			ords := memstore.NewOrderStore(db)
+			line := orders.Line{BookID: "ghi03", Quantity: 1, UnitPrice: money.New(1000, money.USD)}
+			for _, id := range []string{"ord01", "ord02", "ord03"} {
+				require.NoError(t, ords.CreateOrder(ctx, orders.Order{ID: id, Status: orders.Pending, Lines: []orders.Line{line}}))
+			}
+
+			var ids []string
+			q := orders.OrderQuery{Limit: 2}
+			for {
+				page, err := ords.ReadOrders(ctx, q)
+				require.NoError(t, err)
+				for _, o := range page.Orders {
+					ids = append(ids, o.ID)
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				q.Cursor = page.NextCursor
+			}
+			assert.Equal(t, []string{"ord03", "ord02", "ord01"}, ids)
+		})
+	})
+
+	t.Run("users", func(t *testing.T) {
+		db := memstore.NewDB()
+		us, ss, uow := memstore.NewUserStore(db), memstore.NewSessionStore(db), memstore.NewUnitOfWork(db)
+		svc := service.NewService(service.Stores{Users: &us, Sessions: &ss, Work: &uow})
+
+		ann, err := svc.RegisterUser(ctx, users.User{Email: "Ann@example.com", FirstName: "Ann", LastName: "Lee"}, "correct horse")
+		require.NoError(t, err)
+		bob, err := svc.RegisterUser(ctx, users.User{Email: "bob@example.com", FirstName: "Bob", LastName: "Ray"}, "correct horse")
+		require.NoError(t, err)
+		_, err = svc.RegisterUser(ctx, users.User{Email: "ANN@example.com", FirstName: "Ann", LastName: "Other"}, "correct horse")
+		assert.True(t, errors.Is(err, service.ErrDuplicate))
+
+		_, err = svc.UpdateUser(ctx, users.User{ID: bob.ID, Email: "ann@example.com", FirstName: "Bob", LastName: "Ray"})
+		assert.True(t, errors.Is(err, service.ErrDuplicate))
+
+		require.NoError(t, svc.ChangePassword(ctx, ann.ID, "correct horse", "battery staple"))
+		require.NoError(t, svc.SetUserEnabled(ctx, ann.ID, false))
+		got, err := us.ReadUserByEmail(ctx, "ann@example.com")
+		require.NoError(t, err)
+		assert.True(t, got.CheckPassword("battery staple"))
+		assert.False(t, got.Enabled)
+	})
+
+	t.Run("roles", func(t *testing.T) {
+		db := memstore.NewDB()
+		us, ss, bs, uow := memstore.NewUserStore(db), memstore.NewSessionStore(db), memstore.NewBookStore(db), memstore.NewUnitOfWork(db)
+		svc := service.NewService(service.Stores{Books: &bs, Users: &us, Sessions: &ss, Work: &uow})
+		ann, err := svc.RegisterUser(ctx, users.User{Email: "ann@example.com", FirstName: "Ann", LastName: "Lee"}, "correct horse")
+		require.NoError(t, err)
+		assert.Equal(t, users.RoleCustomer, ann.Role)
+		creds, err := svc.Login(ctx, "ann@example.com", "correct horse")
+		require.NoError(t, err)
+
+		signedIn := func() context.Context {
+			u, err := svc.Authenticate(ctx, creds.Token)
+			require.NoError(t, err)
+			return auth.WithUser(context.Background(), u)
+		}
+		_, err = svc.AddBook(signedIn(), "titleA", "9783161484100", books.Details{}, nil)
+		assert.True(t, errors.Is(err, service.ErrForbidden))
+
+		require.NoError(t, svc.SetUserRole(ctx, ann.ID, users.RoleStaff))
+		_, err = svc.AddBook(signedIn(), "titleA", "9783161484100", books.Details{}, nil)
+		assert.NoError(t, err, "the new role applies from the next request")
+	})
+
+	t.Run("api keys", func(t *testing.T) {
+		db := memstore.NewDB()
+		us, ks, uow := memstore.NewUserStore(db), memstore.NewKeyStore(db), memstore.NewUnitOfWork(db)
+		svc := service.NewService(service.Stores{Keys: &ks, Users: &us, Work: &uow})
+		ann, err := svc.RegisterUser(ctx, users.User{Email: "ann@example.com", FirstName: "Ann", LastName: "Lee"}, "correct horse")
+		require.NoError(t, err)
+
+		issued, err := svc.CreateAPIKey(ctx, ann.ID, "warehouse", auth.Scopes{"ReceiveStock"})
+		require.NoError(t, err)
+		u, k, err := svc.AuthenticateKey(context.Background(), issued.Key)
+		require.NoError(t, err)
+		assert.Equal(t, ann.ID, u.ID)
+		assert.NotNil(t, k.LastUsedAt)
+		keys, err := svc.ListAPIKeys(ctx, ann.ID)
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		assert.NotNil(t, keys[0].LastUsedAt, "the last use is recorded")
+
+		rotated, err := svc.RotateAPIKey(ctx, ann.ID, issued.ID)
+		require.NoError(t, err)
+		_, _, err = svc.AuthenticateKey(context.Background(), issued.Key)
+		assert.True(t, errors.Is(err, service.ErrUnauthenticated))
+		require.NoError(t, svc.RevokeAPIKey(ctx, ann.ID, rotated.ID))
+		_, _, err = svc.AuthenticateKey(context.Background(), rotated.Key)
+		assert.True(t, errors.Is(err, service.ErrUnauthenticated))
+	})
+
+	t.Run("categories", func(t *testing.T) {
+		db := memstore.NewDB()
+		bs, cs, uow := memstore.NewBookStore(db), memstore.NewCategoryStore(db), memstore.NewUnitOfWork(db)
+		svc := service.NewService(service.Stores{Books: &bs, Categories: &cs, Work: &uow})
+		require.NoError(t, bs.UpsertBooks(ctx, []books.Book{
+			{ID: "abc01", Title: "Dune", ISBN: "9781861972712"},
+			{ID: "def02", Title: "Emma", ISBN: "9780141439587"},
+		}))
+
+		fiction, err := svc.AddCategory(ctx, categories.Category{Name: "Fiction"})
+		require.NoError(t, err)
+		scifi, err := svc.AddCategory(ctx, categories.Category{Name: "Science Fiction", ParentID: &fiction.ID})
+		require.NoError(t, err)
+		_, err = svc.AddCategory(ctx, categories.Category{Name: "science fiction", ParentID: &fiction.ID})
+		assert.True(t, errors.Is(err, service.ErrDuplicate))
+		_, err = svc.UpdateCategory(ctx, categories.Category{ID: fiction.ID, Name: "Fiction", ParentID: &scifi.ID})
+		assert.True(t, errors.Is(err, service.ErrInvalid), "a category cannot be moved beneath itself")
+
+		require.NoError(t, svc.AddBookCategory(ctx, "abc01", scifi.ID))
+		require.NoError(t, svc.AddBookCategory(ctx, "def02", fiction.ID))
+		assert.True(t, errors.Is(svc.AddBookCategory(ctx, "abc01", "unknown"), service.ErrInvalidReference))
+		require.NoError(t, svc.TagBook(ctx, "abc01", "Space"))
+
+		page, err := svc.ListBooks(ctx, books.BookQuery{Category: fiction.ID})
+		require.NoError(t, err)
+		assert.Len(t, page.Books, 2, "fiction includes science fiction")
+		page, err = svc.ListBooks(ctx, books.BookQuery{Category: scifi.ID, Tag: "space"})
+		require.NoError(t, err)
+		require.Len(t, page.Books, 1)
+		assert.Equal(t, "abc01", page.Books[0].ID)
+
+		bk, err := svc.GetBook(ctx, "abc01")
+		require.NoError(t, err)
+		assert.Equal(t, []books.BookCategory{{ID: scifi.ID, Name: "Science Fiction"}}, bk.Categories)
+		assert.Equal(t, []string{"space"}, bk.Tags)
+
+		assert.True(t, errors.Is(svc.RemoveCategory(ctx, fiction.ID), service.ErrInvalid), "fiction has subcategories")
+		require.NoError(t, svc.RemoveCategory(ctx, scifi.ID))
+		bk, err = svc.GetBook(ctx, "abc01")
+		require.NoError(t, err)
+		assert.Empty(t, bk.Categories)
+	})
+
+	t.Run("publishers", func(t *testing.T) {
+		db := memstore.NewDB()
+		bs, ps, uow := memstore.NewBookStore(db), memstore.NewPublisherStore(db), memstore.NewUnitOfWork(db)
+		svc := service.NewService(service.Stores{Books: &bs, Publishers: &ps, Work: &uow})
+
+		chilton, err := svc.AddPublisher(ctx, publishers.Publisher{Name: "Chilton Books"})
+		require.NoError(t, err)
+		_, err = svc.AddPublisher(ctx, publishers.Publisher{Name: "CHILTON BOOKS"})
+		assert.True(t, errors.Is(err, service.ErrDuplicate))
+		ace, err := svc.AddPublisher(ctx, publishers.Publisher{Name: "Ace Books"})
+		require.NoError(t, err)
+
+		dune, err := svc.AddBook(ctx, "Dune", "9781861972712", books.Details{PublisherID: chilton.ID, Subtitle: "A Novel"}, nil)
+		require.NoError(t, err)
+		title := "Dune Messiah"
+		require.NoError(t, svc.UpdateBook(ctx, dune.ID, books.Patch{Title: &title}))
+		bk, err := svc.GetBook(ctx, dune.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Dune Messiah", bk.Title)
+		assert.Equal(t, "A Novel", bk.Subtitle, "details left out of a patch are kept")
+		assert.Equal(t, chilton.ID, bk.PublisherID, "a publisher left out of a patch is kept")
+		_, err = svc.AddBook(ctx, "Emma", "9780141439587", books.Details{PublisherID: "unknown"}, nil)
+		assert.True(t, errors.Is(err, service.ErrInvalidReference))
+
+		page, err := svc.ListPublishers(ctx, publishers.PublisherQuery{Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, page.Publishers, 1)
+		assert.Equal(t, ace.ID, page.Publishers[0].ID)
+		page, err = svc.ListPublishers(ctx, publishers.PublisherQuery{Limit: 1, Cursor: page.NextCursor})
+		require.NoError(t, err)
+		require.Len(t, page.Publishers, 1)
+		assert.Equal(t, chilton.ID, page.Publishers[0].ID)
+
+		pub, err := svc.GetPublisher(ctx, chilton.ID)
+		require.NoError(t, err)
+		require.Len(t, pub.Books, 1)
+		assert.Equal(t, "Dune Messiah", pub.Books[0].Title)
+		bk, err = svc.GetBook(ctx, pub.Books[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, &books.BookPublisher{ID: chilton.ID, Name: "Chilton Books"}, bk.Publisher)
+
+		require.NoError(t, svc.RemovePublisher(ctx, chilton.ID))
+		bk, err = svc.GetBook(ctx, bk.ID)
+		require.NoError(t, err)
+		assert.Nil(t, bk.Publisher)
+	})
+
+	t.Run("reviews", func(t *testing.T) {
+		db := memstore.NewDB()
+		us, bs, rs, uow := memstore.NewUserStore(db), memstore.NewBookStore(db), memstore.NewReviewStore(db), memstore.NewUnitOfWork(db)
+		as, ps := memstore.NewAuthorStore(db), memstore.NewPublisherStore(db)
+		svc := service.NewService(service.Stores{Authors: &as, Books: &bs, Publishers: &ps, Reviews: &rs, Users: &us, Work: &uow})
+		require.NoError(t, as.UpsertAuthors(ctx, []authors.Author{{ID: "auth01", FirstName: "Frank", LastName: "Herbert", DOB: &dt}}))
+		require.NoError(t, ps.UpsertPublishers(ctx, []publishers.Publisher{{ID: "pub01", Name: "Chilton Books"}}))
+		dune := books.Book{ID: "abc01", Title: "Dune", ISBN: "9781861972712"}
+		dune.PublisherID = "pub01"
+		require.NoError(t, bs.UpsertBooks(ctx, []books.Book{dune}))
+		require.NoError(t, bs.LinkBookAuthors(ctx, "abc01", books.Credit{AuthorID: "auth01", Role: books.RoleAuthor, Sequence: 1}))
+		ann, err := svc.RegisterUser(ctx, users.User{Email: "ann@example.com", FirstName: "Ann", LastName: "Lee"}, "correct horse")
+		require.NoError(t, err)
+		bob, err := svc.RegisterUser(ctx, users.User{Email: "bob@example.com", FirstName: "Bob", LastName: "Ray"}, "correct horse")
+		require.NoError(t, err)
+		annCtx, bobCtx := auth.WithUser(context.Background(), ann), auth.WithUser(context.Background(), bob)
+
+		first, err := svc.AddReview(annCtx, reviews.Review{BookID: "abc01", Rating: 5, Body: "Gripping."})
+		require.NoError(t, err)
+		_, err = svc.AddReview(annCtx, reviews.Review{BookID: "abc01", Rating: 1})
+		assert.True(t, errors.Is(err, service.ErrDuplicate), "one review per reviewer")
+		_, err = svc.AddReview(annCtx, reviews.Review{BookID: "abc99", Rating: 1})
+		assert.True(t, errors.Is(err, service.ErrNotFound))
+		second, err := svc.AddReview(bobCtx, reviews.Review{BookID: "abc01", Rating: 2})
+		require.NoError(t, err)
+
+		bk, err := bs.ReadBookByID(ctx, "abc01")
+		require.NoError(t, err)
+		assert.Equal(t, &books.Rating{}, bk.Rating, "pending reviews do not count")
+
+		_, err = svc.ModerateReview(ctx, "abc01", first.ID, reviews.Approved)
+		require.NoError(t, err)
+		_, err = svc.ModerateReview(ctx, "abc01", second.ID, reviews.Approved)
+		require.NoError(t, err)
+		bk, err = bs.ReadBookByID(ctx, "abc01")
+		require.NoError(t, err)
+		assert.Equal(t, &books.Rating{Average: 3.5, Count: 2}, bk.Rating)
+
+		auth, err := svc.GetAuthor(ctx, "auth01")
+		require.NoError(t, err)
+		require.Len(t, auth.Books, 1)
+		assert.Nil(t, auth.Books[0].Rating, "books listed under an author are not rated")
+		out, err := json.Marshal(auth)
+		require.NoError(t, err)
+		assert.NotContains(t, string(out), `"rating"`)
+		pub, err := svc.GetPublisher(ctx, "pub01")
+		require.NoError(t, err)
+		require.Len(t, pub.Books, 1)
+		assert.Nil(t, pub.Books[0].Rating, "books listed under a publisher are not rated")
+
+		_, err = svc.UpdateReview(bobCtx, reviews.Review{ID: second.ID, BookID: "abc01", Rating: 4})
+		require.NoError(t, err)
+		page, err := svc.ListReviews(context.Background(), "abc01", reviews.ReviewQuery{})
+		require.NoError(t, err)
+		require.Len(t, page.Reviews, 1, "changed reviews wait for moderation again")
+		assert.Equal(t, first.ID, page.Reviews[0].ID)
+
+		require.NoError(t, bs.DeleteBooks(ctx, "abc01"))
+		_, err = rs.ReadReview(ctx, "abc01", first.ID)
+		assert.True(t, errors.Is(err, datastore.ErrNotFound), "reviews go with their book")
+	})
+
+	t.Run("sessions", func(t *testing.T) {
+		db := memstore.NewDB()
+		us, ss, uow := memstore.NewUserStore(db), memstore.NewSessionStore(db), memstore.NewUnitOfWork(db)
+		svc := service.NewService(service.Stores{Users: &us, Sessions: &ss, Work: &uow})
+		ann, err := svc.RegisterUser(ctx, users.User{Email: "ann@example.com", FirstName: "Ann", LastName: "Lee"}, "correct horse")
+		require.NoError(t, err)
+
+		creds, err := svc.Login(ctx, "ann@example.com", "correct horse")
+		require.NoError(t, err)
+		u, err := svc.Authenticate(ctx, creds.Token)
+		require.NoError(t, err)
+		assert.Equal(t, ann.ID, u.ID)
+
+		earlier := time.Now().Add(-time.Minute)
+		require.NoError(t, ss.CreateSession(ctx, auth.Session{TokenHash: "stale", UserID: ann.ID, ExpiresAt: &earlier}))
+		_, err = ss.ReadSession(ctx, "stale")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+		n, err := svc.PurgeExpiredSessions(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+
+		require.NoError(t, svc.SetUserEnabled(ctx, ann.ID, false))
+		_, err = svc.Authenticate(ctx, creds.Token)
+		assert.True(t, errors.Is(err, service.ErrUnauthenticated))
+		_, err = ss.ReadSession(ctx, auth.HashToken(creds.Token))
+		assert.True(t, errors.Is(err, datastore.ErrNotFound), "disabling a user ends their sessions")
+	})
+
+	t.Run("unit of work", func(t *testing.T) {
+		db := memstore.NewDB()
+		bs, uow := memstore.NewBookStore(db), memstore.NewUnitOfWork(db)
+		err := bs.UpsertBooks(ctx, []books.Book{{ID: "abc01", Title: "titleA", ISBN: "9783161484100"}})
+		require.NoError(t, err)
+
+		err = uow.Do(ctx, func(ctx context.Context) error {
+			if err := bs.UpsertBooks(ctx, []books.Book{{ID: "new01", Title: "new", ISBN: "9791032300824"}}); err != nil {
+				return err
+			}
+			if err := bs.DeleteBooks(ctx, "abc01"); err != nil {
+				return err
+			}
+			return bs.LinkBookAuthors(ctx, "new01", books.Credit{AuthorID: "unknown", Role: books.RoleAuthor, Sequence: 1})
+		})
+		assert.True(t, errors.Is(err, datastore.ErrMissingReference))
+
+		// every write of the failed unit of work was undone
+		_, err = bs.ReadBookByID(ctx, "new01")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+		_, err = bs.ReadBookByID(ctx, "abc01")
+		assert.NoError(t, err)
+
+		err = uow.Do(ctx, func(ctx context.Context) error {
+			return bs.UpsertBooks(ctx, []books.Book{{ID: "new01", Title: "new", ISBN: "9791032300824"}})
+		})
+		require.NoError(t, err)
+		_, err = bs.ReadBookByID(ctx, "new01")
+		assert.NoError(t, err)
+	})
+
+	t.Run("unit of work undoes cascades", func(t *testing.T) {
+		db := memstore.NewDB()
+		as, bs, uow := memstore.NewAuthorStore(db), memstore.NewBookStore(db), memstore.NewUnitOfWork(db)
+		cats, cs, ss := memstore.NewCategoryStore(db), memstore.NewCartStore(db), memstore.NewStockStore(db)
+		require.NoError(t, as.UpsertAuthors(ctx, []authors.Author{{ID: "auth01", FirstName: "Frank", LastName: "Herbert", DOB: &dt}}))
+		require.NoError(t, bs.UpsertBooks(ctx, []books.Book{{ID: "abc01", Title: "Dune", ISBN: "9781861972712"}}))
+		require.NoError(t, bs.LinkBookAuthors(ctx, "abc01", books.Credit{AuthorID: "auth01", Role: books.RoleAuthor, Sequence: 1}))
+		require.NoError(t, cats.CreateCategory(ctx, categories.Category{ID: "cat01", Name: "Fiction"}))
+		require.NoError(t, bs.LinkBookCategories(ctx, "abc01", "cat01"))
+		require.NoError(t, bs.TagBook(ctx, "abc01", "classic"))
+		require.NoError(t, bs.AddBookPrice(ctx, "abc01", books.Price{ListPrice: money.New(999, money.USD), EffectiveFrom: time.Now().Add(-time.Hour)}))
+		_, err := ss.ApplyMovement(ctx, stock.Movement{BookID: "abc01", Kind: stock.Receive, Quantity: 3, Reason: "delivery"})
+		require.NoError(t, err)
+		expires := time.Now().Add(carts.TTL)
+		require.NoError(t, cs.CreateCart(ctx, carts.Cart{Token: "tok01", ExpiresAt: &expires}))
+		require.NoError(t, cs.AddCartItems(ctx, "tok01", carts.Item{BookID: "abc01", Quantity: 1}))
+
+		err = uow.Do(ctx, func(ctx context.Context) error {
+			if err := bs.DeleteBooks(ctx, "abc01"); err != nil {
+				return err
+			}
+			return errors.New("changed my mind")
+		})
+		require.EqualError(t, err, "changed my mind")
+
+		bk, err := bs.ReadBookByID(ctx, "abc01")
+		require.NoError(t, err)
+		assert.Len(t, bk.Authors, 1)
+		assert.Len(t, bk.Categories, 1)
+		assert.Equal(t, []string{"classic"}, bk.Tags)
+		require.NotNil(t, bk.ListPrice)
+		assert.Equal(t, money.New(999, money.USD), *bk.ListPrice)
+		lvl, err := ss.ReadStock(ctx, "abc01")
+		require.NoError(t, err)
+		assert.Equal(t, 3, lvl.OnHand)
+		assert.Len(t, lvl.Ledger, 1)
+		c, err := cs.ReadCart(ctx, "tok01")
+		require.NoError(t, err)
+		assert.Len(t, c.Items, 1)
+	})
+
+	t.Run("concurrent upserts", func(t *testing.T) {
+		_, bs := newStores(t)
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 20)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs <- bs.UpsertBooks(ctx, []books.Book{
+					{ID: fmt.Sprintf("new%02d", i), Title: "same", ISBN: "9791032300824"},
+				})
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+
+		var ok int
+		for err := range errs {
+			if err == nil {
+				ok++
+				continue
+			}
+			assert.True(t, errors.Is(err, datastore.ErrDuplicate))
+		}
+		assert.Equal(t, 1, ok)
+	})
+
+	t.Run("concurrent AddBook", func(t *testing.T) {
+		db := memstore.NewDB()
+		as, bs, uow := memstore.NewAuthorStore(db), memstore.NewBookStore(db), memstore.NewUnitOfWork(db)
+		svc := service.NewService(service.Stores{Authors: &as, Books: &bs, Work: &uow})
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 10)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := svc.AddBook(ctx, "same", "9791032300824", books.Details{}, nil)
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		var ok int
+		for err := range errs {
+			if err == nil {
+				ok++
+				continue
+			}
+			assert.True(t, errors.Is(err, service.ErrDuplicate))
+		}
+		assert.Equal(t, 1, ok)
+	})
+}
@@ -0,0 +1,112 @@
+package memstore
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"bookshop/datastore"
+	"bookshop/money"
+	"bookshop/orders"
+)
+
+// OrderStore is an in-memory implementation of the order datastore. Orders are
+// kept with their lines, which are never changed once placed.
+type OrderStore struct {
+	db *DB
+}
+
+func NewOrderStore(db *DB) OrderStore {
+	return OrderStore{db: db}
+}
+
+// CreateOrder will add the order along with its lines, returning
+// datastore.ErrDuplicate if its ID is already in use and
+// datastore.ErrMissingReference if its user does not exist.
+func (s *OrderStore) CreateOrder(ctx context.Context, o orders.Order) error {
+	if len(o.Lines) == 0 {
+		return errors.New("no order lines to create")
+	}
+	defer s.db.write(ctx, ordersTable)()
+
+	if _, ok := s.db.orders[o.ID]; ok {
+		return datastore.ErrDuplicate
+	}
+	if _, ok := s.db.users[o.UserID]; o.UserID != "" && !ok {
+		return datastore.ErrMissingReference
+	}
+	o.CreatedAt, o.UpdatedAt = now(), now()
+	o.Lines = append([]orders.Line{}, o.Lines...)
+	for i := range o.Lines {
+		o.Lines[i].LineTotal = money.Money{}
+	}
+	o.Totals = nil
+	s.db.orders[o.ID] = o
+	return nil
+}
+
+// ReadOrder will return the order with the given ID along with its lines,
+// returning datastore.ErrNotFound if it does not exist.
+func (s *OrderStore) ReadOrder(ctx context.Context, id string) (orders.Order, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	o, ok := s.db.orders[id]
+	if !ok {
+		return orders.Order{}, datastore.ErrNotFound
+	}
+	o.Lines = append([]orders.Line{}, o.Lines...)
+	return o, nil
+}
+
+// ReadOrders will return a page of orders matching the query, newest first.
+func (s *OrderStore) ReadOrders(ctx context.Context, q orders.OrderQuery) (orders.Page, error) {
+	q = q.WithDefaults()
+	var c datastore.Cursor
+	if q.Cursor != "" {
+		var err error
+		if c, err = q.DecodeCursor(); err != nil {
+			return orders.Page{}, err
+		}
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	ords := []orders.Order{}
+	for _, o := range s.db.orders {
+		if q.Status != "" && o.Status != q.Status {
+			continue
+		}
+		if q.UserID != "" && o.UserID != q.UserID {
+			continue
+		}
+		if q.Cursor != "" && !pastCursor(compareCursor("", o.CreatedAt, o.ID, c, true), true) {
+			continue
+		}
+		o.Lines = append([]orders.Line{}, o.Lines...)
+		ords = append(ords, o)
+	}
+
+	sort.Slice(ords, func(i, j int) bool {
+		if cmp := compareTimes(ords[i].CreatedAt, ords[j].CreatedAt); cmp != 0 {
+			return cmp > 0
+		}
+		return ords[i].ID > ords[j].ID
+	})
+	return orders.NewPage(ords, q), nil
+}
+
+// UpdateOrderStatus will move the order from one status to another, returning
+// datastore.ErrNotFound if there is no order with the ID in the from status.
+func (s *OrderStore) UpdateOrderStatus(ctx context.Context, id string, from, to orders.Status) error {
+	defer s.db.write(ctx, ordersTable)()
+
+	o, ok := s.db.orders[id]
+	if !ok || o.Status != from {
+		return datastore.ErrNotFound
+	}
+	o.Status, o.UpdatedAt = to, now()
+	s.db.orders[id] = o
+	return nil
+}
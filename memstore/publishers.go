@@ -0,0 +1,155 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"bookshop/books"
+	"bookshop/datastore"
+	"bookshop/publishers"
+
+	"github.com/pkg/errors"
+)
+
+// PublisherStore is an in-memory implementation of the publisher datastore.
+type PublisherStore struct {
+	db *DB
+}
+
+func NewPublisherStore(db *DB) PublisherStore {
+	return PublisherStore{db: db}
+}
+
+// ReadPublishers will return a page of publishers matching the query.
+func (s *PublisherStore) ReadPublishers(ctx context.Context, q publishers.PublisherQuery) (publishers.Page, error) {
+	q = q.WithDefaults()
+	var c datastore.Cursor
+	if q.Cursor != "" {
+		var err error
+		if c, err = datastore.DecodeCursor(q.Cursor, publishers.SortByName, false); err != nil {
+			return publishers.Page{}, err
+		}
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	pubs := []publishers.Publisher{}
+	for _, p := range s.db.publishers {
+		if q.NamePrefix != "" && !hasPrefixFold(p.Name, q.NamePrefix) {
+			continue
+		}
+		if q.Cursor != "" && compareCursor(p.Name, nil, p.ID, c, false) <= 0 {
+			continue
+		}
+		pubs = append(pubs, p)
+	}
+	sort.Slice(pubs, func(i, j int) bool {
+		if cmp := compareStrings(pubs[i].Name, pubs[j].Name); cmp != 0 {
+			return cmp < 0
+		}
+		return pubs[i].ID < pubs[j].ID
+	})
+
+	if len(pubs) <= q.Limit {
+		return publishers.Page{Publishers: pubs}, nil
+	}
+	pubs = pubs[:q.Limit]
+	last := pubs[len(pubs)-1]
+	return publishers.Page{
+		Publishers: pubs,
+		NextCursor: datastore.Cursor{
+			Sort:  publishers.SortByName,
+			Value: last.Name,
+			ID:    last.ID,
+		}.Encode(),
+	}, nil
+}
+
+// ReadPublisherAndBooks will return the given publisher looked up by ID along
+// with the books they publish, returning datastore.ErrNotFound if it does not
+// exist.
+func (s *PublisherStore) ReadPublisherAndBooks(ctx context.Context, id string) (publishers.Publisher, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	pub, ok := s.db.publishers[id]
+	if !ok {
+		return publishers.Publisher{}, datastore.ErrNotFound
+	}
+	for _, bk := range s.db.books {
+		if bk.PublisherID != id {
+			continue
+		}
+		pub.Books = append(pub.Books, books.Book{
+			ID:    bk.ID,
+			Title: bk.Title,
+			ISBN:  bk.ISBN,
+		})
+	}
+	sort.Slice(pub.Books, func(i, j int) bool {
+		bi, bj := pub.Books[i], pub.Books[j]
+		if bi.Title != bj.Title {
+			return bi.Title < bj.Title
+		}
+		return bi.ID < bj.ID
+	})
+	return pub, nil
+}
+
+// DeletePublisher will delete a publisher by their ID, leaving their books
+// without a publisher, returning datastore.ErrNotFound if it does not exist.
+func (s *PublisherStore) DeletePublisher(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("no id submitted to delete")
+	}
+	defer s.db.write(ctx, publishersTable|booksTable)()
+
+	if _, ok := s.db.publishers[id]; !ok {
+		return datastore.ErrNotFound
+	}
+	delete(s.db.publishers, id)
+	for bookID, bk := range s.db.books {
+		if bk.PublisherID == id {
+			bk.PublisherID = ""
+			s.db.books[bookID] = bk
+		}
+	}
+	return nil
+}
+
+// UpsertPublishers will modify or add the publishers in the given list. No
+// changes are made if any of them shares a name, regardless of case, with
+// another publisher.
+func (s *PublisherStore) UpsertPublishers(ctx context.Context, pubs []publishers.Publisher) error {
+	if len(pubs) == 0 {
+		return errors.New("no publishers to upsert")
+	}
+	defer s.db.write(ctx, publishersTable)()
+
+	next := make(map[string]publishers.Publisher, len(s.db.publishers)+len(pubs))
+	for id, p := range s.db.publishers {
+		next[id] = p
+	}
+	for _, p := range pubs {
+		at := now()
+		p.CreatedAt, p.UpdatedAt = at, at
+		if extant, ok := next[p.ID]; ok {
+			p.CreatedAt = extant.CreatedAt
+		}
+		p.Books = nil
+		next[p.ID] = p
+	}
+
+	seen := map[string]string{}
+	for id, p := range next {
+		key := strings.ToLower(p.Name)
+		if other, ok := seen[key]; ok && other != id {
+			return datastore.ErrDuplicate
+		}
+		seen[key] = id
+	}
+	s.db.publishers = next
+	return nil
+}
@@ -0,0 +1,134 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+
+	"bookshop/datastore"
+	"bookshop/reviews"
+)
+
+// ReviewStore is an in-memory implementation of the review datastore.
+type ReviewStore struct {
+	db *DB
+}
+
+func NewReviewStore(db *DB) ReviewStore {
+	return ReviewStore{db: db}
+}
+
+// CreateReview will add the given review, returning datastore.ErrDuplicate if
+// its user has already reviewed the book and datastore.ErrMissingReference if
+// the book or user does not exist.
+func (s *ReviewStore) CreateReview(ctx context.Context, r reviews.Review) error {
+	defer s.db.write(ctx, reviewsTable)()
+
+	if _, ok := s.db.reviews[r.ID]; ok {
+		return datastore.ErrDuplicate
+	}
+	for _, other := range s.db.reviews {
+		if other.BookID == r.BookID && other.UserID == r.UserID {
+			return datastore.ErrDuplicate
+		}
+	}
+	if _, ok := s.db.books[r.BookID]; !ok {
+		return datastore.ErrMissingReference
+	}
+	if _, ok := s.db.users[r.UserID]; !ok {
+		return datastore.ErrMissingReference
+	}
+	r.CreatedAt, r.UpdatedAt = now(), now()
+	s.db.reviews[r.ID] = r
+	return nil
+}
+
+// ReadReview will return the review of the given book with the given ID,
+// returning datastore.ErrNotFound if it does not exist.
+func (s *ReviewStore) ReadReview(ctx context.Context, bookID, id string) (reviews.Review, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	r, ok := s.db.reviews[id]
+	if !ok || r.BookID != bookID {
+		return reviews.Review{}, datastore.ErrNotFound
+	}
+	return r, nil
+}
+
+// ReadReviews will return a page of the book's reviews matching the query,
+// newest first.
+func (s *ReviewStore) ReadReviews(ctx context.Context, bookID string, q reviews.ReviewQuery) (reviews.Page, error) {
+	q = q.WithDefaults()
+	var c datastore.Cursor
+	if q.Cursor != "" {
+		var err error
+		if c, err = q.DecodeCursor(); err != nil {
+			return reviews.Page{}, err
+		}
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	revs := []reviews.Review{}
+	for _, r := range s.db.reviews {
+		if r.BookID != bookID || (q.Status != "" && r.Status != q.Status) {
+			continue
+		}
+		if q.Cursor != "" && !pastCursor(compareCursor("", r.CreatedAt, r.ID, c, true), true) {
+			continue
+		}
+		revs = append(revs, r)
+	}
+
+	sort.Slice(revs, func(i, j int) bool {
+		if cmp := compareTimes(revs[i].CreatedAt, revs[j].CreatedAt); cmp != 0 {
+			return cmp > 0
+		}
+		return revs[i].ID > revs[j].ID
+	})
+	return reviews.NewPage(revs, q), nil
+}
+
+// UpdateReview will change the rating, text and status of the review,
+// returning datastore.ErrNotFound if the book has no review with its ID.
+func (s *ReviewStore) UpdateReview(ctx context.Context, r reviews.Review) error {
+	return s.change(ctx, r.BookID, r.ID, func(cur *reviews.Review) {
+		cur.Rating, cur.Body, cur.Status = r.Rating, r.Body, r.Status
+	})
+}
+
+// SetReviewStatus will change the status of the review, returning
+// datastore.ErrNotFound if the book has no review with the ID.
+func (s *ReviewStore) SetReviewStatus(ctx context.Context, bookID, id string, status reviews.Status) error {
+	return s.change(ctx, bookID, id, func(cur *reviews.Review) {
+		cur.Status = status
+	})
+}
+
+// DeleteReview will delete the review, returning datastore.ErrNotFound if the
+// book has no review with the ID.
+func (s *ReviewStore) DeleteReview(ctx context.Context, bookID, id string) error {
+	defer s.db.write(ctx, reviewsTable)()
+
+	if r, ok := s.db.reviews[id]; !ok || r.BookID != bookID {
+		return datastore.ErrNotFound
+	}
+	delete(s.db.reviews, id)
+	return nil
+}
+
+// change applies fn to the book's review with the ID along with a new
+// updated_at.
+func (s *ReviewStore) change(ctx context.Context, bookID, id string, fn func(*reviews.Review)) error {
+	defer s.db.write(ctx, reviewsTable)()
+
+	r, ok := s.db.reviews[id]
+	if !ok || r.BookID != bookID {
+		return datastore.ErrNotFound
+	}
+	fn(&r)
+	r.UpdatedAt = now()
+	s.db.reviews[id] = r
+	return nil
+}
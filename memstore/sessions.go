@@ -0,0 +1,84 @@
+package memstore
+
+import (
+	"context"
+	"time"
+
+	"bookshop/auth"
+	"bookshop/datastore"
+)
+
+// SessionStore is an in-memory implementation of the session datastore.
+type SessionStore struct {
+	db *DB
+}
+
+func NewSessionStore(db *DB) SessionStore {
+	return SessionStore{db: db}
+}
+
+// CreateSession will add the given session, returning
+// datastore.ErrMissingReference if its user does not exist.
+func (s *SessionStore) CreateSession(ctx context.Context, sess auth.Session) error {
+	defer s.db.write(ctx, sessionsTable)()
+
+	if _, ok := s.db.users[sess.UserID]; !ok {
+		return datastore.ErrMissingReference
+	}
+	sess.CreatedAt = now()
+	s.db.sessions[sess.TokenHash] = sess
+	return nil
+}
+
+// ReadSession will return the session with the given token hash, returning
+// datastore.ErrNotFound if it does not exist or has expired.
+func (s *SessionStore) ReadSession(ctx context.Context, tokenHash string) (auth.Session, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	sess, ok := s.db.sessions[tokenHash]
+	if !ok || (sess.ExpiresAt != nil && !sess.ExpiresAt.After(time.Now())) {
+		return auth.Session{}, datastore.ErrNotFound
+	}
+	return sess, nil
+}
+
+// DeleteSession will end the session with the given token hash, returning
+// datastore.ErrNotFound if it does not exist.
+func (s *SessionStore) DeleteSession(ctx context.Context, tokenHash string) error {
+	defer s.db.write(ctx, sessionsTable)()
+
+	if _, ok := s.db.sessions[tokenHash]; !ok {
+		return datastore.ErrNotFound
+	}
+	delete(s.db.sessions, tokenHash)
+	return nil
+}
+
+// DeleteUserSessions will end every session of the given user, returning how
+// many were ended.
+func (s *SessionStore) DeleteUserSessions(ctx context.Context, userID string) (int64, error) {
+	defer s.db.write(ctx, sessionsTable)()
+	return s.deleteWhere(func(sess auth.Session) bool { return sess.UserID == userID }), nil
+}
+
+// DeleteExpiredSessions will delete the sessions that expired before the given
+// time, returning how many were deleted.
+func (s *SessionStore) DeleteExpiredSessions(ctx context.Context, before time.Time) (int64, error) {
+	defer s.db.write(ctx, sessionsTable)()
+	return s.deleteWhere(func(sess auth.Session) bool {
+		return sess.ExpiresAt != nil && !sess.ExpiresAt.After(before)
+	}), nil
+}
+
+// deleteWhere deletes the sessions matching fn. The caller must hold the lock.
+func (s *SessionStore) deleteWhere(fn func(auth.Session) bool) int64 {
+	var n int64
+	for hash, sess := range s.db.sessions {
+		if fn(sess) {
+			delete(s.db.sessions, hash)
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,66 @@
+package memstore
+
+import (
+	"context"
+
+	"bookshop/datastore"
+	"bookshop/stock"
+)
+
+// StockStore is an in-memory implementation of the stock datastore.
+type StockStore struct {
+	db *DB
+}
+
+func NewStockStore(db *DB) StockStore {
+	return StockStore{db: db}
+}
+
+// ReadStock will return the stock level of the given book along with its latest
+// ledger entries, returning datastore.ErrNotFound if the book does not exist.
+func (s *StockStore) ReadStock(ctx context.Context, bookID string) (stock.Level, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	if _, ok := s.db.books[bookID]; !ok {
+		return stock.Level{}, datastore.ErrNotFound
+	}
+	lvl := s.db.stock[bookID]
+	lvl.BookID = bookID
+	lvl.Ledger = []stock.Entry{}
+	for i := len(s.db.ledger) - 1; i >= 0 && len(lvl.Ledger) < stock.LedgerLimit; i-- {
+		if s.db.ledger[i].BookID == bookID {
+			lvl.Ledger = append(lvl.Ledger, s.db.ledger[i])
+		}
+	}
+	return lvl, nil
+}
+
+// ApplyMovement will apply the movement to the book's stock and record it in the
+// ledger, returning datastore.ErrCheckViolation if it would leave fewer copies on
+// hand than are reserved.
+func (s *StockStore) ApplyMovement(ctx context.Context, m stock.Movement) (stock.Level, error) {
+	defer s.db.write(ctx, stockTable|ledgerTable)()
+
+	if _, ok := s.db.books[m.BookID]; !ok {
+		return stock.Level{}, datastore.ErrMissingReference
+	}
+	entry := m.Entry()
+	lvl := s.db.stock[m.BookID]
+	lvl.BookID = m.BookID
+	lvl.OnHand += entry.OnHandDelta
+	lvl.Reserved += entry.ReservedDelta
+	if lvl.Reserved < 0 || lvl.OnHand < lvl.Reserved {
+		return stock.Level{}, datastore.ErrCheckViolation
+	}
+	lvl.UpdatedAt = now()
+	s.db.stock[m.BookID] = lvl
+
+	entry.ID = 1
+	if n := len(s.db.ledger); n > 0 {
+		entry.ID = s.db.ledger[n-1].ID + 1
+	}
+	entry.CreatedAt = lvl.UpdatedAt
+	s.db.ledger = append(s.db.ledger, entry)
+	return lvl, nil
+}
@@ -0,0 +1,140 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+
+	"bookshop/datastore"
+	"bookshop/users"
+)
+
+// UserStore is an in-memory implementation of the user datastore.
+type UserStore struct {
+	db *DB
+}
+
+func NewUserStore(db *DB) UserStore {
+	return UserStore{db: db}
+}
+
+// CreateUser will add the given user, returning datastore.ErrDuplicate if the
+// email address is already in use.
+func (s *UserStore) CreateUser(ctx context.Context, u users.User) error {
+	defer s.db.write(ctx, usersTable)()
+
+	if _, ok := s.db.users[u.ID]; ok || s.emailTaken(u.Email, u.ID) {
+		return datastore.ErrDuplicate
+	}
+	if u.Role == "" {
+		u.Role = users.RoleCustomer
+	}
+	u.CreatedAt, u.UpdatedAt = now(), now()
+	s.db.users[u.ID] = u
+	return nil
+}
+
+// ReadUserByID will return the user with the given ID, returning
+// datastore.ErrNotFound if it does not exist.
+func (s *UserStore) ReadUserByID(ctx context.Context, id string) (users.User, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	u, ok := s.db.users[id]
+	if !ok {
+		return users.User{}, datastore.ErrNotFound
+	}
+	return u, nil
+}
+
+// ReadUserByEmail will return the user with the given email address, returning
+// datastore.ErrNotFound if it does not exist.
+func (s *UserStore) ReadUserByEmail(ctx context.Context, email string) (users.User, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, u := range s.db.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return users.User{}, datastore.ErrNotFound
+}
+
+// UpdateUser will change the email address and names of the user, returning
+// datastore.ErrNotFound if it does not exist and datastore.ErrDuplicate if the
+// email address belongs to another user.
+func (s *UserStore) UpdateUser(ctx context.Context, u users.User) error {
+	defer s.db.write(ctx, usersTable)()
+
+	cur, ok := s.db.users[u.ID]
+	if !ok {
+		return datastore.ErrNotFound
+	}
+	if s.emailTaken(u.Email, u.ID) {
+		return datastore.ErrDuplicate
+	}
+	cur.Email, cur.FirstName, cur.LastName, cur.UpdatedAt = u.Email, u.FirstName, u.LastName, now()
+	s.db.users[u.ID] = cur
+	return nil
+}
+
+// SetUserPassword will replace the password hash of the user, returning
+// datastore.ErrNotFound if it does not exist.
+func (s *UserStore) SetUserPassword(ctx context.Context, id, hash string) error {
+	defer s.db.write(ctx, usersTable)()
+
+	u, ok := s.db.users[id]
+	if !ok {
+		return datastore.ErrNotFound
+	}
+	u.PasswordHash, u.UpdatedAt = hash, now()
+	s.db.users[id] = u
+	return nil
+}
+
+// SetUserEnabled will enable or disable the user, returning
+// datastore.ErrNotFound if it does not exist.
+func (s *UserStore) SetUserEnabled(ctx context.Context, id string, enabled bool) error {
+	defer s.db.write(ctx, usersTable)()
+
+	u, ok := s.db.users[id]
+	if !ok {
+		return datastore.ErrNotFound
+	}
+	u.Enabled, u.UpdatedAt = enabled, now()
+	s.db.users[id] = u
+	return nil
+}
+
+// SetUserRole will change the role of the user, returning
+// datastore.ErrNotFound if it does not exist.
+func (s *UserStore) SetUserRole(ctx context.Context, id string, role users.Role) error {
+	defer s.db.write(ctx, usersTable)()
+
+	u, ok := s.db.users[id]
+	if !ok {
+		return datastore.ErrNotFound
+	}
+	u.Role, u.UpdatedAt = role, now()
+	s.db.users[id] = u
+	return nil
+}
+
+// ReadRolePermissions will return the permissions given to the role, sorted by
+// name. A role without any has an empty list.
+func (s *UserStore) ReadRolePermissions(ctx context.Context, role users.Role) ([]users.Permission, error) {
+	perms := append([]users.Permission{}, users.RolePermissions[role]...)
+	sort.Slice(perms, func(i, j int) bool { return perms[i] < perms[j] })
+	return perms, nil
+}
+
+// emailTaken reports whether a user other than the one with the given ID has
+// the email address. The caller must hold the lock.
+func (s *UserStore) emailTaken(email, id string) bool {
+	for _, u := range s.db.users {
+		if u.Email == email && u.ID != id {
+			return true
+		}
+	}
+	return false
+}
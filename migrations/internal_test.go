@@ -0,0 +1,23 @@
+//go:build int
+// +build int
+
+package migrations_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"bookshop/datastore/dbtest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaselinePostgres(t *testing.T) {
+	dbh := dbtest.EmptyPostgres(t, "migrations_testing")
+
+	schema, err := ioutil.ReadFile("testdata/authors_books.sql")
+	require.NoError(t, err)
+	_, err = dbh.Exec(string(schema))
+	require.NoError(t, err)
+	testBaseline(t, dbh)
+}
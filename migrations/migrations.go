@@ -0,0 +1,246 @@
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"bookshop/datastore"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// files holds the migrations for each database, named
+// <version>_<name>.up.sql and <version>_<name>.down.sql.
+//
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// lockKey identifies the postgres advisory lock held while migrating.
+const lockKey = 7041987
+
+const createTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version bigint NOT NULL,
+	name varchar(128) NOT NULL,
+	applied_at timestamp NOT NULL,
+	PRIMARY KEY(version)
+)`
+
+// ErrNoMigration is returned by Down when there is nothing left to roll back.
+var ErrNoMigration = errors.New("no migration to roll back")
+
+// Migration is a numbered schema change along with the SQL that reverts it.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status reports whether a migration has been applied to the database.
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Migrator applies and rolls back the migrations for the database it is
+// connected to. Each migration runs in its own transaction holding a lock, so
+// instances migrating the same database concurrently apply each one only once.
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *sqlx.DB) (Migrator, error) {
+	dir := datastore.Postgres
+	if datastore.IsSQLite(db) {
+		dir = datastore.SQLite
+	}
+	migrations, err := load(dir)
+	if err != nil {
+		return Migrator{}, err
+	}
+	return Migrator{db: db, migrations: migrations}, nil
+}
+
+// Up will apply every pending migration in order, returning the ones applied.
+func (m *Migrator) Up() ([]Migration, error) {
+	var applied []Migration
+	for _, mig := range m.migrations {
+		ran := false
+		err := m.locked(func(tx *sqlx.Tx) error {
+			var n int
+			if err := tx.Get(&n, tx.Rebind("SELECT count(*) FROM schema_migrations WHERE version = ?"), mig.Version); err != nil {
+				return err
+			}
+			if n > 0 {
+				return nil
+			}
+			if _, err := tx.Exec(mig.Up); err != nil {
+				return err
+			}
+			ran = true
+			_, err := tx.Exec(tx.Rebind("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"),
+				mig.Version, mig.Name, time.Now().UTC())
+			return err
+		})
+		if err != nil {
+			return applied, errors.Wrapf(err, "failed applying migration %d_%s", mig.Version, mig.Name)
+		}
+		if ran {
+			applied = append(applied, mig)
+		}
+	}
+	return applied, nil
+}
+
+// Down will roll back the most recently applied migration, returning
+// ErrNoMigration if none are applied.
+func (m *Migrator) Down() (Migration, error) {
+	var mig Migration
+	err := m.locked(func(tx *sqlx.Tx) error {
+		var version int
+		err := tx.Get(&version, "SELECT version FROM schema_migrations ORDER BY version DESC LIMIT 1")
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoMigration
+		}
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if mig, ok = m.find(version); !ok {
+			return errors.Errorf("migration %d is not known to this build", version)
+		}
+		if _, err := tx.Exec(mig.Down); err != nil {
+			return err
+		}
+		_, err = tx.Exec(tx.Rebind("DELETE FROM schema_migrations WHERE version = ?"), version)
+		return err
+	})
+	if errors.Is(err, ErrNoMigration) {
+		return Migration{}, err
+	}
+	if err != nil {
+		return Migration{}, errors.Wrap(err, "failed rolling back migration")
+	}
+	return mig, nil
+}
+
+// Status will return every known migration and when it was applied.
+func (m *Migrator) Status() ([]Status, error) {
+	if _, err := m.db.Exec(createTable); err != nil {
+		return nil, errors.Wrap(err, "failed creating schema_migrations")
+	}
+	var rows []struct {
+		Version   int       `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+	if err := m.db.Select(&rows, "SELECT version, applied_at FROM schema_migrations"); err != nil {
+		return nil, errors.Wrap(err, "failed reading schema_migrations")
+	}
+	applied := map[int]time.Time{}
+	for _, r := range rows {
+		applied[r.Version] = r.AppliedAt
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		st := Status{Version: mig.Version, Name: mig.Name}
+		if at, ok := applied[mig.Version]; ok {
+			st.AppliedAt = &at
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses, nil
+}
+
+// locked runs fn in a transaction that no other migrator can run alongside.
+// Postgres takes an advisory lock released on commit, while SQLite connections
+// begin transactions with the write lock already held.
+func (m *Migrator) locked(fn func(tx *sqlx.Tx) error) error {
+	tx, err := m.db.Beginx()
+	if err != nil {
+		return err
+	}
+	if !datastore.IsSQLite(m.db) {
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", lockKey); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err := tx.Exec(createTable); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) find(version int) (Migration, bool) {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig, true
+		}
+	}
+	return Migration{}, false
+}
+
+// load reads the migrations in dir, sorted by version. Every migration must
+// have both an up and a down file.
+func load(dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed reading %s migrations", dir)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		match := fileName.FindStringSubmatch(e.Name())
+		if match == nil {
+			return nil, errors.Errorf("unexpected migration file %s/%s", dir, e.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != match[2] {
+			return nil, errors.Errorf("migration %d has files named %s and %s", version, mig.Name, match[2])
+		}
+
+		contents, err := fs.ReadFile(files, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if match[3] == "up" {
+			mig.Up = string(contents)
+		} else {
+			mig.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, errors.Errorf("migration %d_%s is missing its up or down file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
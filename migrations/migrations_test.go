@@ -0,0 +1,260 @@
+package migrations_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"bookshop/datastore"
+	"bookshop/migrations"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrator(t *testing.T) {
+	t.Run("up, status and down", func(t *testing.T) {
+		dbh, err := datastore.ConnectSQLite(":memory:")
+		require.NoError(t, err)
+		defer dbh.Close()
+
+		m, err := migrations.NewMigrator(dbh)
+		require.NoError(t, err)
+
+		statuses, err := m.Status()
+		require.NoError(t, err)
+		require.True(t, len(statuses) >= 2)
+		assert.Equal(t, 1, statuses[0].Version)
+		assert.Equal(t, "create_tables", statuses[0].Name)
+		assert.Nil(t, statuses[0].AppliedAt)
+
+		applied, err := m.Up()
+		require.NoError(t, err)
+		assert.Len(t, applied, len(statuses))
+
+		_, err = dbh.Exec("INSERT INTO books (id, title, isbn) VALUES ('abc01', 'Dune', '9781861972712')")
+		require.NoError(t, err)
+
+		// nothing left to apply
+		applied, err = m.Up()
+		require.NoError(t, err)
+		assert.Empty(t, applied)
+
+		statuses, err = m.Status()
+		require.NoError(t, err)
+		for _, st := range statuses {
+			assert.NotNil(t, st.AppliedAt, st.Name)
+		}
+
+		// roll back to before the search tables
+		for v := len(statuses); v >= 2; v-- {
+			mig, err := m.Down()
+			require.NoError(t, err)
+			assert.Equal(t, v, mig.Version)
+		}
+		_, err = dbh.Exec("SELECT * FROM books_search")
+		assert.Error(t, err)
+
+		// reapplying the search tables indexes the existing rows
+		_, err = m.Up()
+		require.NoError(t, err)
+		var n int
+		require.NoError(t, dbh.Get(&n, "SELECT count(*) FROM books_search WHERE books_search MATCH 'dune'"))
+		assert.Equal(t, 1, n)
+
+		for range statuses {
+			_, err = m.Down()
+			require.NoError(t, err)
+		}
+		_, err = m.Down()
+		assert.True(t, errors.Is(err, migrations.ErrNoMigration))
+	})
+
+	t.Run("publishers from book details", func(t *testing.T) {
+		dbh, err := datastore.ConnectSQLite(":memory:")
+		require.NoError(t, err)
+		defer dbh.Close()
+		m, err := migrations.NewMigrator(dbh)
+		require.NoError(t, err)
+		_, err = m.Up()
+		require.NoError(t, err)
+
+		// roll back to when publishers were a column of books
+		for {
+			mig, err := m.Down()
+			require.NoError(t, err)
+			if mig.Name == "publishers" {
+				break
+			}
+		}
+		_, err = dbh.Exec(`INSERT INTO books (id, title, isbn, publisher) VALUES
+			('abc01', 'Dune', '9781861972712', 'Chilton Books'),
+			('def02', 'Dune Messiah', '9780306406157', 'CHILTON BOOKS'),
+			('ghi03', 'Emma', '9780141439587', '')`)
+		require.NoError(t, err)
+
+		_, err = m.Up()
+		require.NoError(t, err)
+		var names []string
+		require.NoError(t, dbh.Select(&names, "SELECT name FROM publishers"))
+		assert.Len(t, names, 1)
+		var linked int
+		require.NoError(t, dbh.Get(&linked, "SELECT count(*) FROM books_publishers"))
+		assert.Equal(t, 2, linked)
+
+		for {
+			mig, err := m.Down()
+			require.NoError(t, err)
+			if mig.Name == "publishers" {
+				break
+			}
+		}
+		var publisher string
+		require.NoError(t, dbh.Get(&publisher, "SELECT publisher FROM books WHERE id = 'def02'"))
+		assert.Equal(t, names[0], publisher)
+	})
+
+	t.Run("contributor roles", func(t *testing.T) {
+		dbh, err := datastore.ConnectSQLite(":memory:")
+		require.NoError(t, err)
+		defer dbh.Close()
+		m, err := migrations.NewMigrator(dbh)
+		require.NoError(t, err)
+		_, err = m.Up()
+		require.NoError(t, err)
+
+		// roll back to when a book's authors were plain pairs
+		for {
+			mig, err := m.Down()
+			require.NoError(t, err)
+			if mig.Name == "contributor_roles" {
+				break
+			}
+		}
+		_, err = dbh.Exec(`INSERT INTO authors (id, first_name, middle_name, last_name, dob) VALUES
+			('auth01', 'Neil', '', 'Gaiman', '1960-11-10'),
+			('auth02', 'Terry', '', 'Pratchett', '1948-04-28')`)
+		require.NoError(t, err)
+		_, err = dbh.Exec(`INSERT INTO books (id, title, isbn) VALUES ('abc01', 'Good Omens', '9780060853983')`)
+		require.NoError(t, err)
+		_, err = dbh.Exec(`INSERT INTO books_authors (book_id, author_id) VALUES ('abc01', 'auth02'), ('abc01', 'auth01')`)
+		require.NoError(t, err)
+
+		// existing credits are authors, numbered by name
+		_, err = m.Up()
+		require.NoError(t, err)
+		var credits []struct {
+			AuthorID string `db:"author_id"`
+			Role     string `db:"role"`
+			Sequence int    `db:"sequence"`
+		}
+		require.NoError(t, dbh.Select(&credits, "SELECT author_id, role, sequence FROM books_authors ORDER BY sequence"))
+		require.Len(t, credits, 2)
+		assert.Equal(t, "auth01", credits[0].AuthorID)
+		assert.Equal(t, "author", credits[0].Role)
+		assert.Equal(t, 1, credits[0].Sequence)
+		assert.Equal(t, 2, credits[1].Sequence)
+
+		_, err = dbh.Exec(`INSERT INTO books_authors (book_id, author_id, role, sequence) VALUES ('abc01', 'auth02', 'illustrator', 3)`)
+		require.NoError(t, err)
+		_, err = dbh.Exec(`INSERT INTO books_authors (book_id, author_id, role, sequence) VALUES ('abc01', 'auth02', 'ghostwriter', 4)`)
+		assert.Error(t, err)
+
+		// rolling back keeps one credit per author
+		for {
+			mig, err := m.Down()
+			require.NoError(t, err)
+			if mig.Name == "contributor_roles" {
+				break
+			}
+		}
+		var linked int
+		require.NoError(t, dbh.Get(&linked, "SELECT count(*) FROM books_authors"))
+		assert.Equal(t, 2, linked)
+	})
+
+	t.Run("baseline from the schema file", func(t *testing.T) {
+		dbh, err := datastore.ConnectSQLite(":memory:")
+		require.NoError(t, err)
+		defer dbh.Close()
+
+		// the schema file was written for postgres
+		schema, err := ioutil.ReadFile("testdata/authors_books.sql")
+		require.NoError(t, err)
+		_, err = dbh.Exec(strings.ReplaceAll(string(schema), "NOW()", "CURRENT_TIMESTAMP"))
+		require.NoError(t, err)
+		testBaseline(t, dbh)
+	})
+
+	t.Run("concurrent up", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "bookshop.db")
+
+		var wg sync.WaitGroup
+		counts := make(chan int, 4)
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				dbh, err := datastore.ConnectSQLite(path)
+				if !assert.NoError(t, err) {
+					return
+				}
+				defer dbh.Close()
+				m, err := migrations.NewMigrator(dbh)
+				if !assert.NoError(t, err) {
+					return
+				}
+				applied, err := m.Up()
+				assert.NoError(t, err)
+				counts <- len(applied)
+			}()
+		}
+		wg.Wait()
+		close(counts)
+
+		var total int
+		for n := range counts {
+			total += n
+		}
+
+		dbh, err := datastore.ConnectSQLite(path)
+		require.NoError(t, err)
+		defer dbh.Close()
+		m, err := migrations.NewMigrator(dbh)
+		require.NoError(t, err)
+		statuses, err := m.Status()
+		require.NoError(t, err)
+		assert.Equal(t, len(statuses), total)
+	})
+}
+
+// testBaseline migrates a database holding the tables of the schema file that
+// predates migrations, checking that its rows survive.
+func testBaseline(t *testing.T, dbh *sqlx.DB) {
+	_, err := dbh.Exec(`INSERT INTO authors (id, first_name, middle_name, last_name, dob) VALUES
+		('auth01', 'Frank', '', 'Herbert', '1920-10-08')`)
+	require.NoError(t, err)
+	_, err = dbh.Exec(`INSERT INTO books (id, title, isbn) VALUES ('abc01', 'Dune', '9780441013593')`)
+	require.NoError(t, err)
+	_, err = dbh.Exec(`INSERT INTO books_authors (book_id, author_id) VALUES ('abc01', 'auth01')`)
+	require.NoError(t, err)
+
+	m, err := migrations.NewMigrator(dbh)
+	require.NoError(t, err)
+	_, err = m.Up()
+	require.NoError(t, err)
+
+	statuses, err := m.Status()
+	require.NoError(t, err)
+	for _, st := range statuses {
+		assert.NotNil(t, st.AppliedAt, st.Name)
+	}
+	var title string
+	require.NoError(t, dbh.Get(&title, `SELECT b.title FROM books b, books_authors ab
+		WHERE b.id = ab.book_id AND ab.author_id = 'auth01'`))
+	assert.Equal(t, "Dune", title)
+}
@@ -0,0 +1,2 @@
+DROP INDEX authors_name_search;
+DROP INDEX books_title_search;
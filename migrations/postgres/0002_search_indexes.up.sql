@@ -0,0 +1,5 @@
+CREATE INDEX books_title_search ON books
+	USING GIN (to_tsvector('english', title));
+
+CREATE INDEX authors_name_search ON authors
+	USING GIN (to_tsvector('simple', first_name || ' ' || middle_name || ' ' || last_name));
@@ -0,0 +1,21 @@
+CREATE TABLE stock (
+	book_id varchar(36) NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+	on_hand integer NOT NULL DEFAULT 0,
+	reserved integer NOT NULL DEFAULT 0,
+	updated_at timestamp NOT NULL DEFAULT NOW(),
+	PRIMARY KEY(book_id),
+	CHECK (reserved >= 0 AND on_hand >= reserved)
+);
+
+CREATE TABLE stock_ledger (
+	id bigserial NOT NULL,
+	book_id varchar(36) NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+	kind varchar(16) NOT NULL,
+	on_hand_delta integer NOT NULL,
+	reserved_delta integer NOT NULL,
+	reason varchar(256) NOT NULL,
+	created_at timestamp NOT NULL DEFAULT NOW(),
+	PRIMARY KEY(id)
+);
+
+CREATE INDEX stock_ledger_book ON stock_ledger (book_id, id);
@@ -0,0 +1,12 @@
+CREATE TABLE book_prices (
+	id bigserial NOT NULL,
+	book_id varchar(36) NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+	amount bigint NOT NULL,
+	currency char(3) NOT NULL,
+	effective_from timestamp NOT NULL,
+	created_at timestamp NOT NULL DEFAULT NOW(),
+	PRIMARY KEY(id),
+	CHECK (amount >= 0)
+);
+
+CREATE INDEX book_prices_book ON book_prices (book_id, effective_from);
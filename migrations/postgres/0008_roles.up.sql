@@ -0,0 +1,16 @@
+-- a user's role decides what they may do beyond shopping; role_permissions
+-- lists what each role is allowed and is read when a user signs in
+ALTER TABLE users ADD COLUMN role varchar(16) NOT NULL DEFAULT 'customer'
+	CHECK (role IN ('customer', 'staff', 'admin'));
+
+CREATE TABLE role_permissions (
+	role varchar(16) NOT NULL,
+	permission varchar(32) NOT NULL,
+	PRIMARY KEY(role, permission)
+);
+
+INSERT INTO role_permissions (role, permission) VALUES
+	('staff', 'books.edit'),
+	('admin', 'books.edit'),
+	('admin', 'authors.remove'),
+	('admin', 'users.manage');
@@ -0,0 +1,16 @@
+-- keys let other services call the API as the user they belong to, limited to
+-- the operations listed in scopes; like session tokens only their hashes are
+-- kept
+CREATE TABLE api_keys (
+	id varchar(36) NOT NULL,
+	user_id varchar(36) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	name varchar(64) NOT NULL,
+	key_hash varchar(64) NOT NULL,
+	scopes varchar(2048) NOT NULL,
+	created_at timestamp NOT NULL DEFAULT NOW(),
+	last_used_at timestamp,
+	PRIMARY KEY(id),
+	UNIQUE(key_hash)
+);
+
+CREATE INDEX api_keys_user ON api_keys (user_id);
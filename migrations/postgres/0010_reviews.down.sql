@@ -0,0 +1,2 @@
+DELETE FROM role_permissions WHERE permission = 'reviews.moderate';
+DROP TABLE reviews;
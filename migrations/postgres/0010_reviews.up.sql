@@ -0,0 +1,22 @@
+-- a customer reviews a book at most once; only approved reviews are shown to
+-- everyone and counted in the book's rating
+CREATE TABLE reviews (
+	id varchar(36) NOT NULL,
+	book_id varchar(36) NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+	user_id varchar(36) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	rating smallint NOT NULL,
+	body varchar(4000) NOT NULL DEFAULT '',
+	status varchar(16) NOT NULL DEFAULT 'pending',
+	created_at timestamp NOT NULL DEFAULT NOW(),
+	updated_at timestamp NOT NULL DEFAULT NOW(),
+	PRIMARY KEY(id),
+	UNIQUE(book_id, user_id),
+	CHECK (rating BETWEEN 1 AND 5),
+	CHECK (status IN ('pending', 'approved', 'rejected'))
+);
+
+CREATE INDEX reviews_book_created_at ON reviews (book_id, created_at, id);
+
+INSERT INTO role_permissions (role, permission) VALUES
+	('staff', 'reviews.moderate'),
+	('admin', 'reviews.moderate');
@@ -0,0 +1,3 @@
+DROP TABLE books_tags;
+DROP TABLE books_categories;
+DROP TABLE categories;
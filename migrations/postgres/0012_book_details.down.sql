@@ -0,0 +1,11 @@
+ALTER TABLE books DROP COLUMN depth_mm;
+ALTER TABLE books DROP COLUMN width_mm;
+ALTER TABLE books DROP COLUMN height_mm;
+ALTER TABLE books DROP COLUMN edition;
+ALTER TABLE books DROP COLUMN format;
+ALTER TABLE books DROP COLUMN page_count;
+ALTER TABLE books DROP COLUMN language;
+ALTER TABLE books DROP COLUMN published_on;
+ALTER TABLE books DROP COLUMN publisher;
+ALTER TABLE books DROP COLUMN description;
+ALTER TABLE books DROP COLUMN subtitle;
@@ -0,0 +1,15 @@
+-- bibliographic details shown in the storefront; books without dimensions
+-- leave all three of them null
+ALTER TABLE books ADD COLUMN subtitle varchar(200) NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN description text NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN publisher varchar(200) NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN published_on date;
+ALTER TABLE books ADD COLUMN language varchar(2) NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN page_count integer NOT NULL DEFAULT 0
+	CHECK (page_count >= 0);
+ALTER TABLE books ADD COLUMN format varchar(16) NOT NULL DEFAULT ''
+	CHECK (format IN ('', 'hardcover', 'paperback', 'ebook', 'audiobook'));
+ALTER TABLE books ADD COLUMN edition varchar(50) NOT NULL DEFAULT '';
+ALTER TABLE books ADD COLUMN height_mm integer;
+ALTER TABLE books ADD COLUMN width_mm integer;
+ALTER TABLE books ADD COLUMN depth_mm integer;
@@ -0,0 +1,33 @@
+-- publishers are unique by name regardless of case; a book has at most one
+CREATE TABLE publishers (
+	id varchar(36) NOT NULL,
+	name varchar(200) NOT NULL,
+	website varchar(256) NOT NULL DEFAULT '',
+	created_at timestamp NOT NULL DEFAULT NOW(),
+	updated_at timestamp NOT NULL DEFAULT NOW(),
+	PRIMARY KEY(id)
+);
+
+CREATE UNIQUE INDEX publishers_name ON publishers (lower(name));
+
+CREATE TABLE books_publishers (
+	book_id varchar(36) NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+	publisher_id varchar(36) NOT NULL REFERENCES publishers (id) ON DELETE CASCADE,
+	PRIMARY KEY(book_id)
+);
+
+CREATE INDEX books_publishers_publisher ON books_publishers (publisher_id);
+
+-- the publisher names already given to books become publishers
+INSERT INTO publishers (id, name)
+SELECT md5(lower(publisher)), MIN(publisher)
+FROM books
+WHERE publisher <> ''
+GROUP BY lower(publisher);
+
+INSERT INTO books_publishers (book_id, publisher_id)
+SELECT b.id, p.id
+FROM books b, publishers p
+WHERE lower(b.publisher) = lower(p.name);
+
+ALTER TABLE books DROP COLUMN publisher;
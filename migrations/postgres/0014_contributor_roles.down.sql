@@ -0,0 +1,14 @@
+-- an author credited in several roles on a book keeps a single credit
+DELETE FROM books_authors ab
+USING books_authors other
+WHERE ab.book_id = other.book_id
+AND ab.author_id = other.author_id
+AND ab.role > other.role;
+
+DROP INDEX books_authors_author;
+
+ALTER TABLE books_authors DROP CONSTRAINT books_authors_pkey;
+ALTER TABLE books_authors ADD PRIMARY KEY (book_id, author_id);
+
+ALTER TABLE books_authors DROP COLUMN sequence;
+ALTER TABLE books_authors DROP COLUMN role;
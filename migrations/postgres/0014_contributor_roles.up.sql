@@ -0,0 +1,20 @@
+-- authors are credited on a book in a role, and may be credited in several;
+-- sequence orders a book's credits, starting at 1
+ALTER TABLE books_authors ADD COLUMN role varchar(20) NOT NULL DEFAULT 'author'
+	CHECK (role IN ('author', 'translator', 'illustrator', 'editor', 'narrator'));
+ALTER TABLE books_authors ADD COLUMN sequence integer NOT NULL DEFAULT 1;
+
+ALTER TABLE books_authors DROP CONSTRAINT books_authors_pkey;
+ALTER TABLE books_authors ADD PRIMARY KEY (book_id, author_id, role);
+
+CREATE INDEX books_authors_author ON books_authors (author_id);
+
+-- existing credits keep the order books were listed in, by author name
+UPDATE books_authors SET sequence = c.sequence
+FROM (
+	SELECT ab.book_id, ab.author_id,
+		ROW_NUMBER() OVER (PARTITION BY ab.book_id ORDER BY a.last_name, a.first_name, a.id) AS sequence
+	FROM books_authors ab, authors a
+	WHERE a.id = ab.author_id) c
+WHERE books_authors.book_id = c.book_id
+AND books_authors.author_id = c.author_id;
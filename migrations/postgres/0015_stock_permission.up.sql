@@ -0,0 +1,4 @@
+-- receiving, adjusting, reserving and releasing stock is left to staff
+INSERT INTO role_permissions (role, permission) VALUES
+	('staff', 'stock.manage'),
+	('admin', 'stock.manage');
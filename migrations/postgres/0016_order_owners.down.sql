@@ -0,0 +1,3 @@
+DELETE FROM role_permissions WHERE permission = 'orders.manage';
+DROP INDEX orders_user_created_at;
+ALTER TABLE orders DROP COLUMN user_id;
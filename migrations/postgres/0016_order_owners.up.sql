@@ -0,0 +1,9 @@
+-- orders belong to the user who placed them; those placed before orders had
+-- owners are left without one, so only staff see them
+ALTER TABLE orders ADD COLUMN user_id varchar(36) REFERENCES users (id) ON DELETE SET NULL;
+
+CREATE INDEX orders_user_created_at ON orders (user_id, created_at, id);
+
+INSERT INTO role_permissions (role, permission) VALUES
+	('staff', 'orders.manage'),
+	('admin', 'orders.manage');
@@ -0,0 +1,4 @@
+DROP TABLE books_authors;
+DROP TABLE books;
+DROP TABLE authors;
+DROP TABLE users;
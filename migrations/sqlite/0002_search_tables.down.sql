@@ -0,0 +1,9 @@
+DROP TRIGGER authors_search_delete;
+DROP TRIGGER authors_search_update;
+DROP TRIGGER authors_search_insert;
+DROP TABLE authors_search;
+
+DROP TRIGGER books_search_delete;
+DROP TRIGGER books_search_update;
+DROP TRIGGER books_search_insert;
+DROP TABLE books_search;
@@ -0,0 +1,38 @@
+-- full text search tables standing in for the postgres tsvector indexes, kept
+-- in sync with their source tables by triggers
+CREATE VIRTUAL TABLE books_search USING fts5(id UNINDEXED, title);
+
+INSERT INTO books_search (id, title) SELECT id, title FROM books;
+
+CREATE TRIGGER books_search_insert AFTER INSERT ON books BEGIN
+	INSERT INTO books_search (id, title) VALUES (new.id, new.title);
+END;
+
+CREATE TRIGGER books_search_update AFTER UPDATE ON books BEGIN
+	DELETE FROM books_search WHERE id = old.id;
+	INSERT INTO books_search (id, title) VALUES (new.id, new.title);
+END;
+
+CREATE TRIGGER books_search_delete AFTER DELETE ON books BEGIN
+	DELETE FROM books_search WHERE id = old.id;
+END;
+
+CREATE VIRTUAL TABLE authors_search USING fts5(id UNINDEXED, name);
+
+INSERT INTO authors_search (id, name)
+	SELECT id, trim(first_name || ' ' || middle_name) || ' ' || last_name FROM authors;
+
+CREATE TRIGGER authors_search_insert AFTER INSERT ON authors BEGIN
+	INSERT INTO authors_search (id, name)
+		VALUES (new.id, trim(new.first_name || ' ' || new.middle_name) || ' ' || new.last_name);
+END;
+
+CREATE TRIGGER authors_search_update AFTER UPDATE ON authors BEGIN
+	DELETE FROM authors_search WHERE id = old.id;
+	INSERT INTO authors_search (id, name)
+		VALUES (new.id, trim(new.first_name || ' ' || new.middle_name) || ' ' || new.last_name);
+END;
+
+CREATE TRIGGER authors_search_delete AFTER DELETE ON authors BEGIN
+	DELETE FROM authors_search WHERE id = old.id;
+END;
@@ -0,0 +1,18 @@
+CREATE TABLE carts (
+	token varchar(64) NOT NULL,
+	created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	expires_at timestamp NOT NULL,
+	PRIMARY KEY(token)
+);
+
+CREATE INDEX carts_expires_at ON carts (expires_at);
+
+CREATE TABLE cart_items (
+	cart_token varchar(64) NOT NULL REFERENCES carts (token) ON DELETE CASCADE,
+	book_id varchar(36) NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+	quantity integer NOT NULL,
+	added_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY(cart_token, book_id),
+	CHECK (quantity > 0 AND quantity <= 99)
+);
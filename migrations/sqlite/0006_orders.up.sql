@@ -0,0 +1,24 @@
+CREATE TABLE orders (
+	id varchar(36) NOT NULL,
+	status varchar(16) NOT NULL,
+	created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY(id)
+);
+
+CREATE INDEX orders_created_at ON orders (created_at, id);
+
+-- order lines copy what was agreed from the book rather than referencing it,
+-- so later changes to or removal of the book leave the order as it was
+CREATE TABLE order_lines (
+	order_id varchar(36) NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+	line_no integer NOT NULL,
+	book_id varchar(36) NOT NULL,
+	title varchar(200) NOT NULL,
+	isbn varchar(18) NOT NULL,
+	quantity integer NOT NULL,
+	unit_amount bigint NOT NULL,
+	currency char(3) NOT NULL,
+	PRIMARY KEY(order_id, line_no),
+	CHECK (quantity > 0 AND unit_amount >= 0)
+);
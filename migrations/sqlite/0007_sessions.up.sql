@@ -0,0 +1,12 @@
+-- only a hash of each session token is kept, so the table cannot be used to
+-- sign in as anyone
+CREATE TABLE sessions (
+	token_hash varchar(64) NOT NULL,
+	user_id varchar(36) NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	expires_at timestamp NOT NULL,
+	PRIMARY KEY(token_hash)
+);
+
+CREATE INDEX sessions_user ON sessions (user_id);
+CREATE INDEX sessions_expires_at ON sessions (expires_at);
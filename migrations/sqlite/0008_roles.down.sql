@@ -0,0 +1,2 @@
+DROP TABLE role_permissions;
+ALTER TABLE users DROP COLUMN role;
@@ -0,0 +1,29 @@
+-- categories form a tree; names are unique among a category's siblings and a
+-- category cannot be removed while it has subcategories
+CREATE TABLE categories (
+	id varchar(36) NOT NULL,
+	parent_id varchar(36) REFERENCES categories (id),
+	name varchar(100) NOT NULL,
+	created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY(id)
+);
+
+CREATE UNIQUE INDEX categories_parent_name ON categories (COALESCE(parent_id, ''), lower(name));
+
+CREATE TABLE books_categories (
+	book_id varchar(36) NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+	category_id varchar(36) NOT NULL REFERENCES categories (id) ON DELETE CASCADE,
+	PRIMARY KEY(book_id, category_id)
+);
+
+CREATE INDEX books_categories_category ON books_categories (category_id);
+
+-- tags are free-form, kept in lower case
+CREATE TABLE books_tags (
+	book_id varchar(36) NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+	tag varchar(50) NOT NULL,
+	PRIMARY KEY(book_id, tag)
+);
+
+CREATE INDEX books_tags_tag ON books_tags (tag);
@@ -0,0 +1,11 @@
+ALTER TABLE books ADD COLUMN publisher varchar(200) NOT NULL DEFAULT '';
+
+UPDATE books SET publisher = (
+	SELECT p.name
+	FROM publishers p, books_publishers bp
+	WHERE p.id = bp.publisher_id
+	AND bp.book_id = books.id)
+WHERE id IN (SELECT book_id FROM books_publishers);
+
+DROP TABLE books_publishers;
+DROP TABLE publishers;
@@ -0,0 +1,12 @@
+-- an author credited in several roles on a book keeps a single credit
+CREATE TABLE books_authors_old (
+	book_id varchar(36) NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+	author_id varchar(36) NOT NULL REFERENCES authors (id) ON DELETE CASCADE,
+	PRIMARY KEY(book_id, author_id)
+);
+
+INSERT INTO books_authors_old (book_id, author_id)
+SELECT DISTINCT book_id, author_id FROM books_authors;
+
+DROP TABLE books_authors;
+ALTER TABLE books_authors_old RENAME TO books_authors;
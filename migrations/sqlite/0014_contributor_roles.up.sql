@@ -0,0 +1,23 @@
+-- authors are credited on a book in a role, and may be credited in several;
+-- sequence orders a book's credits, starting at 1. sqlite cannot change a
+-- primary key, so the table is rebuilt.
+CREATE TABLE books_authors_new (
+	book_id varchar(36) NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+	author_id varchar(36) NOT NULL REFERENCES authors (id) ON DELETE CASCADE,
+	role varchar(20) NOT NULL DEFAULT 'author'
+		CHECK (role IN ('author', 'translator', 'illustrator', 'editor', 'narrator')),
+	sequence integer NOT NULL DEFAULT 1,
+	PRIMARY KEY(book_id, author_id, role)
+);
+
+-- existing credits keep the order books were listed in, by author name
+INSERT INTO books_authors_new (book_id, author_id, role, sequence)
+SELECT ab.book_id, ab.author_id, 'author',
+	ROW_NUMBER() OVER (PARTITION BY ab.book_id ORDER BY a.last_name, a.first_name, a.id)
+FROM books_authors ab, authors a
+WHERE a.id = ab.author_id;
+
+DROP TABLE books_authors;
+ALTER TABLE books_authors_new RENAME TO books_authors;
+
+CREATE INDEX books_authors_author ON books_authors (author_id);
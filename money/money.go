@@ -0,0 +1,184 @@
+// Package money holds amounts of money as whole numbers of a currency's minor
+// unit, so that prices add up exactly.
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Currency is an ISO 4217 currency code.
+type Currency string
+
+// The currencies the shop trades in.
+const (
+	USD Currency = "USD"
+	EUR Currency = "EUR"
+	GBP Currency = "GBP"
+)
+
+type currencyInfo struct {
+	symbol string
+	digits int
+}
+
+var currencies = map[Currency]currencyInfo{
+	USD: {symbol: "$", digits: 2},
+	EUR: {symbol: "€", digits: 2},
+	GBP: {symbol: "£", digits: 2},
+}
+
+var (
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrCurrencyMismatch = errors.New("currencies do not match")
+	ErrOverflow         = errors.New("amount out of range")
+)
+
+// ParseCurrency returns the currency with the given code, ignoring case.
+func ParseCurrency(code string) (Currency, error) {
+	c := Currency(strings.ToUpper(strings.TrimSpace(code)))
+	if _, ok := currencies[c]; !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownCurrency, code)
+	}
+	return c, nil
+}
+
+// Digits returns the number of decimal places of the currency's minor unit.
+func (c Currency) Digits() int {
+	return currencies[c].digits
+}
+
+// Money is an amount of a currency counted in its minor unit, e.g. cents.
+type Money struct {
+	Amount   int64    `json:"amount"`
+	Currency Currency `json:"currency"`
+}
+
+// New returns the given number of minor units of the currency.
+func New(amount int64, c Currency) Money {
+	return Money{Amount: amount, Currency: c}
+}
+
+// Parse reads a decimal amount such as "12.99" of the given currency. Amounts
+// with more decimal places than the currency's minor unit are rejected rather
+// than rounded.
+func Parse(s string, c Currency) (Money, error) {
+	info, ok := currencies[c]
+	if !ok {
+		return Money{}, fmt.Errorf("%w %q", ErrUnknownCurrency, c)
+	}
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	whole, frac := strings.TrimPrefix(s, "-"), ""
+	if i := strings.IndexByte(whole, '.'); i >= 0 {
+		whole, frac = whole[:i], whole[i+1:]
+	}
+	if whole == "" || len(frac) > info.digits || strings.ContainsAny(whole+frac, "+-") {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+	frac += strings.Repeat("0", info.digits-len(frac))
+	amount, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+	if neg {
+		amount = -amount
+	}
+	return Money{Amount: amount, Currency: c}, nil
+}
+
+// Validate checks the currency is one the shop trades in.
+func (m Money) Validate() error {
+	if _, ok := currencies[m.Currency]; !ok {
+		return fmt.Errorf("%w %q", ErrUnknownCurrency, m.Currency)
+	}
+	return nil
+}
+
+// IsNegative reports whether the amount is below zero.
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Add returns the sum of two amounts of the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+	}
+	if (o.Amount > 0 && m.Amount > math.MaxInt64-o.Amount) || (o.Amount < 0 && m.Amount < math.MinInt64-o.Amount) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+// Sub returns the difference of two amounts of the same currency.
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Amount == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+	return m.Add(Money{Amount: -o.Amount, Currency: o.Currency})
+}
+
+// Mul returns the amount multiplied by n, such as the price of n copies.
+func (m Money) Mul(n int64) (Money, error) {
+	if n == 0 {
+		return Money{Currency: m.Currency}, nil
+	}
+	p := m.Amount * n
+	if p/n != m.Amount || (n == -1 && m.Amount == math.MinInt64) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Amount: p, Currency: m.Currency}, nil
+}
+
+// String formats the amount with the currency's symbol and thousands
+// separators, e.g. "$1,234.50".
+func (m Money) String() string {
+	info, ok := currencies[m.Currency]
+	if !ok {
+		return fmt.Sprintf("%d %s", m.Amount, m.Currency)
+	}
+
+	sign, amount := "", uint64(m.Amount)
+	if m.Amount < 0 {
+		sign, amount = "-", uint64(-m.Amount)
+	}
+	unit := uint64(math.Pow10(info.digits))
+	whole := strconv.FormatUint(amount/unit, 10)
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	if info.digits == 0 {
+		return sign + info.symbol + whole
+	}
+	return fmt.Sprintf("%s%s%s.%0*d", sign, info.symbol, whole, info.digits, amount%unit)
+}
+
+// MarshalJSON adds the formatted amount to the JSON form of the money.
+func (m Money) MarshalJSON() ([]byte, error) {
+	type plain Money
+	return json.Marshal(struct {
+		plain
+		Formatted string `json:"formatted"`
+	}{plain(m), m.String()})
+}
+
+// UnmarshalJSON reads the amount and currency of the money, accepting any case
+// for the currency code.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	type plain Money
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	c, err := ParseCurrency(string(p.Currency))
+	if err != nil {
+		return err
+	}
+	*m = Money{Amount: p.Amount, Currency: c}
+	return nil
+}
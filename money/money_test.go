@@ -0,0 +1,91 @@
+package money_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	"bookshop/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoney(t *testing.T) {
+	t.Run("Parse", func(t *testing.T) {
+		for in, want := range map[string]int64{
+			"12.99": 1299,
+			"12.9":  1290,
+			"12":    1200,
+			"0.05":  5,
+			"-3.50": -350,
+		} {
+			m, err := money.Parse(in, money.USD)
+			require.NoError(t, err, in)
+			assert.Equal(t, money.New(want, money.USD), m, in)
+		}
+
+		for _, in := range []string{"", "12.999", "abc", "1.2.3", ".5", "--1", "1.-5"} {
+			_, err := money.Parse(in, money.USD)
+			assert.Error(t, err, in)
+		}
+
+		_, err := money.Parse("1.00", "XYZ")
+		assert.True(t, errors.Is(err, money.ErrUnknownCurrency))
+	})
+
+	t.Run("ParseCurrency", func(t *testing.T) {
+		c, err := money.ParseCurrency(" gbp")
+		require.NoError(t, err)
+		assert.Equal(t, money.GBP, c)
+
+		_, err = money.ParseCurrency("JPY")
+		assert.True(t, errors.Is(err, money.ErrUnknownCurrency))
+	})
+
+	t.Run("arithmetic", func(t *testing.T) {
+		sum, err := money.New(1299, money.EUR).Add(money.New(1, money.EUR))
+		require.NoError(t, err)
+		assert.Equal(t, money.New(1300, money.EUR), sum)
+
+		diff, err := money.New(100, money.EUR).Sub(money.New(250, money.EUR))
+		require.NoError(t, err)
+		assert.Equal(t, money.New(-150, money.EUR), diff)
+		assert.True(t, diff.IsNegative())
+
+		prod, err := money.New(1299, money.EUR).Mul(3)
+		require.NoError(t, err)
+		assert.Equal(t, money.New(3897, money.EUR), prod)
+
+		_, err = money.New(1, money.EUR).Add(money.New(1, money.USD))
+		assert.True(t, errors.Is(err, money.ErrCurrencyMismatch))
+
+		_, err = money.New(math.MaxInt64, money.EUR).Add(money.New(1, money.EUR))
+		assert.True(t, errors.Is(err, money.ErrOverflow))
+		_, err = money.New(math.MaxInt64/2+1, money.EUR).Mul(2)
+		assert.True(t, errors.Is(err, money.ErrOverflow))
+		_, err = money.New(math.MinInt64, money.EUR).Mul(-1)
+		assert.True(t, errors.Is(err, money.ErrOverflow))
+	})
+
+	t.Run("String", func(t *testing.T) {
+		assert.Equal(t, "$12.99", money.New(1299, money.USD).String())
+		assert.Equal(t, "€1,234,567.05", money.New(123456705, money.EUR).String())
+		assert.Equal(t, "-£0.50", money.New(-50, money.GBP).String())
+		assert.Equal(t, "$0.00", money.New(0, money.USD).String())
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		data, err := json.Marshal(money.New(1299, money.USD))
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"amount": 1299, "currency": "USD", "formatted": "$12.99"}`, string(data))
+
+		var m money.Money
+		require.NoError(t, json.Unmarshal([]byte(`{"amount": 500, "currency": "eur"}`), &m))
+		assert.Equal(t, money.New(500, money.EUR), m)
+
+		assert.Error(t, json.Unmarshal([]byte(`{"amount": 5.5, "currency": "EUR"}`), &m))
+		assert.Error(t, json.Unmarshal([]byte(`{"amount": 5, "currency": "XYZ"}`), &m))
+	})
+}
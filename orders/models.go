@@ -0,0 +1,107 @@
+package orders
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"bookshop/books"
+	"bookshop/money"
+)
+
+// Status is the stage an order has reached.
+type Status string
+
+const (
+	Pending   Status = "pending"
+	Paid      Status = "paid"
+	Shipped   Status = "shipped"
+	Delivered Status = "delivered"
+	Cancelled Status = "cancelled"
+	Refunded  Status = "refunded"
+)
+
+// transitions lists the statuses each status may move on to. Cancelled and
+// refunded orders are final.
+var transitions = map[Status][]Status{
+	Pending:   {Paid, Cancelled},
+	Paid:      {Shipped, Refunded},
+	Shipped:   {Delivered},
+	Delivered: {Refunded},
+}
+
+// ParseStatus returns the status with the given name, ignoring case.
+func ParseStatus(s string) (Status, error) {
+	st := Status(strings.ToLower(strings.TrimSpace(s)))
+	switch st {
+	case Pending, Paid, Shipped, Delivered, Cancelled, Refunded:
+		return st, nil
+	}
+	return "", fmt.Errorf("unknown order status %q", s)
+}
+
+// CanBecome reports whether an order may move from the status to next.
+func (s Status) CanBecome(next Status) bool {
+	for _, st := range transitions[s] {
+		if st == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Order is the model representing a row in the orders table along with its
+// lines. Totals are worked out from the lines rather than stored.
+type Order struct {
+	ID     string `db:"id" json:"id"`
+	Status Status `db:"status" json:"status"`
+	// UserID is the user who placed the order, blank for orders placed before
+	// orders had owners.
+	UserID    string     `db:"-" json:"user_id,omitempty"`
+	CreatedAt *time.Time `db:"created_at" json:"created_at,omitempty"`
+	UpdatedAt *time.Time `db:"updated_at" json:"updated_at,omitempty"`
+
+	Lines []Line `json:"lines"`
+	// Totals holds the sum of the lines in each currency.
+	Totals []money.Money `json:"totals"`
+}
+
+// Line is the model representing a book in an order. The title, ISBN and unit
+// price are copied from the book when the order is placed and never change.
+type Line struct {
+	BookID    string      `json:"book_id"`
+	Title     string      `json:"title"`
+	ISBN      books.ISBN  `json:"isbn"`
+	Quantity  int         `json:"quantity"`
+	UnitPrice money.Money `json:"unit_price"`
+	LineTotal money.Money `json:"line_total"`
+}
+
+// WithTotals returns the order with its line totals and the order totals per
+// currency filled in.
+func (o Order) WithTotals() (Order, error) {
+	o.Lines = append([]Line{}, o.Lines...)
+	totals := map[money.Currency]money.Money{}
+	for i, line := range o.Lines {
+		total, err := line.UnitPrice.Mul(int64(line.Quantity))
+		if err != nil {
+			return Order{}, err
+		}
+		sum, err := total.Add(money.New(totals[total.Currency].Amount, total.Currency))
+		if err != nil {
+			return Order{}, err
+		}
+		totals[total.Currency] = sum
+		o.Lines[i].LineTotal = total
+	}
+
+	o.Totals = []money.Money{}
+	for _, t := range totals {
+		o.Totals = append(o.Totals, t)
+	}
+	sort.Slice(o.Totals, func(i, j int) bool {
+		return o.Totals[i].Currency < o.Totals[j].Currency
+	})
+	return o, nil
+}
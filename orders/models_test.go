@@ -0,0 +1,55 @@
+package orders_test
+
+import (
+	"testing"
+
+	"bookshop/money"
+	"bookshop/orders"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatus(t *testing.T) {
+	t.Run("CanBecome", func(t *testing.T) {
+		allowed := map[orders.Status][]orders.Status{
+			orders.Pending:   {orders.Paid, orders.Cancelled},
+			orders.Paid:      {orders.Shipped, orders.Refunded},
+			orders.Shipped:   {orders.Delivered},
+			orders.Delivered: {orders.Refunded},
+		}
+		all := []orders.Status{orders.Pending, orders.Paid, orders.Shipped, orders.Delivered, orders.Cancelled, orders.Refunded}
+		for _, from := range all {
+			for _, to := range all {
+				want := false
+				for _, st := range allowed[from] {
+					want = want || st == to
+				}
+				assert.Equal(t, want, from.CanBecome(to), "%s to %s", from, to)
+			}
+		}
+	})
+
+	t.Run("ParseStatus", func(t *testing.T) {
+		st, err := orders.ParseStatus(" Shipped ")
+		require.NoError(t, err)
+		assert.Equal(t, orders.Shipped, st)
+
+		_, err = orders.ParseStatus("lost")
+		assert.Error(t, err)
+	})
+}
+
+func TestOrderWithTotals(t *testing.T) {
+	o := orders.Order{Lines: []orders.Line{
+		{BookID: "abc01", Quantity: 2, UnitPrice: money.New(1299, money.USD)},
+		{BookID: "def02", Quantity: 1, UnitPrice: money.New(850, money.GBP)},
+		{BookID: "ghi03", Quantity: 3, UnitPrice: money.New(100, money.USD)},
+	}}
+
+	got, err := o.WithTotals()
+	require.NoError(t, err)
+	assert.Equal(t, money.New(2598, money.USD), got.Lines[0].LineTotal)
+	assert.Equal(t, []money.Money{money.New(850, money.GBP), money.New(2898, money.USD)}, got.Totals)
+	assert.Empty(t, o.Lines[0].LineTotal.Amount, "the original order is left alone")
+}
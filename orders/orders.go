@@ -0,0 +1,203 @@
+package orders
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"bookshop/books"
+	"bookshop/datastore"
+	"bookshop/money"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type OrderStore struct {
+	db *sqlx.DB
+}
+
+func NewOrderStore(db *sqlx.DB) OrderStore {
+	return OrderStore{db: db}
+}
+
+// orderRow is an orders row, whose user is null for orders placed before
+// orders had owners.
+type orderRow struct {
+	Order
+	UserID sql.NullString `db:"user_id"`
+}
+
+func (r orderRow) order() Order {
+	o := r.Order
+	o.UserID = r.UserID.String
+	return o
+}
+
+// lineRow is an order_lines row.
+type lineRow struct {
+	OrderID    string `db:"order_id"`
+	LineNo     int    `db:"line_no"`
+	BookID     string `db:"book_id"`
+	Title      string `db:"title"`
+	ISBN       string `db:"isbn"`
+	Quantity   int    `db:"quantity"`
+	UnitAmount int64  `db:"unit_amount"`
+	Currency   string `db:"currency"`
+}
+
+func (r lineRow) line() Line {
+	return Line{
+		BookID:    r.BookID,
+		Title:     r.Title,
+		ISBN:      books.ISBN(r.ISBN),
+		Quantity:  r.Quantity,
+		UnitPrice: money.New(r.UnitAmount, money.Currency(r.Currency)),
+	}
+}
+
+// CreateOrder will add the order along with its lines, returning
+// datastore.ErrDuplicate if its ID is already in use and
+// datastore.ErrMissingReference if its user does not exist.
+func (s *OrderStore) CreateOrder(ctx context.Context, o Order) error {
+	if len(o.Lines) == 0 {
+		return errors.New("no order lines to create")
+	}
+	tx, err := datastore.Begin(ctx, s.db)
+	if err != nil {
+		return errors.Wrap(err, "failed creating order")
+	}
+
+	const sqlOrder = `INSERT INTO orders (id, status, user_id, created_at, updated_at) VALUES (?,?,?,?,?)`
+	now := time.Now()
+	userID := sql.NullString{String: o.UserID, Valid: o.UserID != ""}
+	if _, err := tx.ExecContext(ctx, s.db.Rebind(sqlOrder), o.ID, o.Status, userID, now, now); err != nil {
+		tx.Rollback()
+		if datastore.IsUniqueViolation(err) {
+			return datastore.ErrDuplicate
+		}
+		if datastore.IsForeignKeyViolation(err) {
+			return datastore.ErrMissingReference
+		}
+		return errors.Wrap(err, "failed creating order")
+	}
+
+	const sqlLinesPre = `INSERT INTO order_lines (order_id, line_no, book_id, title, isbn, quantity, unit_amount, currency) VALUES `
+	const sqlValues = `(?,?,?,?,?,?,?,?)`
+	var qryRows []string
+	var qryArgs []interface{}
+	for i, l := range o.Lines {
+		qryRows = append(qryRows, sqlValues)
+		qryArgs = append(qryArgs, o.ID, i+1, l.BookID, l.Title, string(l.ISBN), l.Quantity, l.UnitPrice.Amount, string(l.UnitPrice.Currency))
+	}
+	if _, err := tx.ExecContext(ctx, s.db.Rebind(sqlLinesPre+strings.Join(qryRows, ",")), qryArgs...); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed creating order lines")
+	}
+	return tx.Commit()
+}
+
+// ReadOrder will return the order with the given ID along with its lines,
+// returning datastore.ErrNotFound if it does not exist.
+func (s *OrderStore) ReadOrder(ctx context.Context, id string) (Order, error) {
+	row := orderRow{}
+	if err := datastore.Conn(ctx, s.db).GetContext(ctx, &row, s.db.Rebind("SELECT * FROM orders WHERE id=?"), id); err != nil {
+		if err == sql.ErrNoRows {
+			return Order{}, datastore.ErrNotFound
+		}
+		return Order{}, errors.Wrap(err, "failed to read order")
+	}
+	ords := []Order{row.order()}
+	if err := s.readLines(ctx, ords); err != nil {
+		return Order{}, err
+	}
+	return ords[0], nil
+}
+
+// ReadOrders will return a page of orders matching the query, newest first.
+func (s *OrderStore) ReadOrders(ctx context.Context, q OrderQuery) (Page, error) {
+	q = q.WithDefaults()
+
+	var where []string
+	var args []interface{}
+	if q.Status != "" {
+		where = append(where, "o.status = ?")
+		args = append(args, q.Status)
+	}
+	if q.UserID != "" {
+		where = append(where, "o.user_id = ?")
+		args = append(args, q.UserID)
+	}
+	col := datastore.Timestamp(s.db, "o.created_at")
+	if q.Cursor != "" {
+		c, err := q.DecodeCursor()
+		if err != nil {
+			return Page{}, err
+		}
+		where = append(where, "("+col+", o.id) < ("+datastore.CursorTimestamp(s.db)+", ?)")
+		args = append(args, c.Value, c.ID)
+	}
+
+	qry := "SELECT o.* FROM orders o"
+	if len(where) > 0 {
+		qry += " WHERE " + strings.Join(where, " AND ")
+	}
+	qry += " ORDER BY " + col + " DESC, o.id DESC LIMIT ?"
+	args = append(args, q.Limit+1)
+
+	rows := []orderRow{}
+	if err := datastore.Conn(ctx, s.db).SelectContext(ctx, &rows, s.db.Rebind(qry), args...); err != nil {
+		return Page{}, errors.Wrap(err, "failed to read orders")
+	}
+	ords := make([]Order, len(rows))
+	for i, r := range rows {
+		ords[i] = r.order()
+	}
+	page := NewPage(ords, q)
+	if err := s.readLines(ctx, page.Orders); err != nil {
+		return Page{}, err
+	}
+	return page, nil
+}
+
+// readLines fills in the lines of the given orders.
+func (s *OrderStore) readLines(ctx context.Context, ords []Order) error {
+	if len(ords) == 0 {
+		return nil
+	}
+	idx := map[string]int{}
+	var ids []string
+	for i, o := range ords {
+		idx[o.ID] = i
+		ids = append(ids, o.ID)
+		ords[i].Lines = []Line{}
+	}
+	qry, args, err := sqlx.In("SELECT * FROM order_lines WHERE order_id IN (?) ORDER BY order_id, line_no", ids)
+	if err != nil {
+		return errors.Wrap(err, "failed to read order lines")
+	}
+	rows := []lineRow{}
+	if err := datastore.Conn(ctx, s.db).SelectContext(ctx, &rows, s.db.Rebind(qry), args...); err != nil {
+		return errors.Wrap(err, "failed to read order lines")
+	}
+	for _, r := range rows {
+		i := idx[r.OrderID]
+		ords[i].Lines = append(ords[i].Lines, r.line())
+	}
+	return nil
+}
+
+// UpdateOrderStatus will move the order from one status to another, returning
+// datastore.ErrNotFound if there is no order with the ID in the from status.
+func (s *OrderStore) UpdateOrderStatus(ctx context.Context, id string, from, to Status) error {
+	const sqlSt = `UPDATE orders SET status = ?, updated_at = ? WHERE id = ? AND status = ?`
+	res, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind(sqlSt), to, time.Now(), id, from)
+	if err != nil {
+		return errors.Wrap(err, "failed updating order status")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return datastore.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package orders_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bookshop/books"
+	"bookshop/datastore"
+	"bookshop/datastore/dbtest"
+	"bookshop/money"
+	"bookshop/orders"
+	"bookshop/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrders(t *testing.T) {
+	ctx := context.Background()
+	newStores := func(t *testing.T) (orders.OrderStore, books.BookStore) {
+		dbh := dbtest.Open(t)
+
+		bs := books.NewBookStore(dbh)
+		err := bs.UpsertBooks(ctx, []books.Book{{ID: "abc01", Title: "Dune", ISBN: "9781861972712"}})
+		require.NoError(t, err)
+		us := users.NewUserStore(dbh)
+		err = us.CreateUser(ctx, users.User{ID: "usr01", Email: "ann@example.com", Enabled: true})
+		require.NoError(t, err)
+		return orders.NewOrderStore(dbh), bs
+	}
+	line := orders.Line{BookID: "abc01", Title: "Dune", ISBN: "9781861972712", Quantity: 2, UnitPrice: money.New(1299, money.USD)}
+
+	t.Run("CreateOrder", func(t *testing.T) {
+		store, _ := newStores(t)
+
+		other := orders.Line{BookID: "def02", Title: "Emma", ISBN: "9780141439587", Quantity: 1, UnitPrice: money.New(850, money.GBP)}
+		err := store.CreateOrder(ctx, orders.Order{ID: "ord01", Status: orders.Pending, Lines: []orders.Line{line, other}})
+		require.NoError(t, err)
+
+		o, err := store.ReadOrder(ctx, "ord01")
+		require.NoError(t, err)
+		assert.Equal(t, orders.Pending, o.Status)
+		assert.NotNil(t, o.CreatedAt)
+		assert.Equal(t, []orders.Line{line, other}, o.Lines)
+
+		err = store.CreateOrder(ctx, orders.Order{ID: "ord01", Status: orders.Pending, Lines: []orders.Line{line}})
+		assert.True(t, errors.Is(err, datastore.ErrDuplicate))
+
+		_, err = store.ReadOrder(ctx, "unknown")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("owner", func(t *testing.T) {
+		store, _ := newStores(t)
+		require.NoError(t, store.CreateOrder(ctx, orders.Order{ID: "ord01", Status: orders.Pending, UserID: "usr01", Lines: []orders.Line{line}}))
+
+		o, err := store.ReadOrder(ctx, "ord01")
+		require.NoError(t, err)
+		assert.Equal(t, "usr01", o.UserID)
+
+		err = store.CreateOrder(ctx, orders.Order{ID: "ord02", Status: orders.Pending, UserID: "usr99", Lines: []orders.Line{line}})
+		assert.True(t, errors.Is(err, datastore.ErrMissingReference))
+	})
+
+	t.Run("snapshot outlives the book", func(t *testing.T) {
+		store, bs := newStores(t)
+		require.NoError(t, store.CreateOrder(ctx, orders.Order{ID: "ord01", Status: orders.Pending, Lines: []orders.Line{line}}))
+
+		require.NoError(t, bs.UpsertBooks(ctx, []books.Book{{ID: "abc01", Title: "Dune Messiah", ISBN: "9781861972712"}}))
+		require.NoError(t, bs.DeleteBooks(ctx, "abc01"))
+
+		o, err := store.ReadOrder(ctx, "ord01")
+		require.NoError(t, err)
+		assert.Equal(t, []orders.Line{line}, o.Lines)
+	})
+
+	t.Run("UpdateOrderStatus", func(t *testing.T) {
+		store, _ := newStores(t)
+		require.NoError(t, store.CreateOrder(ctx, orders.Order{ID: "ord01", Status: orders.Pending, Lines: []orders.Line{line}}))
+
+		require.NoError(t, store.UpdateOrderStatus(ctx, "ord01", orders.Pending, orders.Paid))
+		o, err := store.ReadOrder(ctx, "ord01")
+		require.NoError(t, err)
+		assert.Equal(t, orders.Paid, o.Status)
+
+		err = store.UpdateOrderStatus(ctx, "ord01", orders.Pending, orders.Cancelled)
+		assert.True(t, errors.Is(err, datastore.ErrNotFound), "the order is no longer pending")
+		err = store.UpdateOrderStatus(ctx, "unknown", orders.Pending, orders.Paid)
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("ReadOrders", func(t *testing.T) {
+		store, _ := newStores(t)
+		for _, id := range []string{"ord01", "ord02", "ord03"} {
+			require.NoError(t, store.CreateOrder(ctx, orders.Order{ID: id, Status: orders.Pending, Lines: []orders.Line{line}}))
+		}
+		require.NoError(t, store.UpdateOrderStatus(ctx, "ord02", orders.Pending, orders.Cancelled))
+
+		var ids []string
+		q := orders.OrderQuery{Limit: 2}
+		for {
+			page, err := store.ReadOrders(ctx, q)
+			require.NoError(t, err)
+			for _, o := range page.Orders {
+				assert.Equal(t, []orders.Line{line}, o.Lines)
+				ids = append(ids, o.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+		assert.Equal(t, []string{"ord03", "ord02", "ord01"}, ids)
+
+		page, err := store.ReadOrders(ctx, orders.OrderQuery{Status: orders.Cancelled})
+		require.NoError(t, err)
+		require.Len(t, page.Orders, 1)
+		assert.Equal(t, "ord02", page.Orders[0].ID)
+
+		require.NoError(t, store.CreateOrder(ctx, orders.Order{ID: "ord04", Status: orders.Pending, UserID: "usr01", Lines: []orders.Line{line}}))
+		page, err = store.ReadOrders(ctx, orders.OrderQuery{UserID: "usr01"})
+		require.NoError(t, err)
+		require.Len(t, page.Orders, 1)
+		assert.Equal(t, "ord04", page.Orders[0].ID)
+		assert.Equal(t, "usr01", page.Orders[0].UserID)
+	})
+}
@@ -0,0 +1,89 @@
+package orders
+
+import (
+	"fmt"
+	"time"
+
+	"bookshop/datastore"
+)
+
+// cursorSort is the sort order named in order listing cursors. Orders are
+// always listed newest first.
+const cursorSort = "created_at"
+
+// OrderQuery holds the filters and page position for listing orders, newest
+// first. The zero value lists the first page of all orders.
+type OrderQuery struct {
+	// Status limits results to orders with the given status.
+	Status Status
+	// UserID limits results to orders placed by the given user.
+	UserID string
+
+	// Limit is the page size, defaulting to datastore.DefaultLimit.
+	Limit int
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+}
+
+// Page is a single page of orders along with the cursor for the following page.
+// NextCursor is blank on the last page.
+type Page struct {
+	Orders     []Order `json:"orders"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// WithDefaults returns a copy of the query with the default limit filled in.
+func (q OrderQuery) WithDefaults() OrderQuery {
+	if q.Limit == 0 {
+		q.Limit = datastore.DefaultLimit
+	}
+	return q
+}
+
+// Validate checks the query for unknown statuses, out of range limits and
+// cursors that were not issued for order listings.
+func (q OrderQuery) Validate() error {
+	q = q.WithDefaults()
+	if q.Status != "" {
+		if _, err := ParseStatus(string(q.Status)); err != nil {
+			return err
+		}
+	}
+	if q.Limit < 1 || q.Limit > datastore.MaxLimit {
+		return fmt.Errorf("limit must be between 1 and %d", datastore.MaxLimit)
+	}
+	if q.Cursor != "" {
+		if _, err := datastore.DecodeCursor(q.Cursor, cursorSort, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeCursor returns the position in the listing held by the query's cursor.
+func (q OrderQuery) DecodeCursor() (datastore.Cursor, error) {
+	return datastore.DecodeCursor(q.Cursor, cursorSort, true)
+}
+
+// NewPage trims the extra order fetched to detect a following page and builds
+// the cursor pointing at it.
+func NewPage(ords []Order, q OrderQuery) Page {
+	if len(ords) <= q.Limit {
+		return Page{Orders: ords}
+	}
+	ords = ords[:q.Limit]
+	last := ords[len(ords)-1]
+	value := ""
+	if last.CreatedAt != nil {
+		value = last.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return Page{
+		Orders: ords,
+		NextCursor: datastore.Cursor{
+			Sort:  cursorSort,
+			Desc:  true,
+			Value: value,
+			ID:    last.ID,
+		}.Encode(),
+	}
+}
@@ -0,0 +1,44 @@
+package publishers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"bookshop/books"
+)
+
+// MaxNameLength is the most characters a publisher's name may have.
+const MaxNameLength = 200
+
+// Publisher is the model representing a publisher row in the datastore.
+type Publisher struct {
+	ID        string     `db:"id" json:"id,omitempty"`
+	Name      string     `db:"name" json:"name,omitempty"`
+	Website   string     `db:"website" json:"website,omitempty"`
+	CreatedAt *time.Time `db:"created_at" json:"created_at,omitempty"`
+	UpdatedAt *time.Time `db:"updated_at" json:"updated_at,omitempty"`
+
+	Books []books.Book `db:"-" json:"books,omitempty"`
+}
+
+// Validate checks the publisher has a name that is not too long and that its
+// website, if it has one, is an http or https URL.
+func (p Publisher) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return errors.New("publisher name cannot be blank")
+	}
+	if utf8.RuneCountInString(p.Name) > MaxNameLength {
+		return fmt.Errorf("publisher name must be at most %d characters long", MaxNameLength)
+	}
+	if p.Website != "" {
+		u, err := url.Parse(p.Website)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("publisher website must be an http or https URL")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package publishers
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"bookshop/datastore"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type PublisherStore struct {
+	db *sqlx.DB
+}
+
+func NewPublisherStore(db *sqlx.DB) PublisherStore {
+	return PublisherStore{db: db}
+}
+
+// ReadPublishers will return a page of publishers matching the query.
+func (s *PublisherStore) ReadPublishers(ctx context.Context, q PublisherQuery) (Page, error) {
+	q = q.WithDefaults()
+
+	var where []string
+	var args []interface{}
+	if q.NamePrefix != "" {
+		where = append(where, `lower(p.name) LIKE lower(?) ESCAPE '\'`)
+		args = append(args, datastore.EscapeLike(q.NamePrefix)+"%")
+	}
+	if q.Cursor != "" {
+		c, err := datastore.DecodeCursor(q.Cursor, SortByName, false)
+		if err != nil {
+			return Page{}, err
+		}
+		where = append(where, `(p.name, p.id) > (?, ?)`)
+		args = append(args, c.Value, c.ID)
+	}
+
+	qry := "SELECT p.* FROM publishers p"
+	if len(where) > 0 {
+		qry += " WHERE " + strings.Join(where, " AND ")
+	}
+	qry += " ORDER BY p.name ASC, p.id ASC LIMIT ?"
+	args = append(args, q.Limit+1)
+
+	pubs := []Publisher{}
+	if err := datastore.Conn(ctx, s.db).SelectContext(ctx, &pubs, s.db.Rebind(qry), args...); err != nil {
+		return Page{}, errors.Wrap(err, "failed to read publishers")
+	}
+	return newPage(pubs, q), nil
+}
+
+// newPage trims the extra row fetched to detect a following page and builds
+// the cursor pointing at it.
+func newPage(pubs []Publisher, q PublisherQuery) Page {
+	if len(pubs) <= q.Limit {
+		return Page{Publishers: pubs}
+	}
+	pubs = pubs[:q.Limit]
+	last := pubs[len(pubs)-1]
+	return Page{
+		Publishers: pubs,
+		NextCursor: datastore.Cursor{
+			Sort:  SortByName,
+			Value: last.Name,
+			ID:    last.ID,
+		}.Encode(),
+	}
+}
+
+// ReadPublisherAndBooks will return the given publisher looked up by ID along
+// with the books they publish, returning datastore.ErrNotFound if it does not
+// exist.
+func (s *PublisherStore) ReadPublisherAndBooks(ctx context.Context, id string) (Publisher, error) {
+	pub := Publisher{}
+	if err := datastore.Conn(ctx, s.db).GetContext(ctx, &pub, s.db.Rebind("SELECT * FROM publishers WHERE id=?"), id); err != nil {
+		if err == sql.ErrNoRows {
+			return Publisher{}, datastore.ErrNotFound
+		}
+		return Publisher{}, errors.Wrap(err, "failed to read publisher")
+	}
+
+	sqlSt :=
+		`SELECT b.id, b.title, b.isbn
+		FROM books b, books_publishers bp
+		WHERE b.id = bp.book_id
+		AND bp.publisher_id = ?
+		ORDER BY b.title ASC, b.id ASC`
+	if err := datastore.Conn(ctx, s.db).SelectContext(ctx, &pub.Books, s.db.Rebind(sqlSt), id); err != nil {
+		return Publisher{}, errors.Wrap(err, "failed to read publisher books")
+	}
+	return pub, nil
+}
+
+// DeletePublisher will delete a publisher by their ID, leaving their books
+// without a publisher, returning datastore.ErrNotFound if it does not exist.
+func (s *PublisherStore) DeletePublisher(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("no id submitted to delete")
+	}
+	res, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind("DELETE FROM publishers WHERE id = ?"), id)
+	if err != nil {
+		return errors.Wrap(err, "failed deleting publisher")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return datastore.ErrNotFound
+	}
+	return nil
+}
+
+// UpsertPublishers will modify or add the publishers in the given list,
+// returning datastore.ErrDuplicate if any of them shares a name with another
+// publisher.
+func (s *PublisherStore) UpsertPublishers(ctx context.Context, pubs []Publisher) error {
+	if len(pubs) == 0 {
+		return errors.New("no publishers to upsert")
+	}
+	const sqlSetPre = `INSERT INTO publishers (id, name, website, updated_at) VALUES `
+
+	const sqlSetPost = ` ON CONFLICT(id) DO
+	UPDATE SET
+		name = EXCLUDED.name,
+		website = EXCLUDED.website,
+		updated_at = EXCLUDED.updated_at`
+	const sqlValues = `(?,?,?,?)`
+
+	var qryRows []string
+	var qryArgs []interface{}
+	for _, p := range pubs {
+		qryRows = append(qryRows, sqlValues)
+		qryArgs = append(qryArgs, p.ID, p.Name, p.Website, time.Now())
+	}
+	qry := sqlSetPre + strings.Join(qryRows, ",") + sqlSetPost
+
+	if _, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind(qry), qryArgs...); err != nil {
+		// the name index is the only constraint besides the primary key that
+		// the upsert can trip over
+		if datastore.IsUniqueViolation(err) {
+			return datastore.ErrDuplicate
+		}
+		return errors.Wrap(err, "failed upserting publishers")
+	}
+	return nil
+}
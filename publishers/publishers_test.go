@@ -0,0 +1,109 @@
+package publishers_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bookshop/books"
+	"bookshop/datastore"
+	"bookshop/datastore/dbtest"
+	"bookshop/publishers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishers(t *testing.T) {
+	ctx := context.Background()
+	newStores := func(t *testing.T) (publishers.PublisherStore, books.BookStore) {
+		dbh := dbtest.Open(t)
+
+		ps, bs := publishers.NewPublisherStore(dbh), books.NewBookStore(dbh)
+		err := ps.UpsertPublishers(ctx, []publishers.Publisher{
+			{ID: "pub01", Name: "Chilton Books"},
+			{ID: "pub02", Name: "Ace Books", Website: "https://ace.example.com"},
+			{ID: "pub03", Name: "Penguin Classics"},
+		})
+		require.NoError(t, err)
+		err = bs.UpsertBooks(ctx, []books.Book{
+			{ID: "abc01", Title: "Dune", ISBN: "9781861972712", Details: books.Details{PublisherID: "pub01"}},
+			{ID: "def02", Title: "Dune Messiah", ISBN: "9780306406157", Details: books.Details{PublisherID: "pub02"}},
+			{ID: "ghi03", Title: "Children of Dune", ISBN: "9783161484100", Details: books.Details{PublisherID: "pub02"}},
+		})
+		require.NoError(t, err)
+		return ps, bs
+	}
+
+	t.Run("ReadPublishers", func(t *testing.T) {
+		store, _ := newStores(t)
+
+		page, err := store.ReadPublishers(ctx, publishers.PublisherQuery{Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, page.Publishers, 2)
+		assert.Equal(t, "Ace Books", page.Publishers[0].Name)
+		assert.Equal(t, "https://ace.example.com", page.Publishers[0].Website)
+		assert.Equal(t, "Chilton Books", page.Publishers[1].Name)
+		assert.NotNil(t, page.Publishers[0].CreatedAt)
+		assert.Nil(t, page.Publishers[0].Books)
+
+		page, err = store.ReadPublishers(ctx, publishers.PublisherQuery{Limit: 2, Cursor: page.NextCursor})
+		require.NoError(t, err)
+		require.Len(t, page.Publishers, 1)
+		assert.Equal(t, "Penguin Classics", page.Publishers[0].Name)
+		assert.Empty(t, page.NextCursor)
+
+		page, err = store.ReadPublishers(ctx, publishers.PublisherQuery{NamePrefix: "PEN"})
+		require.NoError(t, err)
+		require.Len(t, page.Publishers, 1)
+		assert.Equal(t, "pub03", page.Publishers[0].ID)
+	})
+
+	t.Run("ReadPublisherAndBooks", func(t *testing.T) {
+		store, _ := newStores(t)
+
+		pub, err := store.ReadPublisherAndBooks(ctx, "pub02")
+		require.NoError(t, err)
+		assert.Equal(t, "Ace Books", pub.Name)
+		require.Len(t, pub.Books, 2)
+		assert.Equal(t, "Children of Dune", pub.Books[0].Title)
+		assert.Equal(t, books.ISBN("9783161484100"), pub.Books[0].ISBN)
+		assert.Equal(t, "Dune Messiah", pub.Books[1].Title)
+
+		pub, err = store.ReadPublisherAndBooks(ctx, "pub03")
+		require.NoError(t, err)
+		assert.Empty(t, pub.Books)
+
+		_, err = store.ReadPublisherAndBooks(ctx, "nope")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("UpsertPublishers", func(t *testing.T) {
+		store, _ := newStores(t)
+
+		err := store.UpsertPublishers(ctx, []publishers.Publisher{{ID: "pub01", Name: "Chilton Company"}})
+		require.NoError(t, err)
+		pub, err := store.ReadPublisherAndBooks(ctx, "pub01")
+		require.NoError(t, err)
+		assert.Equal(t, "Chilton Company", pub.Name)
+		assert.Len(t, pub.Books, 1)
+
+		err = store.UpsertPublishers(ctx, []publishers.Publisher{{ID: "pub09", Name: "ACE BOOKS"}})
+		assert.True(t, errors.Is(err, datastore.ErrDuplicate))
+	})
+
+	t.Run("DeletePublisher", func(t *testing.T) {
+		store, bs := newStores(t)
+
+		require.NoError(t, store.DeletePublisher(ctx, "pub02"))
+		_, err := store.ReadPublisherAndBooks(ctx, "pub02")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+
+		bk, err := bs.ReadBookByID(ctx, "def02")
+		require.NoError(t, err, "books outlive their publisher")
+		assert.Nil(t, bk.Publisher)
+
+		err = store.DeletePublisher(ctx, "pub02")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+}
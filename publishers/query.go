@@ -0,0 +1,53 @@
+package publishers
+
+import (
+	"fmt"
+
+	"bookshop/datastore"
+)
+
+// SortByName is the only order publishers are listed in, recorded in cursors.
+const SortByName = "name"
+
+// PublisherQuery holds the filters and page position for listing publishers,
+// which are sorted by name. The zero value lists the first page of all
+// publishers.
+type PublisherQuery struct {
+	// NamePrefix limits results to names starting with the value (case insensitive).
+	NamePrefix string
+
+	// Limit is the page size, defaulting to datastore.DefaultLimit.
+	Limit int
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+}
+
+// Page is a single page of publishers along with the cursor for the following
+// page. NextCursor is blank on the last page.
+type Page struct {
+	Publishers []Publisher `json:"publishers"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// WithDefaults returns a copy of the query with the default limit filled in.
+func (q PublisherQuery) WithDefaults() PublisherQuery {
+	if q.Limit == 0 {
+		q.Limit = datastore.DefaultLimit
+	}
+	return q
+}
+
+// Validate checks the query for out of range limits and cursors that were not
+// issued for listing publishers.
+func (q PublisherQuery) Validate() error {
+	q = q.WithDefaults()
+	if q.Limit < 1 || q.Limit > datastore.MaxLimit {
+		return fmt.Errorf("limit must be between 1 and %d", datastore.MaxLimit)
+	}
+	if q.Cursor != "" {
+		if _, err := datastore.DecodeCursor(q.Cursor, SortByName, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package reviews
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Status is where a review is in moderation.
+type Status string
+
+// New and edited reviews wait for moderation. Only approved reviews are shown
+// to everyone and counted in a book's rating.
+const (
+	Pending  Status = "pending"
+	Approved Status = "approved"
+	Rejected Status = "rejected"
+)
+
+// ParseStatus returns the status with the given name, ignoring case.
+func ParseStatus(s string) (Status, error) {
+	st := Status(strings.ToLower(strings.TrimSpace(s)))
+	switch st {
+	case Pending, Approved, Rejected:
+		return st, nil
+	}
+	return "", fmt.Errorf("unknown review status %q", s)
+}
+
+// MinRating and MaxRating bound a review's star rating.
+const (
+	MinRating = 1
+	MaxRating = 5
+)
+
+// MaxBodyLength is the most characters a review's text may have.
+const MaxBodyLength = 4000
+
+// Review is the model representing a row in the reviews table: one user's
+// rating and review of one book.
+type Review struct {
+	ID        string     `db:"id" json:"id"`
+	BookID    string     `db:"book_id" json:"book_id"`
+	UserID    string     `db:"user_id" json:"user_id"`
+	Rating    int        `db:"rating" json:"rating"`
+	Body      string     `db:"body" json:"body"`
+	Status    Status     `db:"status" json:"status"`
+	CreatedAt *time.Time `db:"created_at" json:"created_at,omitempty"`
+	UpdatedAt *time.Time `db:"updated_at" json:"updated_at,omitempty"`
+}
+
+// Validate checks the rating is in range and the text is not too long.
+func (r Review) Validate() error {
+	if r.Rating < MinRating || r.Rating > MaxRating {
+		return fmt.Errorf("rating must be between %d and %d", MinRating, MaxRating)
+	}
+	if utf8.RuneCountInString(r.Body) > MaxBodyLength {
+		return fmt.Errorf("review must be at most %d characters long", MaxBodyLength)
+	}
+	return nil
+}
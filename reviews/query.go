@@ -0,0 +1,87 @@
+package reviews
+
+import (
+	"fmt"
+	"time"
+
+	"bookshop/datastore"
+)
+
+// cursorSort is the sort order named in review listing cursors. Reviews are
+// always listed newest first.
+const cursorSort = "created_at"
+
+// ReviewQuery holds the filters and page position for listing a book's
+// reviews, newest first. The zero value lists the first page of all of them.
+type ReviewQuery struct {
+	// Status limits results to reviews with the given status.
+	Status Status
+
+	// Limit is the page size, defaulting to datastore.DefaultLimit.
+	Limit int
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+}
+
+// Page is a single page of reviews along with the cursor for the following
+// page. NextCursor is blank on the last page.
+type Page struct {
+	Reviews    []Review `json:"reviews"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+// WithDefaults returns a copy of the query with the default limit filled in.
+func (q ReviewQuery) WithDefaults() ReviewQuery {
+	if q.Limit == 0 {
+		q.Limit = datastore.DefaultLimit
+	}
+	return q
+}
+
+// Validate checks the query for unknown statuses, out of range limits and
+// cursors that were not issued for review listings.
+func (q ReviewQuery) Validate() error {
+	q = q.WithDefaults()
+	if q.Status != "" {
+		if _, err := ParseStatus(string(q.Status)); err != nil {
+			return err
+		}
+	}
+	if q.Limit < 1 || q.Limit > datastore.MaxLimit {
+		return fmt.Errorf("limit must be between 1 and %d", datastore.MaxLimit)
+	}
+	if q.Cursor != "" {
+		if _, err := datastore.DecodeCursor(q.Cursor, cursorSort, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeCursor returns the position in the listing held by the query's cursor.
+func (q ReviewQuery) DecodeCursor() (datastore.Cursor, error) {
+	return datastore.DecodeCursor(q.Cursor, cursorSort, true)
+}
+
+// NewPage trims the extra review fetched to detect a following page and builds
+// the cursor pointing at it.
+func NewPage(revs []Review, q ReviewQuery) Page {
+	if len(revs) <= q.Limit {
+		return Page{Reviews: revs}
+	}
+	revs = revs[:q.Limit]
+	last := revs[len(revs)-1]
+	value := ""
+	if last.CreatedAt != nil {
+		value = last.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return Page{
+		Reviews: revs,
+		NextCursor: datastore.Cursor{
+			Sort:  cursorSort,
+			Desc:  true,
+			Value: value,
+			ID:    last.ID,
+		}.Encode(),
+	}
+}
@@ -0,0 +1,119 @@
+package reviews
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"bookshop/datastore"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type ReviewStore struct {
+	db *sqlx.DB
+}
+
+func NewReviewStore(db *sqlx.DB) ReviewStore {
+	return ReviewStore{db: db}
+}
+
+// CreateReview will add the given review, returning datastore.ErrDuplicate if
+// its user has already reviewed the book and datastore.ErrMissingReference if
+// the book or user does not exist.
+func (s *ReviewStore) CreateReview(ctx context.Context, r Review) error {
+	const sqlSt = `INSERT INTO reviews (id, book_id, user_id, rating, body, status, created_at, updated_at)
+		VALUES (?,?,?,?,?,?,?,?)`
+	now := time.Now()
+	_, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind(sqlSt),
+		r.ID, r.BookID, r.UserID, r.Rating, r.Body, r.Status, now, now)
+	if err != nil {
+		if datastore.IsUniqueViolation(err) {
+			return datastore.ErrDuplicate
+		}
+		if datastore.IsForeignKeyViolation(err) {
+			return datastore.ErrMissingReference
+		}
+		return errors.Wrap(err, "failed creating review")
+	}
+	return nil
+}
+
+// ReadReview will return the review of the given book with the given ID,
+// returning datastore.ErrNotFound if it does not exist.
+func (s *ReviewStore) ReadReview(ctx context.Context, bookID, id string) (Review, error) {
+	r := Review{}
+	err := datastore.Conn(ctx, s.db).GetContext(ctx, &r, s.db.Rebind("SELECT * FROM reviews WHERE id=? AND book_id=?"), id, bookID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Review{}, datastore.ErrNotFound
+		}
+		return Review{}, errors.Wrap(err, "failed to read review")
+	}
+	return r, nil
+}
+
+// ReadReviews will return a page of the book's reviews matching the query,
+// newest first.
+func (s *ReviewStore) ReadReviews(ctx context.Context, bookID string, q ReviewQuery) (Page, error) {
+	q = q.WithDefaults()
+
+	where := []string{"r.book_id = ?"}
+	args := []interface{}{bookID}
+	if q.Status != "" {
+		where = append(where, "r.status = ?")
+		args = append(args, q.Status)
+	}
+	col := datastore.Timestamp(s.db, "r.created_at")
+	if q.Cursor != "" {
+		c, err := q.DecodeCursor()
+		if err != nil {
+			return Page{}, err
+		}
+		where = append(where, "("+col+", r.id) < ("+datastore.CursorTimestamp(s.db)+", ?)")
+		args = append(args, c.Value, c.ID)
+	}
+
+	qry := "SELECT r.* FROM reviews r WHERE " + strings.Join(where, " AND ") +
+		" ORDER BY " + col + " DESC, r.id DESC LIMIT ?"
+	args = append(args, q.Limit+1)
+
+	revs := []Review{}
+	if err := datastore.Conn(ctx, s.db).SelectContext(ctx, &revs, s.db.Rebind(qry), args...); err != nil {
+		return Page{}, errors.Wrap(err, "failed to read reviews")
+	}
+	return NewPage(revs, q), nil
+}
+
+// UpdateReview will change the rating, text and status of the review,
+// returning datastore.ErrNotFound if the book has no review with its ID.
+func (s *ReviewStore) UpdateReview(ctx context.Context, r Review) error {
+	const sqlSt = `UPDATE reviews SET rating = ?, body = ?, status = ?, updated_at = ? WHERE id = ? AND book_id = ?`
+	return s.exec(ctx, sqlSt, r.Rating, r.Body, r.Status, time.Now(), r.ID, r.BookID)
+}
+
+// SetReviewStatus will change the status of the review, returning
+// datastore.ErrNotFound if the book has no review with the ID.
+func (s *ReviewStore) SetReviewStatus(ctx context.Context, bookID, id string, status Status) error {
+	const sqlSt = `UPDATE reviews SET status = ?, updated_at = ? WHERE id = ? AND book_id = ?`
+	return s.exec(ctx, sqlSt, status, time.Now(), id, bookID)
+}
+
+// DeleteReview will delete the review, returning datastore.ErrNotFound if the
+// book has no review with the ID.
+func (s *ReviewStore) DeleteReview(ctx context.Context, bookID, id string) error {
+	return s.exec(ctx, `DELETE FROM reviews WHERE id = ? AND book_id = ?`, id, bookID)
+}
+
+func (s *ReviewStore) exec(ctx context.Context, qry string, args ...interface{}) error {
+	res, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind(qry), args...)
+	if err != nil {
+		return errors.Wrap(err, "failed changing review")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return datastore.ErrNotFound
+	}
+	return nil
+}
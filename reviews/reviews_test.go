@@ -0,0 +1,164 @@
+package reviews_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"bookshop/authors"
+	"bookshop/books"
+	"bookshop/datastore"
+	"bookshop/datastore/dbtest"
+	"bookshop/publishers"
+	"bookshop/reviews"
+	"bookshop/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReviews(t *testing.T) {
+	ctx := context.Background()
+	newStores := func(t *testing.T) (reviews.ReviewStore, books.BookStore) {
+		dbh := dbtest.Open(t)
+
+		bs, us := books.NewBookStore(dbh), users.NewUserStore(dbh)
+		err := bs.UpsertBooks(ctx, []books.Book{{ID: "abc01", Title: "Dune", ISBN: "9781861972712"}})
+		require.NoError(t, err)
+		for _, u := range []users.User{
+			{ID: "usr01", Email: "ann@example.com", FirstName: "Ann", LastName: "Lee", PasswordHash: "hash1", Enabled: true},
+			{ID: "usr02", Email: "bob@example.com", FirstName: "Bob", LastName: "Ray", PasswordHash: "hash2", Enabled: true},
+			{ID: "usr03", Email: "cat@example.com", FirstName: "Cat", LastName: "Orr", PasswordHash: "hash3", Enabled: true},
+		} {
+			require.NoError(t, us.CreateUser(ctx, u))
+		}
+		return reviews.NewReviewStore(dbh), bs
+	}
+	review := reviews.Review{ID: "rev01", BookID: "abc01", UserID: "usr01", Rating: 4, Body: "Gripping.", Status: reviews.Pending}
+
+	t.Run("CreateReview", func(t *testing.T) {
+		store, _ := newStores(t)
+		require.NoError(t, store.CreateReview(ctx, review))
+
+		r, err := store.ReadReview(ctx, "abc01", "rev01")
+		require.NoError(t, err)
+		assert.NotNil(t, r.CreatedAt)
+		r.CreatedAt, r.UpdatedAt = nil, nil
+		assert.Equal(t, review, r)
+
+		err = store.CreateReview(ctx, reviews.Review{ID: "rev02", BookID: "abc01", UserID: "usr01", Rating: 1, Status: reviews.Pending})
+		assert.True(t, errors.Is(err, datastore.ErrDuplicate), "one review per reviewer")
+		err = store.CreateReview(ctx, reviews.Review{ID: "rev02", BookID: "abc99", UserID: "usr02", Rating: 1, Status: reviews.Pending})
+		assert.True(t, errors.Is(err, datastore.ErrMissingReference))
+
+		_, err = store.ReadReview(ctx, "abc02", "rev01")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("UpdateReview", func(t *testing.T) {
+		store, _ := newStores(t)
+		require.NoError(t, store.CreateReview(ctx, review))
+
+		changed := review
+		changed.Rating, changed.Body, changed.Status = 2, "Dragged.", reviews.Approved
+		require.NoError(t, store.UpdateReview(ctx, changed))
+		r, err := store.ReadReview(ctx, "abc01", "rev01")
+		require.NoError(t, err)
+		assert.Equal(t, 2, r.Rating)
+		assert.Equal(t, "Dragged.", r.Body)
+		assert.Equal(t, reviews.Approved, r.Status)
+
+		require.NoError(t, store.SetReviewStatus(ctx, "abc01", "rev01", reviews.Rejected))
+		r, err = store.ReadReview(ctx, "abc01", "rev01")
+		require.NoError(t, err)
+		assert.Equal(t, reviews.Rejected, r.Status)
+
+		err = store.SetReviewStatus(ctx, "abc01", "rev99", reviews.Approved)
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+
+		require.NoError(t, store.DeleteReview(ctx, "abc01", "rev01"))
+		err = store.DeleteReview(ctx, "abc01", "rev01")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("ReadReviews", func(t *testing.T) {
+		store, _ := newStores(t)
+		for i, id := range []string{"rev01", "rev02", "rev03"} {
+			r := reviews.Review{ID: id, BookID: "abc01", UserID: []string{"usr01", "usr02", "usr03"}[i], Rating: 3, Status: reviews.Pending}
+			require.NoError(t, store.CreateReview(ctx, r))
+		}
+		require.NoError(t, store.SetReviewStatus(ctx, "abc01", "rev02", reviews.Approved))
+
+		var ids []string
+		q := reviews.ReviewQuery{Limit: 2}
+		for {
+			page, err := store.ReadReviews(ctx, "abc01", q)
+			require.NoError(t, err)
+			for _, r := range page.Reviews {
+				ids = append(ids, r.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+		assert.Equal(t, []string{"rev03", "rev02", "rev01"}, ids)
+
+		page, err := store.ReadReviews(ctx, "abc01", reviews.ReviewQuery{Status: reviews.Approved})
+		require.NoError(t, err)
+		require.Len(t, page.Reviews, 1)
+		assert.Equal(t, "rev02", page.Reviews[0].ID)
+	})
+
+	t.Run("book rating", func(t *testing.T) {
+		store, bs := newStores(t)
+		for i, rating := range []int{5, 4, 1} {
+			id := []string{"rev01", "rev02", "rev03"}[i]
+			r := reviews.Review{ID: id, BookID: "abc01", UserID: []string{"usr01", "usr02", "usr03"}[i], Rating: rating, Status: reviews.Approved}
+			require.NoError(t, store.CreateReview(ctx, r))
+		}
+		require.NoError(t, store.SetReviewStatus(ctx, "abc01", "rev03", reviews.Rejected))
+
+		bk, err := bs.ReadBookByID(ctx, "abc01")
+		require.NoError(t, err)
+		assert.Equal(t, &books.Rating{Average: 4.5, Count: 2}, bk.Rating, "only approved reviews count")
+
+		page, err := bs.ReadBooks(ctx, books.BookQuery{})
+		require.NoError(t, err)
+		require.Len(t, page.Books, 1)
+		assert.Equal(t, &books.Rating{Average: 4.5, Count: 2}, page.Books[0].Rating)
+
+		require.NoError(t, bs.DeleteBooks(ctx, "abc01"))
+		_, err = store.ReadReview(ctx, "abc01", "rev01")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound), "reviews go with their book")
+	})
+
+	t.Run("nested books are not rated", func(t *testing.T) {
+		dbh := dbtest.Open(t)
+		as, bs, ps, us := authors.NewAuthorStore(dbh), books.NewBookStore(dbh), publishers.NewPublisherStore(dbh), users.NewUserStore(dbh)
+		dob := time.Date(1920, 10, 8, 0, 0, 0, 0, time.UTC)
+		require.NoError(t, as.UpsertAuthors(ctx, []authors.Author{{ID: "auth01", FirstName: "Frank", LastName: "Herbert", DOB: &dob}}))
+		require.NoError(t, ps.UpsertPublishers(ctx, []publishers.Publisher{{ID: "pub01", Name: "Chilton Books"}}))
+		dune := books.Book{ID: "abc01", Title: "Dune", ISBN: "9781861972712"}
+		dune.PublisherID = "pub01"
+		require.NoError(t, bs.UpsertBooks(ctx, []books.Book{dune}))
+		require.NoError(t, bs.LinkBookAuthors(ctx, "abc01", books.Credit{AuthorID: "auth01", Role: books.RoleAuthor, Sequence: 1}))
+		require.NoError(t, us.CreateUser(ctx, users.User{ID: "usr01", Email: "ann@example.com", FirstName: "Ann", LastName: "Lee", PasswordHash: "hash1", Enabled: true}))
+		store := reviews.NewReviewStore(dbh)
+		require.NoError(t, store.CreateReview(ctx, reviews.Review{ID: "rev01", BookID: "abc01", UserID: "usr01", Rating: 5, Status: reviews.Approved}))
+
+		auth, err := as.ReadAuthorAndBooks(ctx, "auth01")
+		require.NoError(t, err)
+		require.Len(t, auth.Books, 1)
+		assert.Nil(t, auth.Books[0].Rating)
+		pub, err := ps.ReadPublisherAndBooks(ctx, "pub01")
+		require.NoError(t, err)
+		require.Len(t, pub.Books, 1)
+		assert.Nil(t, pub.Books[0].Rating)
+
+		bk, err := bs.ReadBookByID(ctx, "abc01")
+		require.NoError(t, err)
+		assert.Equal(t, &books.Rating{Average: 5, Count: 1}, bk.Rating)
+	})
+}
@@ -0,0 +1,46 @@
+package search
+
+import (
+	"html"
+	"strings"
+)
+
+// Kind identifies what type of record a search result refers to.
+type Kind string
+
+const (
+	KindAuthor Kind = "author"
+	KindBook   Kind = "book"
+)
+
+const (
+	// HighlightStart and HighlightStop surround the matched terms in snippets.
+	HighlightStart = "<mark>"
+	HighlightStop  = "</mark>"
+
+	// MatchStart and MatchStop are the markers stores put around matched terms
+	// before the text is turned into a snippet. They are private use
+	// characters, so they never clash with markup in the text itself.
+	MatchStart = "\uE000"
+	MatchStop  = "\uE001"
+)
+
+var highlighter = strings.NewReplacer(MatchStart, HighlightStart, MatchStop, HighlightStop)
+
+// Snippet returns text with its matched terms marked by MatchStart and
+// MatchStop as HTML: the text is escaped and the matches are highlighted with
+// HighlightStart and HighlightStop.
+func Snippet(marked string) string {
+	return highlighter.Replace(html.EscapeString(marked))
+}
+
+// Result is the model representing a single ranked search hit. Title holds the
+// book title or the author's full name as plain text, and Snippet holds the
+// same text as HTML with the matched terms highlighted.
+type Result struct {
+	Kind    Kind    `db:"kind" json:"kind"`
+	ID      string  `db:"id" json:"id"`
+	Title   string  `db:"title" json:"title"`
+	Snippet string  `db:"snippet" json:"snippet"`
+	Rank    float64 `db:"rank" json:"rank"`
+}
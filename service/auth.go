@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"bookshop/auth"
+	"bookshop/datastore"
+	"bookshop/users"
+)
+
+// Login starts a session for the enabled user with the given email address and
+// password, returning the credentials to sign requests with. Whichever of the
+// two is wrong, the answer is the same ErrUnauthenticated.
+func (s *Service) Login(ctx context.Context, email, password string) (auth.Credentials, error) {
+	u, err := s.userStore.ReadUserByEmail(ctx, users.NormalizeEmail(email))
+	if errors.Is(err, datastore.ErrNotFound) {
+		// check a password anyway so unknown addresses take as long to refuse
+		u = users.User{PasswordHash: decoyHash()}
+	} else if err != nil {
+		return auth.Credentials{}, err
+	}
+	if !u.CheckPassword(password) || !u.Enabled {
+		return auth.Credentials{}, ErrUnauthenticated
+	}
+	return s.startSession(ctx, u.ID)
+}
+
+// Authenticate returns the enabled user whose session the token belongs to,
+// along with the permissions of their role.
+func (s *Service) Authenticate(ctx context.Context, token string) (users.User, error) {
+	sess, err := s.sessStore.ReadSession(ctx, auth.HashToken(token))
+	if errors.Is(err, datastore.ErrNotFound) {
+		return users.User{}, ErrUnauthenticated
+	}
+	if err != nil {
+		return users.User{}, err
+	}
+	return s.signedInUser(ctx, sess.UserID)
+}
+
+// Authorize checks the signed in user carried by ctx has the permission. It is
+// called by every service call that needs more than a customer may do.
+func (s *Service) Authorize(ctx context.Context, perm users.Permission) error {
+	u, ok := auth.UserFrom(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+	if !u.Can(perm) {
+		return NewErrForbidden(perm)
+	}
+	return nil
+}
+
+// authorizeOwner checks the signed in user carried by ctx is the user with the
+// given ID or has the permission, which lets staff act on other users' records.
+func authorizeOwner(ctx context.Context, userID string, perm users.Permission) error {
+	u, ok := auth.UserFrom(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+	if u.ID != userID && !u.Can(perm) {
+		return NewErrForbidden(perm)
+	}
+	return nil
+}
+
+// RefreshSession swaps the session the token belongs to for a new one with a
+// fresh expiry. The old token stops working.
+func (s *Service) RefreshSession(ctx context.Context, token string) (auth.Credentials, error) {
+	var creds auth.Credentials
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		u, err := s.Authenticate(ctx, token)
+		if err != nil {
+			return err
+		}
+		if err := s.sessStore.DeleteSession(ctx, auth.HashToken(token)); err != nil {
+			return err
+		}
+		creds, err = s.startSession(ctx, u.ID)
+		return err
+	})
+	if err != nil {
+		return auth.Credentials{}, err
+	}
+	return creds, nil
+}
+
+// Logout ends the session the token belongs to.
+func (s *Service) Logout(ctx context.Context, token string) error {
+	err := s.sessStore.DeleteSession(ctx, auth.HashToken(token))
+	if errors.Is(err, datastore.ErrNotFound) {
+		return ErrUnauthenticated
+	}
+	return err
+}
+
+// RevokeSessions ends every session of the user, signing them out everywhere.
+// Users may revoke their own sessions and admins anyone's.
+func (s *Service) RevokeSessions(ctx context.Context, userID string) error {
+	if err := authorizeOwner(ctx, userID, users.PermManageUsers); err != nil {
+		return err
+	}
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		if _, err := s.readUser(ctx, userID); err != nil {
+			return err
+		}
+		_, err := s.sessStore.DeleteUserSessions(ctx, userID)
+		return err
+	})
+}
+
+// PurgeExpiredSessions will delete the sessions that have expired, returning
+// how many were deleted.
+func (s *Service) PurgeExpiredSessions(ctx context.Context) (int64, error) {
+	return s.sessStore.DeleteExpiredSessions(ctx, time.Now())
+}
+
+// signedInUser returns the enabled user with the given ID along with the
+// permissions of their role, or ErrUnauthenticated if there is no such user.
+func (s *Service) signedInUser(ctx context.Context, id string) (users.User, error) {
+	u, err := s.userStore.ReadUserByID(ctx, id)
+	if errors.Is(err, datastore.ErrNotFound) || (err == nil && !u.Enabled) {
+		return users.User{}, ErrUnauthenticated
+	}
+	if err != nil {
+		return users.User{}, err
+	}
+	if u.Permissions, err = s.userStore.ReadRolePermissions(ctx, u.Role); err != nil {
+		return users.User{}, err
+	}
+	return u, nil
+}
+
+func (s *Service) startSession(ctx context.Context, userID string) (auth.Credentials, error) {
+	token, err := auth.NewToken()
+	if err != nil {
+		return auth.Credentials{}, err
+	}
+	expires := time.Now().Add(auth.TTL)
+	sess := auth.Session{TokenHash: auth.HashToken(token), UserID: userID, ExpiresAt: &expires}
+	if err := s.sessStore.CreateSession(ctx, sess); err != nil {
+		return auth.Credentials{}, err
+	}
+	return auth.Credentials{Token: token, ExpiresAt: expires}, nil
+}
+
+var (
+	decoyOnce sync.Once
+	decoy     string
+)
+
+// decoyHash returns the hash of a password no user has, made once on first use.
+func decoyHash() string {
+	decoyOnce.Do(func() {
+		decoy, _ = users.HashPassword("no user has this password")
+	})
+	return decoy
+}
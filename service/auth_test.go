@@ -0,0 +1,201 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"bookshop/auth"
+	"bookshop/authors"
+	"bookshop/books"
+	"bookshop/datastore"
+	"bookshop/service"
+	"bookshop/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceAuth(t *testing.T) {
+	ctx := context.Background()
+	srv := service.NewService(service.Stores{Sessions: &mockSessionStore{}, Users: &mockUserStore{}, Work: &mockUnitOfWork{}})
+	hash, err := users.HashPassword("correct horse")
+	require.NoError(t, err)
+	reset := func() {
+		mockUserErr, mockSessionErr = nil, nil
+		mockUser = users.User{ID: "usr01", Email: "ann@example.com", PasswordHash: hash, Enabled: true}
+		mockSessions, mockSessionsRevoked = map[string]auth.Session{}, nil
+	}
+
+	t.Run("Login", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+
+			creds, err := srv.Login(ctx, " Ann@Example.com", "correct horse")
+			require.NoError(t, err)
+			assert.NotEmpty(t, creds.Token)
+			assert.WithinDuration(t, time.Now().Add(auth.TTL), creds.ExpiresAt, time.Minute)
+
+			sess, ok := mockSessions[auth.HashToken(creds.Token)]
+			require.True(t, ok, "the session is kept under the token's hash")
+			assert.Equal(t, "usr01", sess.UserID)
+			_, ok = mockSessions[creds.Token]
+			assert.False(t, ok, "the token itself is not kept")
+		})
+
+		t.Run("refused", func(t *testing.T) {
+			for name, setup := range map[string]func(){
+				"wrong password": func() {},
+				"disabled":       func() { mockUser.Enabled = false },
+				"unknown email":  func() { mockUserErr = datastore.ErrNotFound },
+			} {
+				reset()
+				setup()
+				password := "correct horse"
+				if name == "wrong password" {
+					password = "wrong horse"
+				}
+
+				_, err := srv.Login(ctx, "ann@example.com", password)
+				assert.True(t, errors.Is(err, service.ErrUnauthenticated), name)
+				assert.Empty(t, mockSessions, name)
+			}
+		})
+	})
+
+	t.Run("Authenticate", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+			creds, err := srv.Login(ctx, "ann@example.com", "correct horse")
+			require.NoError(t, err)
+
+			u, err := srv.Authenticate(ctx, creds.Token)
+			require.NoError(t, err)
+			assert.Equal(t, "usr01", u.ID)
+			assert.Empty(t, u.Permissions, "customers have no permissions")
+
+			mockUser.Role = users.RoleStaff
+			u, err = srv.Authenticate(ctx, creds.Token)
+			require.NoError(t, err)
+			assert.Equal(t, []users.Permission{users.PermEditBooks, users.PermModerateReviews, users.PermManageStock, users.PermManageOrders}, u.Permissions)
+		})
+
+		t.Run("unknown token", func(t *testing.T) {
+			reset()
+
+			_, err := srv.Authenticate(ctx, "nope")
+			assert.True(t, errors.Is(err, service.ErrUnauthenticated))
+		})
+
+		t.Run("disabled since", func(t *testing.T) {
+			reset()
+			creds, err := srv.Login(ctx, "ann@example.com", "correct horse")
+			require.NoError(t, err)
+			mockUser.Enabled = false
+
+			_, err = srv.Authenticate(ctx, creds.Token)
+			assert.True(t, errors.Is(err, service.ErrUnauthenticated))
+		})
+	})
+
+	t.Run("RefreshSession", func(t *testing.T) {
+		reset()
+		creds, err := srv.Login(ctx, "ann@example.com", "correct horse")
+		require.NoError(t, err)
+
+		fresh, err := srv.RefreshSession(ctx, creds.Token)
+		require.NoError(t, err)
+		assert.NotEqual(t, creds.Token, fresh.Token)
+		_, err = srv.Authenticate(ctx, creds.Token)
+		assert.True(t, errors.Is(err, service.ErrUnauthenticated), "the old token stops working")
+		_, err = srv.Authenticate(ctx, fresh.Token)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Logout", func(t *testing.T) {
+		reset()
+		creds, err := srv.Login(ctx, "ann@example.com", "correct horse")
+		require.NoError(t, err)
+
+		require.NoError(t, srv.Logout(ctx, creds.Token))
+		_, err = srv.Authenticate(ctx, creds.Token)
+		assert.True(t, errors.Is(err, service.ErrUnauthenticated))
+		err = srv.Logout(ctx, creds.Token)
+		assert.True(t, errors.Is(err, service.ErrUnauthenticated))
+	})
+
+	t.Run("RevokeSessions", func(t *testing.T) {
+		ann := auth.WithUser(ctx, users.User{ID: "usr01", Role: users.RoleCustomer})
+		bob := auth.WithUser(ctx, users.User{ID: "usr04", Role: users.RoleCustomer})
+		admin := auth.WithUser(ctx, users.User{ID: "usr03", Role: users.RoleAdmin, Permissions: users.RolePermissions[users.RoleAdmin]})
+
+		reset()
+		require.NoError(t, srv.RevokeSessions(ann, "usr01"))
+		assert.Equal(t, []string{"usr01"}, mockSessionsRevoked)
+
+		reset()
+		require.NoError(t, srv.RevokeSessions(admin, "usr01"))
+		assert.Equal(t, []string{"usr01"}, mockSessionsRevoked, "admins sign anyone out")
+
+		reset()
+		err := srv.RevokeSessions(bob, "usr01")
+		assert.True(t, errors.Is(err, service.ErrForbidden))
+		assert.Empty(t, mockSessionsRevoked)
+
+		reset()
+		mockUserErr = datastore.ErrNotFound
+		err = srv.RevokeSessions(admin, "usr01")
+		assert.True(t, errors.Is(err, service.ErrNotFound))
+		assert.Empty(t, mockSessionsRevoked)
+	})
+	t.Run("Authorize", func(t *testing.T) {
+		customer := users.User{ID: "usr01", Role: users.RoleCustomer}
+		staff := users.User{ID: "usr02", Role: users.RoleStaff, Permissions: users.RolePermissions[users.RoleStaff]}
+		admin := users.User{ID: "usr03", Role: users.RoleAdmin, Permissions: users.RolePermissions[users.RoleAdmin]}
+
+		err := srv.Authorize(ctx, users.PermEditBooks)
+		assert.True(t, errors.Is(err, service.ErrUnauthenticated), "nobody is signed in")
+		err = srv.Authorize(auth.WithUser(ctx, customer), users.PermEditBooks)
+		assert.True(t, errors.Is(err, service.ErrForbidden))
+		assert.Equal(t, "permission required: books.edit", err.Error())
+		assert.NoError(t, srv.Authorize(auth.WithUser(ctx, staff), users.PermEditBooks))
+		err = srv.Authorize(auth.WithUser(ctx, staff), users.PermRemoveAuthors)
+		assert.True(t, errors.Is(err, service.ErrForbidden))
+		assert.NoError(t, srv.Authorize(auth.WithUser(ctx, admin), users.PermRemoveAuthors))
+	})
+
+	t.Run("catalogue changes", func(t *testing.T) {
+		bookSrv := service.NewService(service.Stores{Authors: &mockAuthorStore{}, Books: &mockBookStore{}, Work: &mockUnitOfWork{}})
+		customer := auth.WithUser(ctx, users.User{ID: "usr01", Role: users.RoleCustomer})
+		staff := auth.WithUser(ctx, users.User{ID: "usr02", Role: users.RoleStaff, Permissions: users.RolePermissions[users.RoleStaff]})
+
+		for name, call := range map[string]func(ctx context.Context) error{
+			"AddBook": func(ctx context.Context) error {
+				_, err := bookSrv.AddBook(ctx, "titleA", "9783161484100", books.Details{}, nil)
+				return err
+			},
+			"UpdateBook": func(ctx context.Context) error {
+				title := "titleA"
+				return bookSrv.UpdateBook(ctx, "abc01", books.Patch{Title: &title})
+			},
+			"RemoveBooks": func(ctx context.Context) error { return bookSrv.RemoveBooks(ctx, "abc01") },
+			"AddAuthor": func(ctx context.Context) error {
+				_, err := bookSrv.AddAuthor(ctx, authors.Author{FirstName: "First", LastName: "Last"})
+				return err
+			},
+			"UpdateAuthor": func(ctx context.Context) error {
+				last := "Last"
+				return bookSrv.UpdateAuthor(ctx, "auth01", authors.Patch{LastName: &last})
+			},
+		} {
+			err := call(ctx)
+			assert.True(t, errors.Is(err, service.ErrUnauthenticated), name)
+			err = call(customer)
+			assert.True(t, errors.Is(err, service.ErrForbidden), name)
+		}
+
+		err := bookSrv.RemoveAuthor(staff, "auth01")
+		assert.True(t, errors.Is(err, service.ErrForbidden), "only admins remove authors")
+	})
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"bookshop/books"
+	"bookshop/carts"
+	"bookshop/datastore"
+)
+
+// CreateCart will start a new empty cart under a fresh token.
+func (s *Service) CreateCart(ctx context.Context) (carts.Cart, error) {
+	token, err := carts.NewToken()
+	if err != nil {
+		return carts.Cart{}, err
+	}
+	expires := time.Now().Add(carts.TTL)
+	if err := s.cartStore.CreateCart(ctx, carts.Cart{Token: token, ExpiresAt: &expires}); err != nil {
+		return carts.Cart{}, err
+	}
+	return s.GetCart(ctx, token)
+}
+
+// GetCart will return the cart with the given token, its items priced at the
+// books' current list prices and its totals.
+func (s *Service) GetCart(ctx context.Context, token string) (carts.Cart, error) {
+	c, err := s.cartStore.ReadCart(ctx, token)
+	if err != nil {
+		return carts.Cart{}, notFound(err, "cart", token)
+	}
+	for i, item := range c.Items {
+		bk, err := s.bookStore.ReadBookByID(ctx, item.BookID)
+		if err != nil {
+			return carts.Cart{}, err
+		}
+		c.Items[i].Title = bk.Title
+		c.Items[i].UnitPrice = bk.ListPrice
+	}
+	return c.WithTotals()
+}
+
+// AddCartItem will put copies of the book in the cart, on top of any already
+// in it. Only books with a list price can be added.
+func (s *Service) AddCartItem(ctx context.Context, token, bookID string, quantity int) (carts.Cart, error) {
+	return s.changeCart(ctx, token, func(ctx context.Context) error {
+		if _, err := s.checkForSale(ctx, bookID, quantity); err != nil {
+			return err
+		}
+		return s.cartStore.AddCartItems(ctx, token, carts.Item{BookID: bookID, Quantity: quantity})
+	})
+}
+
+// SetCartItem will change how many copies of the book are in the cart, adding
+// it if it is not in it yet.
+func (s *Service) SetCartItem(ctx context.Context, token, bookID string, quantity int) (carts.Cart, error) {
+	return s.changeCart(ctx, token, func(ctx context.Context) error {
+		if _, err := s.checkForSale(ctx, bookID, quantity); err != nil {
+			return err
+		}
+		return s.cartStore.SetCartItem(ctx, token, carts.Item{BookID: bookID, Quantity: quantity})
+	})
+}
+
+// RemoveCartItem will take the book out of the cart.
+func (s *Service) RemoveCartItem(ctx context.Context, token, bookID string) (carts.Cart, error) {
+	return s.changeCart(ctx, token, func(ctx context.Context) error {
+		return notFound(s.cartStore.DeleteCartItem(ctx, token, bookID), "cart item", bookID)
+	})
+}
+
+// MergeCarts will move the items of the other cart into the cart with the given
+// token, adding up the copies of books in both, and then delete the other cart.
+func (s *Service) MergeCarts(ctx context.Context, token, otherToken string) (carts.Cart, error) {
+	if token == otherToken {
+		return carts.Cart{}, NewErrInvalid("token", errors.New("cannot merge a cart into itself"))
+	}
+	return s.changeCart(ctx, token, func(ctx context.Context) error {
+		other, err := s.cartStore.ReadCart(ctx, otherToken)
+		if err != nil {
+			return notFound(err, "cart", otherToken)
+		}
+		if len(other.Items) > 0 {
+			if err := s.cartStore.AddCartItems(ctx, token, other.Items...); err != nil {
+				return err
+			}
+		}
+		return s.cartStore.DeleteCart(ctx, otherToken)
+	})
+}
+
+// PurgeExpiredCarts will delete the carts that have expired, returning how many
+// were deleted.
+func (s *Service) PurgeExpiredCarts(ctx context.Context) (int64, error) {
+	return s.cartStore.DeleteExpiredCarts(ctx, time.Now())
+}
+
+// changeCart runs fn against the cart with the given token as one unit of work,
+// extends the cart's life and returns it as changed.
+func (s *Service) changeCart(ctx context.Context, token string, fn func(ctx context.Context) error) (carts.Cart, error) {
+	var c carts.Cart
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		if _, err := s.cartStore.ReadCart(ctx, token); err != nil {
+			return notFound(err, "cart", token)
+		}
+		if err := fn(ctx); err != nil {
+			if errors.Is(err, datastore.ErrCheckViolation) {
+				return NewErrInvalid("quantity", fmt.Errorf("a cart can hold at most %d copies of a book", carts.MaxQuantity))
+			}
+			return err
+		}
+		if err := s.cartStore.TouchCart(ctx, token, time.Now().Add(carts.TTL)); err != nil {
+			return err
+		}
+
+		var err error
+		c, err = s.GetCart(ctx, token)
+		return err
+	})
+	if err != nil {
+		return carts.Cart{}, err
+	}
+	return c, nil
+}
+
+// checkForSale checks the quantity of the book can be sold, returning the book
+// as it is now.
+func (s *Service) checkForSale(ctx context.Context, bookID string, quantity int) (books.Book, error) {
+	if quantity < 1 || quantity > carts.MaxQuantity {
+		return books.Book{}, NewErrInvalid("quantity", fmt.Errorf("must be between 1 and %d", carts.MaxQuantity))
+	}
+	bk, err := s.bookStore.ReadBookByID(ctx, bookID)
+	if errors.Is(err, datastore.ErrNotFound) {
+		return books.Book{}, ErrInvalidReference
+	}
+	if err != nil {
+		return books.Book{}, err
+	}
+	if bk.ListPrice == nil {
+		return books.Book{}, NewErrInvalid("book", fmt.Errorf("book %s is not for sale", bookID))
+	}
+	return bk, nil
+}
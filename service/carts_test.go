@@ -0,0 +1,168 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"bookshop/books"
+	"bookshop/carts"
+	"bookshop/datastore"
+	"bookshop/money"
+	"bookshop/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceCarts(t *testing.T) {
+	ctx := context.Background()
+	srv := service.NewService(service.Stores{Books: &mockBookStore{}, Carts: &mockCartStore{}, Work: &mockUnitOfWork{}})
+	price := money.New(1299, money.USD)
+	reset := func() {
+		mockCartErr, mockBooksErr = nil, nil
+		mockCart = carts.Cart{Items: []carts.Item{{BookID: "abc01", Quantity: 2}}}
+		mockBook = books.Book{ID: "abc01", Title: "Dune", ISBN: "9781861972712", ListPrice: &price}
+		mockCartAdded, mockCartDeleted, mockCartTouched = nil, nil, nil
+	}
+
+	t.Run("CreateCart", func(t *testing.T) {
+		reset()
+		mockCart = carts.Cart{}
+
+		c, err := srv.CreateCart(ctx)
+		require.NoError(t, err)
+		assert.NotEmpty(t, c.Token)
+		require.NotNil(t, mockCart.ExpiresAt)
+		assert.WithinDuration(t, time.Now().Add(carts.TTL), *mockCart.ExpiresAt, time.Minute)
+	})
+
+	t.Run("GetCart", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+
+			c, err := srv.GetCart(ctx, "tok01")
+			require.NoError(t, err)
+			require.Len(t, c.Items, 1)
+			assert.Equal(t, "Dune", c.Items[0].Title)
+			assert.Equal(t, &price, c.Items[0].UnitPrice)
+			assert.Equal(t, money.New(2598, money.USD), *c.Items[0].LineTotal)
+			assert.Equal(t, []money.Money{money.New(2598, money.USD)}, c.Totals)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			reset()
+			mockCartErr = datastore.ErrNotFound
+
+			_, err := srv.GetCart(ctx, "tok01")
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+	})
+
+	t.Run("AddCartItem", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+
+			_, err := srv.AddCartItem(ctx, "tok01", "abc01", 3)
+			require.NoError(t, err)
+			assert.Equal(t, []carts.Item{{BookID: "abc01", Quantity: 3}}, mockCartAdded)
+			assert.NotNil(t, mockCartTouched)
+		})
+
+		t.Run("invalid quantity", func(t *testing.T) {
+			reset()
+			for _, n := range []int{0, -1, carts.MaxQuantity + 1} {
+				_, err := srv.AddCartItem(ctx, "tok01", "abc01", n)
+				assert.True(t, errors.Is(err, service.ErrInvalid), n)
+			}
+			assert.Empty(t, mockCartAdded)
+		})
+
+		t.Run("too many copies", func(t *testing.T) {
+			reset()
+			mockCartErr = nil
+			srv := service.NewService(service.Stores{Books: &mockBookStore{}, Carts: &checkViolationCartStore{}, Work: &mockUnitOfWork{}})
+
+			_, err := srv.AddCartItem(ctx, "tok01", "abc01", 50)
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+		})
+
+		t.Run("not for sale", func(t *testing.T) {
+			reset()
+			mockBook.ListPrice = nil
+
+			_, err := srv.AddCartItem(ctx, "tok01", "abc01", 1)
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+		})
+
+		t.Run("unknown book", func(t *testing.T) {
+			reset()
+			mockBooksErr = datastore.ErrNotFound
+			defer func() { mockBooksErr = nil }()
+
+			_, err := srv.AddCartItem(ctx, "tok01", "nope", 1)
+			assert.True(t, errors.Is(err, service.ErrInvalidReference))
+		})
+
+		t.Run("unknown cart", func(t *testing.T) {
+			reset()
+			mockCartErr = datastore.ErrNotFound
+
+			_, err := srv.AddCartItem(ctx, "tok01", "abc01", 1)
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+	})
+
+	t.Run("SetCartItem", func(t *testing.T) {
+		reset()
+
+		_, err := srv.SetCartItem(ctx, "tok01", "abc01", 5)
+		require.NoError(t, err)
+		assert.Equal(t, []carts.Item{{BookID: "abc01", Quantity: 5}}, mockCartAdded)
+	})
+
+	t.Run("RemoveCartItem", func(t *testing.T) {
+		reset()
+
+		_, err := srv.RemoveCartItem(ctx, "tok01", "abc01")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"abc01"}, mockCartDeleted)
+	})
+
+	t.Run("MergeCarts", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+
+			_, err := srv.MergeCarts(ctx, "tok01", "tok02")
+			require.NoError(t, err)
+			assert.Equal(t, mockCart.Items, mockCartAdded)
+			assert.Equal(t, []string{"tok02"}, mockCartDeleted)
+		})
+
+		t.Run("into itself", func(t *testing.T) {
+			reset()
+
+			_, err := srv.MergeCarts(ctx, "tok01", "tok01")
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+		})
+	})
+
+	t.Run("PurgeExpiredCarts", func(t *testing.T) {
+		reset()
+
+		n, err := srv.PurgeExpiredCarts(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+	})
+}
+
+// checkViolationCartStore refuses to add items as if a quantity went over the
+// most a cart can hold.
+type checkViolationCartStore struct {
+	mockCartStore
+}
+
+func (m *checkViolationCartStore) AddCartItems(ctx context.Context, token string, items ...carts.Item) error {
+	return datastore.ErrCheckViolation
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"bookshop/books"
+	"bookshop/categories"
+	"bookshop/datastore"
+	"bookshop/users"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// AddCategory adds the given category generating its UUID, beneath its parent
+// if it has one. Only staff may change the catalogue.
+func (s *Service) AddCategory(ctx context.Context, c categories.Category) (categories.Category, error) {
+	if err := s.Authorize(ctx, users.PermEditBooks); err != nil {
+		return categories.Category{}, err
+	}
+	c.ID, c.Name = uuid.NewV4().String(), strings.TrimSpace(c.Name)
+	if err := c.Validate(); err != nil {
+		return categories.Category{}, NewErrInvalid("category", err)
+	}
+
+	var added categories.Category
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.catStore.CreateCategory(ctx, c); err != nil {
+			return categoryErr(err, c)
+		}
+
+		var err error
+		added, err = s.catStore.ReadCategory(ctx, c.ID)
+		return err
+	})
+	if err != nil {
+		return categories.Category{}, err
+	}
+	return added, nil
+}
+
+// GetCategory will return the category with the given ID along with the
+// categories beneath it.
+func (s *Service) GetCategory(ctx context.Context, id string) (categories.Category, error) {
+	cats, err := s.catStore.ReadCategories(ctx)
+	if err != nil {
+		return categories.Category{}, err
+	}
+	c, ok := categories.Subtree(cats, id)
+	if !ok {
+		return categories.Category{}, NewErrNotFound("category", id)
+	}
+	return c, nil
+}
+
+// ListCategories will return the category tree: the categories at the top of
+// it, each with the categories beneath it, sorted by name.
+func (s *Service) ListCategories(ctx context.Context) ([]categories.Category, error) {
+	cats, err := s.catStore.ReadCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return categories.Tree(cats), nil
+}
+
+// UpdateCategory will rename the given category and move it beneath its
+// parent, or to the top of the tree if it has none. A category cannot be moved
+// beneath itself.
+func (s *Service) UpdateCategory(ctx context.Context, c categories.Category) (categories.Category, error) {
+	if err := s.Authorize(ctx, users.PermEditBooks); err != nil {
+		return categories.Category{}, err
+	}
+	c.Name = strings.TrimSpace(c.Name)
+	if err := c.Validate(); err != nil {
+		return categories.Category{}, NewErrInvalid("category", err)
+	}
+
+	var updated categories.Category
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		if c.ParentID != nil {
+			cats, err := s.catStore.ReadCategories(ctx)
+			if err != nil {
+				return err
+			}
+			if categories.Descendants(cats, c.ID)[*c.ParentID] {
+				return NewErrInvalid("parent_id", errors.New("category cannot be moved beneath itself"))
+			}
+		}
+		if err := s.catStore.UpdateCategory(ctx, c); err != nil {
+			return categoryErr(err, c)
+		}
+
+		var err error
+		updated, err = s.GetCategory(ctx, c.ID)
+		return err
+	})
+	if err != nil {
+		return categories.Category{}, err
+	}
+	return updated, nil
+}
+
+// RemoveCategory will delete the category, taking its books out of it. A
+// category cannot be removed while it has subcategories.
+func (s *Service) RemoveCategory(ctx context.Context, id string) error {
+	if err := s.Authorize(ctx, users.PermEditBooks); err != nil {
+		return err
+	}
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		c, err := s.GetCategory(ctx, id)
+		if err != nil {
+			return err
+		}
+		if len(c.Children) > 0 {
+			return NewErrInvalid("category", errors.New("category has subcategories"))
+		}
+		return notFound(s.catStore.DeleteCategory(ctx, id), "category", id)
+	})
+}
+
+// AddBookCategory files the given book under the given category.
+func (s *Service) AddBookCategory(ctx context.Context, bookID, categoryID string) error {
+	if err := s.Authorize(ctx, users.PermEditBooks); err != nil {
+		return err
+	}
+	if err := s.bookStore.LinkBookCategories(ctx, bookID, categoryID); err != nil {
+		if errors.Is(err, datastore.ErrMissingReference) {
+			return ErrInvalidReference
+		}
+		return err
+	}
+	return nil
+}
+
+// RemoveBookCategory takes the given book out of the given category.
+func (s *Service) RemoveBookCategory(ctx context.Context, bookID, categoryID string) error {
+	if err := s.Authorize(ctx, users.PermEditBooks); err != nil {
+		return err
+	}
+	return notFound(s.bookStore.UnlinkBookCategory(ctx, bookID, categoryID), "book category", categoryID)
+}
+
+// TagBook gives the book the tag, which is stored in lower case.
+func (s *Service) TagBook(ctx context.Context, bookID, tag string) error {
+	if err := s.Authorize(ctx, users.PermEditBooks); err != nil {
+		return err
+	}
+	parsed, err := books.ParseTag(tag)
+	if err != nil {
+		return NewErrInvalid("tag", err)
+	}
+	if err := s.bookStore.TagBook(ctx, bookID, parsed); err != nil {
+		if errors.Is(err, datastore.ErrMissingReference) {
+			return NewErrNotFound("book", bookID)
+		}
+		return err
+	}
+	return nil
+}
+
+// UntagBook removes the tag from the book.
+func (s *Service) UntagBook(ctx context.Context, bookID, tag string) error {
+	if err := s.Authorize(ctx, users.PermEditBooks); err != nil {
+		return err
+	}
+	parsed, err := books.ParseTag(tag)
+	if err != nil {
+		return NewErrInvalid("tag", err)
+	}
+	return notFound(s.bookStore.UntagBook(ctx, bookID, parsed), "book tag", parsed)
+}
+
+// ListTags will return every tag given to a book along with how many books
+// have it, sorted by name.
+func (s *Service) ListTags(ctx context.Context) ([]books.Tag, error) {
+	return s.bookStore.ReadTags(ctx)
+}
+
+// categoryErr translates the datastore errors of a category write.
+func categoryErr(err error, c categories.Category) error {
+	switch {
+	case errors.Is(err, datastore.ErrDuplicate):
+		return NewErrDuplicateCategory(c.Name)
+	case errors.Is(err, datastore.ErrMissingReference):
+		return ErrInvalidReference
+	}
+	return notFound(err, "category", c.ID)
+}
@@ -0,0 +1,186 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bookshop/auth"
+	"bookshop/books"
+	"bookshop/categories"
+	"bookshop/datastore"
+	"bookshop/service"
+	"bookshop/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceCategories(t *testing.T) {
+	staff := users.User{ID: "usr01", Role: users.RoleStaff, Permissions: users.RolePermissions[users.RoleStaff]}
+	customer := users.User{ID: "usr02", Role: users.RoleCustomer, Permissions: users.RolePermissions[users.RoleCustomer]}
+	ctx := auth.WithUser(context.Background(), staff)
+	customerCtx := auth.WithUser(context.Background(), customer)
+
+	srv := service.NewService(service.Stores{Books: &mockBookStore{}, Categories: &mockCategoryStore{}, Work: &mockUnitOfWork{}})
+	fiction, scifi := "fic01", "sci02"
+	reset := func() {
+		mockCategoryErr, mockLinkErr, mockBooksErr, mockTagged = nil, nil, nil, nil
+		mockCategories = map[string]categories.Category{
+			fiction: {ID: fiction, Name: "Fiction"},
+			scifi:   {ID: scifi, ParentID: &fiction, Name: "Science Fiction"},
+			"his03": {ID: "his03", Name: "History"},
+		}
+	}
+
+	t.Run("AddCategory", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+			c, err := srv.AddCategory(ctx, categories.Category{Name: " Space Opera ", ParentID: &scifi})
+			require.NoError(t, err)
+			assert.NotEmpty(t, c.ID)
+			assert.Equal(t, "Space Opera", c.Name)
+			assert.Equal(t, &scifi, c.ParentID)
+		})
+
+		t.Run("invalid", func(t *testing.T) {
+			reset()
+			_, err := srv.AddCategory(ctx, categories.Category{Name: " "})
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+		})
+
+		t.Run("duplicate", func(t *testing.T) {
+			reset()
+			mockCategoryErr = datastore.ErrDuplicate
+			_, err := srv.AddCategory(ctx, categories.Category{Name: "science fiction", ParentID: &fiction})
+			assert.True(t, errors.Is(err, service.ErrDuplicate))
+		})
+
+		t.Run("unknown parent", func(t *testing.T) {
+			reset()
+			mockCategoryErr = datastore.ErrMissingReference
+			_, err := srv.AddCategory(ctx, categories.Category{Name: "Poetry", ParentID: new(string)})
+			assert.True(t, errors.Is(err, service.ErrInvalidReference))
+		})
+
+		t.Run("forbidden", func(t *testing.T) {
+			reset()
+			_, err := srv.AddCategory(customerCtx, categories.Category{Name: "Poetry"})
+			assert.True(t, errors.Is(err, service.ErrForbidden))
+		})
+	})
+
+	t.Run("ListCategories", func(t *testing.T) {
+		reset()
+		tree, err := srv.ListCategories(context.Background())
+		require.NoError(t, err)
+		require.Len(t, tree, 2)
+		assert.Equal(t, "Fiction", tree[0].Name)
+		assert.Equal(t, "History", tree[1].Name)
+		require.Len(t, tree[0].Children, 1)
+		assert.Equal(t, "Science Fiction", tree[0].Children[0].Name)
+	})
+
+	t.Run("GetCategory", func(t *testing.T) {
+		reset()
+		c, err := srv.GetCategory(context.Background(), fiction)
+		require.NoError(t, err)
+		require.Len(t, c.Children, 1)
+		assert.Equal(t, scifi, c.Children[0].ID)
+
+		_, err = srv.GetCategory(context.Background(), "unknown")
+		assert.True(t, errors.Is(err, service.ErrNotFound))
+	})
+
+	t.Run("UpdateCategory", func(t *testing.T) {
+		t.Run("move", func(t *testing.T) {
+			reset()
+			c, err := srv.UpdateCategory(ctx, categories.Category{ID: scifi, Name: "SF"})
+			require.NoError(t, err)
+			assert.Nil(t, c.ParentID, "moved to the top of the tree")
+			assert.Equal(t, "SF", c.Name)
+		})
+
+		t.Run("beneath itself", func(t *testing.T) {
+			reset()
+			_, err := srv.UpdateCategory(ctx, categories.Category{ID: fiction, Name: "Fiction", ParentID: &scifi})
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+			_, err = srv.UpdateCategory(ctx, categories.Category{ID: fiction, Name: "Fiction", ParentID: &fiction})
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+			assert.Nil(t, mockCategories[fiction].ParentID)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			reset()
+			_, err := srv.UpdateCategory(ctx, categories.Category{ID: "unknown", Name: "Poetry"})
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+	})
+
+	t.Run("RemoveCategory", func(t *testing.T) {
+		reset()
+		err := srv.RemoveCategory(ctx, fiction)
+		assert.True(t, errors.Is(err, service.ErrInvalid), "fiction has subcategories")
+
+		require.NoError(t, srv.RemoveCategory(ctx, scifi))
+		require.NoError(t, srv.RemoveCategory(ctx, fiction))
+		err = srv.RemoveCategory(ctx, fiction)
+		assert.True(t, errors.Is(err, service.ErrNotFound))
+	})
+
+	t.Run("AddBookCategory", func(t *testing.T) {
+		reset()
+		require.NoError(t, srv.AddBookCategory(ctx, "abc01", scifi))
+
+		mockLinkErr = datastore.ErrMissingReference
+		err := srv.AddBookCategory(ctx, "abc01", "unknown")
+		assert.True(t, errors.Is(err, service.ErrInvalidReference))
+
+		err = srv.AddBookCategory(customerCtx, "abc01", scifi)
+		assert.True(t, errors.Is(err, service.ErrForbidden))
+	})
+
+	t.Run("RemoveBookCategory", func(t *testing.T) {
+		reset()
+		mockLinkErr = datastore.ErrNotFound
+		err := srv.RemoveBookCategory(ctx, "abc01", scifi)
+		assert.True(t, errors.Is(err, service.ErrNotFound))
+	})
+
+	t.Run("TagBook", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+			require.NoError(t, srv.TagBook(ctx, "abc01", "  Space   Opera "))
+			assert.Equal(t, []string{"space opera"}, mockTagged)
+		})
+
+		t.Run("invalid", func(t *testing.T) {
+			reset()
+			err := srv.TagBook(ctx, "abc01", " ")
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+			assert.Empty(t, mockTagged)
+		})
+
+		t.Run("unknown book", func(t *testing.T) {
+			reset()
+			mockLinkErr = datastore.ErrMissingReference
+			err := srv.TagBook(ctx, "abc99", "space")
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+	})
+
+	t.Run("UntagBook", func(t *testing.T) {
+		reset()
+		mockLinkErr = datastore.ErrNotFound
+		err := srv.UntagBook(ctx, "abc01", "space")
+		assert.True(t, errors.Is(err, service.ErrNotFound))
+	})
+
+	t.Run("ListTags", func(t *testing.T) {
+		reset()
+		mockTags = []books.Tag{{Name: "space", Books: 2}}
+		tags, err := srv.ListTags(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, mockTags, tags)
+	})
+}
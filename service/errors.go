@@ -3,10 +3,27 @@ package service
 import (
 	"errors"
 	"fmt"
+
+	"bookshop/orders"
+	"bookshop/users"
 )
 
 var (
 	ErrDuplicate = NewErrDuplicate("")
+	ErrNotFound  = NewErrNotFound("", "")
+	ErrInvalid   = NewErrInvalid("", nil)
+
+	ErrInsufficientStock = NewErrInsufficientStock("")
+	ErrIllegalTransition = NewErrIllegalTransition("", "", "")
+	ErrForbidden         = NewErrForbidden("")
+
+	// ErrInvalidReference is returned when a request refers to a book, author,
+	// category or publisher that does not exist.
+	ErrInvalidReference = errors.New("book, author, category or publisher does not exist")
+
+	// ErrUnauthenticated is returned when a caller's email and password or
+	// session token do not identify an enabled user.
+	ErrUnauthenticated = errors.New("missing or invalid credentials")
 )
 
 func NewErrDuplicate(title string) error {
@@ -16,6 +33,51 @@ func NewErrDuplicate(title string) error {
 	}
 }
 
+// NewErrDuplicateAuthor returns a DuplicateError for an author that shares the
+// same full name and date of birth with an existing author.
+func NewErrDuplicateAuthor(name string) error {
+	return &DuplicateError{
+		Err:   errors.New("author already exists with same name and date of birth"),
+		Title: name,
+	}
+}
+
+// NewErrDuplicateUser returns a DuplicateError for a user whose email address
+// belongs to another user.
+func NewErrDuplicateUser(email string) error {
+	return &DuplicateError{
+		Err:   errors.New("user already exists with same email"),
+		Title: email,
+	}
+}
+
+// NewErrDuplicateReview returns a DuplicateError for a user reviewing a book
+// they have already reviewed.
+func NewErrDuplicateReview(bookID string) error {
+	return &DuplicateError{
+		Err:   errors.New("book already reviewed by same user"),
+		Title: bookID,
+	}
+}
+
+// NewErrDuplicateCategory returns a DuplicateError for a category named the
+// same as another category with the same parent.
+func NewErrDuplicateCategory(name string) error {
+	return &DuplicateError{
+		Err:   errors.New("category already exists with same name and parent"),
+		Title: name,
+	}
+}
+
+// NewErrDuplicatePublisher returns a DuplicateError for a publisher that shares
+// its name with another publisher.
+func NewErrDuplicatePublisher(name string) error {
+	return &DuplicateError{
+		Err:   errors.New("publisher already exists with same name"),
+		Title: name,
+	}
+}
+
 type DuplicateError struct {
 	Err   error
 	Title string
@@ -28,3 +90,156 @@ func (e *DuplicateError) Error() string {
 func (e *DuplicateError) Is(target error) bool {
 	return target == ErrDuplicate
 }
+
+// NewErrNotFound returns a NotFoundError for the given kind of record and its ID.
+func NewErrNotFound(kind, id string) error {
+	return &NotFoundError{
+		Kind: kind,
+		ID:   id,
+	}
+}
+
+type NotFoundError struct {
+	Kind string
+	ID   string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s not found: %s", e.Kind, e.ID)
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// NewErrInvalid returns a ValidationError for the given field and the reason it
+// was rejected.
+func NewErrInvalid(field string, err error) error {
+	return &ValidationError{
+		Err:   err,
+		Field: field,
+	}
+}
+
+type ValidationError struct {
+	Err   error
+	Field string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Err)
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalid
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// NewErrInsufficientStock returns a StockError for a stock movement that would
+// leave the book with fewer copies on hand than are reserved.
+func NewErrInsufficientStock(bookID string) error {
+	return &StockError{
+		BookID: bookID,
+	}
+}
+
+type StockError struct {
+	BookID string
+}
+
+func (e *StockError) Error() string {
+	return fmt.Sprintf("not enough stock for book %s", e.BookID)
+}
+
+func (e *StockError) Is(target error) bool {
+	return target == ErrInsufficientStock
+}
+
+// NewErrIllegalTransition returns a TransitionError for an order that cannot
+// move from its current status to the one asked for.
+func NewErrIllegalTransition(orderID string, from, to orders.Status) error {
+	return &TransitionError{
+		OrderID: orderID,
+		From:    from,
+		To:      to,
+	}
+}
+
+type TransitionError struct {
+	OrderID string
+	From    orders.Status
+	To      orders.Status
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("order %s cannot go from %s to %s", e.OrderID, e.From, e.To)
+}
+
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrIllegalTransition
+}
+
+// NewErrForbidden returns a PermissionError for a signed in user whose role
+// does not give them the permission needed.
+func NewErrForbidden(perm users.Permission) error {
+	return &PermissionError{
+		Permission: perm,
+	}
+}
+
+type PermissionError struct {
+	Permission users.Permission
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("permission required: %s", e.Permission)
+}
+
+func (e *PermissionError) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+// NewErrOutOfScope returns a ScopeError for an API key used to call an
+// operation that is not one of its scopes.
+func NewErrOutOfScope(op string) error {
+	return &ScopeError{
+		Operation: op,
+	}
+}
+
+type ScopeError struct {
+	Operation string
+}
+
+func (e *ScopeError) Error() string {
+	return fmt.Sprintf("api key is not scoped for %s", e.Operation)
+}
+
+func (e *ScopeError) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+// NewErrNotOwner returns an OwnerError for a signed in user changing a record
+// of the given kind that belongs to another user.
+func NewErrNotOwner(kind, id string) error {
+	return &OwnerError{
+		Kind: kind,
+		ID:   id,
+	}
+}
+
+type OwnerError struct {
+	Kind string
+	ID   string
+}
+
+func (e *OwnerError) Error() string {
+	return fmt.Sprintf("%s %s belongs to another user", e.Kind, e.ID)
+}
+
+func (e *OwnerError) Is(target error) bool {
+	return target == ErrForbidden
+}
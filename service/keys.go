@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"bookshop/auth"
+	"bookshop/datastore"
+	"bookshop/users"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// keyTouchInterval is how long after an API key's recorded last use a request
+// made with it records a new one, so busy keys do not write on every request.
+const keyTouchInterval = time.Minute
+
+// operations are the names of the SVC methods, which are the scopes an API key
+// can be given.
+var operations = func() map[string]bool {
+	t := reflect.TypeOf((*SVC)(nil)).Elem()
+	ops := make(map[string]bool, t.NumMethod())
+	for i := 0; i < t.NumMethod(); i++ {
+		ops[t.Method(i).Name] = true
+	}
+	return ops
+}()
+
+// Operations returns the names of the service operations, sorted.
+func Operations() []string {
+	ops := make([]string, 0, len(operations))
+	for op := range operations {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+// CreateAPIKey issues the user an API key that may call the operations in its
+// scopes, with the permissions of the user's role. Only admins may manage keys.
+func (s *Service) CreateAPIKey(ctx context.Context, userID, name string, scopes auth.Scopes) (auth.IssuedKey, error) {
+	if err := s.Authorize(ctx, users.PermManageUsers); err != nil {
+		return auth.IssuedKey{}, err
+	}
+	if len(name) > auth.MaxKeyNameLength {
+		return auth.IssuedKey{}, NewErrInvalid("name", fmt.Errorf("must be at most %d bytes long", auth.MaxKeyNameLength))
+	}
+	scopes = scopes.Normalize()
+	if err := validateScopes(scopes); err != nil {
+		return auth.IssuedKey{}, err
+	}
+
+	key, err := auth.NewAPIKey()
+	if err != nil {
+		return auth.IssuedKey{}, err
+	}
+	k := auth.APIKey{ID: uuid.NewV4().String(), UserID: userID, Name: name, KeyHash: auth.HashToken(key), Scopes: scopes}
+	if err := s.keyStore.CreateAPIKey(ctx, k); err != nil {
+		if errors.Is(err, datastore.ErrMissingReference) {
+			return auth.IssuedKey{}, NewErrNotFound("user", userID)
+		}
+		return auth.IssuedKey{}, err
+	}
+	return s.issuedKey(ctx, key)
+}
+
+// ListAPIKeys will return the user's API keys, oldest first.
+func (s *Service) ListAPIKeys(ctx context.Context, userID string) ([]auth.APIKey, error) {
+	if err := s.Authorize(ctx, users.PermManageUsers); err != nil {
+		return nil, err
+	}
+	if _, err := s.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.keyStore.ReadAPIKeys(ctx, userID)
+}
+
+// RotateAPIKey replaces the user's API key with a new one keeping its name and
+// scopes. The old key stops working.
+func (s *Service) RotateAPIKey(ctx context.Context, userID, id string) (auth.IssuedKey, error) {
+	if err := s.Authorize(ctx, users.PermManageUsers); err != nil {
+		return auth.IssuedKey{}, err
+	}
+	key, err := auth.NewAPIKey()
+	if err != nil {
+		return auth.IssuedKey{}, err
+	}
+	if err := s.keyStore.RotateAPIKey(ctx, userID, id, auth.HashToken(key)); err != nil {
+		return auth.IssuedKey{}, notFound(err, "api key", id)
+	}
+	return s.issuedKey(ctx, key)
+}
+
+// RevokeAPIKey deletes the user's API key.
+func (s *Service) RevokeAPIKey(ctx context.Context, userID, id string) error {
+	if err := s.Authorize(ctx, users.PermManageUsers); err != nil {
+		return err
+	}
+	return notFound(s.keyStore.DeleteAPIKey(ctx, userID, id), "api key", id)
+}
+
+// AuthenticateKey returns the enabled user the API key belongs to along with
+// the key, whose scopes limit what it may be used for, and records that the
+// key was used.
+func (s *Service) AuthenticateKey(ctx context.Context, key string) (users.User, auth.APIKey, error) {
+	k, err := s.keyStore.ReadAPIKeyByHash(ctx, auth.HashToken(key))
+	if errors.Is(err, datastore.ErrNotFound) {
+		return users.User{}, auth.APIKey{}, ErrUnauthenticated
+	}
+	if err != nil {
+		return users.User{}, auth.APIKey{}, err
+	}
+	u, err := s.signedInUser(ctx, k.UserID)
+	if err != nil {
+		return users.User{}, auth.APIKey{}, err
+	}
+
+	if now := time.Now(); k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= keyTouchInterval {
+		if err := s.keyStore.TouchAPIKey(ctx, k.ID, now); err != nil {
+			return users.User{}, auth.APIKey{}, err
+		}
+		k.LastUsedAt = &now
+	}
+	return u, k, nil
+}
+
+// issuedKey reads back the API key just stored for key.
+func (s *Service) issuedKey(ctx context.Context, key string) (auth.IssuedKey, error) {
+	k, err := s.keyStore.ReadAPIKeyByHash(ctx, auth.HashToken(key))
+	if err != nil {
+		return auth.IssuedKey{}, err
+	}
+	return auth.IssuedKey{APIKey: k, Key: key}, nil
+}
+
+// validateScopes checks there is at least one scope and each names an operation.
+func validateScopes(scopes auth.Scopes) error {
+	if len(scopes) == 0 {
+		return NewErrInvalid("scopes", errors.New("must name at least one operation"))
+	}
+	for _, sc := range scopes {
+		if !operations[sc] {
+			return NewErrInvalid("scopes", fmt.Errorf("unknown operation %q", sc))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"bookshop/auth"
+	"bookshop/datastore"
+	"bookshop/service"
+	"bookshop/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceKeys(t *testing.T) {
+	admin := users.User{ID: "usr02", Role: users.RoleAdmin, Permissions: users.RolePermissions[users.RoleAdmin]}
+	ctx := auth.WithUser(context.Background(), admin)
+	srv := service.NewService(service.Stores{Keys: &mockKeyStore{}, Users: &mockUserStore{}, Work: &mockUnitOfWork{}})
+	reset := func() {
+		mockUserErr, mockKeyErr, mockKeyTouched = nil, nil, nil
+		mockUser = users.User{ID: "usr01", Email: "warehouse@example.com", Role: users.RoleStaff, Enabled: true}
+		mockAPIKeys = map[string]auth.APIKey{}
+	}
+
+	t.Run("CreateAPIKey", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+
+			k, err := srv.CreateAPIKey(ctx, "usr01", "warehouse", auth.Scopes{"ReceiveStock", " GetStock", "ReceiveStock"})
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(k.Key, auth.KeyPrefix))
+			assert.Equal(t, auth.Scopes{"GetStock", "ReceiveStock"}, k.Scopes)
+			assert.Equal(t, auth.HashToken(k.Key), k.KeyHash, "only the hash is stored")
+			assert.Equal(t, "usr01", k.UserID)
+		})
+
+		t.Run("invalid", func(t *testing.T) {
+			for name, scopes := range map[string]auth.Scopes{
+				"no scopes":         {" "},
+				"unknown operation": {"ReceiveStock", "DropTables"},
+			} {
+				reset()
+				_, err := srv.CreateAPIKey(ctx, "usr01", "warehouse", scopes)
+				assert.True(t, errors.Is(err, service.ErrInvalid), name)
+			}
+			_, err := srv.CreateAPIKey(ctx, "usr01", strings.Repeat("x", auth.MaxKeyNameLength+1), auth.Scopes{"GetStock"})
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+			assert.Empty(t, mockAPIKeys)
+		})
+
+		t.Run("unknown user", func(t *testing.T) {
+			reset()
+			mockKeyErr = datastore.ErrMissingReference
+			_, err := srv.CreateAPIKey(ctx, "usr99", "warehouse", auth.Scopes{"GetStock"})
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+
+		t.Run("not an admin", func(t *testing.T) {
+			reset()
+			staff := auth.WithUser(context.Background(), mockUser)
+			_, err := srv.CreateAPIKey(staff, "usr01", "warehouse", auth.Scopes{"GetStock"})
+			assert.True(t, errors.Is(err, service.ErrForbidden))
+		})
+	})
+
+	t.Run("AuthenticateKey", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+			issued, err := srv.CreateAPIKey(ctx, "usr01", "warehouse", auth.Scopes{"ReceiveStock"})
+			require.NoError(t, err)
+
+			u, k, err := srv.AuthenticateKey(context.Background(), issued.Key)
+			require.NoError(t, err)
+			assert.Equal(t, "usr01", u.ID)
+			assert.Equal(t, users.RolePermissions[users.RoleStaff], u.Permissions, "a key acts with its user's permissions")
+			assert.Equal(t, auth.Scopes{"ReceiveStock"}, k.Scopes)
+			assert.Equal(t, []string{issued.ID}, mockKeyTouched)
+
+			_, _, err = srv.AuthenticateKey(context.Background(), issued.Key)
+			require.NoError(t, err)
+			assert.Len(t, mockKeyTouched, 1, "a recent use is not recorded again")
+		})
+
+		t.Run("refused", func(t *testing.T) {
+			reset()
+			issued, err := srv.CreateAPIKey(ctx, "usr01", "warehouse", auth.Scopes{"ReceiveStock"})
+			require.NoError(t, err)
+
+			_, _, err = srv.AuthenticateKey(context.Background(), "bsk_unknown")
+			assert.True(t, errors.Is(err, service.ErrUnauthenticated))
+			mockUser.Enabled = false
+			_, _, err = srv.AuthenticateKey(context.Background(), issued.Key)
+			assert.True(t, errors.Is(err, service.ErrUnauthenticated), "keys of disabled users stop working")
+		})
+	})
+
+	t.Run("RotateAPIKey", func(t *testing.T) {
+		reset()
+		issued, err := srv.CreateAPIKey(ctx, "usr01", "warehouse", auth.Scopes{"ReceiveStock"})
+		require.NoError(t, err)
+
+		rotated, err := srv.RotateAPIKey(ctx, "usr01", issued.ID)
+		require.NoError(t, err)
+		assert.Equal(t, issued.ID, rotated.ID)
+		assert.Equal(t, issued.Scopes, rotated.Scopes)
+		assert.NotEqual(t, issued.Key, rotated.Key)
+		_, _, err = srv.AuthenticateKey(context.Background(), issued.Key)
+		assert.True(t, errors.Is(err, service.ErrUnauthenticated), "the old key stops working")
+		_, _, err = srv.AuthenticateKey(context.Background(), rotated.Key)
+		assert.NoError(t, err)
+
+		_, err = srv.RotateAPIKey(ctx, "usr99", issued.ID)
+		assert.True(t, errors.Is(err, service.ErrNotFound), "keys are rotated through the user they belong to")
+	})
+
+	t.Run("RevokeAPIKey", func(t *testing.T) {
+		reset()
+		issued, err := srv.CreateAPIKey(ctx, "usr01", "warehouse", auth.Scopes{"ReceiveStock"})
+		require.NoError(t, err)
+
+		require.NoError(t, srv.RevokeAPIKey(ctx, "usr01", issued.ID))
+		_, _, err = srv.AuthenticateKey(context.Background(), issued.Key)
+		assert.True(t, errors.Is(err, service.ErrUnauthenticated))
+		err = srv.RevokeAPIKey(ctx, "usr01", issued.ID)
+		assert.True(t, errors.Is(err, service.ErrNotFound))
+	})
+
+	t.Run("ListAPIKeys", func(t *testing.T) {
+		reset()
+		_, err := srv.CreateAPIKey(ctx, "usr01", "warehouse", auth.Scopes{"ReceiveStock"})
+		require.NoError(t, err)
+
+		keys, err := srv.ListAPIKeys(ctx, "usr01")
+		require.NoError(t, err)
+		assert.Len(t, keys, 1)
+
+		mockUserErr = datastore.ErrNotFound
+		_, err = srv.ListAPIKeys(ctx, "usr99")
+		assert.True(t, errors.Is(err, service.ErrNotFound))
+	})
+
+	t.Run("Scoped", func(t *testing.T) {
+		reset()
+		scoped := service.Scoped(&srv)
+		keyCtx := auth.WithScopes(ctx, auth.Scopes{"ListAPIKeys"})
+
+		_, err := scoped.ListAPIKeys(keyCtx, "usr01")
+		assert.NoError(t, err)
+		_, err = scoped.CreateAPIKey(keyCtx, "usr01", "more", auth.Scopes{"ReceiveStock"})
+		assert.True(t, errors.Is(err, service.ErrForbidden))
+		assert.Equal(t, "api key is not scoped for CreateAPIKey", err.Error())
+		_, err = scoped.CreateAPIKey(ctx, "usr01", "more", auth.Scopes{"ReceiveStock"})
+		assert.NoError(t, err, "calls made without a key are not limited")
+	})
+
+	t.Run("Operations", func(t *testing.T) {
+		ops := service.Operations()
+		assert.Contains(t, ops, "ReceiveStock")
+		assert.Contains(t, ops, "AddBook")
+		assert.NotContains(t, ops, "PurgeExpiredSessions", "only SVC operations can be scopes")
+	})
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"bookshop/auth"
+	"bookshop/datastore"
+	"bookshop/orders"
+	"bookshop/users"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// PlaceOrder will order the books and quantities in the given lines at their
+// current list prices. The title, ISBN and price of each book are kept with the
+// order, so later changes to the books do not alter it. Lines for the same book
+// are combined. The order belongs to the signed in user placing it.
+func (s *Service) PlaceOrder(ctx context.Context, lines []orders.Line) (orders.Order, error) {
+	u, ok := auth.UserFrom(ctx)
+	if !ok {
+		return orders.Order{}, ErrUnauthenticated
+	}
+	if len(lines) == 0 {
+		return orders.Order{}, NewErrInvalid("lines", errors.New("an order needs at least one book"))
+	}
+
+	var o orders.Order
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		placed := orders.Order{ID: uuid.NewV4().String(), Status: orders.Pending, UserID: u.ID}
+		for _, l := range combineLines(lines) {
+			bk, err := s.checkForSale(ctx, l.BookID, l.Quantity)
+			if err != nil {
+				return err
+			}
+			placed.Lines = append(placed.Lines, orders.Line{
+				BookID:    bk.ID,
+				Title:     bk.Title,
+				ISBN:      bk.ISBN,
+				Quantity:  l.Quantity,
+				UnitPrice: *bk.ListPrice,
+			})
+		}
+		if _, err := placed.WithTotals(); err != nil {
+			return NewErrInvalid("lines", err)
+		}
+		if err := s.orderStore.CreateOrder(ctx, placed); err != nil {
+			return err
+		}
+
+		var err error
+		o, err = s.readOrder(ctx, placed.ID)
+		return err
+	})
+	if err != nil {
+		return orders.Order{}, err
+	}
+	return o, nil
+}
+
+// CheckoutCart will place an order for the items in the cart with the given
+// token and then delete the cart.
+func (s *Service) CheckoutCart(ctx context.Context, token string) (orders.Order, error) {
+	var o orders.Order
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		c, err := s.cartStore.ReadCart(ctx, token)
+		if err != nil {
+			return notFound(err, "cart", token)
+		}
+		if len(c.Items) == 0 {
+			return NewErrInvalid("cart", errors.New("cannot check out an empty cart"))
+		}
+		var lines []orders.Line
+		for _, item := range c.Items {
+			lines = append(lines, orders.Line{BookID: item.BookID, Quantity: item.Quantity})
+		}
+		if o, err = s.PlaceOrder(ctx, lines); err != nil {
+			return err
+		}
+		return s.cartStore.DeleteCart(ctx, token)
+	})
+	if err != nil {
+		return orders.Order{}, err
+	}
+	return o, nil
+}
+
+// GetOrder will return the order with the given ID along with its totals.
+// Users may read their own orders and staff anyone's.
+func (s *Service) GetOrder(ctx context.Context, id string) (orders.Order, error) {
+	if _, ok := auth.UserFrom(ctx); !ok {
+		return orders.Order{}, ErrUnauthenticated
+	}
+	o, err := s.readOrder(ctx, id)
+	if err != nil {
+		return orders.Order{}, err
+	}
+	if err := authorizeOwner(ctx, o.UserID, users.PermManageOrders); err != nil {
+		return orders.Order{}, err
+	}
+	return o, nil
+}
+
+func (s *Service) readOrder(ctx context.Context, id string) (orders.Order, error) {
+	o, err := s.orderStore.ReadOrder(ctx, id)
+	if err != nil {
+		return orders.Order{}, notFound(err, "order", id)
+	}
+	return o.WithTotals()
+}
+
+// ListOrders will return a page of orders matching the query, newest first.
+// Users are only shown their own orders, while staff see everyone's.
+func (s *Service) ListOrders(ctx context.Context, q orders.OrderQuery) (orders.Page, error) {
+	u, ok := auth.UserFrom(ctx)
+	if !ok {
+		return orders.Page{}, ErrUnauthenticated
+	}
+	if !u.Can(users.PermManageOrders) {
+		q.UserID = u.ID
+	}
+	if err := q.Validate(); err != nil {
+		return orders.Page{}, NewErrInvalid("query", err)
+	}
+	page, err := s.orderStore.ReadOrders(ctx, q)
+	if err != nil {
+		return orders.Page{}, err
+	}
+	for i, o := range page.Orders {
+		if page.Orders[i], err = o.WithTotals(); err != nil {
+			return orders.Page{}, err
+		}
+	}
+	return page, nil
+}
+
+// CancelOrder will cancel an order that has not been paid for yet. Users may
+// cancel their own orders and staff anyone's.
+func (s *Service) CancelOrder(ctx context.Context, id string) (orders.Order, error) {
+	if _, ok := auth.UserFrom(ctx); !ok {
+		return orders.Order{}, ErrUnauthenticated
+	}
+	return s.moveOrder(ctx, id, orders.Cancelled, func(current orders.Order) error {
+		return authorizeOwner(ctx, current.UserID, users.PermManageOrders)
+	})
+}
+
+// UpdateOrderStatus will move the order on to the given status, returning a
+// TransitionError if the order cannot go there from its current status. Only
+// staff may move orders on.
+func (s *Service) UpdateOrderStatus(ctx context.Context, id string, status orders.Status) (orders.Order, error) {
+	if err := s.Authorize(ctx, users.PermManageOrders); err != nil {
+		return orders.Order{}, err
+	}
+	if _, err := orders.ParseStatus(string(status)); err != nil {
+		return orders.Order{}, NewErrInvalid("status", err)
+	}
+	return s.moveOrder(ctx, id, status, func(orders.Order) error { return nil })
+}
+
+// moveOrder moves the order on to the status once allowed, which checks the
+// caller may move the order as it currently is.
+func (s *Service) moveOrder(ctx context.Context, id string, status orders.Status, allowed func(current orders.Order) error) (orders.Order, error) {
+	var o orders.Order
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		current, err := s.orderStore.ReadOrder(ctx, id)
+		if err != nil {
+			return notFound(err, "order", id)
+		}
+		if err := allowed(current); err != nil {
+			return err
+		}
+		if !current.Status.CanBecome(status) {
+			return NewErrIllegalTransition(id, current.Status, status)
+		}
+		// the order was read in the same unit of work, so it can only have
+		// left its status if another request moved it first
+		if err := s.orderStore.UpdateOrderStatus(ctx, id, current.Status, status); err != nil {
+			if errors.Is(err, datastore.ErrNotFound) {
+				return NewErrIllegalTransition(id, current.Status, status)
+			}
+			return err
+		}
+
+		o, err = s.readOrder(ctx, id)
+		return err
+	})
+	if err != nil {
+		return orders.Order{}, err
+	}
+	return o, nil
+}
+
+// combineLines returns the lines with the quantities of lines for the same
+// book added together, in the order each book first appears.
+func combineLines(lines []orders.Line) []orders.Line {
+	var combined []orders.Line
+	idx := map[string]int{}
+	for _, l := range lines {
+		if i, ok := idx[l.BookID]; ok {
+			combined[i].Quantity += l.Quantity
+			continue
+		}
+		idx[l.BookID] = len(combined)
+		combined = append(combined, orders.Line{BookID: l.BookID, Quantity: l.Quantity})
+	}
+	return combined
+}
@@ -0,0 +1,270 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bookshop/auth"
+	"bookshop/books"
+	"bookshop/carts"
+	"bookshop/datastore"
+	"bookshop/money"
+	"bookshop/orders"
+	"bookshop/service"
+	"bookshop/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceOrders(t *testing.T) {
+	// orders are placed by ann, usr01, unless a test says otherwise
+	ctx := auth.WithUser(context.Background(), users.User{ID: "usr01", Role: users.RoleCustomer})
+	bobCtx := auth.WithUser(context.Background(), users.User{ID: "usr02", Role: users.RoleCustomer})
+	staffCtx := auth.WithUser(context.Background(), users.User{ID: "usr03", Role: users.RoleStaff, Permissions: users.RolePermissions[users.RoleStaff]})
+	srv := service.NewService(service.Stores{Books: &mockBookStore{}, Carts: &mockCartStore{}, Orders: &mockOrderStore{}, Work: &mockUnitOfWork{}})
+	price := money.New(1299, money.USD)
+	reset := func() {
+		mockOrderErr, mockOrderMoveErr, mockCartErr, mockBooksErr = nil, nil, nil, nil
+		mockOrder, mockOrderMoved, mockOrderQuery = orders.Order{}, nil, orders.OrderQuery{}
+		mockBook = books.Book{ID: "abc01", Title: "Dune", ISBN: "9781861972712", ListPrice: &price}
+		mockCart = carts.Cart{Items: []carts.Item{{BookID: "abc01", Quantity: 2}}}
+		mockCartDeleted = nil
+	}
+
+	t.Run("PlaceOrder", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+
+			o, err := srv.PlaceOrder(ctx, []orders.Line{{BookID: "abc01", Quantity: 2}, {BookID: "abc01", Quantity: 1}})
+			require.NoError(t, err)
+			assert.NotEmpty(t, o.ID)
+			assert.Equal(t, orders.Pending, o.Status)
+			assert.Equal(t, "usr01", o.UserID, "the order belongs to whoever placed it")
+			require.Len(t, o.Lines, 1)
+			assert.Equal(t, orders.Line{
+				BookID:    "abc01",
+				Title:     "Dune",
+				ISBN:      "9781861972712",
+				Quantity:  3,
+				UnitPrice: price,
+				LineTotal: money.New(3897, money.USD),
+			}, o.Lines[0])
+			assert.Equal(t, []money.Money{money.New(3897, money.USD)}, o.Totals)
+		})
+
+		t.Run("no lines", func(t *testing.T) {
+			reset()
+
+			_, err := srv.PlaceOrder(ctx, nil)
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+		})
+
+		t.Run("signed out", func(t *testing.T) {
+			reset()
+
+			_, err := srv.PlaceOrder(context.Background(), []orders.Line{{BookID: "abc01", Quantity: 1}})
+			assert.True(t, errors.Is(err, service.ErrUnauthenticated))
+			assert.Empty(t, mockOrder.ID)
+		})
+
+		t.Run("invalid quantity", func(t *testing.T) {
+			reset()
+			for _, n := range []int{0, -1, carts.MaxQuantity + 1} {
+				_, err := srv.PlaceOrder(ctx, []orders.Line{{BookID: "abc01", Quantity: n}})
+				assert.True(t, errors.Is(err, service.ErrInvalid), n)
+			}
+			assert.Empty(t, mockOrder.ID)
+		})
+
+		t.Run("not for sale", func(t *testing.T) {
+			reset()
+			mockBook.ListPrice = nil
+
+			_, err := srv.PlaceOrder(ctx, []orders.Line{{BookID: "abc01", Quantity: 1}})
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+		})
+
+		t.Run("unknown book", func(t *testing.T) {
+			reset()
+			mockBooksErr = datastore.ErrNotFound
+			defer func() { mockBooksErr = nil }()
+
+			_, err := srv.PlaceOrder(ctx, []orders.Line{{BookID: "nope", Quantity: 1}})
+			assert.True(t, errors.Is(err, service.ErrInvalidReference))
+		})
+	})
+
+	t.Run("CheckoutCart", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+
+			o, err := srv.CheckoutCart(ctx, "tok01")
+			require.NoError(t, err)
+			require.Len(t, o.Lines, 1)
+			assert.Equal(t, 2, o.Lines[0].Quantity)
+			assert.Equal(t, []string{"tok01"}, mockCartDeleted)
+		})
+
+		t.Run("empty cart", func(t *testing.T) {
+			reset()
+			mockCart.Items = nil
+
+			_, err := srv.CheckoutCart(ctx, "tok01")
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+			assert.Empty(t, mockCartDeleted)
+		})
+
+		t.Run("unknown cart", func(t *testing.T) {
+			reset()
+			mockCartErr = datastore.ErrNotFound
+
+			_, err := srv.CheckoutCart(ctx, "tok01")
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+	})
+
+	t.Run("GetOrder", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+			mockOrder = orders.Order{ID: "ord01", Status: orders.Pending, UserID: "usr01"}
+
+			o, err := srv.GetOrder(ctx, "ord01")
+			require.NoError(t, err)
+			assert.Equal(t, "ord01", o.ID)
+			_, err = srv.GetOrder(staffCtx, "ord01")
+			assert.NoError(t, err, "staff read anyone's orders")
+		})
+
+		t.Run("another user", func(t *testing.T) {
+			reset()
+			mockOrder = orders.Order{ID: "ord01", Status: orders.Pending, UserID: "usr01"}
+
+			_, err := srv.GetOrder(bobCtx, "ord01")
+			assert.True(t, errors.Is(err, service.ErrForbidden))
+			_, err = srv.GetOrder(context.Background(), "ord01")
+			assert.True(t, errors.Is(err, service.ErrUnauthenticated))
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			reset()
+			mockOrderErr = datastore.ErrNotFound
+
+			_, err := srv.GetOrder(ctx, "ord01")
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+	})
+
+	t.Run("ListOrders", func(t *testing.T) {
+		t.Run("own orders", func(t *testing.T) {
+			reset()
+
+			_, err := srv.ListOrders(ctx, orders.OrderQuery{UserID: "usr02"})
+			require.NoError(t, err)
+			assert.Equal(t, "usr01", mockOrderQuery.UserID, "customers only list their own orders")
+		})
+
+		t.Run("staff", func(t *testing.T) {
+			reset()
+
+			_, err := srv.ListOrders(staffCtx, orders.OrderQuery{})
+			require.NoError(t, err)
+			assert.Empty(t, mockOrderQuery.UserID)
+			_, err = srv.ListOrders(staffCtx, orders.OrderQuery{UserID: "usr02"})
+			require.NoError(t, err)
+			assert.Equal(t, "usr02", mockOrderQuery.UserID)
+		})
+
+		t.Run("invalid", func(t *testing.T) {
+			reset()
+
+			_, err := srv.ListOrders(ctx, orders.OrderQuery{Status: "lost"})
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+			_, err = srv.ListOrders(context.Background(), orders.OrderQuery{})
+			assert.True(t, errors.Is(err, service.ErrUnauthenticated))
+		})
+	})
+
+	t.Run("CancelOrder", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+			mockOrder = orders.Order{ID: "ord01", Status: orders.Pending, UserID: "usr01"}
+
+			o, err := srv.CancelOrder(ctx, "ord01")
+			require.NoError(t, err)
+			assert.Equal(t, orders.Cancelled, o.Status)
+		})
+
+		t.Run("staff", func(t *testing.T) {
+			reset()
+			mockOrder = orders.Order{ID: "ord01", Status: orders.Pending, UserID: "usr01"}
+
+			_, err := srv.CancelOrder(staffCtx, "ord01")
+			assert.NoError(t, err)
+		})
+
+		t.Run("another user", func(t *testing.T) {
+			reset()
+			mockOrder = orders.Order{ID: "ord01", Status: orders.Pending, UserID: "usr01"}
+
+			_, err := srv.CancelOrder(bobCtx, "ord01")
+			assert.True(t, errors.Is(err, service.ErrForbidden))
+			assert.Nil(t, mockOrderMoved)
+		})
+	})
+
+	t.Run("UpdateOrderStatus", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+			mockOrder = orders.Order{ID: "ord01", Status: orders.Pending}
+
+			o, err := srv.UpdateOrderStatus(staffCtx, "ord01", orders.Paid)
+			require.NoError(t, err)
+			assert.Equal(t, orders.Paid, o.Status)
+			assert.Equal(t, []orders.Status{orders.Pending, orders.Paid}, mockOrderMoved)
+		})
+
+		t.Run("not staff", func(t *testing.T) {
+			reset()
+			mockOrder = orders.Order{ID: "ord01", Status: orders.Pending, UserID: "usr01"}
+
+			_, err := srv.UpdateOrderStatus(ctx, "ord01", orders.Paid)
+			assert.True(t, errors.Is(err, service.ErrForbidden), "owners do not pay for orders by saying so")
+			assert.Nil(t, mockOrderMoved)
+		})
+
+		t.Run("illegal", func(t *testing.T) {
+			reset()
+			mockOrder = orders.Order{ID: "ord01", Status: orders.Shipped, UserID: "usr01"}
+
+			_, err := srv.CancelOrder(ctx, "ord01")
+			assert.True(t, errors.Is(err, service.ErrIllegalTransition))
+			assert.Nil(t, mockOrderMoved)
+		})
+
+		t.Run("moved meanwhile", func(t *testing.T) {
+			reset()
+			mockOrder = orders.Order{ID: "ord01", Status: orders.Pending, UserID: "usr01"}
+			mockOrderMoveErr = datastore.ErrNotFound
+
+			_, err := srv.CancelOrder(ctx, "ord01")
+			assert.True(t, errors.Is(err, service.ErrIllegalTransition))
+		})
+
+		t.Run("unknown status", func(t *testing.T) {
+			reset()
+
+			_, err := srv.UpdateOrderStatus(staffCtx, "ord01", "lost")
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			reset()
+			mockOrderErr = datastore.ErrNotFound
+
+			_, err := srv.CancelOrder(ctx, "ord01")
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+	})
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"bookshop/datastore"
+	"bookshop/publishers"
+	"bookshop/users"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// AddPublisher adds the given publisher generating its UUID. A publisher
+// sharing its name with an existing publisher is rejected. Only staff may
+// change the catalogue.
+func (s *Service) AddPublisher(ctx context.Context, pub publishers.Publisher) (publishers.Publisher, error) {
+	if err := s.Authorize(ctx, users.PermEditBooks); err != nil {
+		return publishers.Publisher{}, err
+	}
+	pub.ID = uuid.NewV4().String()
+	if err := s.upsertPublisher(ctx, &pub); err != nil {
+		return publishers.Publisher{}, err
+	}
+	return pub, nil
+}
+
+// GetPublisher will return the details for a publisher by the given id
+// including their list of books.
+func (s *Service) GetPublisher(ctx context.Context, id string) (publishers.Publisher, error) {
+	pub, err := s.pubStore.ReadPublisherAndBooks(ctx, id)
+	if err != nil {
+		return publishers.Publisher{}, notFound(err, "publisher", id)
+	}
+	return pub, nil
+}
+
+// ListPublishers will return a page of publishers matching the query, sorted
+// by name.
+func (s *Service) ListPublishers(ctx context.Context, q publishers.PublisherQuery) (publishers.Page, error) {
+	if err := q.Validate(); err != nil {
+		return publishers.Page{}, NewErrInvalid("query", err)
+	}
+	return s.pubStore.ReadPublishers(ctx, q)
+}
+
+// RemovePublisher will delete the publisher from the datastore, leaving their
+// books without a publisher.
+func (s *Service) RemovePublisher(ctx context.Context, id string) error {
+	if err := s.Authorize(ctx, users.PermEditBooks); err != nil {
+		return err
+	}
+	return notFound(s.pubStore.DeletePublisher(ctx, id), "publisher", id)
+}
+
+// UpdatePublisher will update the given publisher with the information from
+// the request.
+func (s *Service) UpdatePublisher(ctx context.Context, pub publishers.Publisher) error {
+	if err := s.Authorize(ctx, users.PermEditBooks); err != nil {
+		return err
+	}
+	return s.upsertPublisher(ctx, &pub)
+}
+
+// upsertPublisher tidies up and validates the publisher before saving it.
+func (s *Service) upsertPublisher(ctx context.Context, pub *publishers.Publisher) error {
+	pub.Name, pub.Website = strings.TrimSpace(pub.Name), strings.TrimSpace(pub.Website)
+	if err := pub.Validate(); err != nil {
+		return NewErrInvalid("publisher", err)
+	}
+	if err := s.pubStore.UpsertPublishers(ctx, []publishers.Publisher{*pub}); err != nil {
+		if errors.Is(err, datastore.ErrDuplicate) {
+			return NewErrDuplicatePublisher(pub.Name)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bookshop/auth"
+	"bookshop/books"
+	"bookshop/datastore"
+	"bookshop/publishers"
+	"bookshop/service"
+	"bookshop/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServicePublishers(t *testing.T) {
+	staff := users.User{ID: "usr01", Role: users.RoleStaff, Permissions: users.RolePermissions[users.RoleStaff]}
+	customer := users.User{ID: "usr02", Role: users.RoleCustomer, Permissions: users.RolePermissions[users.RoleCustomer]}
+	ctx := auth.WithUser(context.Background(), staff)
+	customerCtx := auth.WithUser(context.Background(), customer)
+
+	srv := service.NewService(service.Stores{Publishers: &mockPublisherStore{}, Work: &mockUnitOfWork{}})
+	reset := func() {
+		mockPublisherErr, mockPublisherUpserted = nil, nil
+		mockPublisher = publishers.Publisher{
+			ID:    "pub01",
+			Name:  "Chilton Books",
+			Books: []books.Book{{ID: "abc01", Title: "Dune", ISBN: "9780441013593"}},
+		}
+	}
+
+	t.Run("AddPublisher", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+			pub, err := srv.AddPublisher(ctx, publishers.Publisher{Name: " Ace Books ", Website: "https://ace.example.com"})
+			require.NoError(t, err)
+			assert.NotEmpty(t, pub.ID)
+			assert.Equal(t, "Ace Books", pub.Name)
+			require.Len(t, mockPublisherUpserted, 1)
+			assert.Equal(t, pub, mockPublisherUpserted[0])
+		})
+
+		t.Run("invalid", func(t *testing.T) {
+			reset()
+			for _, pub := range []publishers.Publisher{
+				{Name: " "},
+				{Name: "Ace Books", Website: "ace.example.com"},
+				{Name: "Ace Books", Website: "ftp://ace.example.com"},
+			} {
+				_, err := srv.AddPublisher(ctx, pub)
+				assert.True(t, errors.Is(err, service.ErrInvalid), pub)
+			}
+			assert.Nil(t, mockPublisherUpserted)
+		})
+
+		t.Run("duplicate", func(t *testing.T) {
+			reset()
+			mockPublisherErr = datastore.ErrDuplicate
+			_, err := srv.AddPublisher(ctx, publishers.Publisher{Name: "chilton books"})
+			assert.True(t, errors.Is(err, service.ErrDuplicate))
+		})
+
+		t.Run("forbidden", func(t *testing.T) {
+			reset()
+			_, err := srv.AddPublisher(customerCtx, publishers.Publisher{Name: "Ace Books"})
+			assert.True(t, errors.Is(err, service.ErrForbidden))
+		})
+	})
+
+	t.Run("GetPublisher", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+			pub, err := srv.GetPublisher(context.Background(), "pub01")
+			require.NoError(t, err)
+			assert.Equal(t, mockPublisher, pub)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			reset()
+			mockPublisherErr = datastore.ErrNotFound
+			_, err := srv.GetPublisher(context.Background(), "nope")
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+	})
+
+	t.Run("ListPublishers", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+			page, err := srv.ListPublishers(context.Background(), publishers.PublisherQuery{NamePrefix: "chil"})
+			require.NoError(t, err)
+			assert.Len(t, page.Publishers, 1)
+		})
+
+		t.Run("invalid query", func(t *testing.T) {
+			reset()
+			_, err := srv.ListPublishers(context.Background(), publishers.PublisherQuery{Cursor: "garbage"})
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+		})
+	})
+
+	t.Run("UpdatePublisher", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+			err := srv.UpdatePublisher(ctx, publishers.Publisher{ID: "pub01", Name: "Chilton Company"})
+			require.NoError(t, err)
+			require.Len(t, mockPublisherUpserted, 1)
+			assert.Equal(t, "pub01", mockPublisherUpserted[0].ID)
+		})
+
+		t.Run("duplicate", func(t *testing.T) {
+			reset()
+			mockPublisherErr = datastore.ErrDuplicate
+			err := srv.UpdatePublisher(ctx, publishers.Publisher{ID: "pub01", Name: "Ace Books"})
+			assert.True(t, errors.Is(err, service.ErrDuplicate))
+		})
+
+		t.Run("forbidden", func(t *testing.T) {
+			reset()
+			err := srv.UpdatePublisher(customerCtx, publishers.Publisher{ID: "pub01", Name: "Ace Books"})
+			assert.True(t, errors.Is(err, service.ErrForbidden))
+		})
+	})
+
+	t.Run("RemovePublisher", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+			assert.NoError(t, srv.RemovePublisher(ctx, "pub01"))
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			reset()
+			mockPublisherErr = datastore.ErrNotFound
+			err := srv.RemovePublisher(ctx, "nope")
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+
+		t.Run("forbidden", func(t *testing.T) {
+			reset()
+			err := srv.RemovePublisher(customerCtx, "pub01")
+			assert.True(t, errors.Is(err, service.ErrForbidden))
+		})
+	})
+}
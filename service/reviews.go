@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"bookshop/auth"
+	"bookshop/datastore"
+	"bookshop/reviews"
+	"bookshop/users"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// AddReview adds the signed in user's review of a book. The review waits for
+// moderation before it is shown to others or counted in the book's rating.
+// Each user may review a book once.
+func (s *Service) AddReview(ctx context.Context, r reviews.Review) (reviews.Review, error) {
+	u, ok := auth.UserFrom(ctx)
+	if !ok {
+		return reviews.Review{}, ErrUnauthenticated
+	}
+	if err := r.Validate(); err != nil {
+		return reviews.Review{}, NewErrInvalid("review", err)
+	}
+
+	r.ID, r.UserID, r.Status = uuid.NewV4().String(), u.ID, reviews.Pending
+	var added reviews.Review
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.revStore.CreateReview(ctx, r); err != nil {
+			switch {
+			case errors.Is(err, datastore.ErrDuplicate):
+				return NewErrDuplicateReview(r.BookID)
+			case errors.Is(err, datastore.ErrMissingReference):
+				return NewErrNotFound("book", r.BookID)
+			}
+			return err
+		}
+
+		var err error
+		added, err = s.revStore.ReadReview(ctx, r.BookID, r.ID)
+		return err
+	})
+	if err != nil {
+		return reviews.Review{}, err
+	}
+	return added, nil
+}
+
+// GetReview will return the book's review with the given ID. Reviews that are
+// not approved are only shown to their author and to moderators.
+func (s *Service) GetReview(ctx context.Context, bookID, id string) (reviews.Review, error) {
+	r, err := s.revStore.ReadReview(ctx, bookID, id)
+	if err != nil {
+		return reviews.Review{}, notFound(err, "review", id)
+	}
+	if r.Status != reviews.Approved && !canSeeReview(ctx, r) {
+		return reviews.Review{}, NewErrNotFound("review", id)
+	}
+	return r, nil
+}
+
+// ListReviews will return a page of the book's reviews matching the query,
+// newest first. Only moderators may list reviews that are not approved; for
+// everyone else the query is limited to approved reviews.
+func (s *Service) ListReviews(ctx context.Context, bookID string, q reviews.ReviewQuery) (reviews.Page, error) {
+	if err := q.Validate(); err != nil {
+		return reviews.Page{}, NewErrInvalid("query", err)
+	}
+	if !canModerate(ctx) {
+		if q.Status != "" && q.Status != reviews.Approved {
+			return reviews.Page{}, s.Authorize(ctx, users.PermModerateReviews)
+		}
+		q.Status = reviews.Approved
+	}
+	if _, err := s.GetBook(ctx, bookID); err != nil {
+		return reviews.Page{}, err
+	}
+	return s.revStore.ReadReviews(ctx, bookID, q)
+}
+
+// UpdateReview changes the rating and text of the signed in user's review,
+// which then waits for moderation again. Users may only change their own
+// reviews.
+func (s *Service) UpdateReview(ctx context.Context, r reviews.Review) (reviews.Review, error) {
+	u, ok := auth.UserFrom(ctx)
+	if !ok {
+		return reviews.Review{}, ErrUnauthenticated
+	}
+	if err := r.Validate(); err != nil {
+		return reviews.Review{}, NewErrInvalid("review", err)
+	}
+
+	var updated reviews.Review
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		current, err := s.revStore.ReadReview(ctx, r.BookID, r.ID)
+		if err != nil {
+			return notFound(err, "review", r.ID)
+		}
+		if current.UserID != u.ID {
+			return NewErrNotOwner("review", r.ID)
+		}
+		current.Rating, current.Body, current.Status = r.Rating, r.Body, reviews.Pending
+		if err := s.revStore.UpdateReview(ctx, current); err != nil {
+			return err
+		}
+
+		updated, err = s.revStore.ReadReview(ctx, r.BookID, r.ID)
+		return err
+	})
+	if err != nil {
+		return reviews.Review{}, err
+	}
+	return updated, nil
+}
+
+// ModerateReview will move the review to the given status, approving or
+// rejecting it. Only staff may moderate reviews.
+func (s *Service) ModerateReview(ctx context.Context, bookID, id string, status reviews.Status) (reviews.Review, error) {
+	if err := s.Authorize(ctx, users.PermModerateReviews); err != nil {
+		return reviews.Review{}, err
+	}
+	if _, err := reviews.ParseStatus(string(status)); err != nil {
+		return reviews.Review{}, NewErrInvalid("status", err)
+	}
+
+	var r reviews.Review
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.revStore.SetReviewStatus(ctx, bookID, id, status); err != nil {
+			return notFound(err, "review", id)
+		}
+
+		var err error
+		r, err = s.revStore.ReadReview(ctx, bookID, id)
+		return err
+	})
+	if err != nil {
+		return reviews.Review{}, err
+	}
+	return r, nil
+}
+
+// RemoveReview will delete the review. Users may remove their own reviews and
+// moderators may remove anyone's.
+func (s *Service) RemoveReview(ctx context.Context, bookID, id string) error {
+	u, ok := auth.UserFrom(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		current, err := s.revStore.ReadReview(ctx, bookID, id)
+		if err != nil {
+			return notFound(err, "review", id)
+		}
+		if current.UserID != u.ID && !u.Can(users.PermModerateReviews) {
+			return NewErrForbidden(users.PermModerateReviews)
+		}
+		return notFound(s.revStore.DeleteReview(ctx, bookID, id), "review", id)
+	})
+}
+
+// canModerate reports whether the signed in user carried by ctx, if any, may
+// moderate reviews.
+func canModerate(ctx context.Context) bool {
+	u, ok := auth.UserFrom(ctx)
+	return ok && u.Can(users.PermModerateReviews)
+}
+
+// canSeeReview reports whether the signed in user carried by ctx wrote the
+// review or may moderate it.
+func canSeeReview(ctx context.Context, r reviews.Review) bool {
+	u, ok := auth.UserFrom(ctx)
+	return ok && (u.ID == r.UserID || u.Can(users.PermModerateReviews))
+}
@@ -0,0 +1,200 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"bookshop/auth"
+	"bookshop/books"
+	"bookshop/datastore"
+	"bookshop/reviews"
+	"bookshop/service"
+	"bookshop/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceReviews(t *testing.T) {
+	reviewer := users.User{ID: "usr01", Role: users.RoleCustomer, Permissions: users.RolePermissions[users.RoleCustomer]}
+	other := users.User{ID: "usr02", Role: users.RoleCustomer, Permissions: users.RolePermissions[users.RoleCustomer]}
+	staff := users.User{ID: "usr03", Role: users.RoleStaff, Permissions: users.RolePermissions[users.RoleStaff]}
+	ctx := auth.WithUser(context.Background(), reviewer)
+	otherCtx := auth.WithUser(context.Background(), other)
+	staffCtx := auth.WithUser(context.Background(), staff)
+
+	srv := service.NewService(service.Stores{Books: &mockBookStore{}, Reviews: &mockReviewStore{}, Work: &mockUnitOfWork{}})
+	pending := reviews.Review{ID: "rev01", BookID: "abc01", UserID: "usr01", Rating: 4, Body: "Gripping.", Status: reviews.Pending}
+	approved := reviews.Review{ID: "rev02", BookID: "abc01", UserID: "usr02", Rating: 2, Status: reviews.Approved}
+	reset := func() {
+		mockReviewErr, mockBooksErr = nil, nil
+		mockBook = books.Book{ID: "abc01", Title: "Dune"}
+		mockReviews = map[string]reviews.Review{pending.ID: pending, approved.ID: approved}
+		mockReviewQuery = reviews.ReviewQuery{}
+	}
+
+	t.Run("AddReview", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+			r, err := srv.AddReview(otherCtx, reviews.Review{BookID: "abc02", Rating: 5, Body: "Loved it.", Status: reviews.Approved})
+			require.NoError(t, err)
+			assert.NotEmpty(t, r.ID)
+			assert.Equal(t, "usr02", r.UserID, "the reviewer is the signed in user")
+			assert.Equal(t, reviews.Pending, r.Status, "new reviews wait for moderation")
+		})
+
+		t.Run("invalid", func(t *testing.T) {
+			for name, r := range map[string]reviews.Review{
+				"no rating": {BookID: "abc01"},
+				"too many":  {BookID: "abc01", Rating: 6},
+				"too long":  {BookID: "abc01", Rating: 3, Body: strings.Repeat("x", reviews.MaxBodyLength+1)},
+				"negative":  {BookID: "abc01", Rating: -1},
+			} {
+				reset()
+				_, err := srv.AddReview(ctx, r)
+				assert.True(t, errors.Is(err, service.ErrInvalid), name)
+			}
+		})
+
+		t.Run("already reviewed", func(t *testing.T) {
+			reset()
+			mockReviewErr = datastore.ErrDuplicate
+			_, err := srv.AddReview(ctx, reviews.Review{BookID: "abc01", Rating: 3})
+			assert.True(t, errors.Is(err, service.ErrDuplicate))
+		})
+
+		t.Run("unknown book", func(t *testing.T) {
+			reset()
+			mockReviewErr = datastore.ErrMissingReference
+			_, err := srv.AddReview(ctx, reviews.Review{BookID: "abc99", Rating: 3})
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+
+		t.Run("anonymous", func(t *testing.T) {
+			reset()
+			_, err := srv.AddReview(context.Background(), reviews.Review{BookID: "abc01", Rating: 3})
+			assert.Equal(t, service.ErrUnauthenticated, err)
+		})
+	})
+
+	t.Run("GetReview", func(t *testing.T) {
+		reset()
+		r, err := srv.GetReview(context.Background(), "abc01", "rev02")
+		require.NoError(t, err)
+		assert.Equal(t, approved, r)
+
+		_, err = srv.GetReview(ctx, "abc01", "rev01")
+		assert.NoError(t, err, "reviewers see their own pending reviews")
+		_, err = srv.GetReview(staffCtx, "abc01", "rev01")
+		assert.NoError(t, err, "moderators see pending reviews")
+
+		_, err = srv.GetReview(otherCtx, "abc01", "rev01")
+		assert.True(t, errors.Is(err, service.ErrNotFound), "pending reviews are hidden from others")
+		_, err = srv.GetReview(ctx, "abc02", "rev01")
+		assert.True(t, errors.Is(err, service.ErrNotFound))
+	})
+
+	t.Run("ListReviews", func(t *testing.T) {
+		t.Run("approved only", func(t *testing.T) {
+			reset()
+			page, err := srv.ListReviews(context.Background(), "abc01", reviews.ReviewQuery{})
+			require.NoError(t, err)
+			assert.Equal(t, []reviews.Review{approved}, page.Reviews)
+			assert.Equal(t, reviews.Approved, mockReviewQuery.Status)
+		})
+
+		t.Run("moderator", func(t *testing.T) {
+			reset()
+			page, err := srv.ListReviews(staffCtx, "abc01", reviews.ReviewQuery{Status: reviews.Pending})
+			require.NoError(t, err)
+			assert.Equal(t, []reviews.Review{pending}, page.Reviews)
+		})
+
+		t.Run("forbidden", func(t *testing.T) {
+			reset()
+			_, err := srv.ListReviews(ctx, "abc01", reviews.ReviewQuery{Status: reviews.Pending})
+			assert.True(t, errors.Is(err, service.ErrForbidden))
+		})
+
+		t.Run("unknown book", func(t *testing.T) {
+			reset()
+			mockBooksErr = datastore.ErrNotFound
+			_, err := srv.ListReviews(ctx, "abc99", reviews.ReviewQuery{})
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+
+		t.Run("invalid", func(t *testing.T) {
+			reset()
+			_, err := srv.ListReviews(ctx, "abc01", reviews.ReviewQuery{Limit: datastore.MaxLimit + 1})
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+		})
+	})
+
+	t.Run("UpdateReview", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+			r, err := srv.UpdateReview(otherCtx, reviews.Review{ID: "rev02", BookID: "abc01", Rating: 3, Body: "Grew on me."})
+			require.NoError(t, err)
+			assert.Equal(t, 3, r.Rating)
+			assert.Equal(t, "Grew on me.", r.Body)
+			assert.Equal(t, reviews.Pending, r.Status, "changed reviews wait for moderation again")
+			assert.Equal(t, "usr02", r.UserID)
+		})
+
+		t.Run("not owner", func(t *testing.T) {
+			reset()
+			_, err := srv.UpdateReview(staffCtx, reviews.Review{ID: "rev02", BookID: "abc01", Rating: 3})
+			assert.True(t, errors.Is(err, service.ErrForbidden))
+			assert.Equal(t, approved, mockReviews["rev02"])
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			reset()
+			_, err := srv.UpdateReview(ctx, reviews.Review{ID: "rev99", BookID: "abc01", Rating: 3})
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+	})
+
+	t.Run("ModerateReview", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+			r, err := srv.ModerateReview(staffCtx, "abc01", "rev01", reviews.Approved)
+			require.NoError(t, err)
+			assert.Equal(t, reviews.Approved, r.Status)
+		})
+
+		t.Run("forbidden", func(t *testing.T) {
+			reset()
+			_, err := srv.ModerateReview(ctx, "abc01", "rev01", reviews.Approved)
+			assert.True(t, errors.Is(err, service.ErrForbidden))
+			assert.Equal(t, reviews.Pending, mockReviews["rev01"].Status)
+		})
+
+		t.Run("unknown status", func(t *testing.T) {
+			reset()
+			_, err := srv.ModerateReview(staffCtx, "abc01", "rev01", "hidden")
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			reset()
+			_, err := srv.ModerateReview(staffCtx, "abc01", "rev99", reviews.Rejected)
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+	})
+
+	t.Run("RemoveReview", func(t *testing.T) {
+		reset()
+		err := srv.RemoveReview(otherCtx, "abc01", "rev01")
+		assert.True(t, errors.Is(err, service.ErrForbidden))
+
+		require.NoError(t, srv.RemoveReview(ctx, "abc01", "rev01"))
+		require.NoError(t, srv.RemoveReview(staffCtx, "abc01", "rev02"), "moderators remove anyone's review")
+		assert.Empty(t, mockReviews)
+
+		err = srv.RemoveReview(ctx, "abc01", "rev01")
+		assert.True(t, errors.Is(err, service.ErrNotFound))
+	})
+}
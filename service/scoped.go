@@ -0,0 +1,522 @@
+package service
+
+import (
+	"context"
+
+	"bookshop/auth"
+	"bookshop/authors"
+	"bookshop/books"
+	"bookshop/carts"
+	"bookshop/categories"
+	"bookshop/money"
+	"bookshop/orders"
+	"bookshop/publishers"
+	"bookshop/reviews"
+	"bookshop/search"
+	"bookshop/stock"
+	"bookshop/users"
+)
+
+// Scoped wraps svc so that each call made on behalf of an API key is refused
+// with a ScopeError unless the operation is one of the key's scopes. Calls
+// made with a session, or before the caller is known, are passed straight on.
+func Scoped(svc SVC) SVC {
+	return scoped{svc: svc}
+}
+
+// scoped lists every operation rather than embedding SVC, so an operation
+// added to SVC cannot be called with an API key until it is listed here.
+type scoped struct {
+	svc SVC
+}
+
+// inScope checks op is one of the scopes of the API key in ctx, if any.
+func inScope(ctx context.Context, op string) error {
+	if scopes, ok := auth.ScopesFrom(ctx); ok && !scopes.Allows(op) {
+		return NewErrOutOfScope(op)
+	}
+	return nil
+}
+
+func (s scoped) AddAuthor(ctx context.Context, auth authors.Author) (authors.Author, error) {
+	if err := inScope(ctx, "AddAuthor"); err != nil {
+		return authors.Author{}, err
+	}
+	return s.svc.AddAuthor(ctx, auth)
+}
+
+func (s scoped) GetAuthor(ctx context.Context, id string) (authors.Author, error) {
+	if err := inScope(ctx, "GetAuthor"); err != nil {
+		return authors.Author{}, err
+	}
+	return s.svc.GetAuthor(ctx, id)
+}
+
+func (s scoped) ListAuthors(ctx context.Context, q authors.AuthorQuery) (authors.Page, error) {
+	if err := inScope(ctx, "ListAuthors"); err != nil {
+		return authors.Page{}, err
+	}
+	return s.svc.ListAuthors(ctx, q)
+}
+
+func (s scoped) RemoveAuthor(ctx context.Context, id string) error {
+	if err := inScope(ctx, "RemoveAuthor"); err != nil {
+		return err
+	}
+	return s.svc.RemoveAuthor(ctx, id)
+}
+
+func (s scoped) UpdateAuthor(ctx context.Context, id string, p authors.Patch) error {
+	if err := inScope(ctx, "UpdateAuthor"); err != nil {
+		return err
+	}
+	return s.svc.UpdateAuthor(ctx, id, p)
+}
+
+func (s scoped) AddBook(ctx context.Context, title, isbn string, details books.Details, price *money.Money, authorIDs ...string) (books.Book, error) {
+	if err := inScope(ctx, "AddBook"); err != nil {
+		return books.Book{}, err
+	}
+	return s.svc.AddBook(ctx, title, isbn, details, price, authorIDs...)
+}
+
+func (s scoped) AddBookAuthor(ctx context.Context, bookID, authorID string, role books.Role, sequence int) error {
+	if err := inScope(ctx, "AddBookAuthor"); err != nil {
+		return err
+	}
+	return s.svc.AddBookAuthor(ctx, bookID, authorID, role, sequence)
+}
+
+func (s scoped) GetBook(ctx context.Context, id string) (books.Book, error) {
+	if err := inScope(ctx, "GetBook"); err != nil {
+		return books.Book{}, err
+	}
+	return s.svc.GetBook(ctx, id)
+}
+
+func (s scoped) ListBookPrices(ctx context.Context, bookID string) ([]books.Price, error) {
+	if err := inScope(ctx, "ListBookPrices"); err != nil {
+		return nil, err
+	}
+	return s.svc.ListBookPrices(ctx, bookID)
+}
+
+func (s scoped) SetBookPrice(ctx context.Context, bookID string, p books.Price) error {
+	if err := inScope(ctx, "SetBookPrice"); err != nil {
+		return err
+	}
+	return s.svc.SetBookPrice(ctx, bookID, p)
+}
+
+func (s scoped) RemoveBookAuthor(ctx context.Context, bookID, authorID string, role books.Role) error {
+	if err := inScope(ctx, "RemoveBookAuthor"); err != nil {
+		return err
+	}
+	return s.svc.RemoveBookAuthor(ctx, bookID, authorID, role)
+}
+
+func (s scoped) RemoveBooks(ctx context.Context, ids ...string) error {
+	if err := inScope(ctx, "RemoveBooks"); err != nil {
+		return err
+	}
+	return s.svc.RemoveBooks(ctx, ids...)
+}
+
+func (s scoped) ListBooks(ctx context.Context, q books.BookQuery) (books.Page, error) {
+	if err := inScope(ctx, "ListBooks"); err != nil {
+		return books.Page{}, err
+	}
+	return s.svc.ListBooks(ctx, q)
+}
+
+func (s scoped) UpdateBook(ctx context.Context, id string, p books.Patch) error {
+	if err := inScope(ctx, "UpdateBook"); err != nil {
+		return err
+	}
+	return s.svc.UpdateBook(ctx, id, p)
+}
+
+func (s scoped) AddCategory(ctx context.Context, c categories.Category) (categories.Category, error) {
+	if err := inScope(ctx, "AddCategory"); err != nil {
+		return categories.Category{}, err
+	}
+	return s.svc.AddCategory(ctx, c)
+}
+
+func (s scoped) GetCategory(ctx context.Context, id string) (categories.Category, error) {
+	if err := inScope(ctx, "GetCategory"); err != nil {
+		return categories.Category{}, err
+	}
+	return s.svc.GetCategory(ctx, id)
+}
+
+func (s scoped) ListCategories(ctx context.Context) ([]categories.Category, error) {
+	if err := inScope(ctx, "ListCategories"); err != nil {
+		return nil, err
+	}
+	return s.svc.ListCategories(ctx)
+}
+
+func (s scoped) UpdateCategory(ctx context.Context, c categories.Category) (categories.Category, error) {
+	if err := inScope(ctx, "UpdateCategory"); err != nil {
+		return categories.Category{}, err
+	}
+	return s.svc.UpdateCategory(ctx, c)
+}
+
+func (s scoped) RemoveCategory(ctx context.Context, id string) error {
+	if err := inScope(ctx, "RemoveCategory"); err != nil {
+		return err
+	}
+	return s.svc.RemoveCategory(ctx, id)
+}
+
+func (s scoped) AddBookCategory(ctx context.Context, bookID, categoryID string) error {
+	if err := inScope(ctx, "AddBookCategory"); err != nil {
+		return err
+	}
+	return s.svc.AddBookCategory(ctx, bookID, categoryID)
+}
+
+func (s scoped) RemoveBookCategory(ctx context.Context, bookID, categoryID string) error {
+	if err := inScope(ctx, "RemoveBookCategory"); err != nil {
+		return err
+	}
+	return s.svc.RemoveBookCategory(ctx, bookID, categoryID)
+}
+
+func (s scoped) TagBook(ctx context.Context, bookID, tag string) error {
+	if err := inScope(ctx, "TagBook"); err != nil {
+		return err
+	}
+	return s.svc.TagBook(ctx, bookID, tag)
+}
+
+func (s scoped) UntagBook(ctx context.Context, bookID, tag string) error {
+	if err := inScope(ctx, "UntagBook"); err != nil {
+		return err
+	}
+	return s.svc.UntagBook(ctx, bookID, tag)
+}
+
+func (s scoped) ListTags(ctx context.Context) ([]books.Tag, error) {
+	if err := inScope(ctx, "ListTags"); err != nil {
+		return nil, err
+	}
+	return s.svc.ListTags(ctx)
+}
+
+func (s scoped) AddPublisher(ctx context.Context, pub publishers.Publisher) (publishers.Publisher, error) {
+	if err := inScope(ctx, "AddPublisher"); err != nil {
+		return publishers.Publisher{}, err
+	}
+	return s.svc.AddPublisher(ctx, pub)
+}
+
+func (s scoped) GetPublisher(ctx context.Context, id string) (publishers.Publisher, error) {
+	if err := inScope(ctx, "GetPublisher"); err != nil {
+		return publishers.Publisher{}, err
+	}
+	return s.svc.GetPublisher(ctx, id)
+}
+
+func (s scoped) ListPublishers(ctx context.Context, q publishers.PublisherQuery) (publishers.Page, error) {
+	if err := inScope(ctx, "ListPublishers"); err != nil {
+		return publishers.Page{}, err
+	}
+	return s.svc.ListPublishers(ctx, q)
+}
+
+func (s scoped) RemovePublisher(ctx context.Context, id string) error {
+	if err := inScope(ctx, "RemovePublisher"); err != nil {
+		return err
+	}
+	return s.svc.RemovePublisher(ctx, id)
+}
+
+func (s scoped) UpdatePublisher(ctx context.Context, pub publishers.Publisher) error {
+	if err := inScope(ctx, "UpdatePublisher"); err != nil {
+		return err
+	}
+	return s.svc.UpdatePublisher(ctx, pub)
+}
+
+func (s scoped) GetStock(ctx context.Context, bookID string) (stock.Level, error) {
+	if err := inScope(ctx, "GetStock"); err != nil {
+		return stock.Level{}, err
+	}
+	return s.svc.GetStock(ctx, bookID)
+}
+
+func (s scoped) ReceiveStock(ctx context.Context, bookID string, quantity int, reason string) (stock.Level, error) {
+	if err := inScope(ctx, "ReceiveStock"); err != nil {
+		return stock.Level{}, err
+	}
+	return s.svc.ReceiveStock(ctx, bookID, quantity, reason)
+}
+
+func (s scoped) AdjustStock(ctx context.Context, bookID string, change int, reason string) (stock.Level, error) {
+	if err := inScope(ctx, "AdjustStock"); err != nil {
+		return stock.Level{}, err
+	}
+	return s.svc.AdjustStock(ctx, bookID, change, reason)
+}
+
+func (s scoped) ReserveStock(ctx context.Context, bookID string, quantity int, reason string) (stock.Level, error) {
+	if err := inScope(ctx, "ReserveStock"); err != nil {
+		return stock.Level{}, err
+	}
+	return s.svc.ReserveStock(ctx, bookID, quantity, reason)
+}
+
+func (s scoped) ReleaseStock(ctx context.Context, bookID string, quantity int, reason string) (stock.Level, error) {
+	if err := inScope(ctx, "ReleaseStock"); err != nil {
+		return stock.Level{}, err
+	}
+	return s.svc.ReleaseStock(ctx, bookID, quantity, reason)
+}
+
+func (s scoped) CreateCart(ctx context.Context) (carts.Cart, error) {
+	if err := inScope(ctx, "CreateCart"); err != nil {
+		return carts.Cart{}, err
+	}
+	return s.svc.CreateCart(ctx)
+}
+
+func (s scoped) GetCart(ctx context.Context, token string) (carts.Cart, error) {
+	if err := inScope(ctx, "GetCart"); err != nil {
+		return carts.Cart{}, err
+	}
+	return s.svc.GetCart(ctx, token)
+}
+
+func (s scoped) AddCartItem(ctx context.Context, token, bookID string, quantity int) (carts.Cart, error) {
+	if err := inScope(ctx, "AddCartItem"); err != nil {
+		return carts.Cart{}, err
+	}
+	return s.svc.AddCartItem(ctx, token, bookID, quantity)
+}
+
+func (s scoped) SetCartItem(ctx context.Context, token, bookID string, quantity int) (carts.Cart, error) {
+	if err := inScope(ctx, "SetCartItem"); err != nil {
+		return carts.Cart{}, err
+	}
+	return s.svc.SetCartItem(ctx, token, bookID, quantity)
+}
+
+func (s scoped) RemoveCartItem(ctx context.Context, token, bookID string) (carts.Cart, error) {
+	if err := inScope(ctx, "RemoveCartItem"); err != nil {
+		return carts.Cart{}, err
+	}
+	return s.svc.RemoveCartItem(ctx, token, bookID)
+}
+
+func (s scoped) MergeCarts(ctx context.Context, token, otherToken string) (carts.Cart, error) {
+	if err := inScope(ctx, "MergeCarts"); err != nil {
+		return carts.Cart{}, err
+	}
+	return s.svc.MergeCarts(ctx, token, otherToken)
+}
+
+func (s scoped) PlaceOrder(ctx context.Context, lines []orders.Line) (orders.Order, error) {
+	if err := inScope(ctx, "PlaceOrder"); err != nil {
+		return orders.Order{}, err
+	}
+	return s.svc.PlaceOrder(ctx, lines)
+}
+
+func (s scoped) CheckoutCart(ctx context.Context, token string) (orders.Order, error) {
+	if err := inScope(ctx, "CheckoutCart"); err != nil {
+		return orders.Order{}, err
+	}
+	return s.svc.CheckoutCart(ctx, token)
+}
+
+func (s scoped) GetOrder(ctx context.Context, id string) (orders.Order, error) {
+	if err := inScope(ctx, "GetOrder"); err != nil {
+		return orders.Order{}, err
+	}
+	return s.svc.GetOrder(ctx, id)
+}
+
+func (s scoped) ListOrders(ctx context.Context, q orders.OrderQuery) (orders.Page, error) {
+	if err := inScope(ctx, "ListOrders"); err != nil {
+		return orders.Page{}, err
+	}
+	return s.svc.ListOrders(ctx, q)
+}
+
+func (s scoped) CancelOrder(ctx context.Context, id string) (orders.Order, error) {
+	if err := inScope(ctx, "CancelOrder"); err != nil {
+		return orders.Order{}, err
+	}
+	return s.svc.CancelOrder(ctx, id)
+}
+
+func (s scoped) UpdateOrderStatus(ctx context.Context, id string, status orders.Status) (orders.Order, error) {
+	if err := inScope(ctx, "UpdateOrderStatus"); err != nil {
+		return orders.Order{}, err
+	}
+	return s.svc.UpdateOrderStatus(ctx, id, status)
+}
+
+func (s scoped) RegisterUser(ctx context.Context, u users.User, password string) (users.User, error) {
+	if err := inScope(ctx, "RegisterUser"); err != nil {
+		return users.User{}, err
+	}
+	return s.svc.RegisterUser(ctx, u, password)
+}
+
+func (s scoped) GetUser(ctx context.Context, id string) (users.User, error) {
+	if err := inScope(ctx, "GetUser"); err != nil {
+		return users.User{}, err
+	}
+	return s.svc.GetUser(ctx, id)
+}
+
+func (s scoped) UpdateUser(ctx context.Context, u users.User) (users.User, error) {
+	if err := inScope(ctx, "UpdateUser"); err != nil {
+		return users.User{}, err
+	}
+	return s.svc.UpdateUser(ctx, u)
+}
+
+func (s scoped) ChangePassword(ctx context.Context, id, current, next string) error {
+	if err := inScope(ctx, "ChangePassword"); err != nil {
+		return err
+	}
+	return s.svc.ChangePassword(ctx, id, current, next)
+}
+
+func (s scoped) SetUserEnabled(ctx context.Context, id string, enabled bool) error {
+	if err := inScope(ctx, "SetUserEnabled"); err != nil {
+		return err
+	}
+	return s.svc.SetUserEnabled(ctx, id, enabled)
+}
+
+func (s scoped) SetUserRole(ctx context.Context, id string, role users.Role) error {
+	if err := inScope(ctx, "SetUserRole"); err != nil {
+		return err
+	}
+	return s.svc.SetUserRole(ctx, id, role)
+}
+
+func (s scoped) Login(ctx context.Context, email, password string) (auth.Credentials, error) {
+	if err := inScope(ctx, "Login"); err != nil {
+		return auth.Credentials{}, err
+	}
+	return s.svc.Login(ctx, email, password)
+}
+
+func (s scoped) Authenticate(ctx context.Context, token string) (users.User, error) {
+	if err := inScope(ctx, "Authenticate"); err != nil {
+		return users.User{}, err
+	}
+	return s.svc.Authenticate(ctx, token)
+}
+
+func (s scoped) RefreshSession(ctx context.Context, token string) (auth.Credentials, error) {
+	if err := inScope(ctx, "RefreshSession"); err != nil {
+		return auth.Credentials{}, err
+	}
+	return s.svc.RefreshSession(ctx, token)
+}
+
+func (s scoped) Logout(ctx context.Context, token string) error {
+	if err := inScope(ctx, "Logout"); err != nil {
+		return err
+	}
+	return s.svc.Logout(ctx, token)
+}
+
+func (s scoped) RevokeSessions(ctx context.Context, userID string) error {
+	if err := inScope(ctx, "RevokeSessions"); err != nil {
+		return err
+	}
+	return s.svc.RevokeSessions(ctx, userID)
+}
+
+func (s scoped) CreateAPIKey(ctx context.Context, userID, name string, scopes auth.Scopes) (auth.IssuedKey, error) {
+	if err := inScope(ctx, "CreateAPIKey"); err != nil {
+		return auth.IssuedKey{}, err
+	}
+	return s.svc.CreateAPIKey(ctx, userID, name, scopes)
+}
+
+func (s scoped) ListAPIKeys(ctx context.Context, userID string) ([]auth.APIKey, error) {
+	if err := inScope(ctx, "ListAPIKeys"); err != nil {
+		return nil, err
+	}
+	return s.svc.ListAPIKeys(ctx, userID)
+}
+
+func (s scoped) RotateAPIKey(ctx context.Context, userID, id string) (auth.IssuedKey, error) {
+	if err := inScope(ctx, "RotateAPIKey"); err != nil {
+		return auth.IssuedKey{}, err
+	}
+	return s.svc.RotateAPIKey(ctx, userID, id)
+}
+
+func (s scoped) RevokeAPIKey(ctx context.Context, userID, id string) error {
+	if err := inScope(ctx, "RevokeAPIKey"); err != nil {
+		return err
+	}
+	return s.svc.RevokeAPIKey(ctx, userID, id)
+}
+
+func (s scoped) AuthenticateKey(ctx context.Context, key string) (users.User, auth.APIKey, error) {
+	if err := inScope(ctx, "AuthenticateKey"); err != nil {
+		return users.User{}, auth.APIKey{}, err
+	}
+	return s.svc.AuthenticateKey(ctx, key)
+}
+
+func (s scoped) AddReview(ctx context.Context, r reviews.Review) (reviews.Review, error) {
+	if err := inScope(ctx, "AddReview"); err != nil {
+		return reviews.Review{}, err
+	}
+	return s.svc.AddReview(ctx, r)
+}
+
+func (s scoped) GetReview(ctx context.Context, bookID, id string) (reviews.Review, error) {
+	if err := inScope(ctx, "GetReview"); err != nil {
+		return reviews.Review{}, err
+	}
+	return s.svc.GetReview(ctx, bookID, id)
+}
+
+func (s scoped) ListReviews(ctx context.Context, bookID string, q reviews.ReviewQuery) (reviews.Page, error) {
+	if err := inScope(ctx, "ListReviews"); err != nil {
+		return reviews.Page{}, err
+	}
+	return s.svc.ListReviews(ctx, bookID, q)
+}
+
+func (s scoped) UpdateReview(ctx context.Context, r reviews.Review) (reviews.Review, error) {
+	if err := inScope(ctx, "UpdateReview"); err != nil {
+		return reviews.Review{}, err
+	}
+	return s.svc.UpdateReview(ctx, r)
+}
+
+func (s scoped) ModerateReview(ctx context.Context, bookID, id string, status reviews.Status) (reviews.Review, error) {
+	if err := inScope(ctx, "ModerateReview"); err != nil {
+		return reviews.Review{}, err
+	}
+	return s.svc.ModerateReview(ctx, bookID, id, status)
+}
+
+func (s scoped) RemoveReview(ctx context.Context, bookID, id string) error {
+	if err := inScope(ctx, "RemoveReview"); err != nil {
+		return err
+	}
+	return s.svc.RemoveReview(ctx, bookID, id)
+}
+
+func (s scoped) Search(ctx context.Context, query string, limit int) ([]search.Result, error) {
+	if err := inScope(ctx, "Search"); err != nil {
+		return nil, err
+	}
+	return s.svc.Search(ctx, query, limit)
+}
@@ -1,105 +1,732 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"bookshop/auth"
 	"bookshop/authors"
 	"bookshop/books"
+	"bookshop/carts"
+	"bookshop/categories"
+	"bookshop/datastore"
+	"bookshop/money"
+	"bookshop/orders"
+	"bookshop/publishers"
+	"bookshop/reviews"
+	"bookshop/search"
+	"bookshop/stock"
+	"bookshop/users"
 
 	uuid "github.com/satori/go.uuid"
 )
 
 // AuthorDataStore provides an interface for interacting with the AuthorDataStore.
 type AuthorDataStore interface {
-	DeleteAuthor(id string) error
-	ReadAuthors() ([]authors.Author, error)
-	ReadAuthorAndBooks(id string) (authors.Author, error)
+	DeleteAuthor(ctx context.Context, id string) error
+	ReadAuthors(ctx context.Context, q authors.AuthorQuery) (authors.Page, error)
+	ReadAuthorAndBooks(ctx context.Context, id string) (authors.Author, error)
+	SearchAuthors(ctx context.Context, query string, limit int) ([]search.Result, error)
+	UpdateAuthor(ctx context.Context, auth authors.Author) error
+	UpsertAuthors(ctx context.Context, auths []authors.Author) error
 }
 
 // BookDataStore provides an interface for interacting with the BookDataStore.
 type BookDataStore interface {
-	DeleteBooks(ids ...string) error
-	ReadBookByISBN(isbn string) (books.Book, error)
-	ReadBooks() ([]books.Book, error)
-	UpsertBooks(books []books.Book) error
+	AddBookPrice(ctx context.Context, bookID string, p books.Price) error
+	DeleteBooks(ctx context.Context, ids ...string) error
+	LinkBookAuthors(ctx context.Context, bookID string, credits ...books.Credit) error
+	LinkBookCategories(ctx context.Context, bookID string, categoryIDs ...string) error
+	ReadBookByID(ctx context.Context, id string) (books.Book, error)
+	ReadBookByISBN(ctx context.Context, isbn string) (books.Book, error)
+	ReadBookPrices(ctx context.Context, bookID string) ([]books.Price, error)
+	ReadBooks(ctx context.Context, q books.BookQuery) (books.Page, error)
+	ReadTags(ctx context.Context) ([]books.Tag, error)
+	SearchBooks(ctx context.Context, query string, limit int) ([]search.Result, error)
+	TagBook(ctx context.Context, bookID string, tags ...string) error
+	UnlinkBookAuthor(ctx context.Context, bookID, authorID string, role books.Role) error
+	UnlinkBookCategory(ctx context.Context, bookID, categoryID string) error
+	UntagBook(ctx context.Context, bookID, tag string) error
+	UpsertBooks(ctx context.Context, books []books.Book) error
+}
+
+// CartDataStore provides an interface for interacting with the CartDataStore.
+type CartDataStore interface {
+	AddCartItems(ctx context.Context, token string, items ...carts.Item) error
+	CreateCart(ctx context.Context, c carts.Cart) error
+	DeleteCart(ctx context.Context, token string) error
+	DeleteCartItem(ctx context.Context, token, bookID string) error
+	DeleteExpiredCarts(ctx context.Context, before time.Time) (int64, error)
+	ReadCart(ctx context.Context, token string) (carts.Cart, error)
+	SetCartItem(ctx context.Context, token string, item carts.Item) error
+	TouchCart(ctx context.Context, token string, expiresAt time.Time) error
+}
+
+// CategoryDataStore provides an interface for interacting with the CategoryDataStore.
+type CategoryDataStore interface {
+	CreateCategory(ctx context.Context, c categories.Category) error
+	DeleteCategory(ctx context.Context, id string) error
+	ReadCategories(ctx context.Context) ([]categories.Category, error)
+	ReadCategory(ctx context.Context, id string) (categories.Category, error)
+	UpdateCategory(ctx context.Context, c categories.Category) error
+}
+
+// KeyDataStore provides an interface for interacting with the KeyDataStore.
+type KeyDataStore interface {
+	CreateAPIKey(ctx context.Context, k auth.APIKey) error
+	DeleteAPIKey(ctx context.Context, userID, id string) error
+	ReadAPIKeyByHash(ctx context.Context, keyHash string) (auth.APIKey, error)
+	ReadAPIKeys(ctx context.Context, userID string) ([]auth.APIKey, error)
+	RotateAPIKey(ctx context.Context, userID, id, keyHash string) error
+	TouchAPIKey(ctx context.Context, id string, at time.Time) error
+}
+
+// OrderDataStore provides an interface for interacting with the OrderDataStore.
+type OrderDataStore interface {
+	CreateOrder(ctx context.Context, o orders.Order) error
+	ReadOrder(ctx context.Context, id string) (orders.Order, error)
+	ReadOrders(ctx context.Context, q orders.OrderQuery) (orders.Page, error)
+	UpdateOrderStatus(ctx context.Context, id string, from, to orders.Status) error
+}
+
+// PublisherDataStore provides an interface for interacting with the PublisherDataStore.
+type PublisherDataStore interface {
+	DeletePublisher(ctx context.Context, id string) error
+	ReadPublisherAndBooks(ctx context.Context, id string) (publishers.Publisher, error)
+	ReadPublishers(ctx context.Context, q publishers.PublisherQuery) (publishers.Page, error)
+	UpsertPublishers(ctx context.Context, pubs []publishers.Publisher) error
+}
+
+// ReviewDataStore provides an interface for interacting with the ReviewDataStore.
+type ReviewDataStore interface {
+	CreateReview(ctx context.Context, r reviews.Review) error
+	DeleteReview(ctx context.Context, bookID, id string) error
+	ReadReview(ctx context.Context, bookID, id string) (reviews.Review, error)
+	ReadReviews(ctx context.Context, bookID string, q reviews.ReviewQuery) (reviews.Page, error)
+	SetReviewStatus(ctx context.Context, bookID, id string, status reviews.Status) error
+	UpdateReview(ctx context.Context, r reviews.Review) error
+}
+
+// SessionDataStore provides an interface for interacting with the SessionDataStore.
+type SessionDataStore interface {
+	CreateSession(ctx context.Context, sess auth.Session) error
+	DeleteExpiredSessions(ctx context.Context, before time.Time) (int64, error)
+	DeleteSession(ctx context.Context, tokenHash string) error
+	DeleteUserSessions(ctx context.Context, userID string) (int64, error)
+	ReadSession(ctx context.Context, tokenHash string) (auth.Session, error)
+}
+
+// StockDataStore provides an interface for interacting with the StockDataStore.
+type StockDataStore interface {
+	ApplyMovement(ctx context.Context, m stock.Movement) (stock.Level, error)
+	ReadStock(ctx context.Context, bookID string) (stock.Level, error)
+}
+
+// UserDataStore provides an interface for interacting with the UserDataStore.
+type UserDataStore interface {
+	CreateUser(ctx context.Context, u users.User) error
+	ReadUserByEmail(ctx context.Context, email string) (users.User, error)
+	ReadRolePermissions(ctx context.Context, role users.Role) ([]users.Permission, error)
+	ReadUserByID(ctx context.Context, id string) (users.User, error)
+	SetUserEnabled(ctx context.Context, id string, enabled bool) error
+	SetUserPassword(ctx context.Context, id, hash string) error
+	SetUserRole(ctx context.Context, id string, role users.Role) error
+	UpdateUser(ctx context.Context, u users.User) error
+}
+
+// UnitOfWork runs several store calls atomically. Store calls made with the
+// context given to fn take part in the unit of work.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
 }
 
 // SVC is an interface that fulfills bookshop service calls.
 type SVC interface {
-	GetAuthor(id string) (authors.Author, error)
-	ListAuthors() ([]authors.Author, error)
-	RemoveAuthor(id string) error
+	AddAuthor(ctx context.Context, auth authors.Author) (authors.Author, error)
+	GetAuthor(ctx context.Context, id string) (authors.Author, error)
+	ListAuthors(ctx context.Context, q authors.AuthorQuery) (authors.Page, error)
+	RemoveAuthor(ctx context.Context, id string) error
+	UpdateAuthor(ctx context.Context, id string, p authors.Patch) error
+
+	AddBook(ctx context.Context, title, isbn string, details books.Details, price *money.Money, authorIDs ...string) (books.Book, error)
+	AddBookAuthor(ctx context.Context, bookID, authorID string, role books.Role, sequence int) error
+	GetBook(ctx context.Context, id string) (books.Book, error)
+	ListBookPrices(ctx context.Context, bookID string) ([]books.Price, error)
+	SetBookPrice(ctx context.Context, bookID string, p books.Price) error
+	RemoveBookAuthor(ctx context.Context, bookID, authorID string, role books.Role) error
+	RemoveBooks(ctx context.Context, ids ...string) error
+	ListBooks(ctx context.Context, q books.BookQuery) (books.Page, error)
+	UpdateBook(ctx context.Context, id string, p books.Patch) error
+
+	AddCategory(ctx context.Context, c categories.Category) (categories.Category, error)
+	GetCategory(ctx context.Context, id string) (categories.Category, error)
+	ListCategories(ctx context.Context) ([]categories.Category, error)
+	UpdateCategory(ctx context.Context, c categories.Category) (categories.Category, error)
+	RemoveCategory(ctx context.Context, id string) error
+	AddBookCategory(ctx context.Context, bookID, categoryID string) error
+	RemoveBookCategory(ctx context.Context, bookID, categoryID string) error
+	TagBook(ctx context.Context, bookID, tag string) error
+	UntagBook(ctx context.Context, bookID, tag string) error
+	ListTags(ctx context.Context) ([]books.Tag, error)
+
+	AddPublisher(ctx context.Context, pub publishers.Publisher) (publishers.Publisher, error)
+	GetPublisher(ctx context.Context, id string) (publishers.Publisher, error)
+	ListPublishers(ctx context.Context, q publishers.PublisherQuery) (publishers.Page, error)
+	RemovePublisher(ctx context.Context, id string) error
+	UpdatePublisher(ctx context.Context, pub publishers.Publisher) error
 
-	AddBook(title, isbn string) (books.Book, error)
-	RemoveBooks(ids ...string) error
-	ListBooks() ([]books.Book, error)
-	UpdateBook(bk books.Book) error
+	GetStock(ctx context.Context, bookID string) (stock.Level, error)
+	ReceiveStock(ctx context.Context, bookID string, quantity int, reason string) (stock.Level, error)
+	AdjustStock(ctx context.Context, bookID string, change int, reason string) (stock.Level, error)
+	ReserveStock(ctx context.Context, bookID string, quantity int, reason string) (stock.Level, error)
+	ReleaseStock(ctx context.Context, bookID string, quantity int, reason string) (stock.Level, error)
+
+	CreateCart(ctx context.Context) (carts.Cart, error)
+	GetCart(ctx context.Context, token string) (carts.Cart, error)
+	AddCartItem(ctx context.Context, token, bookID string, quantity int) (carts.Cart, error)
+	SetCartItem(ctx context.Context, token, bookID string, quantity int) (carts.Cart, error)
+	RemoveCartItem(ctx context.Context, token, bookID string) (carts.Cart, error)
+	MergeCarts(ctx context.Context, token, otherToken string) (carts.Cart, error)
+
+	PlaceOrder(ctx context.Context, lines []orders.Line) (orders.Order, error)
+	CheckoutCart(ctx context.Context, token string) (orders.Order, error)
+	GetOrder(ctx context.Context, id string) (orders.Order, error)
+	ListOrders(ctx context.Context, q orders.OrderQuery) (orders.Page, error)
+	CancelOrder(ctx context.Context, id string) (orders.Order, error)
+	UpdateOrderStatus(ctx context.Context, id string, status orders.Status) (orders.Order, error)
+
+	RegisterUser(ctx context.Context, u users.User, password string) (users.User, error)
+	GetUser(ctx context.Context, id string) (users.User, error)
+	UpdateUser(ctx context.Context, u users.User) (users.User, error)
+	ChangePassword(ctx context.Context, id, current, next string) error
+	SetUserEnabled(ctx context.Context, id string, enabled bool) error
+	SetUserRole(ctx context.Context, id string, role users.Role) error
+
+	Login(ctx context.Context, email, password string) (auth.Credentials, error)
+	Authenticate(ctx context.Context, token string) (users.User, error)
+	RefreshSession(ctx context.Context, token string) (auth.Credentials, error)
+	Logout(ctx context.Context, token string) error
+	RevokeSessions(ctx context.Context, userID string) error
+
+	CreateAPIKey(ctx context.Context, userID, name string, scopes auth.Scopes) (auth.IssuedKey, error)
+	ListAPIKeys(ctx context.Context, userID string) ([]auth.APIKey, error)
+	RotateAPIKey(ctx context.Context, userID, id string) (auth.IssuedKey, error)
+	RevokeAPIKey(ctx context.Context, userID, id string) error
+	AuthenticateKey(ctx context.Context, key string) (users.User, auth.APIKey, error)
+
+	AddReview(ctx context.Context, r reviews.Review) (reviews.Review, error)
+	GetReview(ctx context.Context, bookID, id string) (reviews.Review, error)
+	ListReviews(ctx context.Context, bookID string, q reviews.ReviewQuery) (reviews.Page, error)
+	UpdateReview(ctx context.Context, r reviews.Review) (reviews.Review, error)
+	ModerateReview(ctx context.Context, bookID, id string, status reviews.Status) (reviews.Review, error)
+	RemoveReview(ctx context.Context, bookID, id string) error
+
+	Search(ctx context.Context, query string, limit int) ([]search.Result, error)
 }
 
 // Service is a wrapper for the bookshop service business logic.
 type Service struct {
-	authStore AuthorDataStore
-	bookStore BookDataStore
+	authStore  AuthorDataStore
+	bookStore  BookDataStore
+	cartStore  CartDataStore
+	catStore   CategoryDataStore
+	keyStore   KeyDataStore
+	orderStore OrderDataStore
+	pubStore   PublisherDataStore
+	revStore   ReviewDataStore
+	sessStore  SessionDataStore
+	stockStore StockDataStore
+	userStore  UserDataStore
+	uow        UnitOfWork
+}
+
+// Stores are the datastores a Service works with, all backed by the same
+// database so that Work spans them.
+type Stores struct {
+	Authors    AuthorDataStore
+	Books      BookDataStore
+	Carts      CartDataStore
+	Categories CategoryDataStore
+	Keys       KeyDataStore
+	Orders     OrderDataStore
+	Publishers PublisherDataStore
+	Reviews    ReviewDataStore
+	Sessions   SessionDataStore
+	Stock      StockDataStore
+	Users      UserDataStore
+	Work       UnitOfWork
 }
 
 // NewService returns a Service type value.
-func NewService(as AuthorDataStore, bs BookDataStore) Service {
+func NewService(st Stores) Service {
 	return Service{
-		authStore: as,
-		bookStore: bs,
+		authStore:  st.Authors,
+		bookStore:  st.Books,
+		cartStore:  st.Carts,
+		catStore:   st.Categories,
+		keyStore:   st.Keys,
+		orderStore: st.Orders,
+		pubStore:   st.Publishers,
+		revStore:   st.Reviews,
+		sessStore:  st.Sessions,
+		stockStore: st.Stock,
+		userStore:  st.Users,
+		uow:        st.Work,
+	}
+}
+
+// AddAuthor adds the given author generating its UUID. An author sharing the
+// same name and date of birth with an existing author is rejected.
+func (s *Service) AddAuthor(ctx context.Context, auth authors.Author) (authors.Author, error) {
+	if err := s.Authorize(ctx, users.PermEditBooks); err != nil {
+		return authors.Author{}, err
 	}
+	auth.ID = uuid.NewV4().String()
+	if err := s.authStore.UpsertAuthors(ctx, []authors.Author{auth}); err != nil {
+		if errors.Is(err, datastore.ErrDuplicate) {
+			return authors.Author{}, NewErrDuplicateAuthor(auth.FullName())
+		}
+		return authors.Author{}, err
+	}
+	return auth, nil
 }
 
 // GetAuthor will return the details for an author by the given id including
 // their list of books.
-func (s *Service) GetAuthor(id string) (authors.Author, error) {
-	return s.authStore.ReadAuthorAndBooks(id)
+func (s *Service) GetAuthor(ctx context.Context, id string) (authors.Author, error) {
+	auth, err := s.authStore.ReadAuthorAndBooks(ctx, id)
+	if err != nil {
+		return authors.Author{}, notFound(err, "author", id)
+	}
+	return auth, nil
+}
+
+// ListAuthors will return a page of authors matching the query, sorted by last
+// name (ascending) unless the query asks otherwise.
+func (s *Service) ListAuthors(ctx context.Context, q authors.AuthorQuery) (authors.Page, error) {
+	if err := q.Validate(); err != nil {
+		return authors.Page{}, NewErrInvalid("query", err)
+	}
+	return s.authStore.ReadAuthors(ctx, q)
 }
 
-// ListAuthors will return a list of all authors sorted by last name (ascending).
-func (s *Service) ListAuthors() ([]authors.Author, error) {
-	return s.authStore.ReadAuthors()
+// RemoveAuthor will delete the author from the datastore. Only admins may
+// remove authors.
+func (s *Service) RemoveAuthor(ctx context.Context, id string) error {
+	if err := s.Authorize(ctx, users.PermRemoveAuthors); err != nil {
+		return err
+	}
+	return notFound(s.authStore.DeleteAuthor(ctx, id), "author", id)
 }
 
-// RemoveAuthor will delete the author from the datastore.
-func (s *Service) RemoveAuthor(id string) error {
-	return s.authStore.DeleteAuthor(id)
+// UpdateAuthor will make the changes in the patch to the given author, leaving
+// the fields it does not set as they are.
+func (s *Service) UpdateAuthor(ctx context.Context, id string, p authors.Patch) error {
+	if err := s.Authorize(ctx, users.PermEditBooks); err != nil {
+		return err
+	}
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		current, err := s.authStore.ReadAuthorAndBooks(ctx, id)
+		if err != nil {
+			return notFound(err, "author", id)
+		}
+		auth := p.Apply(current)
+		if strings.TrimSpace(auth.FirstName) == "" {
+			return NewErrInvalid("first_name", errors.New("author first name cannot be blank"))
+		}
+		if strings.TrimSpace(auth.LastName) == "" {
+			return NewErrInvalid("last_name", errors.New("author last name cannot be blank"))
+		}
+		if err := s.authStore.UpdateAuthor(ctx, auth); err != nil {
+			if errors.Is(err, datastore.ErrDuplicate) {
+				return NewErrDuplicateAuthor(auth.FullName())
+			}
+			return notFound(err, "author", id)
+		}
+		return nil
+	})
 }
 
-// AddBook add a book from the given title and isbn if the isbn does not already exist.
-func (s *Service) AddBook(title, isbn string) (books.Book, error) {
-	//make sure book doesn't already exist
-	extant, err := s.bookStore.ReadBookByISBN(isbn)
+// AddBook add a book from the given title, isbn and details if the isbn does not already
+// exist, crediting it to the given authors and listing it at the given price, if any. The
+// book, its credits and its price are added together or not at all.
+func (s *Service) AddBook(ctx context.Context, title, isbn string, details books.Details, price *money.Money, authorIDs ...string) (books.Book, error) {
+	if err := s.Authorize(ctx, users.PermEditBooks); err != nil {
+		return books.Book{}, err
+	}
+	parsed, err := books.ParseISBN(isbn)
+	if err != nil {
+		return books.Book{}, NewErrInvalid("isbn", err)
+	}
+	details, err = parseDetails(details)
 	if err != nil {
 		return books.Book{}, err
 	}
-	if isbn == string(extant.ISBN) {
-		return books.Book{}, NewErrDuplicate(title)
+	if err := validatePrice(price); err != nil {
+		return books.Book{}, err
 	}
 
-	bks := []books.Book{
-		{
-			ID:    uuid.NewV4().String(),
-			Title: title,
-			ISBN:  books.ISBN(isbn),
-		},
-	}
-	if err := s.bookStore.UpsertBooks(bks); err != nil {
+	var bk books.Book
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		//make sure book doesn't already exist
+		extant, err := s.bookStore.ReadBookByISBN(ctx, string(parsed))
+		if err != nil && !errors.Is(err, datastore.ErrNotFound) {
+			return err
+		}
+		if parsed == extant.ISBN {
+			return NewErrDuplicate(title)
+		}
+
+		bk = books.Book{
+			ID:      uuid.NewV4().String(),
+			Title:   title,
+			ISBN:    parsed,
+			Details: details,
+		}
+		if err := s.bookStore.UpsertBooks(ctx, []books.Book{bk}); err != nil {
+			if errors.Is(err, datastore.ErrDuplicate) {
+				return NewErrDuplicate(title)
+			}
+			if errors.Is(err, datastore.ErrMissingReference) {
+				return ErrInvalidReference
+			}
+			return err
+		}
+
+		if price != nil {
+			p := books.Price{ListPrice: *price, EffectiveFrom: time.Now()}
+			if err := s.bookStore.AddBookPrice(ctx, bk.ID, p); err != nil {
+				return err
+			}
+			bk.ListPrice = price
+		}
+
+		if len(authorIDs) > 0 {
+			if err := s.linkAuthors(ctx, bk.ID, authorCredits(authorIDs)...); err != nil {
+				return err
+			}
+			bk, err = s.bookStore.ReadBookByID(ctx, bk.ID)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
 		return books.Book{}, err
 	}
+	return bk, nil
+}
+
+// AddBookAuthor credits the given author on the given book in the role, which
+// defaults to author, at the sequence. A sequence of 0 keeps an existing
+// credit where it is and puts a new one after the book's other credits.
+func (s *Service) AddBookAuthor(ctx context.Context, bookID, authorID string, role books.Role, sequence int) error {
+	if err := s.Authorize(ctx, users.PermEditBooks); err != nil {
+		return err
+	}
+	role, err := parseRole(role)
+	if err != nil {
+		return err
+	}
+	if role == "" {
+		role = books.RoleAuthor
+	}
+	if sequence < 0 || sequence > books.MaxSequence {
+		return NewErrInvalid("sequence", fmt.Errorf("sequence must be between 0 and %d", books.MaxSequence))
+	}
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		// the closure can be retried, so the caller's sequence must survive it
+		seq := sequence
+		if seq == 0 {
+			bk, err := s.bookStore.ReadBookByID(ctx, bookID)
+			if err != nil {
+				if errors.Is(err, datastore.ErrNotFound) {
+					return ErrInvalidReference
+				}
+				return err
+			}
+			seq = nextSequence(bk.Authors, authorID, role)
+		}
+		return s.linkAuthors(ctx, bookID, books.Credit{AuthorID: authorID, Role: role, Sequence: seq})
+	})
+}
+
+// GetBook will return the details for a book by the given id including its
+// list of authors.
+func (s *Service) GetBook(ctx context.Context, id string) (books.Book, error) {
+	bk, err := s.bookStore.ReadBookByID(ctx, id)
+	if err != nil {
+		return books.Book{}, notFound(err, "book", id)
+	}
+	return bk, nil
+}
+
+// ListBookPrices will return the list prices the book has had or has been
+// scheduled to have, ordered by when they take effect.
+func (s *Service) ListBookPrices(ctx context.Context, bookID string) ([]books.Price, error) {
+	prices, err := s.bookStore.ReadBookPrices(ctx, bookID)
+	if err != nil {
+		return nil, notFound(err, "book", bookID)
+	}
+	return prices, nil
+}
+
+// SetBookPrice will list the book at the given price from its effective time,
+// which may be in the future. A price without an effective time takes effect
+// immediately.
+func (s *Service) SetBookPrice(ctx context.Context, bookID string, p books.Price) error {
+	if err := s.Authorize(ctx, users.PermEditBooks); err != nil {
+		return err
+	}
+	if err := validatePrice(&p.ListPrice); err != nil {
+		return err
+	}
+	if p.EffectiveFrom.IsZero() {
+		p.EffectiveFrom = time.Now()
+	}
+	if err := s.bookStore.AddBookPrice(ctx, bookID, p); err != nil {
+		if errors.Is(err, datastore.ErrMissingReference) {
+			return NewErrNotFound("book", bookID)
+		}
+		return err
+	}
+	return nil
+}
+
+// ListBooks will return a page of books.Book matching the query, sorted by
+// title (ascending) unless the query asks otherwise.
+func (s *Service) ListBooks(ctx context.Context, q books.BookQuery) (books.Page, error) {
+	if err := q.Validate(); err != nil {
+		return books.Page{}, NewErrInvalid("query", err)
+	}
+	return s.bookStore.ReadBooks(ctx, q)
+}
+
+// RemoveBookAuthor removes the given author's credit in the role from the given
+// book, or all of their credits on it when the role is empty.
+func (s *Service) RemoveBookAuthor(ctx context.Context, bookID, authorID string, role books.Role) error {
+	if err := s.Authorize(ctx, users.PermEditBooks); err != nil {
+		return err
+	}
+	role, err := parseRole(role)
+	if err != nil {
+		return err
+	}
+	return notFound(s.bookStore.UnlinkBookAuthor(ctx, bookID, authorID, role), "book author", authorID)
+}
+
+// RemoveBooks will remove a list of books by the given book.Book IDs. Only
+// staff may change the catalogue.
+func (s *Service) RemoveBooks(ctx context.Context, ids ...string) error {
+	if err := s.Authorize(ctx, users.PermEditBooks); err != nil {
+		return err
+	}
+	return notFound(s.bookStore.DeleteBooks(ctx, ids...), "book", strings.Join(ids, ", "))
+}
+
+// UpdateBook will make the changes in the patch to the given book, leaving the
+// fields it does not set as they are. A list price that differs from the one in
+// effect replaces it immediately.
+func (s *Service) UpdateBook(ctx context.Context, id string, p books.Patch) error {
+	if err := s.Authorize(ctx, users.PermEditBooks); err != nil {
+		return err
+	}
+	if err := validatePrice(p.ListPrice); err != nil {
+		return err
+	}
+
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		current, err := s.bookStore.ReadBookByID(ctx, id)
+		if err != nil {
+			return notFound(err, "book", id)
+		}
+		bk := p.Apply(current)
+		parsed, err := books.ParseISBN(string(bk.ISBN))
+		if err != nil {
+			return NewErrInvalid("isbn", err)
+		}
+		bk.ISBN = parsed
+		if bk.Details, err = parseDetails(bk.Details); err != nil {
+			return err
+		}
+
+		if err := s.bookStore.UpsertBooks(ctx, []books.Book{bk}); err != nil {
+			if errors.Is(err, datastore.ErrDuplicate) {
+				return NewErrDuplicate(bk.Title)
+			}
+			if errors.Is(err, datastore.ErrMissingReference) {
+				return ErrInvalidReference
+			}
+			return err
+		}
+		if p.ListPrice == nil || current.ListPrice != nil && *current.ListPrice == *p.ListPrice {
+			return nil
+		}
+		return s.bookStore.AddBookPrice(ctx, id, books.Price{ListPrice: *p.ListPrice, EffectiveFrom: time.Now()})
+	})
+}
+
+// validatePrice checks a list price, if there is one, is in a currency the shop
+// trades in and is not negative.
+func validatePrice(price *money.Money) error {
+	if price == nil {
+		return nil
+	}
+	if err := price.Validate(); err != nil {
+		return NewErrInvalid("price", err)
+	}
+	if price.IsNegative() {
+		return NewErrInvalid("price", errors.New("price cannot be negative"))
+	}
+	return nil
+}
+
+// parseDetails checks a book's details are within their limits and returns them
+// tidied up: text is trimmed, the language and format are in lower case and the
+// publication date is a date without a time of day.
+func parseDetails(d books.Details) (books.Details, error) {
+	d.Subtitle = strings.TrimSpace(d.Subtitle)
+	d.Description = strings.TrimSpace(d.Description)
+	d.Edition = strings.TrimSpace(d.Edition)
+	for _, f := range []struct {
+		name, value string
+		max         int
+	}{
+		{"subtitle", d.Subtitle, books.MaxSubtitleLength},
+		{"description", d.Description, books.MaxDescriptionLength},
+		{"edition", d.Edition, books.MaxEditionLength},
+	} {
+		if utf8.RuneCountInString(f.value) > f.max {
+			return books.Details{}, NewErrInvalid(f.name, fmt.Errorf("%s must be at most %d characters long", f.name, f.max))
+		}
+	}
+
+	if d.PublishedOn != nil {
+		y, m, day := d.PublishedOn.Date()
+		on := time.Date(y, m, day, 0, 0, 0, 0, time.UTC)
+		d.PublishedOn = &on
+	}
+	if d.Language != "" {
+		lang, err := books.ParseLanguage(d.Language)
+		if err != nil {
+			return books.Details{}, NewErrInvalid("language", err)
+		}
+		d.Language = lang
+	}
+	if d.PageCount < 0 || d.PageCount > books.MaxPageCount {
+		return books.Details{}, NewErrInvalid("page_count", fmt.Errorf("page count must be between 0 and %d", books.MaxPageCount))
+	}
+	if d.Format != "" {
+		f, err := books.ParseFormat(string(d.Format))
+		if err != nil {
+			return books.Details{}, NewErrInvalid("format", err)
+		}
+		d.Format = f
+	}
+	if d.Dimensions != nil {
+		if err := d.Dimensions.Validate(); err != nil {
+			return books.Details{}, NewErrInvalid("dimensions", err)
+		}
+	}
+	return d, nil
+}
+
+// parseRole normalizes a contributor role, leaving an empty one empty.
+func parseRole(role books.Role) (books.Role, error) {
+	if strings.TrimSpace(string(role)) == "" {
+		return "", nil
+	}
+	r, err := books.ParseRole(string(role))
+	if err != nil {
+		return "", NewErrInvalid("role", err)
+	}
+	return r, nil
+}
 
-	return bks[0], nil
+// authorCredits credits the authors as authors of a book in the order given,
+// skipping repeats.
+func authorCredits(authorIDs []string) []books.Credit {
+	var credits []books.Credit
+	seen := map[string]bool{}
+	for _, id := range authorIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		credits = append(credits, books.Credit{AuthorID: id, Role: books.RoleAuthor, Sequence: len(credits) + 1})
+	}
+	return credits
+}
+
+// nextSequence returns the sequence of the author's existing credit in the role
+// or, failing that, the sequence following the book's last credit.
+func nextSequence(credited []books.BookAuthor, authorID string, role books.Role) int {
+	var last int
+	for _, a := range credited {
+		if a.ID == authorID && a.Role == role {
+			return a.Sequence
+		}
+		if a.Sequence > last {
+			last = a.Sequence
+		}
+	}
+	return last + 1
 }
 
-// ListBooks will return a list of books.Book.
-func (s *Service) ListBooks() ([]books.Book, error) {
-	return s.bookStore.ReadBooks()
+func (s *Service) linkAuthors(ctx context.Context, bookID string, credits ...books.Credit) error {
+	if err := s.bookStore.LinkBookAuthors(ctx, bookID, credits...); err != nil {
+		if errors.Is(err, datastore.ErrMissingReference) {
+			return ErrInvalidReference
+		}
+		return err
+	}
+	return nil
 }
 
-// RemoveBooks will remove a list of books by the given book.Book IDs.
-func (s *Service) RemoveBooks(ids ...string) error {
-	return s.bookStore.DeleteBooks(ids...)
+// Search will return up to limit books and authors matching the full text
+// query, ordered by relevance.
+func (s *Service) Search(ctx context.Context, query string, limit int) ([]search.Result, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, NewErrInvalid("query", errors.New("search query cannot be blank"))
+	}
+	if limit == 0 {
+		limit = datastore.DefaultLimit
+	}
+	if limit < 1 || limit > datastore.MaxLimit {
+		return nil, NewErrInvalid("limit", fmt.Errorf("must be between 1 and %d", datastore.MaxLimit))
+	}
+
+	bks, err := s.bookStore.SearchBooks(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	auths, err := s.authStore.SearchAuthors(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	results := append(bks, auths...)
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].Title < results[j].Title
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
 }
 
-// UpdateBook will update the given book with the information from the request.
-func (s *Service) UpdateBook(bk books.Book) error {
-	return s.bookStore.UpsertBooks([]books.Book{bk})
+// notFound translates a datastore.ErrNotFound into a NotFoundError for the given
+// kind of record, leaving any other error untouched.
+func notFound(err error, kind, id string) error {
+	if errors.Is(err, datastore.ErrNotFound) {
+		return NewErrNotFound(kind, id)
+	}
+	return err
 }
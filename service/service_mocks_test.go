@@ -1,8 +1,21 @@
 package service_test
 
 import (
+	"context"
+	"time"
+
+	"bookshop/auth"
 	"bookshop/authors"
 	"bookshop/books"
+	"bookshop/carts"
+	"bookshop/categories"
+	"bookshop/datastore"
+	"bookshop/orders"
+	"bookshop/publishers"
+	"bookshop/reviews"
+	"bookshop/search"
+	"bookshop/stock"
+	"bookshop/users"
 )
 
 var mockAuth authors.Author
@@ -11,36 +24,544 @@ var mockAuthErr error
 
 type mockAuthorStore struct{}
 
-func (m *mockAuthorStore) DeleteAuthor(id string) error {
+func (m *mockAuthorStore) DeleteAuthor(ctx context.Context, id string) error {
 	return mockAuthErr
 }
 
-func (m *mockAuthorStore) ReadAuthors() ([]authors.Author, error) {
-	return mockAuths, mockAuthErr
+func (m *mockAuthorStore) ReadAuthors(ctx context.Context, q authors.AuthorQuery) (authors.Page, error) {
+	return authors.Page{Authors: mockAuths}, mockAuthErr
 }
 
-func (m *mockAuthorStore) ReadAuthorAndBooks(id string) (authors.Author, error) {
+func (m *mockAuthorStore) ReadAuthorAndBooks(ctx context.Context, id string) (authors.Author, error) {
 	return mockAuth, mockAuthErr
 }
 
+var mockAuthResults []search.Result
+
+func (m *mockAuthorStore) SearchAuthors(ctx context.Context, query string, limit int) ([]search.Result, error) {
+	return mockAuthResults, mockAuthErr
+}
+
+var mockAuthUpdated authors.Author
+var mockAuthUpdateErr error
+
+func (m *mockAuthorStore) UpdateAuthor(ctx context.Context, auth authors.Author) error {
+	mockAuthUpdated = auth
+	return mockAuthUpdateErr
+}
+
+func (m *mockAuthorStore) UpsertAuthors(ctx context.Context, auths []authors.Author) error {
+	return mockAuthErr
+}
+
 var mockBook books.Book
 var mockBooksErr error
 var mockBooks []books.Book
+var mockLinkErr error
+var mockISBNErr error
 
 type mockBookStore struct{}
 
-func (m *mockBookStore) DeleteBooks(ids ...string) error {
+var mockPrices []books.Price
+var mockPriceErr error
+
+func (m *mockBookStore) AddBookPrice(ctx context.Context, bookID string, p books.Price) error {
+	if mockPriceErr != nil {
+		return mockPriceErr
+	}
+	mockPrices = append(mockPrices, p)
+	return nil
+}
+
+func (m *mockBookStore) DeleteBooks(ctx context.Context, ids ...string) error {
 	return mockBooksErr
 }
 
-func (m *mockBookStore) ReadBookByISBN(isbn string) (books.Book, error) {
+var mockCredits []books.Credit
+
+func (m *mockBookStore) LinkBookAuthors(ctx context.Context, bookID string, credits ...books.Credit) error {
+	mockCredits = credits
+	return mockLinkErr
+}
+
+func (m *mockBookStore) ReadBookByID(ctx context.Context, id string) (books.Book, error) {
 	return mockBook, mockBooksErr
 }
 
-func (m *mockBookStore) ReadBooks() ([]books.Book, error) {
-	return mockBooks, mockBooksErr
+func (m *mockBookStore) ReadBookByISBN(ctx context.Context, isbn string) (books.Book, error) {
+	if mockISBNErr != nil {
+		return books.Book{}, mockISBNErr
+	}
+	return mockBook, mockBooksErr
+}
+
+func (m *mockBookStore) ReadBookPrices(ctx context.Context, bookID string) ([]books.Price, error) {
+	return mockPrices, mockPriceErr
+}
+
+func (m *mockBookStore) ReadBooks(ctx context.Context, q books.BookQuery) (books.Page, error) {
+	return books.Page{Books: mockBooks}, mockBooksErr
 }
 
-func (m *mockBookStore) UpsertBooks(books []books.Book) error {
+var mockBookResults []search.Result
+
+func (m *mockBookStore) SearchBooks(ctx context.Context, query string, limit int) ([]search.Result, error) {
+	return mockBookResults, mockBooksErr
+}
+
+var mockUnlinkedRole books.Role
+
+func (m *mockBookStore) UnlinkBookAuthor(ctx context.Context, bookID, authorID string, role books.Role) error {
+	mockUnlinkedRole = role
+	return mockLinkErr
+}
+
+var mockUpserted []books.Book
+var mockUpsertErr error
+
+func (m *mockBookStore) UpsertBooks(ctx context.Context, bks []books.Book) error {
+	mockUpserted = bks
+	if mockUpsertErr != nil {
+		return mockUpsertErr
+	}
 	return mockBooksErr
 }
+
+var mockTags []books.Tag
+var mockTagged []string
+
+func (m *mockBookStore) LinkBookCategories(ctx context.Context, bookID string, categoryIDs ...string) error {
+	return mockLinkErr
+}
+
+func (m *mockBookStore) ReadTags(ctx context.Context) ([]books.Tag, error) {
+	return mockTags, mockBooksErr
+}
+
+func (m *mockBookStore) TagBook(ctx context.Context, bookID string, tags ...string) error {
+	if mockLinkErr != nil {
+		return mockLinkErr
+	}
+	mockTagged = append(mockTagged, tags...)
+	return nil
+}
+
+func (m *mockBookStore) UnlinkBookCategory(ctx context.Context, bookID, categoryID string) error {
+	return mockLinkErr
+}
+
+func (m *mockBookStore) UntagBook(ctx context.Context, bookID, tag string) error {
+	return mockLinkErr
+}
+
+var mockWorkErr error
+var mockWorkDone int
+
+type mockUnitOfWork struct{}
+
+func (m *mockUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if mockWorkErr != nil {
+		return mockWorkErr
+	}
+	mockWorkDone++
+	return fn(ctx)
+}
+
+// retryingUnitOfWork runs the unit of work once, calls before, then runs it
+// again as though the first attempt hit a serialization failure.
+type retryingUnitOfWork struct {
+	before func()
+}
+
+func (m *retryingUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := fn(ctx); err != nil {
+		return err
+	}
+	m.before()
+	return fn(ctx)
+}
+
+var mockStock stock.Level
+var mockStockErr error
+var mockMovement stock.Movement
+
+type mockStockStore struct{}
+
+func (m *mockStockStore) ApplyMovement(ctx context.Context, mv stock.Movement) (stock.Level, error) {
+	mockMovement = mv
+	return mockStock, mockStockErr
+}
+
+func (m *mockStockStore) ReadStock(ctx context.Context, bookID string) (stock.Level, error) {
+	return mockStock, mockStockErr
+}
+
+var mockCart carts.Cart
+var mockCartErr error
+var mockCartAdded []carts.Item
+var mockCartDeleted []string
+var mockCartTouched *time.Time
+
+type mockCartStore struct{}
+
+func (m *mockCartStore) AddCartItems(ctx context.Context, token string, items ...carts.Item) error {
+	mockCartAdded = append(mockCartAdded, items...)
+	return mockCartErr
+}
+
+func (m *mockCartStore) CreateCart(ctx context.Context, c carts.Cart) error {
+	mockCart = c
+	return mockCartErr
+}
+
+func (m *mockCartStore) DeleteCart(ctx context.Context, token string) error {
+	mockCartDeleted = append(mockCartDeleted, token)
+	return mockCartErr
+}
+
+func (m *mockCartStore) DeleteCartItem(ctx context.Context, token, bookID string) error {
+	mockCartDeleted = append(mockCartDeleted, bookID)
+	return mockCartErr
+}
+
+func (m *mockCartStore) DeleteExpiredCarts(ctx context.Context, before time.Time) (int64, error) {
+	return 1, mockCartErr
+}
+
+func (m *mockCartStore) ReadCart(ctx context.Context, token string) (carts.Cart, error) {
+	if mockCartErr != nil {
+		return carts.Cart{}, mockCartErr
+	}
+	c := mockCart
+	c.Token = token
+	c.Items = append([]carts.Item{}, c.Items...)
+	return c, nil
+}
+
+func (m *mockCartStore) SetCartItem(ctx context.Context, token string, item carts.Item) error {
+	mockCartAdded = append(mockCartAdded, item)
+	return mockCartErr
+}
+
+func (m *mockCartStore) TouchCart(ctx context.Context, token string, expiresAt time.Time) error {
+	mockCartTouched = &expiresAt
+	return mockCartErr
+}
+
+var mockOrder orders.Order
+var mockOrderErr error
+var mockOrderMoveErr error
+var mockOrderMoved []orders.Status
+
+type mockOrderStore struct{}
+
+func (m *mockOrderStore) CreateOrder(ctx context.Context, o orders.Order) error {
+	if mockOrderErr != nil {
+		return mockOrderErr
+	}
+	mockOrder = o
+	return nil
+}
+
+func (m *mockOrderStore) ReadOrder(ctx context.Context, id string) (orders.Order, error) {
+	if mockOrderErr != nil {
+		return orders.Order{}, mockOrderErr
+	}
+	o := mockOrder
+	o.Lines = append([]orders.Line{}, o.Lines...)
+	return o, nil
+}
+
+var mockOrderQuery orders.OrderQuery
+
+func (m *mockOrderStore) ReadOrders(ctx context.Context, q orders.OrderQuery) (orders.Page, error) {
+	mockOrderQuery = q
+	return orders.Page{Orders: []orders.Order{mockOrder}}, mockOrderErr
+}
+
+func (m *mockOrderStore) UpdateOrderStatus(ctx context.Context, id string, from, to orders.Status) error {
+	if mockOrderMoveErr != nil {
+		return mockOrderMoveErr
+	}
+	mockOrderMoved = []orders.Status{from, to}
+	mockOrder.Status = to
+	return nil
+}
+
+var mockUser users.User
+var mockUserErr error
+var mockUserHash string
+var mockUserEnabled *bool
+
+type mockUserStore struct{}
+
+func (m *mockUserStore) CreateUser(ctx context.Context, u users.User) error {
+	if mockUserErr != nil {
+		return mockUserErr
+	}
+	mockUser = u
+	return nil
+}
+
+func (m *mockUserStore) ReadUserByEmail(ctx context.Context, email string) (users.User, error) {
+	return mockUser, mockUserErr
+}
+
+func (m *mockUserStore) ReadRolePermissions(ctx context.Context, role users.Role) ([]users.Permission, error) {
+	return users.RolePermissions[role], mockUserErr
+}
+
+func (m *mockUserStore) ReadUserByID(ctx context.Context, id string) (users.User, error) {
+	return mockUser, mockUserErr
+}
+
+func (m *mockUserStore) SetUserEnabled(ctx context.Context, id string, enabled bool) error {
+	mockUserEnabled = &enabled
+	return mockUserErr
+}
+
+func (m *mockUserStore) SetUserPassword(ctx context.Context, id, hash string) error {
+	mockUserHash = hash
+	return mockUserErr
+}
+
+func (m *mockUserStore) SetUserRole(ctx context.Context, id string, role users.Role) error {
+	mockUser.Role = role
+	return mockUserErr
+}
+
+func (m *mockUserStore) UpdateUser(ctx context.Context, u users.User) error {
+	if mockUserErr != nil {
+		return mockUserErr
+	}
+	mockUser = u
+	return nil
+}
+
+var mockSessions map[string]auth.Session
+var mockSessionErr error
+var mockSessionsRevoked []string
+
+type mockSessionStore struct{}
+
+func (m *mockSessionStore) CreateSession(ctx context.Context, sess auth.Session) error {
+	if mockSessionErr != nil {
+		return mockSessionErr
+	}
+	mockSessions[sess.TokenHash] = sess
+	return nil
+}
+
+func (m *mockSessionStore) DeleteExpiredSessions(ctx context.Context, before time.Time) (int64, error) {
+	return 1, mockSessionErr
+}
+
+func (m *mockSessionStore) DeleteSession(ctx context.Context, tokenHash string) error {
+	if _, ok := mockSessions[tokenHash]; !ok {
+		return datastore.ErrNotFound
+	}
+	delete(mockSessions, tokenHash)
+	return nil
+}
+
+func (m *mockSessionStore) DeleteUserSessions(ctx context.Context, userID string) (int64, error) {
+	mockSessionsRevoked = append(mockSessionsRevoked, userID)
+	return 0, mockSessionErr
+}
+
+func (m *mockSessionStore) ReadSession(ctx context.Context, tokenHash string) (auth.Session, error) {
+	sess, ok := mockSessions[tokenHash]
+	if !ok {
+		return auth.Session{}, datastore.ErrNotFound
+	}
+	return sess, nil
+}
+
+var mockAPIKeys map[string]auth.APIKey
+var mockKeyErr error
+var mockKeyTouched []string
+
+type mockKeyStore struct{}
+
+func (m *mockKeyStore) CreateAPIKey(ctx context.Context, k auth.APIKey) error {
+	if mockKeyErr != nil {
+		return mockKeyErr
+	}
+	mockAPIKeys[k.ID] = k
+	return nil
+}
+
+func (m *mockKeyStore) DeleteAPIKey(ctx context.Context, userID, id string) error {
+	if k, ok := mockAPIKeys[id]; !ok || k.UserID != userID {
+		return datastore.ErrNotFound
+	}
+	delete(mockAPIKeys, id)
+	return nil
+}
+
+func (m *mockKeyStore) ReadAPIKeyByHash(ctx context.Context, keyHash string) (auth.APIKey, error) {
+	for _, k := range mockAPIKeys {
+		if k.KeyHash == keyHash {
+			return k, nil
+		}
+	}
+	return auth.APIKey{}, datastore.ErrNotFound
+}
+
+func (m *mockKeyStore) ReadAPIKeys(ctx context.Context, userID string) ([]auth.APIKey, error) {
+	var keys []auth.APIKey
+	for _, k := range mockAPIKeys {
+		if k.UserID == userID {
+			keys = append(keys, k)
+		}
+	}
+	return keys, mockKeyErr
+}
+
+func (m *mockKeyStore) RotateAPIKey(ctx context.Context, userID, id, keyHash string) error {
+	k, ok := mockAPIKeys[id]
+	if !ok || k.UserID != userID {
+		return datastore.ErrNotFound
+	}
+	k.KeyHash = keyHash
+	mockAPIKeys[id] = k
+	return nil
+}
+
+func (m *mockKeyStore) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	mockKeyTouched = append(mockKeyTouched, id)
+	k := mockAPIKeys[id]
+	k.LastUsedAt = &at
+	mockAPIKeys[id] = k
+	return mockKeyErr
+}
+
+var mockReviews map[string]reviews.Review
+var mockReviewErr error
+var mockReviewQuery reviews.ReviewQuery
+
+type mockReviewStore struct{}
+
+func (m *mockReviewStore) CreateReview(ctx context.Context, r reviews.Review) error {
+	if mockReviewErr != nil {
+		return mockReviewErr
+	}
+	mockReviews[r.ID] = r
+	return nil
+}
+
+func (m *mockReviewStore) DeleteReview(ctx context.Context, bookID, id string) error {
+	if _, err := m.ReadReview(ctx, bookID, id); err != nil {
+		return err
+	}
+	delete(mockReviews, id)
+	return nil
+}
+
+func (m *mockReviewStore) ReadReview(ctx context.Context, bookID, id string) (reviews.Review, error) {
+	r, ok := mockReviews[id]
+	if !ok || r.BookID != bookID {
+		return reviews.Review{}, datastore.ErrNotFound
+	}
+	return r, nil
+}
+
+func (m *mockReviewStore) ReadReviews(ctx context.Context, bookID string, q reviews.ReviewQuery) (reviews.Page, error) {
+	mockReviewQuery = q
+	page := reviews.Page{Reviews: []reviews.Review{}}
+	for _, r := range mockReviews {
+		if r.BookID == bookID && (q.Status == "" || r.Status == q.Status) {
+			page.Reviews = append(page.Reviews, r)
+		}
+	}
+	return page, mockReviewErr
+}
+
+func (m *mockReviewStore) SetReviewStatus(ctx context.Context, bookID, id string, status reviews.Status) error {
+	r, err := m.ReadReview(ctx, bookID, id)
+	if err != nil {
+		return err
+	}
+	r.Status = status
+	mockReviews[id] = r
+	return nil
+}
+
+func (m *mockReviewStore) UpdateReview(ctx context.Context, r reviews.Review) error {
+	if _, err := m.ReadReview(ctx, r.BookID, r.ID); err != nil {
+		return err
+	}
+	mockReviews[r.ID] = r
+	return nil
+}
+
+var mockCategories map[string]categories.Category
+var mockCategoryErr error
+
+type mockCategoryStore struct{}
+
+func (m *mockCategoryStore) CreateCategory(ctx context.Context, c categories.Category) error {
+	if mockCategoryErr != nil {
+		return mockCategoryErr
+	}
+	mockCategories[c.ID] = c
+	return nil
+}
+
+func (m *mockCategoryStore) DeleteCategory(ctx context.Context, id string) error {
+	if _, ok := mockCategories[id]; !ok {
+		return datastore.ErrNotFound
+	}
+	delete(mockCategories, id)
+	return nil
+}
+
+func (m *mockCategoryStore) ReadCategories(ctx context.Context) ([]categories.Category, error) {
+	var cats []categories.Category
+	for _, c := range mockCategories {
+		cats = append(cats, c)
+	}
+	return cats, nil
+}
+
+func (m *mockCategoryStore) ReadCategory(ctx context.Context, id string) (categories.Category, error) {
+	c, ok := mockCategories[id]
+	if !ok {
+		return categories.Category{}, datastore.ErrNotFound
+	}
+	return c, nil
+}
+
+func (m *mockCategoryStore) UpdateCategory(ctx context.Context, c categories.Category) error {
+	if mockCategoryErr != nil {
+		return mockCategoryErr
+	}
+	if _, ok := mockCategories[c.ID]; !ok {
+		return datastore.ErrNotFound
+	}
+	mockCategories[c.ID] = c
+	return nil
+}
+
+var mockPublisher publishers.Publisher
+var mockPublisherErr error
+var mockPublisherUpserted []publishers.Publisher
+
+type mockPublisherStore struct{}
+
+func (m *mockPublisherStore) DeletePublisher(ctx context.Context, id string) error {
+	return mockPublisherErr
+}
+
+func (m *mockPublisherStore) ReadPublisherAndBooks(ctx context.Context, id string) (publishers.Publisher, error) {
+	return mockPublisher, mockPublisherErr
+}
+
+func (m *mockPublisherStore) ReadPublishers(ctx context.Context, q publishers.PublisherQuery) (publishers.Page, error) {
+	return publishers.Page{Publishers: []publishers.Publisher{mockPublisher}}, mockPublisherErr
+}
+
+func (m *mockPublisherStore) UpsertPublishers(ctx context.Context, pubs []publishers.Publisher) error {
+	mockPublisherUpserted = pubs
+	return mockPublisherErr
+}
@@ -1,26 +1,79 @@
 package service_test
 
 import (
+	"context"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
+	"bookshop/auth"
 	"bookshop/authors"
 	"bookshop/books"
+	"bookshop/datastore"
+	"bookshop/money"
+	"bookshop/search"
 	"bookshop/service"
+	"bookshop/users"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func TestService(t *testing.T) {
+	// catalogue changes need a signed in admin or member of staff
+	admin := users.User{ID: "usr01", Role: users.RoleAdmin, Permissions: users.RolePermissions[users.RoleAdmin]}
+	ctx := auth.WithUser(context.Background(), admin)
 	dt, err := time.Parse(authors.DateParsingFormat, "1970-01-01")
 	require.NoError(t, err)
 
+	t.Run("AddAuthor", func(t *testing.T) {
+		auth := authors.Author{
+			FirstName:  "First",
+			MiddleName: "Middle",
+			LastName:   "Last",
+			DOB:        &dt,
+		}
+
+		t.Run("happy", func(t *testing.T) {
+			mockAuthStore := &mockAuthorStore{}
+			srv := service.NewService(service.Stores{Authors: mockAuthStore, Work: &mockUnitOfWork{}})
+
+			mockAuthErr = nil
+			created, err := srv.AddAuthor(ctx, auth)
+			require.NoError(t, err)
+			assert.NotEmpty(t, created.ID)
+			assert.Equal(t, auth.FirstName, created.FirstName)
+			assert.Equal(t, auth.DOB, created.DOB)
+		})
+
+		t.Run("duplicate", func(t *testing.T) {
+			mockAuthStore := &mockAuthorStore{}
+			srv := service.NewService(service.Stores{Authors: mockAuthStore, Work: &mockUnitOfWork{}})
+
+			mockAuthErr = datastore.ErrDuplicate
+			created, err := srv.AddAuthor(ctx, auth)
+			assert.True(t, errors.Is(err, service.ErrDuplicate))
+			assert.Equal(t, "author already exists with same name and date of birth: First Middle Last", err.Error())
+			assert.Equal(t, authors.Author{}, created)
+		})
+
+		t.Run("datastore error", func(t *testing.T) {
+			mockAuthStore := &mockAuthorStore{}
+			srv := service.NewService(service.Stores{Authors: mockAuthStore, Work: &mockUnitOfWork{}})
+
+			mockAuthErr = errors.New("datastore error")
+			created, err := srv.AddAuthor(ctx, auth)
+			assert.Error(t, err)
+			assert.False(t, errors.Is(err, service.ErrDuplicate))
+			assert.Equal(t, authors.Author{}, created)
+		})
+	})
+
 	t.Run("GetAuthor", func(t *testing.T) {
 		t.Run("happy", func(t *testing.T) {
 			mockAuthStore := &mockAuthorStore{}
-			srv := service.NewService(mockAuthStore, nil)
+			srv := service.NewService(service.Stores{Authors: mockAuthStore, Work: &mockUnitOfWork{}})
 
 			mockAuthErr = nil
 			mockAuth = authors.Author{
@@ -37,25 +90,36 @@ func TestService(t *testing.T) {
 					},
 				},
 			}
-			_, err := srv.GetAuthor("auth01")
+			_, err := srv.GetAuthor(ctx, "auth01")
 			assert.NoError(t, err)
 		})
 
 		t.Run("datastore error", func(t *testing.T) {
 			mockAuthStore := &mockAuthorStore{}
-			srv := service.NewService(mockAuthStore, nil)
+			srv := service.NewService(service.Stores{Authors: mockAuthStore, Work: &mockUnitOfWork{}})
 
 			mockAuthErr = errors.New("datastore error")
 			mockAuth = authors.Author{}
-			_, err := srv.GetAuthor("auth01")
+			_, err := srv.GetAuthor(ctx, "auth01")
 			assert.Error(t, err)
 		})
+
+		t.Run("not found", func(t *testing.T) {
+			mockAuthStore := &mockAuthorStore{}
+			srv := service.NewService(service.Stores{Authors: mockAuthStore, Work: &mockUnitOfWork{}})
+
+			mockAuthErr = datastore.ErrNotFound
+			mockAuth = authors.Author{}
+			_, err := srv.GetAuthor(ctx, "auth01")
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+			assert.Equal(t, "author not found: auth01", err.Error())
+		})
 	})
 
 	t.Run("ListAuthors", func(t *testing.T) {
 		t.Run("happy", func(t *testing.T) {
 			mockAuthStore := &mockAuthorStore{}
-			srv := service.NewService(mockAuthStore, nil)
+			srv := service.NewService(service.Stores{Authors: mockAuthStore, Work: &mockUnitOfWork{}})
 
 			mockAuthErr = nil
 			mockAuths = []authors.Author{
@@ -67,19 +131,37 @@ func TestService(t *testing.T) {
 					DOB:        &dt,
 				},
 			}
-			auths, err := srv.ListAuthors()
+			page, err := srv.ListAuthors(ctx, authors.AuthorQuery{})
 			assert.NoError(t, err)
-			require.Len(t, auths, 1)
-			assert.Equal(t, mockAuths, auths)
+			require.Len(t, page.Authors, 1)
+			assert.Equal(t, mockAuths, page.Authors)
+		})
+
+		t.Run("invalid query", func(t *testing.T) {
+			mockAuthStore := &mockAuthorStore{}
+			srv := service.NewService(service.Stores{Authors: mockAuthStore, Work: &mockUnitOfWork{}})
+
+			mockAuthErr = nil
+			for _, q := range []authors.AuthorQuery{
+				{SortBy: "middle_name"},
+				{Limit: datastore.MaxLimit + 1},
+				{Limit: -1},
+				{Cursor: "garbage"},
+				{DOBFrom: &dt, DOBTo: &time.Time{}},
+				{Cursor: datastore.Cursor{Sort: "dob", Value: "x", ID: "auth01"}.Encode()},
+			} {
+				_, err := srv.ListAuthors(ctx, q)
+				assert.True(t, errors.Is(err, service.ErrInvalid), q)
+			}
 		})
 
 		t.Run("datastore error", func(t *testing.T) {
 			mockAuthStore := &mockAuthorStore{}
-			srv := service.NewService(mockAuthStore, nil)
+			srv := service.NewService(service.Stores{Authors: mockAuthStore, Work: &mockUnitOfWork{}})
 
 			mockAuthErr = errors.New("datastore error")
 			mockAuths = nil
-			_, err := srv.ListAuthors()
+			_, err := srv.ListAuthors(ctx, authors.AuthorQuery{})
 			assert.Error(t, err)
 		})
 	})
@@ -87,19 +169,96 @@ func TestService(t *testing.T) {
 	t.Run("RemoveAuthor", func(t *testing.T) {
 		t.Run("happy", func(t *testing.T) {
 			mockAuthStore := &mockAuthorStore{}
-			srv := service.NewService(mockAuthStore, nil)
+			srv := service.NewService(service.Stores{Authors: mockAuthStore, Work: &mockUnitOfWork{}})
 
 			mockAuthErr = nil
-			err := srv.RemoveAuthor("auth01")
+			err := srv.RemoveAuthor(ctx, "auth01")
 			assert.NoError(t, err)
 		})
 
 		t.Run("datastore error", func(t *testing.T) {
 			mockAuthStore := &mockAuthorStore{}
-			srv := service.NewService(mockAuthStore, nil)
+			srv := service.NewService(service.Stores{Authors: mockAuthStore, Work: &mockUnitOfWork{}})
 
 			mockAuthErr = errors.New("datastore error")
-			err := srv.RemoveAuthor("auth01")
+			err := srv.RemoveAuthor(ctx, "auth01")
+			assert.Error(t, err)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			mockAuthStore := &mockAuthorStore{}
+			srv := service.NewService(service.Stores{Authors: mockAuthStore, Work: &mockUnitOfWork{}})
+
+			mockAuthErr = datastore.ErrNotFound
+			err := srv.RemoveAuthor(ctx, "auth01")
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+	})
+
+	t.Run("UpdateAuthor", func(t *testing.T) {
+		auth := authors.Author{
+			ID:         "auth01",
+			FirstName:  "First",
+			MiddleName: "Middle",
+			LastName:   "Last",
+			DOB:        &dt,
+		}
+
+		first, blank := "Updated", " "
+		defer func() { mockAuthUpdateErr = nil }()
+
+		t.Run("happy", func(t *testing.T) {
+			mockAuthStore := &mockAuthorStore{}
+			srv := service.NewService(service.Stores{Authors: mockAuthStore, Work: &mockUnitOfWork{}})
+
+			mockAuth, mockAuthErr, mockAuthUpdateErr = auth, nil, nil
+			err := srv.UpdateAuthor(ctx, "auth01", authors.Patch{FirstName: &first})
+			assert.NoError(t, err)
+
+			// fields left out of the patch are unchanged
+			want := auth
+			want.FirstName = first
+			assert.Equal(t, want, mockAuthUpdated)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			mockAuthStore := &mockAuthorStore{}
+			srv := service.NewService(service.Stores{Authors: mockAuthStore, Work: &mockUnitOfWork{}})
+
+			mockAuthErr, mockAuthUpdateErr = datastore.ErrNotFound, nil
+			err := srv.UpdateAuthor(ctx, "unknown", authors.Patch{FirstName: &first})
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+
+			// removed between reading and updating
+			mockAuth, mockAuthErr, mockAuthUpdateErr = auth, nil, datastore.ErrNotFound
+			err = srv.UpdateAuthor(ctx, "auth01", authors.Patch{FirstName: &first})
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+
+		t.Run("blank name", func(t *testing.T) {
+			mockAuthStore := &mockAuthorStore{}
+			srv := service.NewService(service.Stores{Authors: mockAuthStore, Work: &mockUnitOfWork{}})
+
+			mockAuth, mockAuthErr, mockAuthUpdateErr = auth, nil, nil
+			err := srv.UpdateAuthor(ctx, "auth01", authors.Patch{LastName: &blank})
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+		})
+
+		t.Run("duplicate", func(t *testing.T) {
+			mockAuthStore := &mockAuthorStore{}
+			srv := service.NewService(service.Stores{Authors: mockAuthStore, Work: &mockUnitOfWork{}})
+
+			mockAuth, mockAuthErr, mockAuthUpdateErr = auth, nil, datastore.ErrDuplicate
+			err := srv.UpdateAuthor(ctx, "auth01", authors.Patch{FirstName: &first})
+			assert.True(t, errors.Is(err, service.ErrDuplicate))
+		})
+
+		t.Run("datastore error", func(t *testing.T) {
+			mockAuthStore := &mockAuthorStore{}
+			srv := service.NewService(service.Stores{Authors: mockAuthStore, Work: &mockUnitOfWork{}})
+
+			mockAuthErr, mockAuthUpdateErr = errors.New("datastore error"), nil
+			err := srv.UpdateAuthor(ctx, "auth01", authors.Patch{FirstName: &first})
 			assert.Error(t, err)
 		})
 	})
@@ -107,16 +266,127 @@ func TestService(t *testing.T) {
 	t.Run("AddBook", func(t *testing.T) {
 		t.Run("happy", func(t *testing.T) {
 			mockBkStore := &mockBookStore{}
-			srv := service.NewService(nil, mockBkStore)
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
 			mockBooks = nil
 
-			_, err := srv.AddBook("titleA", "9783161484100")
+			_, err := srv.AddBook(ctx, "titleA", "9783161484100", books.Details{}, nil)
 			assert.NoError(t, err)
 		})
 
+		t.Run("isbn not found", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr = nil
+			mockISBNErr = datastore.ErrNotFound
+			defer func() { mockISBNErr = nil }()
+
+			results, err := srv.AddBook(ctx, "titleA", "9783161484100", books.Details{}, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, "titleA", results.Title)
+		})
+
+		t.Run("credits authors in order", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr, mockLinkErr, mockCredits = nil, nil, nil
+			mockISBNErr = datastore.ErrNotFound
+			defer func() { mockISBNErr = nil }()
+
+			_, err := srv.AddBook(ctx, "titleA", "9783161484100", books.Details{}, nil, "auth02", "auth01", "auth02")
+			assert.NoError(t, err)
+			assert.Equal(t, []books.Credit{
+				{AuthorID: "auth02", Role: books.RoleAuthor, Sequence: 1},
+				{AuthorID: "auth01", Role: books.RoleAuthor, Sequence: 2},
+			}, mockCredits)
+		})
+
+		t.Run("with price", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr, mockPrices = nil, nil
+			mockISBNErr = datastore.ErrNotFound
+			defer func() { mockISBNErr = nil }()
+
+			price := money.New(1299, money.USD)
+			results, err := srv.AddBook(ctx, "titleA", "9783161484100", books.Details{}, &price)
+			require.NoError(t, err)
+			assert.Equal(t, &price, results.ListPrice)
+			require.Len(t, mockPrices, 1)
+			assert.Equal(t, price, mockPrices[0].ListPrice)
+			assert.False(t, mockPrices[0].EffectiveFrom.IsZero())
+		})
+
+		t.Run("invalid price", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr = nil
+
+			for _, price := range []money.Money{money.New(-1, money.USD), money.New(100, "XYZ")} {
+				_, err := srv.AddBook(ctx, "titleA", "9783161484100", books.Details{}, &price)
+				assert.True(t, errors.Is(err, service.ErrInvalid), price)
+			}
+		})
+
+		t.Run("with details", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr = nil
+			mockISBNErr = datastore.ErrNotFound
+			defer func() { mockISBNErr = nil }()
+
+			on := time.Date(1965, 8, 1, 15, 4, 5, 0, time.UTC)
+			results, err := srv.AddBook(ctx, "titleA", "9783161484100", books.Details{
+				Subtitle:    "  Book One ",
+				Language:    "EN",
+				PageCount:   412,
+				Format:      "Paperback",
+				PublishedOn: &on,
+				Dimensions:  &books.Dimensions{Height: 216, Width: 140, Depth: 38},
+			}, nil)
+			require.NoError(t, err)
+			assert.Equal(t, "Book One", results.Subtitle)
+			assert.Equal(t, "en", results.Language)
+			assert.Equal(t, books.FormatPaperback, results.Format)
+			assert.Equal(t, time.Date(1965, 8, 1, 0, 0, 0, 0, time.UTC), *results.PublishedOn)
+			require.Len(t, mockUpserted, 1)
+			assert.Equal(t, results.Details, mockUpserted[0].Details)
+		})
+
+		t.Run("unknown publisher", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr = datastore.ErrMissingReference
+			mockISBNErr = datastore.ErrNotFound
+			defer func() { mockBooksErr, mockISBNErr = nil, nil }()
+
+			_, err := srv.AddBook(ctx, "titleA", "9783161484100", books.Details{PublisherID: "nope"}, nil)
+			assert.True(t, errors.Is(err, service.ErrInvalidReference))
+		})
+
+		t.Run("invalid details", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr = nil
+
+			for field, details := range map[string]books.Details{
+				"subtitle":    {Subtitle: strings.Repeat("a", books.MaxSubtitleLength+1)},
+				"description": {Description: strings.Repeat("a", books.MaxDescriptionLength+1)},
+				"edition":     {Edition: strings.Repeat("a", books.MaxEditionLength+1)},
+				"language":    {Language: "eng"},
+				"page_count":  {PageCount: -1},
+				"format":      {Format: "scroll"},
+				"dimensions":  {Dimensions: &books.Dimensions{Height: 216, Width: 140}},
+			} {
+				_, err := srv.AddBook(ctx, "titleA", "9783161484100", details, nil)
+				var verr *service.ValidationError
+				require.True(t, errors.As(err, &verr), field)
+				assert.Equal(t, field, verr.Field)
+			}
+		})
+
 		t.Run("already exists", func(t *testing.T) {
 			mockBkStore := &mockBookStore{}
-			srv := service.NewService(nil, mockBkStore)
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
 			mockBooksErr = nil
 			mockBook = books.Book{
 				ID:    "abc01",
@@ -124,27 +394,263 @@ func TestService(t *testing.T) {
 				ISBN:  "9783161484100",
 			}
 
-			results, err := srv.AddBook("titleA", "9783161484100")
+			results, err := srv.AddBook(ctx, "titleA", "9783161484100", books.Details{}, nil)
 			assert.Error(t, err)
 			assert.Equal(t, results, books.Book{})
 		})
 
 		t.Run("datastore error", func(t *testing.T) {
 			mockBkStore := &mockBookStore{}
-			srv := service.NewService(nil, mockBkStore)
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
 			mockBooks = nil
 			mockBooksErr = errors.New("datastore error")
 
-			results, err := srv.AddBook("titleA", "9783161484100")
+			results, err := srv.AddBook(ctx, "titleA", "9783161484100", books.Details{}, nil)
 			assert.Error(t, err)
 			assert.Equal(t, results, books.Book{})
 		})
+
+		t.Run("invalid isbn", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr = nil
+			mockBook = books.Book{}
+
+			results, err := srv.AddBook(ctx, "titleA", "9783161484101", books.Details{}, nil)
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+			assert.True(t, errors.Is(err, books.ErrInvalidISBN))
+			assert.Equal(t, results, books.Book{})
+		})
+
+		t.Run("normalizes isbn", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr = nil
+			mockBook = books.Book{}
+
+			results, err := srv.AddBook(ctx, "titleA", "0-306-40615-2", books.Details{}, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, books.ISBN("9780306406157"), results.ISBN)
+		})
+
+		t.Run("with authors", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr = nil
+			mockLinkErr = nil
+			mockBook = books.Book{}
+
+			results, err := srv.AddBook(ctx, "titleA", "9783161484100", books.Details{}, nil, "auth01")
+			assert.NoError(t, err)
+			assert.Equal(t, mockBook, results)
+		})
+
+		t.Run("unknown author", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr = nil
+			mockLinkErr = datastore.ErrMissingReference
+			mockBook = books.Book{}
+
+			results, err := srv.AddBook(ctx, "titleA", "9783161484100", books.Details{}, nil, "auth01")
+			assert.True(t, errors.Is(err, service.ErrInvalidReference))
+			assert.Equal(t, results, books.Book{})
+		})
+
+		t.Run("in a unit of work", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr = nil
+			mockLinkErr = nil
+			mockBook = books.Book{}
+			mockWorkDone = 0
+
+			_, err := srv.AddBook(ctx, "titleA", "9783161484100", books.Details{}, nil, "auth01")
+			assert.NoError(t, err)
+			assert.Equal(t, 1, mockWorkDone)
+
+			mockWorkErr = errors.New("could not serialize access")
+			defer func() { mockWorkErr = nil }()
+			results, err := srv.AddBook(ctx, "titleA", "9783161484100", books.Details{}, nil, "auth01")
+			assert.Equal(t, mockWorkErr, err)
+			assert.Equal(t, results, books.Book{})
+		})
+	})
+
+	t.Run("GetBook", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr = nil
+			mockBook = books.Book{
+				ID:    "abc01",
+				Title: "titleA",
+				ISBN:  "9783161484100",
+				Authors: []books.BookAuthor{
+					{ID: "auth01", FirstName: "First", LastName: "Last"},
+				},
+			}
+
+			result, err := srv.GetBook(ctx, "abc01")
+			assert.NoError(t, err)
+			assert.Equal(t, mockBook, result)
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr = datastore.ErrNotFound
+
+			result, err := srv.GetBook(ctx, "abc01")
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+			assert.Equal(t, "book not found: abc01", err.Error())
+			assert.Equal(t, books.Book{}, result)
+		})
+
+		t.Run("datastore error", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr = errors.New("datastore error")
+
+			_, err := srv.GetBook(ctx, "abc01")
+			assert.Error(t, err)
+		})
+	})
+
+	t.Run("AddBookAuthor", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockLinkErr, mockBooksErr = nil, nil
+			mockBook = books.Book{ID: "abc01", Authors: []books.BookAuthor{
+				{ID: "auth02", Role: books.RoleAuthor, Sequence: 1},
+				{ID: "auth03", Role: books.RoleEditor, Sequence: 3},
+			}}
+
+			err := srv.AddBookAuthor(ctx, "abc01", "auth01", "", 0)
+			assert.NoError(t, err)
+			assert.Equal(t, []books.Credit{{AuthorID: "auth01", Role: books.RoleAuthor, Sequence: 4}}, mockCredits)
+		})
+
+		t.Run("retried", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			uow := &retryingUnitOfWork{before: func() {
+				// another credit was added before the retry
+				mockBook.Authors = append(mockBook.Authors, books.BookAuthor{ID: "auth04", Role: books.RoleAuthor, Sequence: 4})
+			}}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: uow})
+			mockLinkErr, mockBooksErr = nil, nil
+			mockBook = books.Book{ID: "abc01", Authors: []books.BookAuthor{
+				{ID: "auth02", Role: books.RoleAuthor, Sequence: 1},
+				{ID: "auth03", Role: books.RoleEditor, Sequence: 3},
+			}}
+
+			err := srv.AddBookAuthor(ctx, "abc01", "auth01", "", 0)
+			assert.NoError(t, err)
+			assert.Equal(t, []books.Credit{{AuthorID: "auth01", Role: books.RoleAuthor, Sequence: 5}}, mockCredits)
+		})
+
+		t.Run("existing credit keeps its place", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockLinkErr, mockBooksErr = nil, nil
+			mockBook = books.Book{ID: "abc01", Authors: []books.BookAuthor{
+				{ID: "auth02", Role: books.RoleAuthor, Sequence: 1},
+				{ID: "auth03", Role: books.RoleEditor, Sequence: 3},
+			}}
+
+			err := srv.AddBookAuthor(ctx, "abc01", "auth03", " Editor ", 0)
+			assert.NoError(t, err)
+			assert.Equal(t, []books.Credit{{AuthorID: "auth03", Role: books.RoleEditor, Sequence: 3}}, mockCredits)
+		})
+
+		t.Run("at sequence", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockLinkErr = nil
+			mockBooksErr = errors.New("book should not be read")
+			defer func() { mockBooksErr = nil }()
+
+			err := srv.AddBookAuthor(ctx, "abc01", "auth01", books.RoleTranslator, 2)
+			assert.NoError(t, err)
+			assert.Equal(t, []books.Credit{{AuthorID: "auth01", Role: books.RoleTranslator, Sequence: 2}}, mockCredits)
+		})
+
+		t.Run("invalid", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockLinkErr = nil
+
+			err := srv.AddBookAuthor(ctx, "abc01", "auth01", "ghostwriter", 0)
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+			err = srv.AddBookAuthor(ctx, "abc01", "auth01", "", -1)
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+			err = srv.AddBookAuthor(ctx, "abc01", "auth01", "", books.MaxSequence+1)
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+		})
+
+		t.Run("unknown book or author", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockLinkErr = datastore.ErrMissingReference
+
+			err := srv.AddBookAuthor(ctx, "abc01", "auth01", "", 1)
+			assert.True(t, errors.Is(err, service.ErrInvalidReference))
+
+			mockLinkErr, mockBooksErr = nil, datastore.ErrNotFound
+			defer func() { mockBooksErr = nil }()
+			err = srv.AddBookAuthor(ctx, "abc01", "auth01", "", 0)
+			assert.True(t, errors.Is(err, service.ErrInvalidReference))
+		})
+	})
+
+	t.Run("RemoveBookAuthor", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockLinkErr = nil
+
+			err := srv.RemoveBookAuthor(ctx, "abc01", "auth01", "")
+			assert.NoError(t, err)
+			assert.Equal(t, books.Role(""), mockUnlinkedRole)
+		})
+
+		t.Run("in role", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockLinkErr = nil
+
+			err := srv.RemoveBookAuthor(ctx, "abc01", "auth01", "Narrator")
+			assert.NoError(t, err)
+			assert.Equal(t, books.RoleNarrator, mockUnlinkedRole)
+
+			err = srv.RemoveBookAuthor(ctx, "abc01", "auth01", "ghostwriter")
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+		})
+
+		t.Run("datastore error", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockLinkErr = errors.New("datastore error")
+
+			err := srv.RemoveBookAuthor(ctx, "abc01", "auth01", "")
+			assert.Error(t, err)
+		})
+
+		t.Run("not credited", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockLinkErr = datastore.ErrNotFound
+
+			err := srv.RemoveBookAuthor(ctx, "abc01", "auth01", "")
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
 	})
 
 	t.Run("ListBooks", func(t *testing.T) {
 		t.Run("happy", func(t *testing.T) {
 			mockBkStore := &mockBookStore{}
-			srv := service.NewService(nil, mockBkStore)
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
 			mockBooksErr = nil
 			mockBooks = []books.Book{
 				{
@@ -154,61 +660,288 @@ func TestService(t *testing.T) {
 				},
 			}
 
-			results, err := srv.ListBooks()
+			results, err := srv.ListBooks(ctx, books.BookQuery{TitlePrefix: "title"})
+			assert.NoError(t, err)
+			assert.Len(t, results.Books, 1)
+			assert.Equal(t, mockBooks, results.Books)
+		})
+
+		t.Run("invalid query", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr = nil
+
+			cursor := datastore.Cursor{Sort: "title", Value: "titleA", ID: "abc01"}.Encode()
+			for _, q := range []books.BookQuery{
+				{SortBy: "author"},
+				{Limit: datastore.MaxLimit + 1},
+				{Cursor: "garbage"},
+				{Cursor: cursor, SortBy: books.SortByISBN},
+				{Cursor: cursor, Descending: true},
+			} {
+				_, err := srv.ListBooks(ctx, q)
+				assert.True(t, errors.Is(err, service.ErrInvalid), q)
+			}
+
+			_, err := srv.ListBooks(ctx, books.BookQuery{Cursor: cursor})
 			assert.NoError(t, err)
-			assert.Len(t, results, 1)
-			assert.Equal(t, mockBooks, results)
 		})
 
 		t.Run("datastore error", func(t *testing.T) {
 			mockBkStore := &mockBookStore{}
-			srv := service.NewService(nil, mockBkStore)
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
 			mockBooks = nil
 			mockBooksErr = errors.New("datastore error")
 
-			results, err := srv.ListBooks()
+			results, err := srv.ListBooks(ctx, books.BookQuery{})
 			assert.Error(t, err)
-			assert.Nil(t, results)
+			assert.Nil(t, results.Books)
 		})
 	})
 
 	t.Run("RemoveBooks", func(t *testing.T) {
 		t.Run("happy", func(t *testing.T) {
 			mockBkStore := &mockBookStore{}
-			srv := service.NewService(nil, mockBkStore)
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
 			mockBooksErr = nil
-			err := srv.RemoveBooks("abc01", "def02")
+			err := srv.RemoveBooks(ctx, "abc01", "def02")
 			assert.NoError(t, err)
 		})
 
 		t.Run("datastore error", func(t *testing.T) {
 			mockBkStore := &mockBookStore{}
-			srv := service.NewService(nil, mockBkStore)
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
 			mockBooksErr = errors.New("datastore error")
-			err := srv.RemoveBooks("abc01", "def02")
+			err := srv.RemoveBooks(ctx, "abc01", "def02")
 			assert.Error(t, err)
 		})
+
+		t.Run("not found", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr = datastore.ErrNotFound
+			err := srv.RemoveBooks(ctx, "abc01", "def02")
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+			assert.Equal(t, "book not found: abc01, def02", err.Error())
+		})
 	})
 
 	t.Run("UpdateBook", func(t *testing.T) {
+		published := time.Date(1965, 8, 1, 0, 0, 0, 0, time.UTC)
 		mockBook = books.Book{
 			ID:    "abc01",
 			Title: "titleA",
 			ISBN:  "9783161484100",
+			Details: books.Details{
+				Subtitle:    "A Novel",
+				PublisherID: "pub01",
+				PublishedOn: &published,
+				Format:      books.FormatHardcover,
+				Dimensions:  &books.Dimensions{Height: 216, Width: 140, Depth: 38},
+			},
 		}
+		title := "titleB"
 		t.Run("happy", func(t *testing.T) {
 			mockBkStore := &mockBookStore{}
-			srv := service.NewService(nil, mockBkStore)
-			mockBooksErr = nil
-			err := srv.UpdateBook(mockBook)
-			assert.NoError(t, err)
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr, mockUpsertErr = nil, nil
+			err := srv.UpdateBook(ctx, "abc01", books.Patch{Title: &title})
+			require.NoError(t, err)
+			require.Len(t, mockUpserted, 1)
+			assert.Equal(t, "titleB", mockUpserted[0].Title)
+			assert.Equal(t, mockBook.ISBN, mockUpserted[0].ISBN)
+			assert.Equal(t, mockBook.Details, mockUpserted[0].Details, "details and publisher left out are kept")
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr, mockUpsertErr = datastore.ErrNotFound, nil
+			err := srv.UpdateBook(ctx, "abc01", books.Patch{Title: &title})
+			assert.True(t, errors.Is(err, service.ErrNotFound))
 		})
 
 		t.Run("datastore error", func(t *testing.T) {
 			mockBkStore := &mockBookStore{}
-			srv := service.NewService(nil, mockBkStore)
-			mockBooksErr = errors.New("datastore error")
-			err := srv.UpdateBook(mockBook)
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr, mockUpsertErr = nil, errors.New("datastore error")
+			defer func() { mockUpsertErr = nil }()
+			err := srv.UpdateBook(ctx, "abc01", books.Patch{Title: &title})
+			assert.Error(t, err)
+		})
+
+		t.Run("duplicate isbn", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr, mockUpsertErr = nil, datastore.ErrDuplicate
+			defer func() { mockUpsertErr = nil }()
+			err := srv.UpdateBook(ctx, "abc01", books.Patch{Title: &title})
+			assert.True(t, errors.Is(err, service.ErrDuplicate))
+		})
+
+		t.Run("unknown publisher", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr, mockUpsertErr = nil, datastore.ErrMissingReference
+			defer func() { mockUpsertErr = nil }()
+			pub := "pub99"
+			err := srv.UpdateBook(ctx, "abc01", books.Patch{PublisherID: &pub})
+			assert.True(t, errors.Is(err, service.ErrInvalidReference))
+		})
+
+		t.Run("invalid isbn", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr = nil
+			isbn := books.ISBN("999999999")
+			err := srv.UpdateBook(ctx, "abc01", books.Patch{ISBN: &isbn})
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+		})
+
+		t.Run("details", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr = nil
+			edition, format := " Second edition ", books.Format("EBOOK")
+			require.NoError(t, srv.UpdateBook(ctx, "abc01", books.Patch{Edition: &edition, Format: &format}))
+			require.Len(t, mockUpserted, 1)
+			assert.Equal(t, "Second edition", mockUpserted[0].Edition)
+			assert.Equal(t, books.FormatEbook, mockUpserted[0].Format)
+			assert.Equal(t, "A Novel", mockUpserted[0].Subtitle)
+
+			language := "english"
+			err := srv.UpdateBook(ctx, "abc01", books.Patch{Language: &language})
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+		})
+
+		t.Run("price", func(t *testing.T) {
+			mockBkStore := &mockBookStore{}
+			srv := service.NewService(service.Stores{Books: mockBkStore, Work: &mockUnitOfWork{}})
+			mockBooksErr, mockPrices = nil, nil
+			current := money.New(999, money.EUR)
+			mockBook.ListPrice = &current
+			defer func() { mockBook.ListPrice = nil }()
+
+			// a price left out or unchanged is not recorded again
+			require.NoError(t, srv.UpdateBook(ctx, "abc01", books.Patch{Title: &title}))
+			same := current
+			require.NoError(t, srv.UpdateBook(ctx, "abc01", books.Patch{ListPrice: &same}))
+			assert.Empty(t, mockPrices)
+
+			changed := money.New(1099, money.EUR)
+			err := srv.UpdateBook(ctx, "abc01", books.Patch{ListPrice: &changed})
+			require.NoError(t, err)
+			require.Len(t, mockPrices, 1)
+			assert.Equal(t, changed, mockPrices[0].ListPrice)
+
+			negative := money.New(-1, money.EUR)
+			err = srv.UpdateBook(ctx, "abc01", books.Patch{ListPrice: &negative})
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+		})
+	})
+
+	t.Run("ListBookPrices", func(t *testing.T) {
+		srv := service.NewService(service.Stores{Books: &mockBookStore{}, Work: &mockUnitOfWork{}})
+
+		t.Run("happy", func(t *testing.T) {
+			mockPriceErr = nil
+			mockPrices = []books.Price{{ListPrice: money.New(999, money.GBP), EffectiveFrom: dt}}
+			prices, err := srv.ListBookPrices(ctx, "abc01")
+			require.NoError(t, err)
+			assert.Equal(t, mockPrices, prices)
+		})
+
+		t.Run("book not found", func(t *testing.T) {
+			mockPriceErr = datastore.ErrNotFound
+			defer func() { mockPriceErr = nil }()
+			_, err := srv.ListBookPrices(ctx, "abc01")
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+	})
+
+	t.Run("SetBookPrice", func(t *testing.T) {
+		srv := service.NewService(service.Stores{Books: &mockBookStore{}, Work: &mockUnitOfWork{}})
+
+		t.Run("scheduled", func(t *testing.T) {
+			mockPriceErr, mockPrices = nil, nil
+			later := time.Now().Add(24 * time.Hour)
+			err := srv.SetBookPrice(ctx, "abc01", books.Price{ListPrice: money.New(999, money.GBP), EffectiveFrom: later})
+			require.NoError(t, err)
+			require.Len(t, mockPrices, 1)
+			assert.Equal(t, later, mockPrices[0].EffectiveFrom)
+		})
+
+		t.Run("immediate", func(t *testing.T) {
+			mockPriceErr, mockPrices = nil, nil
+			err := srv.SetBookPrice(ctx, "abc01", books.Price{ListPrice: money.New(999, money.GBP)})
+			require.NoError(t, err)
+			require.Len(t, mockPrices, 1)
+			assert.False(t, mockPrices[0].EffectiveFrom.IsZero())
+		})
+
+		t.Run("invalid price", func(t *testing.T) {
+			mockPriceErr = nil
+			err := srv.SetBookPrice(ctx, "abc01", books.Price{ListPrice: money.New(-5, money.GBP)})
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+		})
+
+		t.Run("book not found", func(t *testing.T) {
+			mockPriceErr = datastore.ErrMissingReference
+			defer func() { mockPriceErr = nil }()
+			err := srv.SetBookPrice(ctx, "abc01", books.Price{ListPrice: money.New(999, money.GBP)})
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		mockBookResults = []search.Result{
+			{Kind: search.KindBook, ID: "abc01", Title: "Dune", Rank: 0.5},
+			{Kind: search.KindBook, ID: "abc02", Title: "Dune Messiah", Rank: 0.2},
+		}
+		mockAuthResults = []search.Result{
+			{Kind: search.KindAuthor, ID: "auth01", Title: "Frank Herbert", Rank: 0.3},
+		}
+
+		t.Run("happy", func(t *testing.T) {
+			srv := service.NewService(service.Stores{Authors: &mockAuthorStore{}, Books: &mockBookStore{}, Work: &mockUnitOfWork{}})
+			mockAuthErr = nil
+			mockBooksErr = nil
+
+			results, err := srv.Search(ctx, "dune", 0)
+			require.NoError(t, err)
+			require.Len(t, results, 3)
+			assert.Equal(t, "abc01", results[0].ID)
+			assert.Equal(t, "auth01", results[1].ID)
+			assert.Equal(t, "abc02", results[2].ID)
+		})
+
+		t.Run("limit", func(t *testing.T) {
+			srv := service.NewService(service.Stores{Authors: &mockAuthorStore{}, Books: &mockBookStore{}, Work: &mockUnitOfWork{}})
+			mockAuthErr = nil
+			mockBooksErr = nil
+
+			results, err := srv.Search(ctx, "dune", 2)
+			require.NoError(t, err)
+			require.Len(t, results, 2)
+			assert.Equal(t, "auth01", results[1].ID)
+		})
+
+		t.Run("blank query", func(t *testing.T) {
+			srv := service.NewService(service.Stores{Authors: &mockAuthorStore{}, Books: &mockBookStore{}, Work: &mockUnitOfWork{}})
+
+			_, err := srv.Search(ctx, "  ", 0)
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+
+			_, err = srv.Search(ctx, "dune", datastore.MaxLimit+1)
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+		})
+
+		t.Run("datastore error", func(t *testing.T) {
+			srv := service.NewService(service.Stores{Authors: &mockAuthorStore{}, Books: &mockBookStore{}, Work: &mockUnitOfWork{}})
+			mockAuthErr = errors.New("datastore error")
+			mockBooksErr = nil
+
+			_, err := srv.Search(ctx, "dune", 0)
 			assert.Error(t, err)
 		})
 	})
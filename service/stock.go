@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"bookshop/auth"
+	"bookshop/datastore"
+	"bookshop/stock"
+	"bookshop/users"
+)
+
+// GetStock will return the stock level of the given book. Its latest ledger
+// entries, which carry the reasons staff gave, are only shown to users with the
+// stock permission.
+func (s *Service) GetStock(ctx context.Context, bookID string) (stock.Level, error) {
+	lvl, err := s.stockStore.ReadStock(ctx, bookID)
+	if err != nil {
+		return stock.Level{}, notFound(err, "book", bookID)
+	}
+	if !canManageStock(ctx) {
+		lvl.Ledger = nil
+	}
+	return lvl, nil
+}
+
+// canManageStock reports whether the signed in user carried by ctx, if any, may
+// move stock.
+func canManageStock(ctx context.Context) bool {
+	u, ok := auth.UserFrom(ctx)
+	return ok && u.Can(users.PermManageStock)
+}
+
+// ReceiveStock adds copies delivered to the shop to the book's stock. Changing
+// stock, like the other movements below, needs the stock permission.
+func (s *Service) ReceiveStock(ctx context.Context, bookID string, quantity int, reason string) (stock.Level, error) {
+	if err := s.Authorize(ctx, users.PermManageStock); err != nil {
+		return stock.Level{}, err
+	}
+	return s.moveStock(ctx, stock.Movement{BookID: bookID, Kind: stock.Receive, Quantity: quantity, Reason: reason})
+}
+
+// AdjustStock corrects the copies of the book on hand by the given change,
+// which may be negative.
+func (s *Service) AdjustStock(ctx context.Context, bookID string, change int, reason string) (stock.Level, error) {
+	if err := s.Authorize(ctx, users.PermManageStock); err != nil {
+		return stock.Level{}, err
+	}
+	return s.moveStock(ctx, stock.Movement{BookID: bookID, Kind: stock.Adjust, Quantity: change, Reason: reason})
+}
+
+// ReserveStock sets available copies of the book aside.
+func (s *Service) ReserveStock(ctx context.Context, bookID string, quantity int, reason string) (stock.Level, error) {
+	if err := s.Authorize(ctx, users.PermManageStock); err != nil {
+		return stock.Level{}, err
+	}
+	return s.moveStock(ctx, stock.Movement{BookID: bookID, Kind: stock.Reserve, Quantity: quantity, Reason: reason})
+}
+
+// ReleaseStock returns reserved copies of the book to those available.
+func (s *Service) ReleaseStock(ctx context.Context, bookID string, quantity int, reason string) (stock.Level, error) {
+	if err := s.Authorize(ctx, users.PermManageStock); err != nil {
+		return stock.Level{}, err
+	}
+	return s.moveStock(ctx, stock.Movement{BookID: bookID, Kind: stock.Release, Quantity: quantity, Reason: reason})
+}
+
+func (s *Service) moveStock(ctx context.Context, m stock.Movement) (stock.Level, error) {
+	if err := m.Validate(); err != nil {
+		return stock.Level{}, NewErrInvalid("stock movement", err)
+	}
+	lvl, err := s.stockStore.ApplyMovement(ctx, m)
+	if errors.Is(err, datastore.ErrCheckViolation) {
+		return stock.Level{}, NewErrInsufficientStock(m.BookID)
+	}
+	if errors.Is(err, datastore.ErrMissingReference) {
+		return stock.Level{}, NewErrNotFound("book", m.BookID)
+	}
+	if err != nil {
+		return stock.Level{}, err
+	}
+	return lvl, nil
+}
@@ -0,0 +1,135 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bookshop/auth"
+	"bookshop/datastore"
+	"bookshop/service"
+	"bookshop/stock"
+	"bookshop/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceStock(t *testing.T) {
+	staff := users.User{ID: "usr01", Role: users.RoleStaff, Permissions: users.RolePermissions[users.RoleStaff]}
+	customer := users.User{ID: "usr02", Role: users.RoleCustomer}
+	ctx := auth.WithUser(context.Background(), staff)
+	customerCtx := auth.WithUser(context.Background(), customer)
+	srv := service.NewService(service.Stores{Stock: &mockStockStore{}, Work: &mockUnitOfWork{}})
+
+	t.Run("GetStock", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockStockErr = nil
+			mockStock = stock.Level{BookID: "abc01", OnHand: 3, Reserved: 1}
+
+			lvl, err := srv.GetStock(ctx, "abc01")
+			require.NoError(t, err)
+			assert.Equal(t, mockStock, lvl)
+			assert.Equal(t, 2, lvl.Available())
+		})
+
+		t.Run("ledger", func(t *testing.T) {
+			mockStockErr = nil
+			mockStock = stock.Level{BookID: "abc01", OnHand: 3, Ledger: []stock.Entry{{ID: 1, BookID: "abc01", Kind: stock.Receive, OnHandDelta: 3, Reason: "delivery"}}}
+
+			lvl, err := srv.GetStock(ctx, "abc01")
+			require.NoError(t, err)
+			assert.Equal(t, mockStock.Ledger, lvl.Ledger, "staff see the ledger")
+
+			for name, ctx := range map[string]context.Context{"customer": customerCtx, "anonymous": context.Background()} {
+				lvl, err = srv.GetStock(ctx, "abc01")
+				require.NoError(t, err)
+				assert.Equal(t, 3, lvl.OnHand, name)
+				assert.Nil(t, lvl.Ledger, name)
+			}
+		})
+
+		t.Run("book not found", func(t *testing.T) {
+			mockStockErr = datastore.ErrNotFound
+
+			_, err := srv.GetStock(ctx, "abc01")
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+	})
+
+	t.Run("movements", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			mockStockErr = nil
+			mockStock = stock.Level{BookID: "abc01", OnHand: 5}
+
+			lvl, err := srv.ReceiveStock(ctx, "abc01", 5, "delivery")
+			require.NoError(t, err)
+			assert.Equal(t, mockStock, lvl)
+			assert.Equal(t, stock.Movement{BookID: "abc01", Kind: stock.Receive, Quantity: 5, Reason: "delivery"}, mockMovement)
+
+			_, err = srv.AdjustStock(ctx, "abc01", -1, "damaged")
+			require.NoError(t, err)
+			assert.Equal(t, stock.Adjust, mockMovement.Kind)
+			assert.Equal(t, -1, mockMovement.Quantity)
+
+			_, err = srv.ReserveStock(ctx, "abc01", 2, "order")
+			require.NoError(t, err)
+			assert.Equal(t, stock.Reserve, mockMovement.Kind)
+
+			_, err = srv.ReleaseStock(ctx, "abc01", 2, "cancelled")
+			require.NoError(t, err)
+			assert.Equal(t, stock.Release, mockMovement.Kind)
+		})
+
+		t.Run("invalid quantity", func(t *testing.T) {
+			mockStockErr = nil
+			mockMovement = stock.Movement{}
+
+			_, err := srv.ReserveStock(ctx, "abc01", 0, "order")
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+			assert.Empty(t, mockMovement.Kind)
+
+			_, err = srv.AdjustStock(ctx, "abc01", 0, "count")
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+		})
+
+		t.Run("insufficient stock", func(t *testing.T) {
+			mockStockErr = datastore.ErrCheckViolation
+
+			_, err := srv.ReserveStock(ctx, "abc01", 9, "order")
+			assert.True(t, errors.Is(err, service.ErrInsufficientStock))
+		})
+
+		t.Run("book not found", func(t *testing.T) {
+			mockStockErr = datastore.ErrMissingReference
+
+			_, err := srv.ReceiveStock(ctx, "abc01", 1, "delivery")
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+
+		t.Run("store error", func(t *testing.T) {
+			mockStockErr = errors.New("store error")
+
+			_, err := srv.ReceiveStock(ctx, "abc01", 1, "delivery")
+			assert.EqualError(t, err, "store error")
+		})
+
+		t.Run("forbidden", func(t *testing.T) {
+			mockStockErr = nil
+			mockMovement = stock.Movement{}
+
+			for name, move := range map[string]func(context.Context, string, int, string) (stock.Level, error){
+				"ReceiveStock": srv.ReceiveStock,
+				"AdjustStock":  srv.AdjustStock,
+				"ReserveStock": srv.ReserveStock,
+				"ReleaseStock": srv.ReleaseStock,
+			} {
+				_, err := move(customerCtx, "abc01", 1, "delivery")
+				assert.True(t, errors.Is(err, service.ErrForbidden), name)
+				_, err = move(context.Background(), "abc01", 1, "delivery")
+				assert.True(t, errors.Is(err, service.ErrUnauthenticated), name)
+			}
+			assert.Empty(t, mockMovement.Kind, "nothing is moved")
+		})
+	})
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"bookshop/auth"
+	"bookshop/datastore"
+	"bookshop/users"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// RegisterUser adds an enabled customer with the given email address, names and
+// password, generating its UUID. Only a bcrypt hash of the password is kept.
+// An email address already in use, in any case, is rejected.
+func (s *Service) RegisterUser(ctx context.Context, u users.User, password string) (users.User, error) {
+	u.Email = users.NormalizeEmail(u.Email)
+	if err := users.ValidateEmail(u.Email); err != nil {
+		return users.User{}, NewErrInvalid("email", err)
+	}
+	hash, err := users.HashPassword(password)
+	if err != nil {
+		return users.User{}, NewErrInvalid("password", err)
+	}
+
+	u.ID, u.PasswordHash, u.Enabled, u.Role = uuid.NewV4().String(), hash, true, users.RoleCustomer
+	if err := s.userStore.CreateUser(ctx, u); err != nil {
+		if errors.Is(err, datastore.ErrDuplicate) {
+			return users.User{}, NewErrDuplicateUser(u.Email)
+		}
+		return users.User{}, err
+	}
+	return s.readUser(ctx, u.ID)
+}
+
+// GetUser will return the user with the given ID. Users may read themselves and
+// admins anyone.
+func (s *Service) GetUser(ctx context.Context, id string) (users.User, error) {
+	if err := authorizeOwner(ctx, id, users.PermManageUsers); err != nil {
+		return users.User{}, err
+	}
+	return s.readUser(ctx, id)
+}
+
+func (s *Service) readUser(ctx context.Context, id string) (users.User, error) {
+	u, err := s.userStore.ReadUserByID(ctx, id)
+	if err != nil {
+		return users.User{}, notFound(err, "user", id)
+	}
+	return u, nil
+}
+
+// UpdateUser changes the email address and names of the user, returning it as
+// changed. Passwords and whether the user is enabled are changed separately.
+// Users may change themselves and admins anyone.
+func (s *Service) UpdateUser(ctx context.Context, u users.User) (users.User, error) {
+	if err := authorizeOwner(ctx, u.ID, users.PermManageUsers); err != nil {
+		return users.User{}, err
+	}
+	u.Email = users.NormalizeEmail(u.Email)
+	if err := users.ValidateEmail(u.Email); err != nil {
+		return users.User{}, NewErrInvalid("email", err)
+	}
+	if err := s.userStore.UpdateUser(ctx, u); err != nil {
+		if errors.Is(err, datastore.ErrDuplicate) {
+			return users.User{}, NewErrDuplicateUser(u.Email)
+		}
+		return users.User{}, notFound(err, "user", u.ID)
+	}
+	return s.readUser(ctx, u.ID)
+}
+
+// ChangePassword replaces the user's password. Users may change their own
+// passwords, provided the current password given matches the one it replaces,
+// and admins may reset anyone else's without it.
+func (s *Service) ChangePassword(ctx context.Context, id, current, next string) error {
+	if err := authorizeOwner(ctx, id, users.PermManageUsers); err != nil {
+		return err
+	}
+	// only admins get past authorizeOwner for someone else
+	caller, _ := auth.UserFrom(ctx)
+	reset := caller.ID != id
+	hash, err := users.HashPassword(next)
+	if err != nil {
+		return NewErrInvalid("password", err)
+	}
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		u, err := s.readUser(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !reset && !u.CheckPassword(current) {
+			return NewErrInvalid("current password", users.ErrPasswordMismatch)
+		}
+		return s.userStore.SetUserPassword(ctx, id, hash)
+	})
+}
+
+// SetUserEnabled enables or disables the user. Disabling a user also ends all
+// of their sessions. Only admins may enable or disable users.
+func (s *Service) SetUserEnabled(ctx context.Context, id string, enabled bool) error {
+	if err := s.Authorize(ctx, users.PermManageUsers); err != nil {
+		return err
+	}
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.userStore.SetUserEnabled(ctx, id, enabled); err != nil {
+			return notFound(err, "user", id)
+		}
+		if enabled {
+			return nil
+		}
+		_, err := s.sessStore.DeleteUserSessions(ctx, id)
+		return err
+	})
+}
+
+// SetUserRole changes the user's role. Only admins may change roles, and the
+// change applies to the user's sessions from their next request.
+func (s *Service) SetUserRole(ctx context.Context, id string, role users.Role) error {
+	if err := s.Authorize(ctx, users.PermManageUsers); err != nil {
+		return err
+	}
+	if _, err := users.ParseRole(string(role)); err != nil {
+		return NewErrInvalid("role", err)
+	}
+	return notFound(s.userStore.SetUserRole(ctx, id, role), "user", id)
+}
@@ -0,0 +1,246 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bookshop/auth"
+	"bookshop/datastore"
+	"bookshop/service"
+	"bookshop/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceUsers(t *testing.T) {
+	ctx := context.Background()
+	// usr01 is the user acted on, bob another customer
+	annCtx := auth.WithUser(ctx, users.User{ID: "usr01", Role: users.RoleCustomer})
+	bobCtx := auth.WithUser(ctx, users.User{ID: "usr04", Role: users.RoleCustomer})
+	adminCtx := auth.WithUser(ctx, users.User{ID: "usr02", Role: users.RoleAdmin, Permissions: users.RolePermissions[users.RoleAdmin]})
+	srv := service.NewService(service.Stores{Sessions: &mockSessionStore{}, Users: &mockUserStore{}, Work: &mockUnitOfWork{}})
+	reset := func() {
+		mockUserErr, mockUserHash, mockUserEnabled = nil, "", nil
+		mockSessionErr, mockSessionsRevoked = nil, nil
+		mockUser = users.User{}
+	}
+
+	t.Run("RegisterUser", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+
+			u, err := srv.RegisterUser(ctx, users.User{Email: " Ann@Example.com", FirstName: "Ann", LastName: "Lee"}, "correct horse")
+			require.NoError(t, err)
+			assert.NotEmpty(t, u.ID)
+			assert.Equal(t, "ann@example.com", u.Email)
+			assert.True(t, u.Enabled)
+			assert.NotEqual(t, "correct horse", u.PasswordHash)
+			assert.True(t, u.CheckPassword("correct horse"))
+			assert.False(t, u.CheckPassword("wrong horse"))
+		})
+
+		t.Run("invalid", func(t *testing.T) {
+			for email, password := range map[string]string{
+				"not an email":          "correct horse",
+				"Ann <ann@example.com>": "correct horse",
+				"ann@example.com":       "short",
+				"ann@example.com\x00":   "correct horse",
+				"bob@example.com":       string(make([]byte, users.MaxPasswordLength+1)),
+			} {
+				reset()
+				_, err := srv.RegisterUser(ctx, users.User{Email: email, FirstName: "Ann", LastName: "Lee"}, password)
+				assert.True(t, errors.Is(err, service.ErrInvalid), email)
+				assert.Empty(t, mockUser.ID)
+			}
+		})
+
+		t.Run("email taken", func(t *testing.T) {
+			reset()
+			mockUserErr = datastore.ErrDuplicate
+
+			_, err := srv.RegisterUser(ctx, users.User{Email: "ann@example.com", FirstName: "Ann", LastName: "Lee"}, "correct horse")
+			assert.True(t, errors.Is(err, service.ErrDuplicate))
+		})
+	})
+
+	t.Run("GetUser", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+			mockUser = users.User{ID: "usr01", Email: "ann@example.com"}
+
+			u, err := srv.GetUser(annCtx, "usr01")
+			require.NoError(t, err)
+			assert.Equal(t, mockUser, u)
+			_, err = srv.GetUser(adminCtx, "usr01")
+			assert.NoError(t, err, "admins read anyone")
+		})
+
+		t.Run("another user", func(t *testing.T) {
+			reset()
+			mockUser = users.User{ID: "usr01", Email: "ann@example.com"}
+
+			_, err := srv.GetUser(bobCtx, "usr01")
+			assert.True(t, errors.Is(err, service.ErrForbidden))
+			_, err = srv.GetUser(ctx, "usr01")
+			assert.True(t, errors.Is(err, service.ErrUnauthenticated))
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			reset()
+			mockUserErr = datastore.ErrNotFound
+
+			_, err := srv.GetUser(adminCtx, "usr01")
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+	})
+
+	t.Run("UpdateUser", func(t *testing.T) {
+		t.Run("happy", func(t *testing.T) {
+			reset()
+
+			_, err := srv.UpdateUser(annCtx, users.User{ID: "usr01", Email: "Ann@Example.org", FirstName: "Ann", LastName: "Lee"})
+			require.NoError(t, err)
+			assert.Equal(t, "ann@example.org", mockUser.Email)
+		})
+
+		t.Run("email taken", func(t *testing.T) {
+			reset()
+			mockUserErr = datastore.ErrDuplicate
+
+			_, err := srv.UpdateUser(annCtx, users.User{ID: "usr01", Email: "bob@example.com", FirstName: "Ann", LastName: "Lee"})
+			assert.True(t, errors.Is(err, service.ErrDuplicate))
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			reset()
+			mockUserErr = datastore.ErrNotFound
+
+			_, err := srv.UpdateUser(adminCtx, users.User{ID: "usr01", Email: "bob@example.com", FirstName: "Ann", LastName: "Lee"})
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+
+		t.Run("another user", func(t *testing.T) {
+			reset()
+
+			_, err := srv.UpdateUser(bobCtx, users.User{ID: "usr01", Email: "bob@example.com", FirstName: "Ann", LastName: "Lee"})
+			assert.True(t, errors.Is(err, service.ErrForbidden))
+			assert.Empty(t, mockUser.Email)
+		})
+	})
+
+	t.Run("ChangePassword", func(t *testing.T) {
+		hash, err := users.HashPassword("correct horse")
+		require.NoError(t, err)
+
+		t.Run("happy", func(t *testing.T) {
+			reset()
+			mockUser = users.User{ID: "usr01", PasswordHash: hash}
+
+			err := srv.ChangePassword(annCtx, "usr01", "correct horse", "battery staple")
+			require.NoError(t, err)
+			assert.True(t, users.User{PasswordHash: mockUserHash}.CheckPassword("battery staple"))
+		})
+
+		t.Run("wrong password", func(t *testing.T) {
+			reset()
+			mockUser = users.User{ID: "usr01", PasswordHash: hash}
+
+			err := srv.ChangePassword(annCtx, "usr01", "wrong horse", "battery staple")
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+			assert.True(t, errors.Is(err, users.ErrPasswordMismatch))
+			assert.Empty(t, mockUserHash)
+		})
+
+		t.Run("weak password", func(t *testing.T) {
+			reset()
+			mockUser = users.User{ID: "usr01", PasswordHash: hash}
+
+			err := srv.ChangePassword(annCtx, "usr01", "correct horse", "short")
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+		})
+
+		t.Run("another user", func(t *testing.T) {
+			reset()
+			mockUser = users.User{ID: "usr01", PasswordHash: hash}
+
+			err := srv.ChangePassword(bobCtx, "usr01", "correct horse", "battery staple")
+			assert.True(t, errors.Is(err, service.ErrForbidden))
+			assert.Empty(t, mockUserHash)
+		})
+
+		t.Run("admin reset", func(t *testing.T) {
+			reset()
+			mockUser = users.User{ID: "usr01", PasswordHash: hash}
+
+			err := srv.ChangePassword(adminCtx, "usr01", "", "battery staple")
+			require.NoError(t, err, "admins reset other users' passwords without the current one")
+			assert.True(t, users.User{PasswordHash: mockUserHash}.CheckPassword("battery staple"))
+		})
+
+		t.Run("admin's own password", func(t *testing.T) {
+			reset()
+			mockUser = users.User{ID: "usr02", PasswordHash: hash}
+
+			err := srv.ChangePassword(adminCtx, "usr02", "", "battery staple")
+			assert.True(t, errors.Is(err, users.ErrPasswordMismatch), "admins give their own current password")
+			assert.Empty(t, mockUserHash)
+			require.NoError(t, srv.ChangePassword(adminCtx, "usr02", "correct horse", "battery staple"))
+			assert.NotEmpty(t, mockUserHash)
+		})
+	})
+
+	t.Run("SetUserEnabled", func(t *testing.T) {
+		reset()
+		require.NoError(t, srv.SetUserEnabled(adminCtx, "usr01", true))
+		assert.Empty(t, mockSessionsRevoked)
+		require.NoError(t, srv.SetUserEnabled(adminCtx, "usr01", false))
+		require.NotNil(t, mockUserEnabled)
+		assert.False(t, *mockUserEnabled)
+		assert.Equal(t, []string{"usr01"}, mockSessionsRevoked, "disabling a user signs them out")
+
+		mockUserErr = datastore.ErrNotFound
+		err := srv.SetUserEnabled(adminCtx, "usr01", true)
+		assert.True(t, errors.Is(err, service.ErrNotFound))
+
+		reset()
+		err = srv.SetUserEnabled(annCtx, "usr01", false)
+		assert.True(t, errors.Is(err, service.ErrForbidden), "only admins enable or disable users, even themselves")
+		err = srv.SetUserEnabled(bobCtx, "usr01", false)
+		assert.True(t, errors.Is(err, service.ErrForbidden))
+		assert.Nil(t, mockUserEnabled)
+	})
+	t.Run("SetUserRole", func(t *testing.T) {
+		admin := users.User{ID: "usr02", Role: users.RoleAdmin, Permissions: users.RolePermissions[users.RoleAdmin]}
+		staff := users.User{ID: "usr03", Role: users.RoleStaff, Permissions: users.RolePermissions[users.RoleStaff]}
+
+		t.Run("happy", func(t *testing.T) {
+			reset()
+			require.NoError(t, srv.SetUserRole(auth.WithUser(ctx, admin), "usr01", users.RoleStaff))
+			assert.Equal(t, users.RoleStaff, mockUser.Role)
+		})
+
+		t.Run("not an admin", func(t *testing.T) {
+			reset()
+			err := srv.SetUserRole(auth.WithUser(ctx, staff), "usr01", users.RoleAdmin)
+			assert.True(t, errors.Is(err, service.ErrForbidden))
+			err = srv.SetUserRole(ctx, "usr01", users.RoleAdmin)
+			assert.True(t, errors.Is(err, service.ErrUnauthenticated))
+			assert.Empty(t, mockUser.Role)
+		})
+
+		t.Run("unknown role", func(t *testing.T) {
+			reset()
+			err := srv.SetUserRole(auth.WithUser(ctx, admin), "usr01", "owner")
+			assert.True(t, errors.Is(err, service.ErrInvalid))
+		})
+
+		t.Run("not found", func(t *testing.T) {
+			reset()
+			mockUserErr = datastore.ErrNotFound
+			err := srv.SetUserRole(auth.WithUser(ctx, admin), "usr01", users.RoleStaff)
+			assert.True(t, errors.Is(err, service.ErrNotFound))
+		})
+	})
+}
@@ -0,0 +1,84 @@
+// +build int
+
+package stock_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"bookshop/books"
+	"bookshop/datastore"
+	"bookshop/datastore/dbtest"
+	"bookshop/stock"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStock(t *testing.T) {
+	ctx := context.Background()
+	dbh := initializeTestDB(t)
+
+	store := stock.NewStockStore(dbh)
+
+	t.Run("ApplyMovement", func(t *testing.T) {
+		lvl, err := store.ApplyMovement(ctx, stock.Movement{BookID: "abc01", Kind: stock.Receive, Quantity: 5, Reason: "delivery"})
+		require.NoError(t, err)
+		assert.Equal(t, 5, lvl.OnHand)
+
+		_, err = store.ApplyMovement(ctx, stock.Movement{BookID: "abc01", Kind: stock.Adjust, Quantity: -6, Reason: "lost"})
+		assert.True(t, errors.Is(err, datastore.ErrCheckViolation))
+
+		_, err = store.ApplyMovement(ctx, stock.Movement{BookID: "unknown", Kind: stock.Receive, Quantity: 1, Reason: "delivery"})
+		assert.True(t, errors.Is(err, datastore.ErrMissingReference))
+
+		lvl, err = store.ReadStock(ctx, "abc01")
+		require.NoError(t, err)
+		assert.Equal(t, 5, lvl.Available())
+		assert.Len(t, lvl.Ledger, 1)
+	})
+
+	t.Run("concurrent reservations", func(t *testing.T) {
+		var wg sync.WaitGroup
+		errs := make(chan error, 10)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := store.ApplyMovement(ctx, stock.Movement{BookID: "abc01", Kind: stock.Reserve, Quantity: 1, Reason: "order"})
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		var reserved int
+		for err := range errs {
+			if err == nil {
+				reserved++
+				continue
+			}
+			assert.True(t, errors.Is(err, datastore.ErrCheckViolation))
+		}
+		assert.Equal(t, 5, reserved)
+
+		lvl, err := store.ReadStock(ctx, "abc01")
+		require.NoError(t, err)
+		assert.Equal(t, 5, lvl.Reserved)
+	})
+}
+
+func initializeTestDB(t *testing.T) *sqlx.DB {
+	dbh := dbtest.Postgres(t, "stock_testing")
+
+	bs := books.NewBookStore(dbh)
+	err := bs.UpsertBooks(context.Background(), []books.Book{
+		{ID: "abc01", Title: "titleA", ISBN: "9783161484100"},
+	})
+	require.NoError(t, err)
+
+	return dbh
+}
@@ -0,0 +1,118 @@
+package stock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LedgerLimit is how many of the latest ledger entries are read with a stock level.
+const LedgerLimit = 20
+
+// Kind is the kind of stock movement recorded in the ledger.
+type Kind string
+
+const (
+	// Receive adds copies delivered to the shop.
+	Receive Kind = "receive"
+	// Adjust corrects the copies on hand, e.g. after a count or for damaged copies.
+	Adjust Kind = "adjust"
+	// Reserve sets copies on hand aside so they are no longer available.
+	Reserve Kind = "reserve"
+	// Release returns reserved copies to those available.
+	Release Kind = "release"
+)
+
+// Level is the model representing a book's row in the stock table.
+type Level struct {
+	BookID    string     `db:"book_id" json:"book_id"`
+	OnHand    int        `db:"on_hand" json:"on_hand"`
+	Reserved  int        `db:"reserved" json:"reserved"`
+	UpdatedAt *time.Time `db:"updated_at" json:"updated_at,omitempty"`
+
+	Ledger []Entry `json:"ledger,omitempty"`
+}
+
+// Available returns the copies on hand that are not reserved.
+func (l Level) Available() int {
+	return l.OnHand - l.Reserved
+}
+
+// MarshalJSON adds the available copies to the level.
+func (l Level) MarshalJSON() ([]byte, error) {
+	type level Level
+	return json.Marshal(struct {
+		level
+		Available int `json:"available"`
+	}{level(l), l.Available()})
+}
+
+// Entry is the model representing a row in the stock ledger.
+type Entry struct {
+	ID            int64      `db:"id" json:"id"`
+	BookID        string     `db:"book_id" json:"book_id"`
+	Kind          Kind       `db:"kind" json:"kind"`
+	OnHandDelta   int        `db:"on_hand_delta" json:"on_hand_delta"`
+	ReservedDelta int        `db:"reserved_delta" json:"reserved_delta"`
+	Reason        string     `db:"reason" json:"reason"`
+	CreatedAt     *time.Time `db:"created_at" json:"created_at,omitempty"`
+}
+
+// Movement is a change to a book's stock.
+type Movement struct {
+	BookID string
+	Kind   Kind
+	// Quantity is the number of copies received, reserved or released, or the
+	// signed change in copies on hand for an adjustment.
+	Quantity int
+	Reason   string
+}
+
+// Deltas returns the change the movement makes to the copies on hand and the
+// copies reserved.
+func (m Movement) Deltas() (onHand, reserved int) {
+	switch m.Kind {
+	case Receive, Adjust:
+		return m.Quantity, 0
+	case Reserve:
+		return 0, m.Quantity
+	case Release:
+		return 0, -m.Quantity
+	}
+	return 0, 0
+}
+
+// Validate checks the movement has a known kind, a quantity that makes sense
+// for it and a reason.
+func (m Movement) Validate() error {
+	switch m.Kind {
+	case Receive, Reserve, Release:
+		if m.Quantity < 1 {
+			return errors.New("quantity must be a positive number")
+		}
+	case Adjust:
+		if m.Quantity == 0 {
+			return errors.New("quantity cannot be zero")
+		}
+	default:
+		return fmt.Errorf("unknown stock movement %q", m.Kind)
+	}
+	if strings.TrimSpace(m.Reason) == "" {
+		return errors.New("reason cannot be blank")
+	}
+	return nil
+}
+
+// Entry returns the ledger entry recording the movement.
+func (m Movement) Entry() Entry {
+	onHand, reserved := m.Deltas()
+	return Entry{
+		BookID:        m.BookID,
+		Kind:          m.Kind,
+		OnHandDelta:   onHand,
+		ReservedDelta: reserved,
+		Reason:        strings.TrimSpace(m.Reason),
+	}
+}
@@ -0,0 +1,159 @@
+package stock_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"bookshop/books"
+	"bookshop/datastore"
+	"bookshop/datastore/dbtest"
+	"bookshop/stock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStockSQLite(t *testing.T) {
+	ctx := context.Background()
+	newStores := func(t *testing.T) (stock.StockStore, books.BookStore) {
+		dbh := dbtest.SQLite(t)
+
+		bs := books.NewBookStore(dbh)
+		err := bs.UpsertBooks(ctx, []books.Book{
+			{ID: "abc01", Title: "titleA", ISBN: "9783161484100"},
+		})
+		require.NoError(t, err)
+		return stock.NewStockStore(dbh), bs
+	}
+
+	t.Run("ReadStock", func(t *testing.T) {
+		store, _ := newStores(t)
+
+		lvl, err := store.ReadStock(ctx, "abc01")
+		require.NoError(t, err)
+		assert.Equal(t, "abc01", lvl.BookID)
+		assert.Zero(t, lvl.OnHand)
+		assert.Empty(t, lvl.Ledger)
+
+		_, err = store.ReadStock(ctx, "nope")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("ApplyMovement", func(t *testing.T) {
+		store, _ := newStores(t)
+
+		lvl, err := store.ApplyMovement(ctx, stock.Movement{BookID: "abc01", Kind: stock.Receive, Quantity: 5, Reason: "delivery"})
+		require.NoError(t, err)
+		assert.Equal(t, 5, lvl.OnHand)
+
+		lvl, err = store.ApplyMovement(ctx, stock.Movement{BookID: "abc01", Kind: stock.Reserve, Quantity: 3, Reason: "order"})
+		require.NoError(t, err)
+		assert.Equal(t, 5, lvl.OnHand)
+		assert.Equal(t, 3, lvl.Reserved)
+		assert.Equal(t, 2, lvl.Available())
+
+		lvl, err = store.ApplyMovement(ctx, stock.Movement{BookID: "abc01", Kind: stock.Release, Quantity: 1, Reason: "cancelled"})
+		require.NoError(t, err)
+		assert.Equal(t, 2, lvl.Reserved)
+
+		lvl, err = store.ApplyMovement(ctx, stock.Movement{BookID: "abc01", Kind: stock.Adjust, Quantity: -1, Reason: "damaged"})
+		require.NoError(t, err)
+		assert.Equal(t, 4, lvl.OnHand)
+
+		lvl, err = store.ReadStock(ctx, "abc01")
+		require.NoError(t, err)
+		require.Len(t, lvl.Ledger, 4)
+		assert.Equal(t, stock.Adjust, lvl.Ledger[0].Kind)
+		assert.Equal(t, -1, lvl.Ledger[0].OnHandDelta)
+		assert.Equal(t, "damaged", lvl.Ledger[0].Reason)
+		assert.Equal(t, stock.Receive, lvl.Ledger[3].Kind)
+	})
+
+	t.Run("ApplyMovement overdrawn", func(t *testing.T) {
+		store, _ := newStores(t)
+		_, err := store.ApplyMovement(ctx, stock.Movement{BookID: "abc01", Kind: stock.Receive, Quantity: 2, Reason: "delivery"})
+		require.NoError(t, err)
+
+		for _, m := range []stock.Movement{
+			{BookID: "abc01", Kind: stock.Reserve, Quantity: 3, Reason: "order"},
+			{BookID: "abc01", Kind: stock.Release, Quantity: 1, Reason: "cancelled"},
+			{BookID: "abc01", Kind: stock.Adjust, Quantity: -3, Reason: "lost"},
+		} {
+			_, err = store.ApplyMovement(ctx, m)
+			assert.True(t, errors.Is(err, datastore.ErrCheckViolation), m.Kind)
+		}
+
+		lvl, err := store.ReadStock(ctx, "abc01")
+		require.NoError(t, err)
+		assert.Equal(t, 2, lvl.OnHand)
+		assert.Zero(t, lvl.Reserved)
+		assert.Len(t, lvl.Ledger, 1)
+	})
+
+	t.Run("ReadBooks in stock", func(t *testing.T) {
+		store, bs := newStores(t)
+
+		page, err := bs.ReadBooks(ctx, books.BookQuery{InStock: true})
+		require.NoError(t, err)
+		assert.Empty(t, page.Books)
+
+		_, err = store.ApplyMovement(ctx, stock.Movement{BookID: "abc01", Kind: stock.Receive, Quantity: 1, Reason: "delivery"})
+		require.NoError(t, err)
+		page, err = bs.ReadBooks(ctx, books.BookQuery{InStock: true})
+		require.NoError(t, err)
+		assert.Len(t, page.Books, 1)
+
+		// reserved copies are not available to sell
+		_, err = store.ApplyMovement(ctx, stock.Movement{BookID: "abc01", Kind: stock.Reserve, Quantity: 1, Reason: "order"})
+		require.NoError(t, err)
+		page, err = bs.ReadBooks(ctx, books.BookQuery{InStock: true})
+		require.NoError(t, err)
+		assert.Empty(t, page.Books)
+	})
+
+	t.Run("ApplyMovement unknown book", func(t *testing.T) {
+		store, _ := newStores(t)
+		_, err := store.ApplyMovement(ctx, stock.Movement{BookID: "nope", Kind: stock.Receive, Quantity: 1, Reason: "delivery"})
+		assert.True(t, errors.Is(err, datastore.ErrMissingReference))
+	})
+
+	t.Run("concurrent reservations", func(t *testing.T) {
+		store, _ := newStores(t)
+		_, err := store.ApplyMovement(ctx, stock.Movement{BookID: "abc01", Kind: stock.Receive, Quantity: 5, Reason: "delivery"})
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 10)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := store.ApplyMovement(ctx, stock.Movement{BookID: "abc01", Kind: stock.Reserve, Quantity: 1, Reason: "order"})
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		var reserved, refused int
+		for err := range errs {
+			switch {
+			case err == nil:
+				reserved++
+			case errors.Is(err, datastore.ErrCheckViolation):
+				refused++
+			default:
+				t.Fatal(err)
+			}
+		}
+		assert.Equal(t, 5, reserved)
+		assert.Equal(t, 5, refused)
+
+		lvl, err := store.ReadStock(ctx, "abc01")
+		require.NoError(t, err)
+		assert.Equal(t, 5, lvl.Reserved)
+		assert.Zero(t, lvl.Available())
+	})
+}
@@ -0,0 +1,103 @@
+package stock
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"bookshop/datastore"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type StockStore struct {
+	db *sqlx.DB
+}
+
+func NewStockStore(db *sqlx.DB) StockStore {
+	return StockStore{db: db}
+}
+
+// ReadStock will return the stock level of the given book along with its latest
+// ledger entries, returning datastore.ErrNotFound if the book does not exist.
+// Books that have never been stocked have a level of zero.
+func (s *StockStore) ReadStock(ctx context.Context, bookID string) (Level, error) {
+	sqlSt :=
+		`SELECT b.id AS book_id,
+				COALESCE(s.on_hand, 0) AS on_hand,
+				COALESCE(s.reserved, 0) AS reserved,
+				s.updated_at
+		FROM books b
+		LEFT JOIN stock s ON s.book_id = b.id
+		WHERE b.id = ?`
+	lvl := Level{}
+	if err := datastore.Conn(ctx, s.db).GetContext(ctx, &lvl, s.db.Rebind(sqlSt), bookID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Level{}, datastore.ErrNotFound
+		}
+		return Level{}, errors.Wrap(err, "failed to read stock")
+	}
+
+	lvl.Ledger = []Entry{}
+	sqlSt = `SELECT * FROM stock_ledger WHERE book_id = ? ORDER BY id DESC LIMIT ?`
+	if err := datastore.Conn(ctx, s.db).SelectContext(ctx, &lvl.Ledger, s.db.Rebind(sqlSt), bookID, LedgerLimit); err != nil {
+		return Level{}, errors.Wrap(err, "failed to read stock ledger")
+	}
+	return lvl, nil
+}
+
+// ApplyMovement will apply the movement to the book's stock and record it in the
+// ledger, returning the new level without its ledger. It returns
+// datastore.ErrCheckViolation if the movement would leave fewer copies on hand
+// than are reserved, and datastore.ErrMissingReference if the book does not exist.
+func (s *StockStore) ApplyMovement(ctx context.Context, m Movement) (Level, error) {
+	tx, err := datastore.Begin(ctx, s.db)
+	if err != nil {
+		return Level{}, errors.Wrap(err, "failed moving stock")
+	}
+
+	// books that have never been stocked get a level of zero to move from
+	const initSt = `INSERT INTO stock (book_id, on_hand, reserved, updated_at) VALUES (?, 0, 0, ?)
+	ON CONFLICT(book_id) DO NOTHING`
+	if _, err = tx.ExecContext(ctx, s.db.Rebind(initSt), m.BookID, time.Now()); err != nil {
+		tx.Rollback()
+		if datastore.IsForeignKeyViolation(err) {
+			return Level{}, datastore.ErrMissingReference
+		}
+		return Level{}, errors.Wrap(err, "failed moving stock")
+	}
+
+	// the update happens in a single statement so concurrent movements of the
+	// same book see each other's changes, and the table's CHECK constraint
+	// rejects any that would overdraw it
+	const sqlSt = `UPDATE stock
+	SET on_hand = on_hand + ?,
+		reserved = reserved + ?,
+		updated_at = ?
+	WHERE book_id = ?
+	RETURNING book_id, on_hand, reserved, updated_at`
+	entry := m.Entry()
+	var lvl Level
+	err = tx.GetContext(ctx, &lvl, s.db.Rebind(sqlSt), entry.OnHandDelta, entry.ReservedDelta, time.Now(), entry.BookID)
+	if err != nil {
+		tx.Rollback()
+		if datastore.IsCheckViolation(err) {
+			return Level{}, datastore.ErrCheckViolation
+		}
+		return Level{}, errors.Wrap(err, "failed moving stock")
+	}
+
+	const ledgerSt = `INSERT INTO stock_ledger (book_id, kind, on_hand_delta, reserved_delta, reason, created_at)
+	VALUES (?, ?, ?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, s.db.Rebind(ledgerSt),
+		entry.BookID, entry.Kind, entry.OnHandDelta, entry.ReservedDelta, entry.Reason, time.Now())
+	if err != nil {
+		tx.Rollback()
+		return Level{}, errors.Wrap(err, "failed recording stock movement")
+	}
+	if err := tx.Commit(); err != nil {
+		return Level{}, err
+	}
+	return lvl, nil
+}
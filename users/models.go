@@ -0,0 +1,120 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength and MaxPasswordLength bound the length of a password in
+// bytes. bcrypt only looks at the first 72 bytes, so longer passwords are
+// refused rather than quietly cut short.
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+)
+
+// ErrPasswordMismatch is returned when a password does not match a user's.
+var ErrPasswordMismatch = errors.New("password does not match")
+
+// Role decides what a user may do beyond shopping.
+type Role string
+
+// The roles a user can have. New users are customers.
+const (
+	RoleCustomer Role = "customer"
+	RoleStaff    Role = "staff"
+	RoleAdmin    Role = "admin"
+)
+
+// Permission names something a role allows its users to do.
+type Permission string
+
+// The permissions roles can be given.
+const (
+	PermEditBooks       Permission = "books.edit"
+	PermRemoveAuthors   Permission = "authors.remove"
+	PermManageUsers     Permission = "users.manage"
+	PermModerateReviews Permission = "reviews.moderate"
+	PermManageStock     Permission = "stock.manage"
+	PermManageOrders    Permission = "orders.manage"
+)
+
+// RolePermissions are the permissions each role is given, as seeded into the
+// role_permissions table. Customers have none.
+var RolePermissions = map[Role][]Permission{
+	RoleStaff: {PermEditBooks, PermModerateReviews, PermManageStock, PermManageOrders},
+	RoleAdmin: {PermEditBooks, PermRemoveAuthors, PermManageUsers, PermModerateReviews, PermManageStock, PermManageOrders},
+}
+
+// ParseRole returns the role with the given name in any case.
+func ParseRole(s string) (Role, error) {
+	switch r := Role(strings.ToLower(strings.TrimSpace(s))); r {
+	case RoleCustomer, RoleStaff, RoleAdmin:
+		return r, nil
+	}
+	return "", fmt.Errorf("unknown role %q", s)
+}
+
+// User is the model representing a row in the users table. The password column
+// only ever holds a bcrypt hash of the user's password.
+type User struct {
+	ID           string     `db:"id" json:"id"`
+	Email        string     `db:"email" json:"email"`
+	FirstName    string     `db:"first_name" json:"first_name"`
+	LastName     string     `db:"last_name" json:"last_name"`
+	PasswordHash string     `db:"password" json:"-"`
+	Enabled      bool       `db:"enabled" json:"enabled"`
+	Role         Role       `db:"role" json:"role"`
+	CreatedAt    *time.Time `db:"created_at" json:"created_at,omitempty"`
+	UpdatedAt    *time.Time `db:"updated_at" json:"updated_at,omitempty"`
+
+	// Permissions are those of the user's role, filled in when they sign in.
+	Permissions []Permission `db:"-" json:"permissions,omitempty"`
+}
+
+// NormalizeEmail returns the email address in the form it is stored and looked
+// up in, so that addresses differing only in case belong to one user.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ValidateEmail checks the email is a single bare address.
+func ValidateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return fmt.Errorf("%q is not an email address", email)
+	}
+	return nil
+}
+
+// HashPassword checks the password's length and returns its bcrypt hash.
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return "", fmt.Errorf("must be between %d and %d bytes long", MinPasswordLength, MaxPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether the password matches the user's hash.
+func (u User) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+}
+
+// Can reports whether the user has the permission.
+func (u User) Can(perm Permission) bool {
+	for _, p := range u.Permissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
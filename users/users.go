@@ -0,0 +1,123 @@
+package users
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"bookshop/datastore"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type UserStore struct {
+	db *sqlx.DB
+}
+
+func NewUserStore(db *sqlx.DB) UserStore {
+	return UserStore{db: db}
+}
+
+// CreateUser will add the given user, returning datastore.ErrDuplicate if the
+// email address is already in use.
+func (s *UserStore) CreateUser(ctx context.Context, u User) error {
+	const sqlSt = `INSERT INTO users (id, email, first_name, last_name, password, enabled, role, created_at, updated_at)
+		VALUES (?,?,?,?,?,?,?,?,?)`
+	if u.Role == "" {
+		u.Role = RoleCustomer
+	}
+	now := time.Now()
+	_, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind(sqlSt),
+		u.ID, u.Email, u.FirstName, u.LastName, u.PasswordHash, u.Enabled, u.Role, now, now)
+	if err != nil {
+		if datastore.IsUniqueViolation(err) {
+			return datastore.ErrDuplicate
+		}
+		return errors.Wrap(err, "failed creating user")
+	}
+	return nil
+}
+
+// ReadUserByID will return the user with the given ID, returning
+// datastore.ErrNotFound if it does not exist.
+func (s *UserStore) ReadUserByID(ctx context.Context, id string) (User, error) {
+	return s.readUser(ctx, "SELECT * FROM users WHERE id=?", id)
+}
+
+// ReadUserByEmail will return the user with the given email address, returning
+// datastore.ErrNotFound if it does not exist.
+func (s *UserStore) ReadUserByEmail(ctx context.Context, email string) (User, error) {
+	return s.readUser(ctx, "SELECT * FROM users WHERE email=?", email)
+}
+
+func (s *UserStore) readUser(ctx context.Context, qry string, arg string) (User, error) {
+	u := User{}
+	if err := datastore.Conn(ctx, s.db).GetContext(ctx, &u, s.db.Rebind(qry), arg); err != nil {
+		if err == sql.ErrNoRows {
+			return User{}, datastore.ErrNotFound
+		}
+		return User{}, errors.Wrap(err, "failed to read user")
+	}
+	return u, nil
+}
+
+// UpdateUser will change the email address and names of the user, returning
+// datastore.ErrNotFound if it does not exist and datastore.ErrDuplicate if the
+// email address belongs to another user.
+func (s *UserStore) UpdateUser(ctx context.Context, u User) error {
+	const sqlSt = `UPDATE users SET email = ?, first_name = ?, last_name = ?, updated_at = ? WHERE id = ?`
+	res, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind(sqlSt), u.Email, u.FirstName, u.LastName, time.Now(), u.ID)
+	if err != nil {
+		if datastore.IsUniqueViolation(err) {
+			return datastore.ErrDuplicate
+		}
+		return errors.Wrap(err, "failed updating user")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return datastore.ErrNotFound
+	}
+	return nil
+}
+
+// SetUserPassword will replace the password hash of the user, returning
+// datastore.ErrNotFound if it does not exist.
+func (s *UserStore) SetUserPassword(ctx context.Context, id, hash string) error {
+	return s.set(ctx, "password", hash, id)
+}
+
+// SetUserEnabled will enable or disable the user, returning
+// datastore.ErrNotFound if it does not exist.
+func (s *UserStore) SetUserEnabled(ctx context.Context, id string, enabled bool) error {
+	return s.set(ctx, "enabled", enabled, id)
+}
+
+// SetUserRole will change the role of the user, returning
+// datastore.ErrNotFound if it does not exist.
+func (s *UserStore) SetUserRole(ctx context.Context, id string, role Role) error {
+	return s.set(ctx, "role", role, id)
+}
+
+// ReadRolePermissions will return the permissions given to the role, sorted by
+// name. A role without any has an empty list.
+func (s *UserStore) ReadRolePermissions(ctx context.Context, role Role) ([]Permission, error) {
+	const sqlSt = `SELECT permission FROM role_permissions WHERE role = ? ORDER BY permission`
+	perms := []Permission{}
+	if err := datastore.Conn(ctx, s.db).SelectContext(ctx, &perms, s.db.Rebind(sqlSt), role); err != nil {
+		return nil, errors.Wrap(err, "failed to read role permissions")
+	}
+	return perms, nil
+}
+
+// set changes a single column of the user's row along with updated_at.
+func (s *UserStore) set(ctx context.Context, col string, value interface{}, id string) error {
+	sqlSt := `UPDATE users SET ` + col + ` = ?, updated_at = ? WHERE id = ?`
+	res, err := datastore.Conn(ctx, s.db).ExecContext(ctx, s.db.Rebind(sqlSt), value, time.Now(), id)
+	if err != nil {
+		return errors.Wrap(err, "failed updating user")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return datastore.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package users_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bookshop/datastore"
+	"bookshop/datastore/dbtest"
+	"bookshop/users"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsers(t *testing.T) {
+	ctx := context.Background()
+	newStore := func(t *testing.T) users.UserStore {
+		dbh := dbtest.Open(t)
+
+		store := users.NewUserStore(dbh)
+		err := store.CreateUser(ctx, users.User{ID: "usr01", Email: "ann@example.com", FirstName: "Ann", LastName: "Lee", PasswordHash: "hash1", Enabled: true})
+		require.NoError(t, err)
+		return store
+	}
+
+	t.Run("CreateUser", func(t *testing.T) {
+		store := newStore(t)
+
+		u, err := store.ReadUserByID(ctx, "usr01")
+		require.NoError(t, err)
+		assert.Equal(t, "ann@example.com", u.Email)
+		assert.Equal(t, "hash1", u.PasswordHash)
+		assert.True(t, u.Enabled)
+		assert.NotNil(t, u.CreatedAt)
+
+		u, err = store.ReadUserByEmail(ctx, "ann@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, "usr01", u.ID)
+
+		err = store.CreateUser(ctx, users.User{ID: "usr02", Email: "ann@example.com", FirstName: "Ann", LastName: "Other", PasswordHash: "hash2"})
+		assert.True(t, errors.Is(err, datastore.ErrDuplicate))
+
+		_, err = store.ReadUserByEmail(ctx, "bob@example.com")
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("UpdateUser", func(t *testing.T) {
+		store := newStore(t)
+		err := store.CreateUser(ctx, users.User{ID: "usr02", Email: "bob@example.com", FirstName: "Bob", LastName: "Ray", PasswordHash: "hash2"})
+		require.NoError(t, err)
+
+		err = store.UpdateUser(ctx, users.User{ID: "usr01", Email: "ann@example.org", FirstName: "Annie", LastName: "Lee", PasswordHash: "ignored"})
+		require.NoError(t, err)
+		u, err := store.ReadUserByID(ctx, "usr01")
+		require.NoError(t, err)
+		assert.Equal(t, "ann@example.org", u.Email)
+		assert.Equal(t, "Annie", u.FirstName)
+		assert.Equal(t, "hash1", u.PasswordHash)
+
+		err = store.UpdateUser(ctx, users.User{ID: "usr01", Email: "bob@example.com", FirstName: "Ann", LastName: "Lee"})
+		assert.True(t, errors.Is(err, datastore.ErrDuplicate))
+		err = store.UpdateUser(ctx, users.User{ID: "unknown", Email: "new@example.com"})
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("password and enabled", func(t *testing.T) {
+		store := newStore(t)
+
+		require.NoError(t, store.SetUserPassword(ctx, "usr01", "hash2"))
+		require.NoError(t, store.SetUserEnabled(ctx, "usr01", false))
+		u, err := store.ReadUserByID(ctx, "usr01")
+		require.NoError(t, err)
+		assert.Equal(t, "hash2", u.PasswordHash)
+		assert.False(t, u.Enabled)
+
+		err = store.SetUserEnabled(ctx, "unknown", true)
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+	t.Run("roles", func(t *testing.T) {
+		store := newStore(t)
+
+		u, err := store.ReadUserByID(ctx, "usr01")
+		require.NoError(t, err)
+		assert.Equal(t, users.RoleCustomer, u.Role, "users start out as customers")
+
+		require.NoError(t, store.SetUserRole(ctx, "usr01", users.RoleStaff))
+		u, err = store.ReadUserByID(ctx, "usr01")
+		require.NoError(t, err)
+		assert.Equal(t, users.RoleStaff, u.Role)
+
+		err = store.SetUserRole(ctx, "usr01", "owner")
+		assert.Error(t, err, "the schema only allows known roles")
+		err = store.SetUserRole(ctx, "unknown", users.RoleAdmin)
+		assert.True(t, errors.Is(err, datastore.ErrNotFound))
+	})
+
+	t.Run("ReadRolePermissions", func(t *testing.T) {
+		store := newStore(t)
+
+		// the seeded table and the in-memory store's copy must agree
+		for _, role := range []users.Role{users.RoleCustomer, users.RoleStaff, users.RoleAdmin} {
+			perms, err := store.ReadRolePermissions(ctx, role)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, users.RolePermissions[role], perms, role)
+		}
+	})
+}